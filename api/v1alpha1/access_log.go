@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Fields of the access log that can be set in .spec.logging.access.fields, besides the headers
+const (
+	AccessLogFieldClientIP   = "client_ip"
+	AccessLogFieldMethod     = "method"
+	AccessLogFieldHost       = "host"
+	AccessLogFieldURL        = "url"
+	AccessLogFieldQuery      = "query"
+	AccessLogFieldProtocol   = "protocol"
+	AccessLogFieldStatus     = "status"
+	AccessLogFieldBytes      = "bytes"
+	AccessLogFieldDurationUs = "duration_us"
+	AccessLogFieldTTFB       = "ttfb_seconds"
+	AccessLogFieldHandling   = "handling"
+	AccessLogFieldVXID       = "vxid"
+	AccessLogFieldUserAgent  = "user_agent"
+	AccessLogFieldReferer    = "referer"
+
+	// AccessLogRequestHeaderPrefix and AccessLogResponseHeaderPrefix are the prefixes of the header fields, e.g. req.http.X-Forwarded-For
+	AccessLogRequestHeaderPrefix  = "req.http."
+	AccessLogResponseHeaderPrefix = "resp.http."
+)
+
+// DefaultAccessLogFields are logged if .spec.logging.access.fields is not set
+var DefaultAccessLogFields = []string{
+	AccessLogFieldClientIP, AccessLogFieldMethod, AccessLogFieldHost, AccessLogFieldURL, AccessLogFieldProtocol,
+	AccessLogFieldStatus, AccessLogFieldBytes, AccessLogFieldDurationUs, AccessLogFieldHandling,
+}
+
+var accessLogFields = map[string]bool{
+	AccessLogFieldClientIP: true, AccessLogFieldMethod: true, AccessLogFieldHost: true, AccessLogFieldURL: true,
+	AccessLogFieldQuery: true, AccessLogFieldProtocol: true, AccessLogFieldStatus: true, AccessLogFieldBytes: true,
+	AccessLogFieldDurationUs: true, AccessLogFieldTTFB: true, AccessLogFieldHandling: true, AccessLogFieldVXID: true,
+	AccessLogFieldUserAgent: true, AccessLogFieldReferer: true,
+}
+
+// validAccessLogging checks the fields, the query and the redacted headers of the access log
+func validAccessLogging(access *VarnishClusterAccessLogging) error {
+	seen := make(map[string]bool, len(access.Fields))
+	for _, field := range access.Fields {
+		if seen[field] {
+			return errors.Errorf("field %q is set more than once", field)
+		}
+		seen[field] = true
+
+		header := ""
+		switch {
+		case strings.HasPrefix(field, AccessLogRequestHeaderPrefix):
+			header = strings.TrimPrefix(field, AccessLogRequestHeaderPrefix)
+		case strings.HasPrefix(field, AccessLogResponseHeaderPrefix):
+			header = strings.TrimPrefix(field, AccessLogResponseHeaderPrefix)
+		case accessLogFields[field]:
+			continue
+		default:
+			return errors.Errorf("unknown field %q", field)
+		}
+		if !headerNameRegexp.MatchString(header) {
+			return errors.Errorf("field %q has an invalid header name", field)
+		}
+	}
+
+	if strings.ContainsAny(access.Query, "\n\r") {
+		return errors.New("query can't contain new lines")
+	}
+
+	for _, header := range access.RedactHeaders {
+		if !headerNameRegexp.MatchString(header) {
+			return errors.Errorf("invalid header name %q in redactHeaders", header)
+		}
+	}
+	return nil
+}
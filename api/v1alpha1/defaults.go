@@ -7,6 +7,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func RegisterDefaults(scheme *runtime.Scheme) error {
@@ -87,10 +88,57 @@ func defaultVarnishClusterSpec(in *VarnishClusterSpec) {
 		in.Service.Type = v1.ServiceTypeClusterIP
 	}
 
-	if in.Backend.ZoneBalancing == nil {
-		in.Backend.ZoneBalancing = &VarnishClusterBackendZoneBalancing{}
+	if in.VCL != nil && in.VCL.RolloutStrategy != nil {
+		defaultVCLRolloutStrategy(in.VCL.RolloutStrategy)
+	}
+
+	if in.VCL != nil {
+		if in.VCL.Fallback == nil {
+			in.VCL.Fallback = &VarnishClusterVCLFallback{}
+		}
+		defaultVCLFallback(in.VCL.Fallback)
+		if in.VCL.Drift == nil {
+			in.VCL.Drift = &VarnishClusterVCLDrift{}
+		}
+		defaultVCLDrift(in.VCL.Drift)
+		if in.VCL.Reload == nil {
+			in.VCL.Reload = &VarnishClusterVCLReload{}
+		}
+		defaultVCLReload(in.VCL.Reload)
+	}
+
+	if in.Backend != nil {
+		defaultBackend(in.Backend)
+	}
+
+	if in.Logging != nil && in.Logging.Access != nil {
+		defaultAccessLogging(in.Logging.Access)
+	}
+
+	if in.Clustering == nil {
+		in.Clustering = &VarnishClusterClustering{}
+	}
+	if in.Clustering.Mode == "" {
+		in.Clustering.Mode = VarnishClusterClusteringModeNone
+	}
+
+	for i := range in.Backends {
+		defaultBackend(&in.Backends[i].VarnishClusterBackend)
 	}
-	defaultVarnishZoneBalancingType(in.Backend.ZoneBalancing)
+}
+
+func defaultBackend(in *VarnishClusterBackend) {
+	if in.ZoneBalancing == nil {
+		in.ZoneBalancing = &VarnishClusterBackendZoneBalancing{}
+	}
+	defaultVarnishZoneBalancingType(in.ZoneBalancing)
+	if in.External != nil {
+		defaultBackendExternal(in.External)
+	}
+	if in.Director == nil {
+		in.Director = &VarnishClusterBackendDirector{}
+	}
+	defaultBackendDirector(in.Director)
 }
 
 func defaultVarnish(in *VarnishClusterVarnish) {
@@ -146,6 +194,21 @@ func defaultVarnishMetricsExporter(in *VarnishClusterVarnishMetricsExporter) {
 	if in.ImagePullPolicy == "" {
 		in.ImagePullPolicy = v1.PullAlways
 	}
+	if in.Type == "" {
+		in.Type = VarnishMetricsExporterTypeExternal
+	}
+	if in.Naming == "" {
+		in.Naming = VarnishMetricsNamingCompatible
+	}
+}
+
+func defaultAccessLogging(in *VarnishClusterAccessLogging) {
+	if len(in.Fields) == 0 {
+		in.Fields = append([]string(nil), DefaultAccessLogFields...)
+	}
+	if in.SamplingPercent == nil {
+		in.SamplingPercent = proto.Int32(100)
+	}
 }
 
 func defaultVarnishZoneBalancingType(in *VarnishClusterBackendZoneBalancing) {
@@ -153,3 +216,74 @@ func defaultVarnishZoneBalancingType(in *VarnishClusterBackendZoneBalancing) {
 		in.Type = VarnishClusterBackendZoneBalancingTypeDisabled
 	}
 }
+
+func defaultBackendExternal(in *VarnishClusterBackendExternal) {
+	if in.ResolveIntervalSeconds == nil {
+		in.ResolveIntervalSeconds = proto.Int32(30)
+	}
+}
+
+func defaultVCLRolloutStrategy(in *VarnishClusterVCLRolloutStrategy) {
+	if in.Type == "" {
+		in.Type = VCLRolloutStrategyAllAtOnce
+	}
+
+	if in.Type != VCLRolloutStrategyCanary {
+		return
+	}
+
+	if in.Canary == nil {
+		in.Canary = &VarnishClusterVCLCanaryRollout{}
+	}
+
+	if in.Canary.Pods == nil {
+		pods := intstr.FromInt(1)
+		in.Canary.Pods = &pods
+	}
+
+	if in.Canary.BakeTimeSeconds == nil {
+		in.Canary.BakeTimeSeconds = proto.Int32(300)
+	}
+}
+
+func defaultVCLFallback(in *VarnishClusterVCLFallback) {
+	if in.Count == nil {
+		in.Count = proto.Int32(1)
+	}
+
+	if in.Temperature == "" {
+		in.Temperature = VCLFallbackTemperatureWarm
+	}
+}
+
+func defaultVCLDrift(in *VarnishClusterVCLDrift) {
+	if in.ThresholdSeconds == nil {
+		in.ThresholdSeconds = proto.Int32(300)
+	}
+}
+
+func defaultVCLReload(in *VarnishClusterVCLReload) {
+	if in.DebounceSeconds == nil {
+		in.DebounceSeconds = proto.Int32(2)
+	}
+
+	if in.MaxDelaySeconds == nil {
+		in.MaxDelaySeconds = proto.Int32(10)
+	}
+
+	if in.MinIntervalSeconds == nil {
+		in.MinIntervalSeconds = proto.Int32(0)
+	}
+}
+
+func defaultBackendDirector(in *VarnishClusterBackendDirector) {
+	if in.Type == "" {
+		in.Type = VarnishClusterBackendDirectorTypeRoundRobin
+	}
+	if in.Type == VarnishClusterBackendDirectorTypeShard && in.Shard == nil {
+		in.Shard = &VarnishClusterBackendDirectorShard{}
+	}
+	if in.Shard != nil && in.Shard.By == "" {
+		in.Shard.By = "HASH"
+	}
+}
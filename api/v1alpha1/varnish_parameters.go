@@ -0,0 +1,231 @@
+package v1alpha1
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// varnishParameterType is the type of a varnishd parameter value as documented by `varnishd -x parameter`
+type varnishParameterType int
+
+const (
+	// seconds, e.g. 120 or 120.5. Units like 2m are accepted as well
+	varnishParameterTimeout varnishParameterType = iota
+	// unsigned integer or "unlimited"
+	varnishParameterUint
+	// floating point number
+	varnishParameterDouble
+	// bytes with an optional unit, e.g. 64k
+	varnishParameterBytes
+	// on/off, true/false, yes/no, enable/disable
+	varnishParameterBool
+	// comma separated min,max,max_age
+	varnishParameterPool
+	// comma separated list of flags, each optionally prefixed with - to clear it, e.g. -VCL_trace,+Hash
+	varnishParameterFlags
+)
+
+type varnishParameter struct {
+	valueType varnishParameterType
+	// the parameter is read only on start (flag must_restart), so the pods have to be restarted to apply it
+	mustRestart bool
+}
+
+// varnishParameters are the parameters of Varnish 6.5 that can be set in .spec.varnish.parameters.
+// Parameters that point to files or commands (cc_command, vcl_path, vmod_path) can only be set with .spec.varnish.args.
+var varnishParameters = map[string]varnishParameter{
+	"accept_filter":                 {valueType: varnishParameterBool, mustRestart: true},
+	"acceptor_sleep_decay":          {valueType: varnishParameterDouble},
+	"acceptor_sleep_incr":           {valueType: varnishParameterTimeout},
+	"acceptor_sleep_max":            {valueType: varnishParameterTimeout},
+	"auto_restart":                  {valueType: varnishParameterBool},
+	"backend_idle_timeout":          {valueType: varnishParameterTimeout},
+	"backend_local_error_holddown":  {valueType: varnishParameterTimeout},
+	"backend_remote_error_holddown": {valueType: varnishParameterTimeout},
+	"ban_cutoff":                    {valueType: varnishParameterUint},
+	"ban_dups":                      {valueType: varnishParameterBool},
+	"ban_lurker_age":                {valueType: varnishParameterTimeout},
+	"ban_lurker_batch":              {valueType: varnishParameterUint},
+	"ban_lurker_holdoff":            {valueType: varnishParameterTimeout},
+	"ban_lurker_sleep":              {valueType: varnishParameterTimeout},
+	"between_bytes_timeout":         {valueType: varnishParameterTimeout},
+	"cli_limit":                     {valueType: varnishParameterBytes},
+	"cli_timeout":                   {valueType: varnishParameterTimeout},
+	"clock_skew":                    {valueType: varnishParameterUint},
+	"clock_step":                    {valueType: varnishParameterTimeout},
+	"connect_timeout":               {valueType: varnishParameterTimeout},
+	"critbit_cooloff":               {valueType: varnishParameterTimeout},
+	"debug":                         {valueType: varnishParameterFlags},
+	"default_grace":                 {valueType: varnishParameterTimeout},
+	"default_keep":                  {valueType: varnishParameterTimeout},
+	"default_ttl":                   {valueType: varnishParameterTimeout},
+	"feature":                       {valueType: varnishParameterFlags},
+	"fetch_chunksize":               {valueType: varnishParameterBytes},
+	"fetch_maxchunksize":            {valueType: varnishParameterBytes},
+	"first_byte_timeout":            {valueType: varnishParameterTimeout},
+	"gzip_buffer":                   {valueType: varnishParameterBytes},
+	"gzip_level":                    {valueType: varnishParameterUint},
+	"gzip_memlevel":                 {valueType: varnishParameterUint},
+	"h2_header_table_size":          {valueType: varnishParameterBytes},
+	"h2_initial_window_size":        {valueType: varnishParameterBytes},
+	"h2_max_concurrent_streams":     {valueType: varnishParameterUint},
+	"h2_max_frame_size":             {valueType: varnishParameterBytes},
+	"h2_max_header_list_size":       {valueType: varnishParameterBytes},
+	"h2_rx_window_increment":        {valueType: varnishParameterBytes},
+	"h2_rx_window_low_water":        {valueType: varnishParameterBytes},
+	"http1_iovs":                    {valueType: varnishParameterUint},
+	"http_gzip_support":             {valueType: varnishParameterBool},
+	"http_max_hdr":                  {valueType: varnishParameterUint, mustRestart: true},
+	"http_range_support":            {valueType: varnishParameterBool},
+	"http_req_hdr_len":              {valueType: varnishParameterBytes},
+	"http_req_size":                 {valueType: varnishParameterBytes},
+	"http_resp_hdr_len":             {valueType: varnishParameterBytes},
+	"http_resp_size":                {valueType: varnishParameterBytes},
+	"idle_send_timeout":             {valueType: varnishParameterTimeout},
+	"listen_depth":                  {valueType: varnishParameterUint, mustRestart: true},
+	"lru_interval":                  {valueType: varnishParameterTimeout},
+	"max_esi_depth":                 {valueType: varnishParameterUint},
+	"max_restarts":                  {valueType: varnishParameterUint},
+	"max_retries":                   {valueType: varnishParameterUint},
+	"max_vcl":                       {valueType: varnishParameterUint},
+	"max_vcl_handling":              {valueType: varnishParameterUint},
+	"nuke_limit":                    {valueType: varnishParameterUint},
+	"pcre_match_limit":              {valueType: varnishParameterUint},
+	"pcre_match_limit_recursion":    {valueType: varnishParameterUint},
+	"ping_interval":                 {valueType: varnishParameterUint, mustRestart: true},
+	"pipe_timeout":                  {valueType: varnishParameterTimeout},
+	"pool_req":                      {valueType: varnishParameterPool},
+	"pool_sess":                     {valueType: varnishParameterPool},
+	"pool_vbo":                      {valueType: varnishParameterPool},
+	"prefer_ipv6":                   {valueType: varnishParameterBool},
+	"rush_exponent":                 {valueType: varnishParameterUint},
+	"send_timeout":                  {valueType: varnishParameterTimeout},
+	"shortlived":                    {valueType: varnishParameterTimeout},
+	"sigsegv_handler":               {valueType: varnishParameterBool, mustRestart: true},
+	"syslog_cli_traffic":            {valueType: varnishParameterBool},
+	"tcp_fastopen":                  {valueType: varnishParameterBool, mustRestart: true},
+	"tcp_keepalive_intvl":           {valueType: varnishParameterTimeout},
+	"tcp_keepalive_probes":          {valueType: varnishParameterUint},
+	"tcp_keepalive_time":            {valueType: varnishParameterTimeout},
+	"thread_pool_add_delay":         {valueType: varnishParameterTimeout},
+	"thread_pool_destroy_delay":     {valueType: varnishParameterTimeout},
+	"thread_pool_fail_delay":        {valueType: varnishParameterTimeout},
+	"thread_pool_max":               {valueType: varnishParameterUint},
+	"thread_pool_min":               {valueType: varnishParameterUint},
+	"thread_pool_reserve":           {valueType: varnishParameterUint},
+	"thread_pool_stack":             {valueType: varnishParameterBytes},
+	"thread_pool_timeout":           {valueType: varnishParameterTimeout},
+	"thread_pool_watchdog":          {valueType: varnishParameterTimeout},
+	"thread_pools":                  {valueType: varnishParameterUint},
+	"thread_queue_limit":            {valueType: varnishParameterUint},
+	"thread_stats_rate":             {valueType: varnishParameterUint},
+	"timeout_idle":                  {valueType: varnishParameterTimeout},
+	"timeout_linger":                {valueType: varnishParameterTimeout},
+	"vcc_allow_inline_c":            {valueType: varnishParameterBool},
+	"vcc_err_unref":                 {valueType: varnishParameterBool},
+	"vcc_unsafe_path":               {valueType: varnishParameterBool},
+	"vcl_cooldown":                  {valueType: varnishParameterTimeout},
+	"vsl_buffer":                    {valueType: varnishParameterBytes},
+	"vsl_mask":                      {valueType: varnishParameterFlags},
+	"vsl_reclen":                    {valueType: varnishParameterBytes},
+	"vsl_space":                     {valueType: varnishParameterBytes, mustRestart: true},
+	"vsm_free_cooldown":             {valueType: varnishParameterTimeout},
+	"workspace_backend":             {valueType: varnishParameterBytes},
+	"workspace_client":              {valueType: varnishParameterBytes},
+	"workspace_session":             {valueType: varnishParameterBytes},
+	"workspace_thread":              {valueType: varnishParameterBytes},
+}
+
+var (
+	varnishTimeoutRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(ms|s|m|h|d|w|y)?$`)
+	varnishBytesRegexp   = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?([kKmMgGtT][bB]?|[bB])?$`)
+	varnishFlagsRegexp   = regexp.MustCompile(`^[-+]?[a-zA-Z0-9_]+(,[-+]?[a-zA-Z0-9_]+)*$`)
+	varnishBoolValues    = map[string]bool{"on": true, "off": true, "true": true, "false": true, "yes": true, "no": true, "enable": true, "disable": true}
+)
+
+// VarnishParameterRequiresRestart returns true if varnish reads the parameter only on start.
+// Such parameters are passed to varnishd as -p arguments, so changing them restarts the pods.
+func VarnishParameterRequiresRestart(name string) bool {
+	return varnishParameters[name].mustRestart
+}
+
+// VarnishParametersRequiringRestart returns the parameters from the map that can't be changed at runtime, sorted by name
+func VarnishParametersRequiringRestart(parameters map[string]string) []string {
+	var names []string
+	for name := range parameters {
+		if VarnishParameterRequiresRestart(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func validVarnishParameter(name, value string) error {
+	param, found := varnishParameters[name]
+	if !found {
+		return errors.Errorf("unknown varnish parameter %q", name)
+	}
+
+	var valid bool
+	switch param.valueType {
+	case varnishParameterTimeout:
+		valid = varnishTimeoutRegexp.MatchString(value)
+	case varnishParameterUint:
+		_, err := strconv.ParseUint(value, 10, 64)
+		valid = err == nil || value == "unlimited"
+	case varnishParameterDouble:
+		_, err := strconv.ParseFloat(value, 64)
+		valid = err == nil
+	case varnishParameterBytes:
+		valid = varnishBytesRegexp.MatchString(value)
+	case varnishParameterBool:
+		valid = varnishBoolValues[strings.ToLower(value)]
+	case varnishParameterPool:
+		parts := strings.Split(value, ",")
+		valid = len(parts) == 3
+		for _, part := range parts {
+			if _, err := strconv.ParseFloat(part, 64); err != nil {
+				valid = false
+			}
+		}
+	case varnishParameterFlags:
+		valid = varnishFlagsRegexp.MatchString(value)
+	}
+
+	if !valid {
+		return errors.Errorf("%q is not a valid value for varnish parameter %q", value, name)
+	}
+	return nil
+}
+
+// validVarnishParameters checks that the parameters are known and their values have the right type.
+// The parameters also can't be set by -p in .spec.varnish.args.
+func validVarnishParameters(parameters map[string]string, args []string) error {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := validVarnishParameter(name, parameters[name]); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] != "-p" {
+			continue
+		}
+		name := strings.SplitN(args[i+1], "=", 2)[0]
+		if _, found := parameters[name]; found {
+			return errors.Errorf("parameter %q is also set in .spec.varnish.args", name)
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package v1alpha1
 // +kubebuilder:validation:Optional
 
 import (
+	"fmt"
+
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
@@ -25,6 +27,7 @@ const (
 	VarnishComponentRole                     = "role"
 	VarnishComponentRoleBinding              = "rolebinding"
 	VarnishComponentVCLFileConfigMap         = "vcl-file-configmap"
+	VarnishComponentLastValidatedVCL         = "last-validated-vcl-configmap"
 	VarnishComponentPodDisruptionBudget      = "poddisruptionbudget"
 	VarnishComponentHeadlessService          = "headless-service"
 	VarnishComponentServiceAccount           = "serviceaccount"
@@ -57,6 +60,48 @@ const (
 	VarnishClusterBackendZoneBalancingTypeDisabled   = "disabled"
 	VarnishClusterBackendZoneBalancingTypeAuto       = "auto"
 	VarnishClusterBackendZoneBalancingTypeThresholds = "thresholds"
+
+	VarnishClusterBackendDirectorTypeRoundRobin = "round_robin"
+	VarnishClusterBackendDirectorTypeRandom     = "random"
+	VarnishClusterBackendDirectorTypeHash       = "hash"
+	VarnishClusterBackendDirectorTypeShard      = "shard"
+	VarnishClusterBackendDirectorTypeFallback   = "fallback"
+
+	VarnishClusterClusteringModeNone  = "none"
+	VarnishClusterClusteringModeShard = "shard"
+
+	VarnishMetricsExporterTypeExternal = "external"
+	VarnishMetricsExporterTypeBuiltin  = "builtin"
+
+	VarnishMetricsNamingCompatible = "compatible"
+	VarnishMetricsNamingNative     = "native"
+
+	VCLValidationPhasePending   = "Pending"
+	VCLValidationPhaseSucceeded = "Succeeded"
+	VCLValidationPhaseFailed    = "Failed"
+
+	VCLRolloutStrategyAllAtOnce = "AllAtOnce"
+	VCLRolloutStrategyCanary    = "Canary"
+
+	VCLRolloutPhaseCanary   = "Canary"
+	VCLRolloutPhasePromoted = "Promoted"
+	VCLRolloutPhaseAborted  = "Aborted"
+
+	VCLFallbackTemperatureWarm = "warm"
+	VCLFallbackTemperatureCold = "cold"
+
+	// VarnishClusterConditionReady is true when all Varnish pods are ready to serve the traffic
+	VarnishClusterConditionReady = "Ready"
+	// VarnishClusterConditionProgressing is true while the pods or the VCL are being updated
+	VarnishClusterConditionProgressing = "Progressing"
+	// VarnishClusterConditionVCLSynced is true when all pods run the current ConfigMap version
+	VarnishClusterConditionVCLSynced = "VCLSynced"
+	// VarnishClusterConditionBackendsDiscovered is true when the pods found at least one backend
+	VarnishClusterConditionBackendsDiscovered = "BackendsDiscovered"
+	// VarnishClusterConditionMonitoringConfigured is true when the requested ServiceMonitor and Grafana dashboard are installed
+	VarnishClusterConditionMonitoringConfigured = "MonitoringConfigured"
+	// VarnishClusterConditionDegraded is true when the operator can't bring the cluster to the desired state
+	VarnishClusterConditionDegraded = "Degraded"
 )
 
 // +kubebuilder:object:root=true
@@ -68,6 +113,9 @@ const (
 // +kubebuilder:resource:scope=Namespaced,shortName=vc
 // +kubebuilder:subresource:status
 // +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.varnishPodsSelector
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=".status.replicas"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=".metadata.creationTimestamp"
 type VarnishCluster struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -84,8 +132,17 @@ type VarnishClusterSpec struct {
 	Varnish        *VarnishClusterVarnish        `json:"varnish,omitempty"`
 	// +kubebuilder:validation:Required
 	VCL *VarnishClusterVCL `json:"vcl,omitempty"`
-	// +kubebuilder:validation:Required
+	// Backend is the default group of backends, available in VCL templates as .Backends.
+	// Optional if backend groups are set in .spec.backends
 	Backend *VarnishClusterBackend `json:"backend,omitempty"`
+	// Backends are named groups of backends. Each group is available in VCL templates as .BackendGroups.<name>
+	// +listType=map
+	// +listMapKey=name
+	Backends []VarnishClusterBackendGroup `json:"backends,omitempty"`
+	// Clustering configures how the varnish pods share the cache
+	Clustering *VarnishClusterClustering `json:"clustering,omitempty"`
+	// Sites configures which VarnishSites the cluster serves
+	Sites *VarnishClusterSites `json:"sites,omitempty"`
 	// +kubebuilder:validation:Required
 	Service             *VarnishClusterService            `json:"service,omitempty"`
 	PodDisruptionBudget *policyv1.PodDisruptionBudgetSpec `json:"podDisruptionBudget,omitempty"`
@@ -93,6 +150,8 @@ type VarnishClusterSpec struct {
 	Affinity            *v1.Affinity                      `json:"affinity,omitempty"`
 	Tolerations         []v1.Toleration                   `json:"tolerations,omitempty"`
 	Monitoring          *VarnishClusterMonitoring         `json:"monitoring,omitempty"`
+	// Logging configures the logs written by the varnish pods
+	Logging *VarnishClusterLogging `json:"logging,omitempty"`
 	// +kubebuilder:validation:Enum=debug;info;warn;error;dpanic;panic;fatal
 	LogLevel string `json:"logLevel,omitempty"`
 	// +kubebuilder:validation:Enum=json;console
@@ -100,6 +159,12 @@ type VarnishClusterSpec struct {
 	PriorityClassName string `json:"priorityClassName,omitempty"`
 }
 
+// VarnishClusterSites configures which VarnishSites the cluster serves
+type VarnishClusterSites struct {
+	// AllowedNamespaces are the namespaces, besides the VarnishCluster namespace, the VarnishSites of the cluster can be created in
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
 type VarnishClusterUpdateStrategyType string
 
 const (
@@ -134,6 +199,18 @@ type VarnishClusterVarnish struct {
 	ExtraVolumeClaimTemplates []PVC                                 `json:"extraVolumeClaimTemplates,omitempty"`
 	ExtraVolumes              []v1.Volume                           `json:"extraVolumes,omitempty"`
 	ExtraVolumeMounts         []v1.VolumeMount                      `json:"extraVolumeMounts,omitempty"`
+	// Parameters are the varnishd runtime parameters, e.g. default_ttl: "3600".
+	// They are applied without restarting the pods, except for the few parameters varnish reads only on start
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Readiness defines what is required, besides the loaded VCL, for a pod to receive traffic
+	Readiness *VarnishClusterVarnishReadiness `json:"readiness,omitempty"`
+}
+
+// VarnishClusterVarnishReadiness defines when a varnish pod is ready. A pod is never ready before the VCL
+// from the ConfigMap is loaded, so it doesn't answer the requests with the VCL varnish has been started with.
+type VarnishClusterVarnishReadiness struct {
+	// RequireHealthyBackend makes the pod ready only if at least one backend of the active VCL is healthy
+	RequireHealthyBackend bool `json:"requireHealthyBackend,omitempty"`
 }
 
 type PVC struct {
@@ -160,6 +237,14 @@ type VarnishClusterVarnishMetricsExporter struct {
 	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
 	ImagePullPolicy v1.PullPolicy           `json:"imagePullPolicy,omitempty"`
 	Resources       v1.ResourceRequirements `json:"resources,omitempty"`
+	// Type is external (default), where the varnish counters are exported by a separate metrics-exporter container,
+	// or builtin, where the varnish-controller exports them with the labels of the VarnishCluster, zone and backend pods
+	// +kubebuilder:validation:Enum=external;builtin
+	Type string `json:"type,omitempty"`
+	// Naming of the metrics exported by the builtin exporter. compatible (default) uses the metric names
+	// of the external exporter, native adds the _total suffix to the counters as the Prometheus conventions require
+	// +kubebuilder:validation:Enum=compatible;native
+	Naming string `json:"naming,omitempty"`
 }
 
 type VarnishClusterVCL struct {
@@ -170,6 +255,74 @@ type VarnishClusterVCL struct {
 	// +kubebuilder:validation:Required
 	// +kubebuilder:validation:Pattern=^.+\.vcl$
 	EntrypointFileName *string `json:"entrypointFileName,omitempty"`
+	// RolloutStrategy defines how a new ConfigMap version is rolled out to the Varnish pods
+	RolloutStrategy *VarnishClusterVCLRolloutStrategy `json:"rolloutStrategy,omitempty"`
+	// Fallback defines the previously active VCLs kept loaded to be able to switch back to them
+	Fallback *VarnishClusterVCLFallback `json:"fallback,omitempty"`
+	// Drift defines when a pod that doesn't run the current ConfigMap version is reported as drifted
+	Drift *VarnishClusterVCLDrift `json:"drift,omitempty"`
+	// Reload defines how the VCL reloads are debounced and rate limited when the backends or the ConfigMap change often
+	Reload *VarnishClusterVCLReload `json:"reload,omitempty"`
+}
+
+// VarnishClusterVCLReload defines how often the varnish-controller reloads the VCL.
+// The changes are collected until no new change comes within the debounce period, but no longer than the max delay,
+// and applied with a single reload. The first load and the removal of backends are applied without waiting.
+type VarnishClusterVCLReload struct {
+	// DebounceSeconds is how long no new change has to come before the collected changes are applied
+	// +kubebuilder:validation:Minimum=0
+	DebounceSeconds *int32 `json:"debounceSeconds,omitempty"`
+	// MaxDelaySeconds is the coalescing window, i.e. the longest time a change can wait for the next ones
+	// +kubebuilder:validation:Minimum=0
+	MaxDelaySeconds *int32 `json:"maxDelaySeconds,omitempty"`
+	// MinIntervalSeconds is the minimum time between two reloads, except the ones applied without waiting
+	// +kubebuilder:validation:Minimum=0
+	MinIntervalSeconds *int32 `json:"minIntervalSeconds,omitempty"`
+}
+
+// VarnishClusterVCLDrift defines when a pod that should run the current ConfigMap version, but doesn't, is reported as drifted.
+// Pods held back by the pre-flight check, a canary rollout or a failed compilation are not counted.
+type VarnishClusterVCLDrift struct {
+	// ThresholdSeconds is how long a pod can stay on an another ConfigMap version before it's reported as drifted
+	// +kubebuilder:validation:Minimum=0
+	ThresholdSeconds *int32 `json:"thresholdSeconds,omitempty"`
+	// Resync makes the operator ask the varnish-controller of a drifted pod to load the current ConfigMap version again
+	Resync bool `json:"resync,omitempty"`
+}
+
+// VarnishClusterVCLFallback defines the previously active VCLs kept loaded in Varnish.
+// Varnish switches back to the latest of them if the new VCL makes it panic or if it's explicitly requested.
+type VarnishClusterVCLFallback struct {
+	// Count is the number of previously active VCLs to keep loaded
+	// +kubebuilder:validation:Minimum=0
+	Count *int32 `json:"count,omitempty"`
+	// Temperature of the kept VCLs. Warm VCLs are ready to serve traffic right away,
+	// cold VCLs release their resources (e.g. stop backend probes) and need to be warmed up before use.
+	// +kubebuilder:validation:Enum=warm;cold
+	Temperature string `json:"temperature,omitempty"`
+}
+
+// VarnishClusterVCLRolloutStrategy defines how a new ConfigMap version is rolled out to the Varnish pods.
+// AllAtOnce applies the new version to all pods as soon as it passes the pre-flight check.
+// Canary applies it to a subset of pods first and rolls it out to the rest of the pods after the bake time.
+type VarnishClusterVCLRolloutStrategy struct {
+	// +kubebuilder:validation:Enum=AllAtOnce;Canary
+	Type   string                          `json:"type,omitempty"`
+	Canary *VarnishClusterVCLCanaryRollout `json:"canary,omitempty"`
+}
+
+type VarnishClusterVCLCanaryRollout struct {
+	// Pods is the number (e.g. 2) or the percentage (e.g. 20%) of pods that get the new ConfigMap version first
+	// +kubebuilder:validation:XIntOrString
+	Pods *intstr.IntOrString `json:"pods,omitempty"`
+	// BakeTimeSeconds is how long the canary pods have to stay healthy before the new version is promoted to all pods
+	// +kubebuilder:validation:Minimum=0
+	BakeTimeSeconds *int32 `json:"bakeTimeSeconds,omitempty"`
+	// MaxErrorRatePercent is the maximum percentage of failed backend fetches relative to client requests on a canary pod.
+	// The rollout is aborted if a canary pod exceeds it during the bake time. Not checked if omitted.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxErrorRatePercent *int32 `json:"maxErrorRatePercent,omitempty"`
 }
 
 // Defines the type and parameters for backend traffic distribution
@@ -192,13 +345,181 @@ type VarnishClusterBackendZoneBalancingThreshold struct {
 }
 
 type VarnishClusterBackend struct {
-	// +kubebuilder:validation:Required
+	// Selector of the backend pods. Either selector or serviceRef has to be set
 	Selector map[string]string `json:"selector,omitempty"`
-	// +kubebuilder:validation:Required
+	// ServiceRef references a Service whose endpoints are discovered through the EndpointSlice API.
+	// Either selector or serviceRef has to be set
+	ServiceRef *VarnishClusterBackendServiceRef `json:"serviceRef,omitempty"`
+	// External backends outside of the cluster. Can be used alone or alongside the selected pods
+	External *VarnishClusterBackendExternal `json:"external,omitempty"`
+	// Port of the backends. Required if selector or serviceRef is set
 	Port          *intstr.IntOrString                 `json:"port,omitempty"`
 	Namespaces    []string                            `json:"namespaces,omitempty"`
 	OnlyReady     bool                                `json:"onlyReady,omitempty"`
 	ZoneBalancing *VarnishClusterBackendZoneBalancing `json:"zoneBalancing,omitempty"`
+	// Probe is the Varnish health probe added to every generated backend definition
+	Probe *VarnishClusterBackendProbe `json:"probe,omitempty"`
+	// Connection parameters added to every generated backend definition
+	Connection *VarnishClusterBackendConnection `json:"connection,omitempty"`
+	// Director that distributes the requests between the backends
+	Director *VarnishClusterBackendDirector `json:"director,omitempty"`
+}
+
+// VarnishClusterLogging configures the logs written by the varnish pods
+type VarnishClusterLogging struct {
+	// Access configures the access log of the client requests
+	Access *VarnishClusterAccessLogging `json:"access,omitempty"`
+}
+
+// VarnishClusterAccessLogging configures the access log written by the varnish-controller.
+// Every client request is read from the varnish shared memory log and logged as a structured line.
+type VarnishClusterAccessLogging struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Fields logged for every request. Either one of client_ip, method, host, url, query, protocol, status, bytes,
+	// duration_us, ttfb_seconds, handling, vxid, user_agent or referer, or a header as req.http.<name> or resp.http.<name>.
+	// Default: client_ip, method, host, url, protocol, status, bytes, duration_us, handling
+	Fields []string `json:"fields,omitempty"`
+	// Query is the VSL query the logged requests are filtered by, e.g. RespStatus >= 500.
+	// See https://varnish-cache.org/docs/6.5/reference/vsl-query.html
+	Query string `json:"query,omitempty"`
+	// SamplingPercent is the percentage of the requests matching the query that are logged. Default: 100
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	SamplingPercent *int32 `json:"samplingPercent,omitempty"`
+	// RedactHeaders are the headers whose values are replaced with REDACTED in the log, e.g. Authorization
+	RedactHeaders []string `json:"redactHeaders,omitempty"`
+}
+
+// VarnishClusterClustering configures how the varnish pods share the cache
+type VarnishClusterClustering struct {
+	// Mode is none (default), where every pod caches the objects independently, or shard, where every object
+	// is cached only by the pod that owns it and the other pods forward the requests to that pod
+	// +kubebuilder:validation:Enum=none;shard
+	Mode string `json:"mode,omitempty"`
+	// ZoneAware shards the objects only between the pods in the same zone, so the requests don't cross zones.
+	// Every zone caches its own copy of an object in that case
+	ZoneAware bool `json:"zoneAware,omitempty"`
+}
+
+// VarnishClusterBackendDirector defines the director generated for the backends.
+// See https://varnish-cache.org/docs/6.5/reference/vmod_directors.html
+type VarnishClusterBackendDirector struct {
+	// Type of the director. Default: round_robin
+	// +kubebuilder:validation:Enum=round_robin;random;hash;shard;fallback
+	Type string `json:"type,omitempty"`
+	// Hash director parameters. Can only be set for the hash director
+	Hash *VarnishClusterBackendDirectorHash `json:"hash,omitempty"`
+	// Shard director parameters. Can only be set for the shard director
+	Shard *VarnishClusterBackendDirectorShard `json:"shard,omitempty"`
+	// Fallback director parameters. Required for the fallback director
+	Fallback *VarnishClusterBackendDirectorFallback `json:"fallback,omitempty"`
+}
+
+// VarnishClusterBackendDirectorHash defines what the hash director picks the backend by.
+// Either header or cookie has to be set
+type VarnishClusterBackendDirectorHash struct {
+	// Name of the request header to pick the backend by
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z][a-zA-Z0-9_-]*$`
+	Header string `json:"header,omitempty"`
+	// Name of the cookie to pick the backend by
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_-]+$`
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// VarnishClusterBackendDirectorShard defines the parameters of the shard director
+type VarnishClusterBackendDirectorShard struct {
+	// What the backend is picked by: the Varnish hash of the request (HASH) or the URL (URL). Default: HASH
+	// +kubebuilder:validation:Enum=HASH;URL
+	By string `json:"by,omitempty"`
+	// Percentage of the requests sent to the next backend to warm up its cache
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	WarmupPercent *int32 `json:"warmupPercent,omitempty"`
+	// How long a backend that became healthy gets a reduced share of the requests
+	Rampup VCLDuration `json:"rampup,omitempty"`
+}
+
+// VarnishClusterBackendDirectorFallback defines the failover chain of the fallback director
+type VarnishClusterBackendDirectorFallback struct {
+	// Names of the backend groups in .spec.backends that are used, in order, when all the backends before them are unhealthy
+	// +kubebuilder:validation:MinItems=1
+	Groups []string `json:"groups"`
+	// Keep using the backend the director failed over to even after the previous ones become healthy again
+	Sticky bool `json:"sticky,omitempty"`
+}
+
+// VCLDuration is a duration in the VCL format, e.g. 500ms, 5s or 1m
+// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?(ms|s|m|h|d|w|y)$`
+type VCLDuration string
+
+// VarnishClusterBackendProbe defines a Varnish backend health probe.
+// See https://varnish-cache.org/docs/6.5/reference/vcl-probe.html
+type VarnishClusterBackendProbe struct {
+	// URL requested by the probe. Default: /
+	URL string `json:"url,omitempty"`
+	// Request lines of a custom probe request. Can't be used together with url
+	Request []string `json:"request,omitempty"`
+	// How often the probe runs
+	Interval VCLDuration `json:"interval,omitempty"`
+	// How fast the probe must finish
+	Timeout VCLDuration `json:"timeout,omitempty"`
+	// How many of the latest probes are considered when determining if the backend is healthy
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	Window *int32 `json:"window,omitempty"`
+	// How many of the probes in the window must have succeeded for the backend to be healthy
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=64
+	Threshold *int32 `json:"threshold,omitempty"`
+	// How many of the probes are considered good when Varnish starts
+	// +kubebuilder:validation:Minimum=0
+	Initial *int32 `json:"initial,omitempty"`
+	// Expected HTTP status code of the response. Default: 200
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	ExpectedResponse *int32 `json:"expectedResponse,omitempty"`
+}
+
+// VarnishClusterBackendConnection defines the connection parameters of the backends
+type VarnishClusterBackendConnection struct {
+	ConnectTimeout      VCLDuration `json:"connectTimeout,omitempty"`
+	FirstByteTimeout    VCLDuration `json:"firstByteTimeout,omitempty"`
+	BetweenBytesTimeout VCLDuration `json:"betweenBytesTimeout,omitempty"`
+	// Maximum number of open connections to a backend
+	// +kubebuilder:validation:Minimum=1
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+}
+
+// VarnishClusterBackendExternal defines backends that are not pods in the cluster.
+// DNS names are re-resolved on an interval and every resolved address becomes a backend.
+type VarnishClusterBackendExternal struct {
+	// Addresses of the backends in the host:port format. The host can be an IP address or a DNS name
+	Addresses []string `json:"addresses,omitempty"`
+	// DNS SRV records the backend hosts and ports are looked up from, e.g. _http._tcp.origin.example.com
+	SRVRecords []string `json:"srvRecords,omitempty"`
+	// Services of type ExternalName. The external name is resolved and the port of the Service is used
+	ExternalNameServices []VarnishClusterBackendServiceRef `json:"externalNameServices,omitempty"`
+	// How often DNS names are resolved again. Default: 30
+	// +kubebuilder:validation:Minimum=1
+	ResolveIntervalSeconds *int32 `json:"resolveIntervalSeconds,omitempty"`
+}
+
+// VarnishClusterBackendServiceRef references the Service the backends are discovered from
+type VarnishClusterBackendServiceRef struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Namespace of the Service. Defaults to the namespace of the VarnishCluster
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// VarnishClusterBackendGroup is a named group of backends with its own selector, namespaces and port
+type VarnishClusterBackendGroup struct {
+	// Name of the group. Has to be a valid identifier, as it's used in VCL templates and VCL code.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z][a-zA-Z0-9_]*$`
+	Name                  string `json:"name"`
+	VarnishClusterBackend `json:",inline"`
 }
 
 type VarnishClusterVarnishSecret struct {
@@ -255,13 +576,122 @@ type VarnishClusterStatus struct {
 	VarnishArgs         string    `json:"varnishArgs,omitempty"`
 	Replicas            int32     `json:"replicas,omitempty"`
 	VarnishPodsSelector string    `json:"varnishPodsSelector,omitempty"`
+	// Parameters are the effective values of the parameters set in .spec.varnish.parameters
+	// +listType=map
+	// +listMapKey=name
+	Parameters []VarnishParameterStatus `json:"parameters,omitempty"`
+	// Pods describes the VCL state of every Varnish pod
+	// +listType=map
+	// +listMapKey=name
+	Pods []VarnishPodStatus `json:"pods,omitempty"`
+	// ObservedGeneration is the .metadata.generation the status has been computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions are the Ready, Progressing, VCLSynced, BackendsDiscovered, MonitoringConfigured and Degraded conditions
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// VarnishPodStatus describes the VCL state of a Varnish pod as reported by its varnish-controller
+type VarnishPodStatus struct {
+	Name string `json:"name"`
+	// ActiveVCL is the name of the VCL the pod serves the requests with
+	ActiveVCL string `json:"activeVCL,omitempty"`
+	// ConfigMapVersion is the ConfigMap resource version the pod runs
+	ConfigMapVersion string `json:"configMapVersion,omitempty"`
+	// VCLVersion is the user defined version from the VCLVersion annotation of the ConfigMap
+	VCLVersion string `json:"vclVersion,omitempty"`
+	// LastReloadTime is the time of the last successful VCL reload
+	LastReloadTime *metav1.Time `json:"lastReloadTime,omitempty"`
+	// LastReloadError is the error of the last VCL reload if it failed
+	LastReloadError      string `json:"lastReloadError,omitempty"`
+	LocalBackendsWeight  string `json:"localBackendsWeight,omitempty"`
+	RemoteBackendsWeight string `json:"remoteBackendsWeight,omitempty"`
+	// OutOfSyncSince is the time the pod was first seen not running the ConfigMap version it should run
+	OutOfSyncSince *metav1.Time `json:"outOfSyncSince,omitempty"`
+	// Drifted is set if the pod has been out of sync for longer than .spec.vcl.drift.thresholdSeconds
+	Drifted bool `json:"drifted,omitempty"`
+}
+
+// VarnishParameterStatus describes the effective value of a varnishd parameter
+type VarnishParameterStatus struct {
+	Name string `json:"name"`
+	// Value is the effective value as reported by varnish in the updated pods
+	Value string `json:"value,omitempty"`
+	// UpdatedPods is the number of pods the value from the spec is applied in
+	UpdatedPods int32 `json:"updatedPods"`
+	// RestartRequired is set for the parameters varnish reads only on start. Changing them restarts the pods
+	RestartRequired bool `json:"restartRequired,omitempty"`
 }
 
 // VCLStatus describes the VCL versions status
 type VCLStatus struct {
-	Version          *string `json:"version,omitempty"`
-	ConfigMapVersion string  `json:"configMapVersion"`
-	Availability     string  `json:"availability"`
+	Version          *string              `json:"version,omitempty"`
+	ConfigMapVersion string               `json:"configMapVersion"`
+	Availability     string               `json:"availability"`
+	Validation       *VCLValidationStatus `json:"validation,omitempty"`
+	Rollout          *VCLRolloutStatus    `json:"rollout,omitempty"`
+	// CompilationError is set while the current ConfigMap version fails to compile on some pods
+	CompilationError *VCLCompilationErrorStatus `json:"compilationError,omitempty"`
+}
+
+// VCLValidationStatus describes the result of the pre-flight compilation check of a ConfigMap version.
+// Varnish pods apply a new ConfigMap version only after the check succeeded.
+type VCLValidationStatus struct {
+	// ConfigMapVersion is the ConfigMap resource version the check has been done for
+	ConfigMapVersion string `json:"configMapVersion"`
+	// +kubebuilder:validation:Enum=Pending;Succeeded;Failed
+	Phase string `json:"phase"`
+	// Pod is the Varnish pod that compiles the VCL
+	Pod     string `json:"pod,omitempty"`
+	Message string `json:"message,omitempty"`
+	// Diagnostics are the errors reported by the VCL compiler
+	Diagnostics []VCLDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// VCLCompilationErrorStatus describes why a ConfigMap version failed to compile when the pods reloaded it
+type VCLCompilationErrorStatus struct {
+	// ConfigMapVersion is the ConfigMap resource version that doesn't compile
+	ConfigMapVersion string `json:"configMapVersion"`
+	// Pods are the Varnish pods the VCL failed to compile on
+	Pods []string `json:"pods,omitempty"`
+	// Message is the beginning of the compiler output
+	Message string `json:"message,omitempty"`
+	// Diagnostics are the errors reported by the VCL compiler
+	Diagnostics []VCLDiagnostic `json:"diagnostics,omitempty"`
+}
+
+// VCLDiagnostic is an error reported by the VCL compiler
+type VCLDiagnostic struct {
+	// File is the ConfigMap entry the error is in
+	File string `json:"file,omitempty"`
+	Line int32  `json:"line,omitempty"`
+	// Column is the position of the error in the line, starting from 1
+	Column  int32  `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// String formats the diagnostic like compilers do, e.g. entrypoint.vcl:12:5: Symbol not found: 'req.foo'
+func (d VCLDiagnostic) String() string {
+	if d.File == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// VCLRolloutStatus describes the progress of the canary rollout of a ConfigMap version
+type VCLRolloutStatus struct {
+	// ConfigMapVersion is the ConfigMap resource version being rolled out
+	ConfigMapVersion string `json:"configMapVersion"`
+	// +kubebuilder:validation:Enum=Canary;Promoted;Aborted
+	Phase string `json:"phase"`
+	// CanaryPods are the pods that get the new version first
+	CanaryPods []string `json:"canaryPods,omitempty"`
+	// BakeStartTime is the time all canary pods started to run the new version
+	BakeStartTime *metav1.Time `json:"bakeStartTime,omitempty"`
+	// UpdatedPods is the number of pods that run the new version
+	UpdatedPods int32  `json:"updatedPods"`
+	Message     string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -2,7 +2,9 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/ibm/varnish-operator/pkg/logger"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -31,6 +34,9 @@ var (
 		"-b": true,
 	}
 	disallowedVarnishArgsAsString string
+	vclDurationRegexp             = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(ms|s|m|h|d|w|y)$`)
+	headerNameRegexp              = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]*$`)
+	cookieNameRegexp              = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 )
 
 func init() {
@@ -106,6 +112,19 @@ func validateCreateUpdate(vc *VarnishCluster) error {
 		if err := validVarnishArgs(vc.Spec.Varnish.Args); err != nil {
 			return fieldError(".spec.varnish.args", err)
 		}
+		if err := validVarnishParameters(vc.Spec.Varnish.Parameters, vc.Spec.Varnish.Args); err != nil {
+			return fieldError(".spec.varnish.parameters", err)
+		}
+		if exporter := vc.Spec.Varnish.MetricsExporter; exporter != nil && exporter.Naming == VarnishMetricsNamingNative &&
+			exporter.Type != VarnishMetricsExporterTypeBuiltin {
+			return fieldError(".spec.varnish.metricsExporter.naming", errors.New("native naming is supported only by the builtin exporter"))
+		}
+	}
+
+	if vc.Spec.Logging != nil && vc.Spec.Logging.Access != nil {
+		if err := validAccessLogging(vc.Spec.Logging.Access); err != nil {
+			return fieldError(".spec.logging.access", err)
+		}
 	}
 
 	if vc.Spec.Service != nil {
@@ -121,24 +140,26 @@ func validateCreateUpdate(vc *VarnishCluster) error {
 		}
 	}
 
+	if vc.Spec.Backend == nil && len(vc.Spec.Backends) == 0 {
+		return fieldError(".spec.backend", errors.New("either .spec.backend or at least one backend group in .spec.backends has to be set"))
+	}
+
 	if vc.Spec.Backend != nil {
+		if err := validBackendDiscovery(".spec.backend", vc.Spec.Backend); err != nil {
+			return err
+		}
+
+		if err := validBackendParameters(".spec.backend", vc.Spec.Backend); err != nil {
+			return err
+		}
+
+		if err := validBackendDirector(".spec.backend", vc.Spec.Backend.Director, vc.Spec.Backends, true); err != nil {
+			return err
+		}
+
 		if vc.Spec.Backend.ZoneBalancing != nil {
-			for _, threshold := range vc.Spec.Backend.ZoneBalancing.Thresholds {
-				if threshold.Local != nil {
-					if err := min(int64(*threshold.Local), 1); err != nil {
-						return fieldError(".spec.backend.zoneBalancing.thresholds[].local", err)
-					}
-				}
-				if threshold.Remote != nil {
-					if err := min(int64(*threshold.Remote), 1); err != nil {
-						return fieldError(".spec.backend.zoneBalancing.thresholds[].remote", err)
-					}
-				}
-				if threshold.Local != nil {
-					if err := inAllowedRange(int64(*threshold.Threshold), 1, 100); err != nil {
-						return fieldError(".spec.backend.zoneBalancing.thresholds[].threshold", err)
-					}
-				}
+			if err := validZoneBalancingThresholds(".spec.backend", vc.Spec.Backend.ZoneBalancing.Thresholds); err != nil {
+				return err
 			}
 
 			if err := inAllowedRange(int64(*vc.Spec.Service.Port), 1, 65535); err != nil {
@@ -147,6 +168,45 @@ func validateCreateUpdate(vc *VarnishCluster) error {
 		}
 	}
 
+	groupNames := make(map[string]bool, len(vc.Spec.Backends))
+	for _, group := range vc.Spec.Backends {
+		if groupNames[group.Name] {
+			return fieldError(".spec.backends[].name", errors.Errorf("backend group %q is defined more than once", group.Name))
+		}
+		groupNames[group.Name] = true
+
+		if err := validBackendDiscovery(".spec.backends[]", &group.VarnishClusterBackend); err != nil {
+			return err
+		}
+
+		if err := validBackendParameters(".spec.backends[]", &group.VarnishClusterBackend); err != nil {
+			return err
+		}
+
+		if err := validBackendDirector(".spec.backends[]", group.Director, vc.Spec.Backends, false); err != nil {
+			return err
+		}
+
+		if group.ZoneBalancing != nil {
+			if err := validZoneBalancingThresholds(".spec.backends[]", group.ZoneBalancing.Thresholds); err != nil {
+				return err
+			}
+		}
+	}
+
+	if vc.Spec.VCL != nil && vc.Spec.VCL.RolloutStrategy != nil && vc.Spec.VCL.RolloutStrategy.Canary != nil {
+		if pods := vc.Spec.VCL.RolloutStrategy.Canary.Pods; pods != nil {
+			if _, err := intstr.GetScaledValueFromIntOrPercent(pods, 100, true); err != nil {
+				return fieldError(".spec.vcl.rolloutStrategy.canary.pods", err)
+			}
+			if pods.Type == intstr.Int {
+				if err := min(int64(pods.IntVal), 1); err != nil {
+					return fieldError(".spec.vcl.rolloutStrategy.canary.pods", err)
+				}
+			}
+		}
+	}
+
 	if vc.Spec.UpdateStrategy != nil && vc.Spec.UpdateStrategy.DelayedRollingUpdate != nil {
 		if err := min(int64(vc.Spec.UpdateStrategy.DelayedRollingUpdate.DelaySeconds), 1); err != nil {
 			return fieldError(".spec.updateStrategy.delayedRollingUpdate.delaySeconds", err)
@@ -156,6 +216,220 @@ func validateCreateUpdate(vc *VarnishCluster) error {
 	return nil
 }
 
+// validBackendDiscovery checks that the backends are discovered either by the selector or by the Service, and/or defined as external backends
+func validBackendDiscovery(backendPath string, backend *VarnishClusterBackend) error {
+	if backend.ServiceRef != nil && len(backend.Selector) > 0 {
+		return fieldError(backendPath+".serviceRef", errors.New("selector and serviceRef can't be set at the same time"))
+	}
+	if backend.ServiceRef == nil && len(backend.Selector) == 0 && backend.External == nil {
+		return fieldError(backendPath+".selector", errors.New("either selector, serviceRef or external has to be set"))
+	}
+	if (backend.ServiceRef != nil || len(backend.Selector) > 0) && backend.Port == nil {
+		return fieldError(backendPath+".port", errors.New("port has to be set for the backends found by selector or serviceRef"))
+	}
+
+	if backend.External != nil {
+		for _, address := range backend.External.Addresses {
+			if err := validHostPort(address); err != nil {
+				return fieldError(backendPath+".external.addresses", err)
+			}
+		}
+		if len(backend.External.Addresses)+len(backend.External.SRVRecords)+len(backend.External.ExternalNameServices) == 0 {
+			return fieldError(backendPath+".external", errors.New("at least one address, SRV record or ExternalName service has to be set"))
+		}
+	}
+	return nil
+}
+
+// validBackendParameters checks the probe and connection parameters that are rendered into the backend definitions
+func validBackendParameters(backendPath string, backend *VarnishClusterBackend) error {
+	if probe := backend.Probe; probe != nil {
+		if probe.URL != "" && len(probe.Request) > 0 {
+			return fieldError(backendPath+".probe.request", errors.New("url and request can't be set at the same time"))
+		}
+		if probe.URL != "" && !strings.HasPrefix(probe.URL, "/") {
+			return fieldError(backendPath+".probe.url", errors.New("url has to start with /"))
+		}
+		for _, str := range append([]string{probe.URL}, probe.Request...) {
+			if err := validVCLString(str); err != nil {
+				return fieldError(backendPath+".probe", err)
+			}
+		}
+		if err := validVCLDuration(probe.Interval); err != nil {
+			return fieldError(backendPath+".probe.interval", err)
+		}
+		if err := validVCLDuration(probe.Timeout); err != nil {
+			return fieldError(backendPath+".probe.timeout", err)
+		}
+		if probe.Window != nil {
+			if err := inAllowedRange(int64(*probe.Window), 1, 64); err != nil {
+				return fieldError(backendPath+".probe.window", err)
+			}
+		}
+		if probe.Threshold != nil {
+			window := int32(8) // varnish default
+			if probe.Window != nil {
+				window = *probe.Window
+			}
+			if err := inAllowedRange(int64(*probe.Threshold), 1, int64(window)); err != nil {
+				return fieldError(backendPath+".probe.threshold", err)
+			}
+		}
+		if probe.Initial != nil {
+			if err := min(int64(*probe.Initial), 0); err != nil {
+				return fieldError(backendPath+".probe.initial", err)
+			}
+		}
+		if probe.ExpectedResponse != nil {
+			if err := inAllowedRange(int64(*probe.ExpectedResponse), 100, 599); err != nil {
+				return fieldError(backendPath+".probe.expectedResponse", err)
+			}
+		}
+	}
+
+	if connection := backend.Connection; connection != nil {
+		if err := validVCLDuration(connection.ConnectTimeout); err != nil {
+			return fieldError(backendPath+".connection.connectTimeout", err)
+		}
+		if err := validVCLDuration(connection.FirstByteTimeout); err != nil {
+			return fieldError(backendPath+".connection.firstByteTimeout", err)
+		}
+		if err := validVCLDuration(connection.BetweenBytesTimeout); err != nil {
+			return fieldError(backendPath+".connection.betweenBytesTimeout", err)
+		}
+		if connection.MaxConnections != nil {
+			if err := min(int64(*connection.MaxConnections), 1); err != nil {
+				return fieldError(backendPath+".connection.maxConnections", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validBackendDirector checks the director parameters. The fallback director can only be used for .spec.backend,
+// as its chain consists of the directors of the backend groups.
+func validBackendDirector(backendPath string, director *VarnishClusterBackendDirector, groups []VarnishClusterBackendGroup, allowFallback bool) error {
+	if director == nil {
+		return nil
+	}
+	directorPath := backendPath + ".director"
+
+	if director.Hash != nil && director.Type != VarnishClusterBackendDirectorTypeHash {
+		return fieldError(directorPath+".hash", errors.New("can only be set for the hash director"))
+	}
+	if director.Shard != nil && director.Type != VarnishClusterBackendDirectorTypeShard {
+		return fieldError(directorPath+".shard", errors.New("can only be set for the shard director"))
+	}
+	if director.Fallback != nil && director.Type != VarnishClusterBackendDirectorTypeFallback {
+		return fieldError(directorPath+".fallback", errors.New("can only be set for the fallback director"))
+	}
+
+	switch director.Type {
+	case VarnishClusterBackendDirectorTypeHash:
+		if director.Hash == nil || (director.Hash.Header == "") == (director.Hash.Cookie == "") {
+			return fieldError(directorPath+".hash", errors.New("either header or cookie has to be set for the hash director"))
+		}
+		if director.Hash.Header != "" && !headerNameRegexp.MatchString(director.Hash.Header) {
+			return fieldError(directorPath+".hash.header", errors.Errorf("%q is not a valid header name", director.Hash.Header))
+		}
+		if director.Hash.Cookie != "" && !cookieNameRegexp.MatchString(director.Hash.Cookie) {
+			return fieldError(directorPath+".hash.cookie", errors.Errorf("%q is not a valid cookie name", director.Hash.Cookie))
+		}
+	case VarnishClusterBackendDirectorTypeShard:
+		if director.Shard != nil {
+			if director.Shard.WarmupPercent != nil {
+				if err := inAllowedRange(int64(*director.Shard.WarmupPercent), 0, 100); err != nil {
+					return fieldError(directorPath+".shard.warmupPercent", err)
+				}
+			}
+			if err := validVCLDuration(director.Shard.Rampup); err != nil {
+				return fieldError(directorPath+".shard.rampup", err)
+			}
+		}
+	case VarnishClusterBackendDirectorTypeFallback:
+		if !allowFallback {
+			return fieldError(directorPath+".type", errors.New("the fallback director can only be used in .spec.backend"))
+		}
+		if director.Fallback == nil || len(director.Fallback.Groups) == 0 {
+			return fieldError(directorPath+".fallback.groups", errors.New("at least one backend group has to be set for the fallback director"))
+		}
+
+		groupDirectors := make(map[string]*VarnishClusterBackendDirector, len(groups))
+		for i := range groups {
+			groupDirectors[groups[i].Name] = groups[i].Director
+		}
+		used := make(map[string]bool, len(director.Fallback.Groups))
+		for _, name := range director.Fallback.Groups {
+			groupDirector, found := groupDirectors[name]
+			if !found {
+				return fieldError(directorPath+".fallback.groups", errors.Errorf("backend group %q is not defined in .spec.backends", name))
+			}
+			if used[name] {
+				return fieldError(directorPath+".fallback.groups", errors.Errorf("backend group %q is used more than once", name))
+			}
+			used[name] = true
+			// the hash director can only pick a backend for a request, so it can't be a part of the chain
+			if groupDirector != nil && groupDirector.Type == VarnishClusterBackendDirectorTypeHash {
+				return fieldError(directorPath+".fallback.groups", errors.Errorf("backend group %q uses the hash director that can't be used in a fallback chain", name))
+			}
+		}
+	}
+
+	return nil
+}
+
+func validVCLDuration(duration VCLDuration) error {
+	if duration != "" && !vclDurationRegexp.MatchString(string(duration)) {
+		return errors.Errorf("%q is not a valid VCL duration, e.g. 500ms, 5s or 1m", duration)
+	}
+	return nil
+}
+
+// VCL strings can't contain double quotes and new lines
+func validVCLString(str string) error {
+	if strings.ContainsAny(str, "\"\n\r") {
+		return errors.Errorf("%q can't contain double quotes or new lines", str)
+	}
+	return nil
+}
+
+func validHostPort(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return errors.Errorf("%q is not in the host:port format", address)
+	}
+	if host == "" {
+		return errors.Errorf("%q has no host", address)
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return errors.Errorf("%q has an invalid port", address)
+	}
+	return inAllowedRange(int64(portNumber), 1, 65535)
+}
+
+func validZoneBalancingThresholds(backendPath string, thresholds []VarnishClusterBackendZoneBalancingThreshold) error {
+	for _, threshold := range thresholds {
+		if threshold.Local != nil {
+			if err := min(int64(*threshold.Local), 1); err != nil {
+				return fieldError(backendPath+".zoneBalancing.thresholds[].local", err)
+			}
+		}
+		if threshold.Remote != nil {
+			if err := min(int64(*threshold.Remote), 1); err != nil {
+				return fieldError(backendPath+".zoneBalancing.thresholds[].remote", err)
+			}
+		}
+		if threshold.Local != nil {
+			if err := inAllowedRange(int64(*threshold.Threshold), 1, 100); err != nil {
+				return fieldError(backendPath+".zoneBalancing.thresholds[].threshold", err)
+			}
+		}
+	}
+	return nil
+}
+
 func inAllowedRange(port int64, min, max int64) error {
 	if port < min || port > max {
 		return errors.Errorf("value should be between %d and %d", min, max)
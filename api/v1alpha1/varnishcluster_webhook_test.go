@@ -2,14 +2,41 @@ package v1alpha1
 
 import (
 	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func TestValidatingWebhook(t *testing.T) {
+	canaryPodsPercentage := intstr.FromString("20%")
+	canaryPodsZero := intstr.FromInt(0)
+	canaryPodsInvalid := intstr.FromString("twenty")
+	zero := 0
+	backendPort := intstr.FromString("http")
+	var window, threshold int32 = 5, 3
 	cases := []struct {
 		name  string
 		vc    *VarnishCluster
 		valid bool
+		// the cases without backends get a default backend, as at least one is required
+		noBackends bool
 	}{
+		{
+			name:       "No backends",
+			vc:         &VarnishCluster{},
+			valid:      false,
+			noBackends: true,
+		},
+		{
+			name: "Only backend groups",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backends: []VarnishClusterBackendGroup{
+						{Name: "api", VarnishClusterBackend: VarnishClusterBackend{Selector: map[string]string{"app": "api"}, Port: &backendPort}},
+					},
+				},
+			},
+			valid: true,
+		},
 		{
 			name: "Valid values",
 			vc: &VarnishCluster{
@@ -54,6 +81,83 @@ func TestValidatingWebhook(t *testing.T) {
 			},
 			valid: true,
 		},
+		{
+			name: "Valid varnish parameters",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						Args: []string{"-p", "vsl_space=100m"},
+						Parameters: map[string]string{
+							"default_ttl":       "3600",
+							"default_grace":     "2m",
+							"thread_pool_max":   "5000",
+							"workspace_client":  "128k",
+							"http_gzip_support": "off",
+							"pool_req":          "10,100,10",
+							"vsl_mask":          "-VCL_trace,+Hash",
+							"http_max_hdr":      "128",
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Unknown varnish parameter",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						Parameters: map[string]string{"default_tll": "3600"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Invalid varnish parameter value",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						Parameters: map[string]string{"thread_pool_max": "a lot"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Varnish parameter set in args as well",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						Args:       []string{"-p", "default_ttl=60"},
+						Parameters: map[string]string{"default_ttl": "3600"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Native metrics naming with the builtin exporter",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						MetricsExporter: &VarnishClusterVarnishMetricsExporter{Type: VarnishMetricsExporterTypeBuiltin, Naming: VarnishMetricsNamingNative},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Native metrics naming with the external exporter",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Varnish: &VarnishClusterVarnish{
+						MetricsExporter: &VarnishClusterVarnishMetricsExporter{Type: VarnishMetricsExporterTypeExternal, Naming: VarnishMetricsNamingNative},
+					},
+				},
+			},
+			valid: false,
+		},
 		{
 			name: "Key pattern should match the whole string",
 			vc: &VarnishCluster{
@@ -76,9 +180,382 @@ func TestValidatingWebhook(t *testing.T) {
 			},
 			valid: false,
 		},
+		{
+			name: "Canary rollout to a percentage of pods",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					VCL: &VarnishClusterVCL{
+						RolloutStrategy: &VarnishClusterVCLRolloutStrategy{
+							Type:   VCLRolloutStrategyCanary,
+							Canary: &VarnishClusterVCLCanaryRollout{Pods: &canaryPodsPercentage},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Canary rollout to zero pods",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					VCL: &VarnishClusterVCL{
+						RolloutStrategy: &VarnishClusterVCLRolloutStrategy{
+							Type:   VCLRolloutStrategyCanary,
+							Canary: &VarnishClusterVCLCanaryRollout{Pods: &canaryPodsZero},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Canary rollout with invalid percentage",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					VCL: &VarnishClusterVCL{
+						RolloutStrategy: &VarnishClusterVCLRolloutStrategy{
+							Type:   VCLRolloutStrategyCanary,
+							Canary: &VarnishClusterVCLCanaryRollout{Pods: &canaryPodsInvalid},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend groups with different names",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backends: []VarnishClusterBackendGroup{
+						{Name: "api", VarnishClusterBackend: VarnishClusterBackend{Selector: map[string]string{"app": "api"}, Port: &backendPort}},
+						{Name: "static", VarnishClusterBackend: VarnishClusterBackend{ServiceRef: &VarnishClusterBackendServiceRef{Name: "static"}, Port: &backendPort}},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Backend groups with the same name",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backends: []VarnishClusterBackendGroup{
+						{Name: "api", VarnishClusterBackend: VarnishClusterBackend{Selector: map[string]string{"app": "api"}, Port: &backendPort}},
+						{Name: "api", VarnishClusterBackend: VarnishClusterBackend{Selector: map[string]string{"app": "api"}, Port: &backendPort}},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend group with invalid zone balancing threshold",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backends: []VarnishClusterBackendGroup{{
+						Name: "api",
+						VarnishClusterBackend: VarnishClusterBackend{
+							Selector: map[string]string{"app": "api"},
+							Port:     &backendPort,
+							ZoneBalancing: &VarnishClusterBackendZoneBalancing{
+								Thresholds: []VarnishClusterBackendZoneBalancingThreshold{{Local: &zero, Remote: &zero, Threshold: &zero}},
+							},
+						},
+					}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend discovered by a Service",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{ServiceRef: &VarnishClusterBackendServiceRef{Name: "web"}, Port: &backendPort},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Backend with both selector and serviceRef",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector:   map[string]string{"app": "web"},
+						ServiceRef: &VarnishClusterBackendServiceRef{Name: "web"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend without selector and serviceRef",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "External backends only",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{External: &VarnishClusterBackendExternal{
+						Addresses:  []string{"10.0.0.1:80", "origin.example.com:8080", "[2001:db8::1]:80"},
+						SRVRecords: []string{"_http._tcp.origin.example.com"},
+					}},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "External backend address without port",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{External: &VarnishClusterBackendExternal{Addresses: []string{"origin.example.com"}}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Empty external backends",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{External: &VarnishClusterBackendExternal{}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Selected backends without port",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{Selector: map[string]string{"app": "web"}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend probe and connection parameters",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Probe: &VarnishClusterBackendProbe{
+							URL: "/health", Interval: "5s", Timeout: "500ms", Window: &window, Threshold: &threshold,
+						},
+						Connection: &VarnishClusterBackendConnection{ConnectTimeout: "1.5s", MaxConnections: &threshold},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Backend probe with both url and request",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Probe:    &VarnishClusterBackendProbe{URL: "/health", Request: []string{"GET /health HTTP/1.1"}},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend probe threshold bigger than the window",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Probe:    &VarnishClusterBackendProbe{Window: &threshold, Threshold: &window},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Backend probe request with double quotes",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Probe:    &VarnishClusterBackendProbe{Request: []string{`GET /"health HTTP/1.1`}},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Invalid backend connection timeout",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector:   map[string]string{"app": "web"},
+						Port:       &backendPort,
+						Connection: &VarnishClusterBackendConnection{FirstByteTimeout: "60"},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Fallback director over backend groups",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Director: &VarnishClusterBackendDirector{
+							Type:     VarnishClusterBackendDirectorTypeFallback,
+							Fallback: &VarnishClusterBackendDirectorFallback{Groups: []string{"dr"}},
+						},
+					},
+					Backends: []VarnishClusterBackendGroup{{
+						Name: "dr",
+						VarnishClusterBackend: VarnishClusterBackend{
+							Selector: map[string]string{"app": "web-dr"},
+							Port:     &backendPort,
+							Director: &VarnishClusterBackendDirector{Type: VarnishClusterBackendDirectorTypeShard},
+						},
+					}},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Fallback director over an undefined backend group",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Director: &VarnishClusterBackendDirector{
+							Type:     VarnishClusterBackendDirectorTypeFallback,
+							Fallback: &VarnishClusterBackendDirectorFallback{Groups: []string{"dr"}},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Fallback director in a backend group",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backends: []VarnishClusterBackendGroup{
+						{Name: "dr", VarnishClusterBackend: VarnishClusterBackend{Selector: map[string]string{"app": "web-dr"}, Port: &backendPort}},
+						{
+							Name: "api",
+							VarnishClusterBackend: VarnishClusterBackend{
+								Selector: map[string]string{"app": "api"},
+								Port:     &backendPort,
+								Director: &VarnishClusterBackendDirector{
+									Type:     VarnishClusterBackendDirectorTypeFallback,
+									Fallback: &VarnishClusterBackendDirectorFallback{Groups: []string{"dr"}},
+								},
+							},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Hash director by cookie",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Director: &VarnishClusterBackendDirector{
+							Type: VarnishClusterBackendDirectorTypeHash,
+							Hash: &VarnishClusterBackendDirectorHash{Cookie: "session_id"},
+						},
+					},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Hash director without header and cookie",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Director: &VarnishClusterBackendDirector{Type: VarnishClusterBackendDirectorTypeHash},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Shard parameters for the round robin director",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Backend: &VarnishClusterBackend{
+						Selector: map[string]string{"app": "web"},
+						Port:     &backendPort,
+						Director: &VarnishClusterBackendDirector{
+							Type:  VarnishClusterBackendDirectorTypeRoundRobin,
+							Shard: &VarnishClusterBackendDirectorShard{Rampup: "30s"},
+						},
+					},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Access log with headers",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Logging: &VarnishClusterLogging{Access: &VarnishClusterAccessLogging{
+						Enabled:       true,
+						Fields:        []string{"method", "url", "status", "req.http.X-Forwarded-For", "resp.http.Cache-Control"},
+						Query:         "RespStatus >= 500",
+						RedactHeaders: []string{"Authorization"},
+					}},
+				},
+			},
+			valid: true,
+		},
+		{
+			name: "Unknown access log field",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Logging: &VarnishClusterLogging{Access: &VarnishClusterAccessLogging{Enabled: true, Fields: []string{"latency"}}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Access log header field with an invalid name",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Logging: &VarnishClusterLogging{Access: &VarnishClusterAccessLogging{Enabled: true, Fields: []string{"req.http.X}i"}}},
+				},
+			},
+			valid: false,
+		},
+		{
+			name: "Access log query with a new line",
+			vc: &VarnishCluster{
+				Spec: VarnishClusterSpec{
+					Logging: &VarnishClusterLogging{Access: &VarnishClusterAccessLogging{Enabled: true, Query: "RespStatus >= 500\nReqURL ~ \"/\""}},
+				},
+			},
+			valid: false,
+		},
 	}
 
 	for _, c := range cases {
+		if !c.noBackends && c.vc.Spec.Backend == nil && len(c.vc.Spec.Backends) == 0 {
+			c.vc.Spec.Backend = &VarnishClusterBackend{Selector: map[string]string{"app": "backend"}, Port: &backendPort}
+		}
+
 		err := c.vc.ValidateCreate()
 		if c.valid != (err == nil) {
 			t.Fatalf("Test %q failed for Create: Expected to be valid: %t, Actual error: %#v", c.name, c.valid, err)
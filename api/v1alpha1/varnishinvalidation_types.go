@@ -0,0 +1,219 @@
+package v1alpha1
+
+// +kubebuilder:validation:Optional
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	VarnishInvalidationPhasePending    = "Pending"
+	VarnishInvalidationPhaseInProgress = "InProgress"
+	VarnishInvalidationPhaseCompleted  = "Completed"
+	VarnishInvalidationPhaseFailed     = "Failed"
+
+	VarnishInvalidationPodPhaseSucceeded = "Succeeded"
+	VarnishInvalidationPodPhaseFailed    = "Failed"
+
+	// SurrogateKeyHeader is the response header the surrogate keys of the objects are read from
+	SurrogateKeyHeader = "Surrogate-Key"
+
+	DefaultInvalidationMaxRetries              int32 = 5
+	DefaultInvalidationTTLSecondsAfterFinished int32 = 86400
+)
+
+var (
+	banConditionRegexp = regexp.MustCompile(`^\s*(\S+)\s*(==|!=|~|!~|<|>)\s*(.*?)\s*$`)
+	// patterns that match any string. Anchors and "match anything" parts are removed before the check
+	broadPatternParts = regexp.MustCompile(`^\^|\$$|\.\*|\.\+`)
+)
+
+// +kubebuilder:object:root=true
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishInvalidation is the Schema for the varnishinvalidations API.
+// It bans the matching objects from the cache of every pod of the referenced VarnishCluster.
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,shortName=vinv
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.varnishCluster`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Expression",type=string,JSONPath=`.status.expression`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VarnishInvalidation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:Required
+	Spec   VarnishInvalidationSpec   `json:"spec"`
+	Status VarnishInvalidationStatus `json:"status,omitempty"`
+}
+
+// VarnishInvalidationSpec defines what is invalidated. Either ban or any combination of url or urlRegex, host and surrogateKeys has to be set.
+type VarnishInvalidationSpec struct {
+	// Name of the VarnishCluster in the same namespace
+	// +kubebuilder:validation:Required
+	VarnishCluster string `json:"varnishCluster"`
+	// Ban is a raw ban expression, e.g. obj.http.Content-Type ~ "^image/". Can't be combined with the other fields
+	Ban string `json:"ban,omitempty"`
+	// URL invalidates the objects with exactly this URL, including the query string
+	URL string `json:"url,omitempty"`
+	// URLRegex invalidates the objects with URLs that match the regular expression
+	URLRegex string `json:"urlRegex,omitempty"`
+	// Host limits the invalidation to the objects requested with this Host header
+	Host string `json:"host,omitempty"`
+	// SurrogateKeys invalidates the objects that have any of the keys in their Surrogate-Key response header
+	SurrogateKeys []string `json:"surrogateKeys,omitempty"`
+	// AllowBroad allows expressions that invalidate the whole cache, e.g. urlRegex: ".*"
+	AllowBroad bool `json:"allowBroad,omitempty"`
+	// How many times a pod retries a failed invalidation. Default: 5
+	// +kubebuilder:validation:Minimum=0
+	MaxRetries *int32 `json:"maxRetries,omitempty"`
+	// The invalidation is deleted after it has been finished for that long. Default: 86400 (a day)
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// VarnishInvalidationStatus defines the observed state of VarnishInvalidation
+type VarnishInvalidationStatus struct {
+	// Pending, InProgress, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+	// Expression is the ban expression executed on the pods
+	Expression string `json:"expression,omitempty"`
+	// Time the invalidation was completed or failed on all pods
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Results of the invalidation on every pod
+	// +listType=map
+	// +listMapKey=name
+	Pods []VarnishInvalidationPodStatus `json:"pods,omitempty"`
+}
+
+// VarnishInvalidationPodStatus is the result of the invalidation on a pod
+type VarnishInvalidationPodStatus struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Succeeded or Failed
+	Phase string `json:"phase,omitempty"`
+	// How many times the invalidation has been executed on the pod
+	Attempts int32 `json:"attempts,omitempty"`
+	// The error of the last attempt
+	Message         string      `json:"message,omitempty"`
+	LastAttemptTime metav1.Time `json:"lastAttemptTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishInvalidationList contains a list of VarnishInvalidation
+type VarnishInvalidationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VarnishInvalidation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VarnishInvalidation{}, &VarnishInvalidationList{})
+}
+
+// Finished returns true if the invalidation has been completed or failed on all pods
+func (in *VarnishInvalidation) Finished() bool {
+	return in.Status.Phase == VarnishInvalidationPhaseCompleted || in.Status.Phase == VarnishInvalidationPhaseFailed
+}
+
+// MaxRetries returns how many times a pod retries a failed invalidation
+func (in *VarnishInvalidation) MaxRetries() int32 {
+	if in.Spec.MaxRetries != nil {
+		return *in.Spec.MaxRetries
+	}
+	return DefaultInvalidationMaxRetries
+}
+
+// TTLSecondsAfterFinished returns how long the invalidation is kept after it has been finished
+func (in *VarnishInvalidation) TTLSecondsAfterFinished() int32 {
+	if in.Spec.TTLSecondsAfterFinished != nil {
+		return *in.Spec.TTLSecondsAfterFinished
+	}
+	return DefaultInvalidationTTLSecondsAfterFinished
+}
+
+// BanExpression returns the ban expression in the format of the varnish CLI ban command.
+// Returns an error if the spec is invalid or the expression invalidates the whole cache and that is not explicitly allowed.
+func (in *VarnishInvalidation) BanExpression() (string, error) {
+	spec := in.Spec
+	if spec.Ban != "" {
+		if spec.URL != "" || spec.URLRegex != "" || spec.Host != "" || len(spec.SurrogateKeys) > 0 {
+			return "", errors.New("ban can't be combined with url, urlRegex, host or surrogateKeys")
+		}
+		if strings.ContainsAny(spec.Ban, "\n\r") {
+			return "", errors.New("ban can't contain new lines")
+		}
+		if !spec.AllowBroad && broadBan(spec.Ban) {
+			return "", errors.Errorf("ban %q invalidates the whole cache. Set allowBroad to allow that", spec.Ban)
+		}
+		return spec.Ban, nil
+	}
+
+	if spec.URL != "" && spec.URLRegex != "" {
+		return "", errors.New("url and urlRegex can't be set at the same time")
+	}
+
+	var conditions []string
+	if spec.URL != "" {
+		conditions = append(conditions, "req.url == "+cliQuote(spec.URL))
+	}
+	if spec.URLRegex != "" {
+		if !spec.AllowBroad && spec.Host == "" && len(spec.SurrogateKeys) == 0 && broadPattern(spec.URLRegex) {
+			return "", errors.Errorf("urlRegex %q invalidates the whole cache. Set allowBroad to allow that", spec.URLRegex)
+		}
+		conditions = append(conditions, "req.url ~ "+cliQuote(spec.URLRegex))
+	}
+	if spec.Host != "" {
+		conditions = append(conditions, "req.http.host == "+cliQuote(spec.Host))
+	}
+	if len(spec.SurrogateKeys) > 0 {
+		keys := make([]string, 0, len(spec.SurrogateKeys))
+		for _, key := range spec.SurrogateKeys {
+			if strings.TrimSpace(key) == "" || strings.ContainsAny(key, " \t\n\r") {
+				return "", errors.Errorf("surrogate key %q can't be empty or contain whitespaces", key)
+			}
+			keys = append(keys, regexp.QuoteMeta(key))
+		}
+		conditions = append(conditions, "obj.http."+SurrogateKeyHeader+" ~ "+cliQuote(`(^|\s)(`+strings.Join(keys, "|")+`)(\s|$)`))
+	}
+
+	if len(conditions) == 0 {
+		return "", errors.New("either ban, url, urlRegex, host or surrogateKeys has to be set")
+	}
+	return strings.Join(conditions, " && "), nil
+}
+
+// broadBan returns true if every condition of the ban expression matches any value
+func broadBan(ban string) bool {
+	for _, condition := range strings.Split(ban, "&&") {
+		match := banConditionRegexp.FindStringSubmatch(condition)
+		if match == nil {
+			return false
+		}
+		operator, argument := match[2], strings.Trim(match[3], `"`)
+		if !(operator == "~" && broadPattern(argument)) && !(operator == "!=" && argument == "") {
+			return false
+		}
+	}
+	return true
+}
+
+// broadPattern returns true if the regular expression matches any string, or any URL
+func broadPattern(pattern string) bool {
+	rest := broadPatternParts.ReplaceAllString(pattern, "")
+	return rest == "" || rest == "." || rest == "/"
+}
+
+// cliQuote quotes a string as an argument of a varnish CLI command
+func cliQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
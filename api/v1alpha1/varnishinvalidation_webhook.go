@@ -0,0 +1,61 @@
+package v1alpha1
+
+import (
+	"reflect"
+
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (in *VarnishInvalidation) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(in).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-caching-ibm-com-v1alpha1-varnishinvalidation,mutating=false,failurePolicy=fail,groups=caching.ibm.com,resources=varnishinvalidations,versions=v1alpha1,name=vvarnishinvalidation.kb.io
+
+var _ webhook.Validator = &VarnishInvalidation{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (in *VarnishInvalidation) ValidateCreate() error {
+	logr := webhookLogger.With(logger.FieldComponent, VarnishComponentValidatingWebhook)
+	logr = logr.With(logger.FieldNamespace, in.Namespace)
+	logr = logr.With("varnishInvalidation", in.Name)
+
+	logr.Debug("Validating webhook has been called on create request")
+	if in.Spec.VarnishCluster == "" {
+		return fieldError(".spec.varnishCluster", errors.New("can't be empty"))
+	}
+	if _, err := in.BanExpression(); err != nil {
+		return fieldError(".spec", err)
+	}
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The spec can't be changed as the invalidation may have already been executed.
+func (in *VarnishInvalidation) ValidateUpdate(old runtime.Object) error {
+	logr := webhookLogger.With(logger.FieldComponent, VarnishComponentValidatingWebhook)
+	logr = logr.With(logger.FieldNamespace, in.Namespace)
+	logr = logr.With("varnishInvalidation", in.Name)
+
+	logr.Debug("Validating webhook has been called on update request")
+	oldInvalidation, ok := old.(*VarnishInvalidation)
+	if !ok {
+		return errors.Errorf("unexpected object type %T", old)
+	}
+	if !reflect.DeepEqual(in.Spec, oldInvalidation.Spec) {
+		return fieldError(".spec", errors.New("is immutable. Create a new VarnishInvalidation instead"))
+	}
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (in *VarnishInvalidation) ValidateDelete() error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestVarnishInvalidationBanExpression(t *testing.T) {
+	cases := []struct {
+		name       string
+		spec       VarnishInvalidationSpec
+		expression string
+		valid      bool
+	}{
+		{
+			name:       "URL",
+			spec:       VarnishInvalidationSpec{URL: "/products/1?color=red"},
+			expression: `req.url == "/products/1?color=red"`,
+			valid:      true,
+		},
+		{
+			name:       "URL regex and host",
+			spec:       VarnishInvalidationSpec{URLRegex: `^/images/.*\.png$`, Host: "shop.example.com"},
+			expression: `req.url ~ "^/images/.*\\.png$" && req.http.host == "shop.example.com"`,
+			valid:      true,
+		},
+		{
+			name:       "Surrogate keys are matched as whole words",
+			spec:       VarnishInvalidationSpec{SurrogateKeys: []string{"product-1", "category.2"}},
+			expression: `obj.http.Surrogate-Key ~ "(^|\\s)(product-1|category\\.2)(\\s|$)"`,
+			valid:      true,
+		},
+		{
+			name:       "Quotes are escaped",
+			spec:       VarnishInvalidationSpec{URL: `/search?q="shoes"`},
+			expression: `req.url == "/search?q=\"shoes\""`,
+			valid:      true,
+		},
+		{
+			name:       "Raw ban",
+			spec:       VarnishInvalidationSpec{Ban: `obj.http.Content-Type ~ "^image/"`},
+			expression: `obj.http.Content-Type ~ "^image/"`,
+			valid:      true,
+		},
+		{
+			name:  "Nothing to invalidate",
+			spec:  VarnishInvalidationSpec{},
+			valid: false,
+		},
+		{
+			name:  "Raw ban combined with other fields",
+			spec:  VarnishInvalidationSpec{Ban: `req.url ~ "^/a"`, Host: "shop.example.com"},
+			valid: false,
+		},
+		{
+			name:  "URL and URL regex",
+			spec:  VarnishInvalidationSpec{URL: "/a", URLRegex: "^/a"},
+			valid: false,
+		},
+		{
+			name:  "Empty surrogate key",
+			spec:  VarnishInvalidationSpec{SurrogateKeys: []string{"a", " "}},
+			valid: false,
+		},
+		{
+			name:  "URL regex matching everything",
+			spec:  VarnishInvalidationSpec{URLRegex: "^/.*"},
+			valid: false,
+		},
+		{
+			name:       "URL regex matching everything limited by host",
+			spec:       VarnishInvalidationSpec{URLRegex: ".*", Host: "shop.example.com"},
+			expression: `req.url ~ ".*" && req.http.host == "shop.example.com"`,
+			valid:      true,
+		},
+		{
+			name:  "Raw ban matching everything",
+			spec:  VarnishInvalidationSpec{Ban: `req.url ~ "." && obj.status != ""`},
+			valid: false,
+		},
+		{
+			name:       "Broad ban explicitly allowed",
+			spec:       VarnishInvalidationSpec{Ban: `req.url ~ "."`, AllowBroad: true},
+			expression: `req.url ~ "."`,
+			valid:      true,
+		},
+	}
+
+	for _, c := range cases {
+		c.spec.VarnishCluster = "varnish"
+		inv := &VarnishInvalidation{Spec: c.spec}
+		expression, err := inv.BanExpression()
+		if c.valid != (err == nil) {
+			t.Fatalf("Test %q failed: Expected to be valid: %t, Actual error: %#v", c.name, c.valid, err)
+		}
+		if expression != c.expression {
+			t.Fatalf("Test %q failed: Expected expression %s, got %s", c.name, c.expression, expression)
+		}
+
+		err = inv.ValidateCreate()
+		if c.valid != (err == nil) {
+			t.Fatalf("Test %q failed for Create: Expected to be valid: %t, Actual error: %#v", c.name, c.valid, err)
+		}
+	}
+}
+
+func TestVarnishInvalidationValidateUpdate(t *testing.T) {
+	old := &VarnishInvalidation{Spec: VarnishInvalidationSpec{VarnishCluster: "varnish", URL: "/a"}}
+
+	updated := old.DeepCopy()
+	updated.Labels = map[string]string{"team": "shop"}
+	if err := updated.ValidateUpdate(old); err != nil {
+		t.Fatalf("Metadata updates should be allowed, got %#v", err)
+	}
+
+	updated.Spec.URL = "/b"
+	if err := updated.ValidateUpdate(old); err == nil {
+		t.Fatal("Spec updates should be rejected")
+	}
+
+	if err := (&VarnishInvalidation{}).ValidateCreate(); err == nil {
+		t.Fatal("Invalidation without a VarnishCluster should be rejected")
+	}
+}
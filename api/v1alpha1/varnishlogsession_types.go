@@ -0,0 +1,166 @@
+package v1alpha1
+
+// +kubebuilder:validation:Optional
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	VarnishLogSessionPhasePending   = "Pending"
+	VarnishLogSessionPhaseRunning   = "Running"
+	VarnishLogSessionPhaseCompleted = "Completed"
+	VarnishLogSessionPhaseFailed    = "Failed"
+
+	VarnishLogSessionPodPhaseCapturing = "Capturing"
+	VarnishLogSessionPodPhaseCompleted = "Completed"
+	VarnishLogSessionPodPhaseFailed    = "Failed"
+
+	// VarnishLogSessionDataKey is the ConfigMap key the captured transactions are stored under
+	VarnishLogSessionDataKey = "varnishlog.txt"
+	// LabelVarnishLogSession is set on the ConfigMaps with the captured transactions
+	LabelVarnishLogSession = "caching.ibm.com/varnish-log-session"
+
+	DefaultLogSessionDurationSeconds         int32 = 60
+	DefaultLogSessionMaxSizeBytes            int32 = 256 * 1024
+	DefaultLogSessionTTLSecondsAfterFinished int32 = 86400
+)
+
+// +kubebuilder:object:root=true
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishLogSession is the Schema for the varnishlogsessions API.
+// It captures the varnishlog transactions of the pods of the referenced VarnishCluster for a limited time.
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,shortName=vlog
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.varnishCluster`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Query",type=string,JSONPath=`.spec.query`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VarnishLogSession struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:Required
+	Spec   VarnishLogSessionSpec   `json:"spec"`
+	Status VarnishLogSessionStatus `json:"status,omitempty"`
+}
+
+// VarnishLogSessionSpec defines what is captured and for how long
+type VarnishLogSessionSpec struct {
+	// Name of the VarnishCluster in the same namespace
+	// +kubebuilder:validation:Required
+	VarnishCluster string `json:"varnishCluster"`
+	// Names of the pods to capture the transactions on. All pods of the VarnishCluster if empty
+	Pods []string `json:"pods,omitempty"`
+	// Query is a VSL query the captured transactions are filtered by, e.g. RespStatus >= 500
+	Query string `json:"query,omitempty"`
+	// How long the transactions are captured, counted from the creation of the session. Default: 60
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=3600
+	DurationSeconds *int32 `json:"durationSeconds,omitempty"`
+	// The maximum size of the transactions captured on a pod. The capture stops when it is reached. Default: 262144 (256KiB)
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=921600
+	MaxSizeBytes *int32 `json:"maxSizeBytes,omitempty"`
+	// The session and the captured transactions are deleted after it has been finished for that long. Default: 86400 (a day)
+	// +kubebuilder:validation:Minimum=0
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// VarnishLogSessionStatus defines the observed state of VarnishLogSession
+type VarnishLogSessionStatus struct {
+	// Pending, Running, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+	// Time the capture was finished on all pods
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Progress of the capture on every pod
+	// +listType=map
+	// +listMapKey=name
+	Pods []VarnishLogSessionPodStatus `json:"pods,omitempty"`
+}
+
+// VarnishLogSessionPodStatus is the progress of the capture on a pod
+type VarnishLogSessionPodStatus struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Capturing, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+	// Number of the captured transactions
+	Transactions int32 `json:"transactions,omitempty"`
+	// Size of the captured transactions in bytes
+	Bytes int32 `json:"bytes,omitempty"`
+	// Truncated is true if the capture was stopped because maxSizeBytes was reached
+	Truncated bool `json:"truncated,omitempty"`
+	// The ConfigMap the captured transactions are stored in
+	ConfigMap string `json:"configMap,omitempty"`
+	// The reason of the failure
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishLogSessionList contains a list of VarnishLogSession
+type VarnishLogSessionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VarnishLogSession `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VarnishLogSession{}, &VarnishLogSessionList{})
+}
+
+// Finished returns true if the capture has been completed or failed on all pods
+func (in *VarnishLogSession) Finished() bool {
+	return in.Status.Phase == VarnishLogSessionPhaseCompleted || in.Status.Phase == VarnishLogSessionPhaseFailed
+}
+
+// Duration returns how long the transactions are captured
+func (in *VarnishLogSession) Duration() time.Duration {
+	if in.Spec.DurationSeconds != nil {
+		return time.Duration(*in.Spec.DurationSeconds) * time.Second
+	}
+	return time.Duration(DefaultLogSessionDurationSeconds) * time.Second
+}
+
+// WindowEnd returns the time the capture ends on all pods
+func (in *VarnishLogSession) WindowEnd() time.Time {
+	return in.CreationTimestamp.Add(in.Duration())
+}
+
+// MaxSizeBytes returns the maximum size of the transactions captured on a pod
+func (in *VarnishLogSession) MaxSizeBytes() int32 {
+	if in.Spec.MaxSizeBytes != nil {
+		return *in.Spec.MaxSizeBytes
+	}
+	return DefaultLogSessionMaxSizeBytes
+}
+
+// TTLSecondsAfterFinished returns how long the session is kept after it has been finished
+func (in *VarnishLogSession) TTLSecondsAfterFinished() int32 {
+	if in.Spec.TTLSecondsAfterFinished != nil {
+		return *in.Spec.TTLSecondsAfterFinished
+	}
+	return DefaultLogSessionTTLSecondsAfterFinished
+}
+
+// TargetsPod returns true if the transactions are captured on the pod
+func (in *VarnishLogSession) TargetsPod(podName string) bool {
+	if len(in.Spec.Pods) == 0 {
+		return true
+	}
+	for _, name := range in.Spec.Pods {
+		if name == podName {
+			return true
+		}
+	}
+	return false
+}
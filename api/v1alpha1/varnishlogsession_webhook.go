@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+func (in *VarnishLogSession) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(in).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-caching-ibm-com-v1alpha1-varnishlogsession,mutating=false,failurePolicy=fail,groups=caching.ibm.com,resources=varnishlogsessions,versions=v1alpha1,name=vvarnishlogsession.kb.io
+
+var _ webhook.Validator = &VarnishLogSession{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (in *VarnishLogSession) ValidateCreate() error {
+	logr := webhookLogger.With(logger.FieldComponent, VarnishComponentValidatingWebhook)
+	logr = logr.With(logger.FieldNamespace, in.Namespace)
+	logr = logr.With("varnishLogSession", in.Name)
+
+	logr.Debug("Validating webhook has been called on create request")
+	if in.Spec.VarnishCluster == "" {
+		return fieldError(".spec.varnishCluster", errors.New("can't be empty"))
+	}
+	for _, pod := range in.Spec.Pods {
+		if errs := validation.IsDNS1123Subdomain(pod); len(errs) > 0 {
+			return fieldError(".spec.pods", errors.Errorf("%q is not a valid pod name: %s", pod, strings.Join(errs, ", ")))
+		}
+	}
+	if strings.ContainsAny(in.Spec.Query, "\n\r") {
+		return fieldError(".spec.query", errors.New("can't contain new lines"))
+	}
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+// The spec can't be changed as the capture may have already been started.
+func (in *VarnishLogSession) ValidateUpdate(old runtime.Object) error {
+	logr := webhookLogger.With(logger.FieldComponent, VarnishComponentValidatingWebhook)
+	logr = logr.With(logger.FieldNamespace, in.Namespace)
+	logr = logr.With("varnishLogSession", in.Name)
+
+	logr.Debug("Validating webhook has been called on update request")
+	oldSession, ok := old.(*VarnishLogSession)
+	if !ok {
+		return errors.Errorf("unexpected object type %T", old)
+	}
+	if !reflect.DeepEqual(in.Spec, oldSession.Spec) {
+		return fieldError(".spec", errors.New("is immutable. Create a new VarnishLogSession instead"))
+	}
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (in *VarnishLogSession) ValidateDelete() error {
+	return nil
+}
@@ -0,0 +1,86 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVarnishLogSessionValidateCreate(t *testing.T) {
+	cases := []struct {
+		name  string
+		spec  VarnishLogSessionSpec
+		valid bool
+	}{
+		{
+			name:  "All pods",
+			spec:  VarnishLogSessionSpec{VarnishCluster: "varnish", Query: "RespStatus >= 500"},
+			valid: true,
+		},
+		{
+			name:  "Selected pods",
+			spec:  VarnishLogSessionSpec{VarnishCluster: "varnish", Pods: []string{"varnish-varnish-0"}},
+			valid: true,
+		},
+		{
+			name:  "No VarnishCluster",
+			spec:  VarnishLogSessionSpec{},
+			valid: false,
+		},
+		{
+			name:  "Invalid pod name",
+			spec:  VarnishLogSessionSpec{VarnishCluster: "varnish", Pods: []string{"Varnish_0"}},
+			valid: false,
+		},
+		{
+			name:  "Query with new lines",
+			spec:  VarnishLogSessionSpec{VarnishCluster: "varnish", Query: "RespStatus >= 500\nReqURL ~ /"},
+			valid: false,
+		},
+	}
+
+	for _, c := range cases {
+		err := (&VarnishLogSession{Spec: c.spec}).ValidateCreate()
+		if c.valid != (err == nil) {
+			t.Fatalf("Test %q failed: Expected to be valid: %t, Actual error: %#v", c.name, c.valid, err)
+		}
+	}
+}
+
+func TestVarnishLogSessionValidateUpdate(t *testing.T) {
+	old := &VarnishLogSession{Spec: VarnishLogSessionSpec{VarnishCluster: "varnish"}}
+
+	updated := old.DeepCopy()
+	updated.Labels = map[string]string{"incident": "1234"}
+	if err := updated.ValidateUpdate(old); err != nil {
+		t.Fatalf("Metadata updates should be allowed, got %#v", err)
+	}
+
+	updated.Spec.Query = "RespStatus >= 500"
+	if err := updated.ValidateUpdate(old); err == nil {
+		t.Fatal("Spec updates should be rejected")
+	}
+}
+
+func TestVarnishLogSessionWindow(t *testing.T) {
+	created := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	session := &VarnishLogSession{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)}}
+	if end := session.WindowEnd(); !end.Equal(created.Add(time.Minute)) {
+		t.Fatalf("Expected the default window to end a minute after the creation, got %s", end)
+	}
+
+	duration := int32(600)
+	session.Spec.DurationSeconds = &duration
+	if end := session.WindowEnd(); !end.Equal(created.Add(10 * time.Minute)) {
+		t.Fatalf("Expected the window to end 10 minutes after the creation, got %s", end)
+	}
+
+	if !session.TargetsPod("varnish-0") {
+		t.Fatal("All pods should be targeted if no pods are set")
+	}
+	session.Spec.Pods = []string{"varnish-1"}
+	if session.TargetsPod("varnish-0") || !session.TargetsPod("varnish-1") {
+		t.Fatal("Only the listed pods should be targeted")
+	}
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+// +kubebuilder:validation:Optional
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishSite is the Schema for the varnishsites API.
+// A site has its own VCL that is loaded into the pods of the referenced VarnishCluster
+// and serves the requests for the site hostnames.
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:scope=Namespaced,shortName=vs
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type=string,JSONPath=`.spec.varnishCluster.name`
+// +kubebuilder:printcolumn:name="Hostnames",type=string,JSONPath=`.spec.hostnames`
+// +kubebuilder:printcolumn:name="Accepted",type=string,JSONPath=`.status.conditions[?(@.type=="Accepted")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VarnishSite struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:Required
+	Spec   VarnishSiteSpec   `json:"spec"`
+	Status VarnishSiteStatus `json:"status,omitempty"`
+}
+
+const (
+	// VarnishSiteConditionAccepted is true if the VarnishCluster serves the site
+	VarnishSiteConditionAccepted = "Accepted"
+)
+
+// VarnishSiteSpec defines the desired state of VarnishSite
+type VarnishSiteSpec struct {
+	// +kubebuilder:validation:Required
+	VarnishCluster VarnishSiteClusterRef `json:"varnishCluster"`
+	// Hostnames the site serves requests for. Matched against the Host header. A leading `*.` matches any subdomain.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Hostnames []VarnishSiteHostname `json:"hostnames"`
+	// +kubebuilder:validation:Required
+	VCL VarnishSiteVCL `json:"vcl"`
+}
+
+// +kubebuilder:validation:Pattern=`^(\*\.)?[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+type VarnishSiteHostname string
+
+// VarnishSiteClusterRef references the VarnishCluster the site is served by
+type VarnishSiteClusterRef struct {
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+	// Namespace of the VarnishCluster. Defaults to the namespace of the VarnishSite.
+	// The namespace of the VarnishSite has to be allowed in the VarnishCluster `.spec.sites.allowedNamespaces` if it differs
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// VarnishSiteStatus defines the observed state of VarnishSite
+type VarnishSiteStatus struct {
+	// Conditions of the site. The `Accepted` condition tells if the VarnishCluster serves the site, or why not
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type VarnishSiteVCL struct {
+	// Name of the ConfigMap in the VarnishSite namespace that contains the site VCL files
+	// +kubebuilder:validation:Required
+	ConfigMapName string `json:"configMapName"`
+	// +kubebuilder:validation:Required
+	EntrypointFileName string `json:"entrypointFileName"`
+}
+
+// +kubebuilder:object:root=true
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VarnishSiteList contains a list of VarnishSite
+type VarnishSiteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VarnishSite `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VarnishSite{}, &VarnishSiteList{})
+}
+
+// ClusterNamespace returns the namespace of the VarnishCluster the site references
+func (in *VarnishSite) ClusterNamespace() string {
+	if in.Spec.VarnishCluster.Namespace != "" {
+		return in.Spec.VarnishCluster.Namespace
+	}
+	return in.Namespace
+}
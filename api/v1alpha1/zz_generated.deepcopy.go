@@ -9,6 +9,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -74,6 +75,70 @@ func (in *UpdateStrategyDelayedRollingUpdate) DeepCopy() *UpdateStrategyDelayedR
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VCLCompilationErrorStatus) DeepCopyInto(out *VCLCompilationErrorStatus) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = make([]VCLDiagnostic, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCLCompilationErrorStatus.
+func (in *VCLCompilationErrorStatus) DeepCopy() *VCLCompilationErrorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VCLCompilationErrorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VCLDiagnostic) DeepCopyInto(out *VCLDiagnostic) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCLDiagnostic.
+func (in *VCLDiagnostic) DeepCopy() *VCLDiagnostic {
+	if in == nil {
+		return nil
+	}
+	out := new(VCLDiagnostic)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VCLRolloutStatus) DeepCopyInto(out *VCLRolloutStatus) {
+	*out = *in
+	if in.CanaryPods != nil {
+		in, out := &in.CanaryPods, &out.CanaryPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BakeStartTime != nil {
+		in, out := &in.BakeStartTime, &out.BakeStartTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCLRolloutStatus.
+func (in *VCLRolloutStatus) DeepCopy() *VCLRolloutStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VCLRolloutStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VCLStatus) DeepCopyInto(out *VCLStatus) {
 	*out = *in
@@ -82,6 +147,21 @@ func (in *VCLStatus) DeepCopyInto(out *VCLStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Validation != nil {
+		in, out := &in.Validation, &out.Validation
+		*out = new(VCLValidationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollout != nil {
+		in, out := &in.Rollout, &out.Rollout
+		*out = new(VCLRolloutStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CompilationError != nil {
+		in, out := &in.CompilationError, &out.CompilationError
+		*out = new(VCLCompilationErrorStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCLStatus.
@@ -94,6 +174,26 @@ func (in *VCLStatus) DeepCopy() *VCLStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VCLValidationStatus) DeepCopyInto(out *VCLValidationStatus) {
+	*out = *in
+	if in.Diagnostics != nil {
+		in, out := &in.Diagnostics, &out.Diagnostics
+		*out = make([]VCLDiagnostic, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VCLValidationStatus.
+func (in *VCLValidationStatus) DeepCopy() *VCLValidationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VCLValidationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VarnishCluster) DeepCopyInto(out *VarnishCluster) {
 	*out = *in
@@ -121,6 +221,36 @@ func (in *VarnishCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterAccessLogging) DeepCopyInto(out *VarnishClusterAccessLogging) {
+	*out = *in
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SamplingPercent != nil {
+		in, out := &in.SamplingPercent, &out.SamplingPercent
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RedactHeaders != nil {
+		in, out := &in.RedactHeaders, &out.RedactHeaders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterAccessLogging.
+func (in *VarnishClusterAccessLogging) DeepCopy() *VarnishClusterAccessLogging {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterAccessLogging)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VarnishClusterBackend) DeepCopyInto(out *VarnishClusterBackend) {
 	*out = *in
@@ -131,6 +261,16 @@ func (in *VarnishClusterBackend) DeepCopyInto(out *VarnishClusterBackend) {
 			(*out)[key] = val
 		}
 	}
+	if in.ServiceRef != nil {
+		in, out := &in.ServiceRef, &out.ServiceRef
+		*out = new(VarnishClusterBackendServiceRef)
+		**out = **in
+	}
+	if in.External != nil {
+		in, out := &in.External, &out.External
+		*out = new(VarnishClusterBackendExternal)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Port != nil {
 		in, out := &in.Port, &out.Port
 		*out = new(intstr.IntOrString)
@@ -146,6 +286,21 @@ func (in *VarnishClusterBackend) DeepCopyInto(out *VarnishClusterBackend) {
 		*out = new(VarnishClusterBackendZoneBalancing)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Probe != nil {
+		in, out := &in.Probe, &out.Probe
+		*out = new(VarnishClusterBackendProbe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Connection != nil {
+		in, out := &in.Connection, &out.Connection
+		*out = new(VarnishClusterBackendConnection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Director != nil {
+		in, out := &in.Director, &out.Director
+		*out = new(VarnishClusterBackendDirector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackend.
@@ -159,215 +314,481 @@ func (in *VarnishClusterBackend) DeepCopy() *VarnishClusterBackend {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterBackendZoneBalancing) DeepCopyInto(out *VarnishClusterBackendZoneBalancing) {
+func (in *VarnishClusterBackendConnection) DeepCopyInto(out *VarnishClusterBackendConnection) {
 	*out = *in
-	if in.Thresholds != nil {
-		in, out := &in.Thresholds, &out.Thresholds
-		*out = make([]VarnishClusterBackendZoneBalancingThreshold, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.MaxConnections != nil {
+		in, out := &in.MaxConnections, &out.MaxConnections
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendZoneBalancing.
-func (in *VarnishClusterBackendZoneBalancing) DeepCopy() *VarnishClusterBackendZoneBalancing {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendConnection.
+func (in *VarnishClusterBackendConnection) DeepCopy() *VarnishClusterBackendConnection {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterBackendZoneBalancing)
+	out := new(VarnishClusterBackendConnection)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterBackendZoneBalancingThreshold) DeepCopyInto(out *VarnishClusterBackendZoneBalancingThreshold) {
+func (in *VarnishClusterBackendDirector) DeepCopyInto(out *VarnishClusterBackendDirector) {
 	*out = *in
-	if in.Local != nil {
-		in, out := &in.Local, &out.Local
-		*out = new(int)
+	if in.Hash != nil {
+		in, out := &in.Hash, &out.Hash
+		*out = new(VarnishClusterBackendDirectorHash)
 		**out = **in
 	}
-	if in.Remote != nil {
-		in, out := &in.Remote, &out.Remote
-		*out = new(int)
-		**out = **in
+	if in.Shard != nil {
+		in, out := &in.Shard, &out.Shard
+		*out = new(VarnishClusterBackendDirectorShard)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Threshold != nil {
-		in, out := &in.Threshold, &out.Threshold
-		*out = new(int)
-		**out = **in
+	if in.Fallback != nil {
+		in, out := &in.Fallback, &out.Fallback
+		*out = new(VarnishClusterBackendDirectorFallback)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendZoneBalancingThreshold.
-func (in *VarnishClusterBackendZoneBalancingThreshold) DeepCopy() *VarnishClusterBackendZoneBalancingThreshold {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendDirector.
+func (in *VarnishClusterBackendDirector) DeepCopy() *VarnishClusterBackendDirector {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterBackendZoneBalancingThreshold)
+	out := new(VarnishClusterBackendDirector)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterList) DeepCopyInto(out *VarnishClusterList) {
+func (in *VarnishClusterBackendDirectorFallback) DeepCopyInto(out *VarnishClusterBackendDirectorFallback) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]VarnishCluster, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterList.
-func (in *VarnishClusterList) DeepCopy() *VarnishClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendDirectorFallback.
+func (in *VarnishClusterBackendDirectorFallback) DeepCopy() *VarnishClusterBackendDirectorFallback {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterList)
+	out := new(VarnishClusterBackendDirectorFallback)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *VarnishClusterList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterBackendDirectorHash) DeepCopyInto(out *VarnishClusterBackendDirectorHash) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendDirectorHash.
+func (in *VarnishClusterBackendDirectorHash) DeepCopy() *VarnishClusterBackendDirectorHash {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(VarnishClusterBackendDirectorHash)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterMonitoring) DeepCopyInto(out *VarnishClusterMonitoring) {
+func (in *VarnishClusterBackendDirectorShard) DeepCopyInto(out *VarnishClusterBackendDirectorShard) {
 	*out = *in
-	if in.PrometheusServiceMonitor != nil {
-		in, out := &in.PrometheusServiceMonitor, &out.PrometheusServiceMonitor
-		*out = new(VarnishClusterMonitoringPrometheusServiceMonitor)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.GrafanaDashboard != nil {
-		in, out := &in.GrafanaDashboard, &out.GrafanaDashboard
-		*out = new(VarnishClusterMonitoringGrafanaDashboard)
-		(*in).DeepCopyInto(*out)
+	if in.WarmupPercent != nil {
+		in, out := &in.WarmupPercent, &out.WarmupPercent
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoring.
-func (in *VarnishClusterMonitoring) DeepCopy() *VarnishClusterMonitoring {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendDirectorShard.
+func (in *VarnishClusterBackendDirectorShard) DeepCopy() *VarnishClusterBackendDirectorShard {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterMonitoring)
+	out := new(VarnishClusterBackendDirectorShard)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterMonitoringGrafanaDashboard) DeepCopyInto(out *VarnishClusterMonitoringGrafanaDashboard) {
+func (in *VarnishClusterBackendExternal) DeepCopyInto(out *VarnishClusterBackendExternal) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.DatasourceName != nil {
-		in, out := &in.DatasourceName, &out.DatasourceName
-		*out = new(string)
+	if in.SRVRecords != nil {
+		in, out := &in.SRVRecords, &out.SRVRecords
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExternalNameServices != nil {
+		in, out := &in.ExternalNameServices, &out.ExternalNameServices
+		*out = make([]VarnishClusterBackendServiceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.ResolveIntervalSeconds != nil {
+		in, out := &in.ResolveIntervalSeconds, &out.ResolveIntervalSeconds
+		*out = new(int32)
 		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoringGrafanaDashboard.
-func (in *VarnishClusterMonitoringGrafanaDashboard) DeepCopy() *VarnishClusterMonitoringGrafanaDashboard {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendExternal.
+func (in *VarnishClusterBackendExternal) DeepCopy() *VarnishClusterBackendExternal {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterMonitoringGrafanaDashboard)
+	out := new(VarnishClusterBackendExternal)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterMonitoringPrometheusServiceMonitor) DeepCopyInto(out *VarnishClusterMonitoringPrometheusServiceMonitor) {
+func (in *VarnishClusterBackendGroup) DeepCopyInto(out *VarnishClusterBackendGroup) {
 	*out = *in
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
+	in.VarnishClusterBackend.DeepCopyInto(&out.VarnishClusterBackend)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoringPrometheusServiceMonitor.
-func (in *VarnishClusterMonitoringPrometheusServiceMonitor) DeepCopy() *VarnishClusterMonitoringPrometheusServiceMonitor {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendGroup.
+func (in *VarnishClusterBackendGroup) DeepCopy() *VarnishClusterBackendGroup {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterMonitoringPrometheusServiceMonitor)
+	out := new(VarnishClusterBackendGroup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterService) DeepCopyInto(out *VarnishClusterService) {
+func (in *VarnishClusterBackendProbe) DeepCopyInto(out *VarnishClusterBackendProbe) {
 	*out = *in
-	if in.Port != nil {
-		in, out := &in.Port, &out.Port
+	if in.Request != nil {
+		in, out := &in.Request, &out.Request
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
 		*out = new(int32)
 		**out = **in
 	}
-	if in.MetricsPort != nil {
-		in, out := &in.MetricsPort, &out.MetricsPort
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
 		*out = new(int32)
 		**out = **in
 	}
-	if in.Annotations != nil {
-		in, out := &in.Annotations, &out.Annotations
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Initial != nil {
+		in, out := &in.Initial, &out.Initial
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ExpectedResponse != nil {
+		in, out := &in.ExpectedResponse, &out.ExpectedResponse
+		*out = new(int32)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterService.
-func (in *VarnishClusterService) DeepCopy() *VarnishClusterService {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendProbe.
+func (in *VarnishClusterBackendProbe) DeepCopy() *VarnishClusterBackendProbe {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterService)
+	out := new(VarnishClusterBackendProbe)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterSpec) DeepCopyInto(out *VarnishClusterSpec) {
+func (in *VarnishClusterBackendServiceRef) DeepCopyInto(out *VarnishClusterBackendServiceRef) {
 	*out = *in
-	if in.Replicas != nil {
-		in, out := &in.Replicas, &out.Replicas
-		*out = new(int32)
-		**out = **in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendServiceRef.
+func (in *VarnishClusterBackendServiceRef) DeepCopy() *VarnishClusterBackendServiceRef {
+	if in == nil {
+		return nil
 	}
-	if in.UpdateStrategy != nil {
-		in, out := &in.UpdateStrategy, &out.UpdateStrategy
-		*out = new(VarnishClusterUpdateStrategy)
-		(*in).DeepCopyInto(*out)
+	out := new(VarnishClusterBackendServiceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterBackendZoneBalancing) DeepCopyInto(out *VarnishClusterBackendZoneBalancing) {
+	*out = *in
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = make([]VarnishClusterBackendZoneBalancingThreshold, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
-	if in.Varnish != nil {
-		in, out := &in.Varnish, &out.Varnish
-		*out = new(VarnishClusterVarnish)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendZoneBalancing.
+func (in *VarnishClusterBackendZoneBalancing) DeepCopy() *VarnishClusterBackendZoneBalancing {
+	if in == nil {
+		return nil
 	}
-	if in.VCL != nil {
-		in, out := &in.VCL, &out.VCL
+	out := new(VarnishClusterBackendZoneBalancing)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterBackendZoneBalancingThreshold) DeepCopyInto(out *VarnishClusterBackendZoneBalancingThreshold) {
+	*out = *in
+	if in.Local != nil {
+		in, out := &in.Local, &out.Local
+		*out = new(int)
+		**out = **in
+	}
+	if in.Remote != nil {
+		in, out := &in.Remote, &out.Remote
+		*out = new(int)
+		**out = **in
+	}
+	if in.Threshold != nil {
+		in, out := &in.Threshold, &out.Threshold
+		*out = new(int)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterBackendZoneBalancingThreshold.
+func (in *VarnishClusterBackendZoneBalancingThreshold) DeepCopy() *VarnishClusterBackendZoneBalancingThreshold {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterBackendZoneBalancingThreshold)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterClustering) DeepCopyInto(out *VarnishClusterClustering) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterClustering.
+func (in *VarnishClusterClustering) DeepCopy() *VarnishClusterClustering {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterClustering)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterList) DeepCopyInto(out *VarnishClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VarnishCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterList.
+func (in *VarnishClusterList) DeepCopy() *VarnishClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterLogging) DeepCopyInto(out *VarnishClusterLogging) {
+	*out = *in
+	if in.Access != nil {
+		in, out := &in.Access, &out.Access
+		*out = new(VarnishClusterAccessLogging)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterLogging.
+func (in *VarnishClusterLogging) DeepCopy() *VarnishClusterLogging {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterLogging)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterMonitoring) DeepCopyInto(out *VarnishClusterMonitoring) {
+	*out = *in
+	if in.PrometheusServiceMonitor != nil {
+		in, out := &in.PrometheusServiceMonitor, &out.PrometheusServiceMonitor
+		*out = new(VarnishClusterMonitoringPrometheusServiceMonitor)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GrafanaDashboard != nil {
+		in, out := &in.GrafanaDashboard, &out.GrafanaDashboard
+		*out = new(VarnishClusterMonitoringGrafanaDashboard)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoring.
+func (in *VarnishClusterMonitoring) DeepCopy() *VarnishClusterMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterMonitoringGrafanaDashboard) DeepCopyInto(out *VarnishClusterMonitoringGrafanaDashboard) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DatasourceName != nil {
+		in, out := &in.DatasourceName, &out.DatasourceName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoringGrafanaDashboard.
+func (in *VarnishClusterMonitoringGrafanaDashboard) DeepCopy() *VarnishClusterMonitoringGrafanaDashboard {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterMonitoringGrafanaDashboard)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterMonitoringPrometheusServiceMonitor) DeepCopyInto(out *VarnishClusterMonitoringPrometheusServiceMonitor) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterMonitoringPrometheusServiceMonitor.
+func (in *VarnishClusterMonitoringPrometheusServiceMonitor) DeepCopy() *VarnishClusterMonitoringPrometheusServiceMonitor {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterMonitoringPrometheusServiceMonitor)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterService) DeepCopyInto(out *VarnishClusterService) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MetricsPort != nil {
+		in, out := &in.MetricsPort, &out.MetricsPort
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterService.
+func (in *VarnishClusterService) DeepCopy() *VarnishClusterService {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterSites) DeepCopyInto(out *VarnishClusterSites) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterSites.
+func (in *VarnishClusterSites) DeepCopy() *VarnishClusterSites {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterSites)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterSpec) DeepCopyInto(out *VarnishClusterSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.UpdateStrategy != nil {
+		in, out := &in.UpdateStrategy, &out.UpdateStrategy
+		*out = new(VarnishClusterUpdateStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Varnish != nil {
+		in, out := &in.Varnish, &out.Varnish
+		*out = new(VarnishClusterVarnish)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VCL != nil {
+		in, out := &in.VCL, &out.VCL
 		*out = new(VarnishClusterVCL)
 		(*in).DeepCopyInto(*out)
 	}
@@ -376,6 +797,23 @@ func (in *VarnishClusterSpec) DeepCopyInto(out *VarnishClusterSpec) {
 		*out = new(VarnishClusterBackend)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Backends != nil {
+		in, out := &in.Backends, &out.Backends
+		*out = make([]VarnishClusterBackendGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Clustering != nil {
+		in, out := &in.Clustering, &out.Clustering
+		*out = new(VarnishClusterClustering)
+		**out = **in
+	}
+	if in.Sites != nil {
+		in, out := &in.Sites, &out.Sites
+		*out = new(VarnishClusterSites)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Service != nil {
 		in, out := &in.Service, &out.Service
 		*out = new(VarnishClusterService)
@@ -393,229 +831,865 @@ func (in *VarnishClusterSpec) DeepCopyInto(out *VarnishClusterSpec) {
 			(*out)[key] = val
 		}
 	}
-	if in.Affinity != nil {
-		in, out := &in.Affinity, &out.Affinity
-		*out = new(corev1.Affinity)
-		(*in).DeepCopyInto(*out)
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(corev1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(VarnishClusterMonitoring)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logging != nil {
+		in, out := &in.Logging, &out.Logging
+		*out = new(VarnishClusterLogging)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterSpec.
+func (in *VarnishClusterSpec) DeepCopy() *VarnishClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterStatus) DeepCopyInto(out *VarnishClusterStatus) {
+	*out = *in
+	in.VCL.DeepCopyInto(&out.VCL)
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make([]VarnishParameterStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]VarnishPodStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterStatus.
+func (in *VarnishClusterStatus) DeepCopy() *VarnishClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterUpdateStrategy) DeepCopyInto(out *VarnishClusterUpdateStrategy) {
+	*out = *in
+	if in.RollingUpdate != nil {
+		in, out := &in.RollingUpdate, &out.RollingUpdate
+		*out = new(appsv1.RollingUpdateStatefulSetStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DelayedRollingUpdate != nil {
+		in, out := &in.DelayedRollingUpdate, &out.DelayedRollingUpdate
+		*out = new(UpdateStrategyDelayedRollingUpdate)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterUpdateStrategy.
+func (in *VarnishClusterUpdateStrategy) DeepCopy() *VarnishClusterUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCL) DeepCopyInto(out *VarnishClusterVCL) {
+	*out = *in
+	if in.ConfigMapName != nil {
+		in, out := &in.ConfigMapName, &out.ConfigMapName
+		*out = new(string)
+		**out = **in
+	}
+	if in.EntrypointFileName != nil {
+		in, out := &in.EntrypointFileName, &out.EntrypointFileName
+		*out = new(string)
+		**out = **in
+	}
+	if in.RolloutStrategy != nil {
+		in, out := &in.RolloutStrategy, &out.RolloutStrategy
+		*out = new(VarnishClusterVCLRolloutStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Fallback != nil {
+		in, out := &in.Fallback, &out.Fallback
+		*out = new(VarnishClusterVCLFallback)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Drift != nil {
+		in, out := &in.Drift, &out.Drift
+		*out = new(VarnishClusterVCLDrift)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Reload != nil {
+		in, out := &in.Reload, &out.Reload
+		*out = new(VarnishClusterVCLReload)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCL.
+func (in *VarnishClusterVCL) DeepCopy() *VarnishClusterVCL {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCL)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCLCanaryRollout) DeepCopyInto(out *VarnishClusterVCLCanaryRollout) {
+	*out = *in
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.BakeTimeSeconds != nil {
+		in, out := &in.BakeTimeSeconds, &out.BakeTimeSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxErrorRatePercent != nil {
+		in, out := &in.MaxErrorRatePercent, &out.MaxErrorRatePercent
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCLCanaryRollout.
+func (in *VarnishClusterVCLCanaryRollout) DeepCopy() *VarnishClusterVCLCanaryRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCLCanaryRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCLDrift) DeepCopyInto(out *VarnishClusterVCLDrift) {
+	*out = *in
+	if in.ThresholdSeconds != nil {
+		in, out := &in.ThresholdSeconds, &out.ThresholdSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCLDrift.
+func (in *VarnishClusterVCLDrift) DeepCopy() *VarnishClusterVCLDrift {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCLDrift)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCLFallback) DeepCopyInto(out *VarnishClusterVCLFallback) {
+	*out = *in
+	if in.Count != nil {
+		in, out := &in.Count, &out.Count
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCLFallback.
+func (in *VarnishClusterVCLFallback) DeepCopy() *VarnishClusterVCLFallback {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCLFallback)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCLReload) DeepCopyInto(out *VarnishClusterVCLReload) {
+	*out = *in
+	if in.DebounceSeconds != nil {
+		in, out := &in.DebounceSeconds, &out.DebounceSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxDelaySeconds != nil {
+		in, out := &in.MaxDelaySeconds, &out.MaxDelaySeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinIntervalSeconds != nil {
+		in, out := &in.MinIntervalSeconds, &out.MinIntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCLReload.
+func (in *VarnishClusterVCLReload) DeepCopy() *VarnishClusterVCLReload {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCLReload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVCLRolloutStrategy) DeepCopyInto(out *VarnishClusterVCLRolloutStrategy) {
+	*out = *in
+	if in.Canary != nil {
+		in, out := &in.Canary, &out.Canary
+		*out = new(VarnishClusterVCLCanaryRollout)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCLRolloutStrategy.
+func (in *VarnishClusterVCLRolloutStrategy) DeepCopy() *VarnishClusterVCLRolloutStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVCLRolloutStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVarnish) DeepCopyInto(out *VarnishClusterVarnish) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Controller != nil {
+		in, out := &in.Controller, &out.Controller
+		*out = new(VarnishClusterVarnishController)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MetricsExporter != nil {
+		in, out := &in.MetricsExporter, &out.MetricsExporter
+		*out = new(VarnishClusterVarnishMetricsExporter)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(VarnishClusterVarnishSecret)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]corev1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraInitContainers != nil {
+		in, out := &in.ExtraInitContainers, &out.ExtraInitContainers
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumeClaimTemplates != nil {
+		in, out := &in.ExtraVolumeClaimTemplates, &out.ExtraVolumeClaimTemplates
+		*out = make([]PVC, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumes != nil {
+		in, out := &in.ExtraVolumes, &out.ExtraVolumes
+		*out = make([]corev1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExtraVolumeMounts != nil {
+		in, out := &in.ExtraVolumeMounts, &out.ExtraVolumeMounts
+		*out = make([]corev1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(VarnishClusterVarnishReadiness)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnish.
+func (in *VarnishClusterVarnish) DeepCopy() *VarnishClusterVarnish {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVarnish)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVarnishController) DeepCopyInto(out *VarnishClusterVarnishController) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishController.
+func (in *VarnishClusterVarnishController) DeepCopy() *VarnishClusterVarnishController {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVarnishController)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVarnishMetricsExporter) DeepCopyInto(out *VarnishClusterVarnishMetricsExporter) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishMetricsExporter.
+func (in *VarnishClusterVarnishMetricsExporter) DeepCopy() *VarnishClusterVarnishMetricsExporter {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVarnishMetricsExporter)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVarnishReadiness) DeepCopyInto(out *VarnishClusterVarnishReadiness) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishReadiness.
+func (in *VarnishClusterVarnishReadiness) DeepCopy() *VarnishClusterVarnishReadiness {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVarnishReadiness)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishClusterVarnishSecret) DeepCopyInto(out *VarnishClusterVarnishSecret) {
+	*out = *in
+	if in.SecretName != nil {
+		in, out := &in.SecretName, &out.SecretName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Key != nil {
+		in, out := &in.Key, &out.Key
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishSecret.
+func (in *VarnishClusterVarnishSecret) DeepCopy() *VarnishClusterVarnishSecret {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishClusterVarnishSecret)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishInvalidation) DeepCopyInto(out *VarnishInvalidation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishInvalidation.
+func (in *VarnishInvalidation) DeepCopy() *VarnishInvalidation {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishInvalidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishInvalidation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishInvalidationList) DeepCopyInto(out *VarnishInvalidationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VarnishInvalidation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishInvalidationList.
+func (in *VarnishInvalidationList) DeepCopy() *VarnishInvalidationList {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishInvalidationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishInvalidationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishInvalidationPodStatus) DeepCopyInto(out *VarnishInvalidationPodStatus) {
+	*out = *in
+	in.LastAttemptTime.DeepCopyInto(&out.LastAttemptTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishInvalidationPodStatus.
+func (in *VarnishInvalidationPodStatus) DeepCopy() *VarnishInvalidationPodStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishInvalidationPodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishInvalidationSpec) DeepCopyInto(out *VarnishInvalidationSpec) {
+	*out = *in
+	if in.SurrogateKeys != nil {
+		in, out := &in.SurrogateKeys, &out.SurrogateKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxRetries != nil {
+		in, out := &in.MaxRetries, &out.MaxRetries
+		*out = new(int32)
+		**out = **in
+	}
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
 	}
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]corev1.Toleration, len(*in))
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishInvalidationSpec.
+func (in *VarnishInvalidationSpec) DeepCopy() *VarnishInvalidationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishInvalidationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishInvalidationStatus) DeepCopyInto(out *VarnishInvalidationStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]VarnishInvalidationPodStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Monitoring != nil {
-		in, out := &in.Monitoring, &out.Monitoring
-		*out = new(VarnishClusterMonitoring)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterSpec.
-func (in *VarnishClusterSpec) DeepCopy() *VarnishClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishInvalidationStatus.
+func (in *VarnishInvalidationStatus) DeepCopy() *VarnishInvalidationStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterSpec)
+	out := new(VarnishInvalidationStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterStatus) DeepCopyInto(out *VarnishClusterStatus) {
+func (in *VarnishLogSession) DeepCopyInto(out *VarnishLogSession) {
 	*out = *in
-	in.VCL.DeepCopyInto(&out.VCL)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterStatus.
-func (in *VarnishClusterStatus) DeepCopy() *VarnishClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishLogSession.
+func (in *VarnishLogSession) DeepCopy() *VarnishLogSession {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterStatus)
+	out := new(VarnishLogSession)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishLogSession) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterUpdateStrategy) DeepCopyInto(out *VarnishClusterUpdateStrategy) {
+func (in *VarnishLogSessionList) DeepCopyInto(out *VarnishLogSessionList) {
 	*out = *in
-	if in.RollingUpdate != nil {
-		in, out := &in.RollingUpdate, &out.RollingUpdate
-		*out = new(appsv1.RollingUpdateStatefulSetStrategy)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.DelayedRollingUpdate != nil {
-		in, out := &in.DelayedRollingUpdate, &out.DelayedRollingUpdate
-		*out = new(UpdateStrategyDelayedRollingUpdate)
-		**out = **in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VarnishLogSession, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterUpdateStrategy.
-func (in *VarnishClusterUpdateStrategy) DeepCopy() *VarnishClusterUpdateStrategy {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishLogSessionList.
+func (in *VarnishLogSessionList) DeepCopy() *VarnishLogSessionList {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterUpdateStrategy)
+	out := new(VarnishLogSessionList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishLogSessionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterVCL) DeepCopyInto(out *VarnishClusterVCL) {
+func (in *VarnishLogSessionPodStatus) DeepCopyInto(out *VarnishLogSessionPodStatus) {
 	*out = *in
-	if in.ConfigMapName != nil {
-		in, out := &in.ConfigMapName, &out.ConfigMapName
-		*out = new(string)
-		**out = **in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
 	}
-	if in.EntrypointFileName != nil {
-		in, out := &in.EntrypointFileName, &out.EntrypointFileName
-		*out = new(string)
-		**out = **in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVCL.
-func (in *VarnishClusterVCL) DeepCopy() *VarnishClusterVCL {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishLogSessionPodStatus.
+func (in *VarnishLogSessionPodStatus) DeepCopy() *VarnishLogSessionPodStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterVCL)
+	out := new(VarnishLogSessionPodStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterVarnish) DeepCopyInto(out *VarnishClusterVarnish) {
+func (in *VarnishLogSessionSpec) DeepCopyInto(out *VarnishLogSessionSpec) {
 	*out = *in
-	if in.Resources != nil {
-		in, out := &in.Resources, &out.Resources
-		*out = new(corev1.ResourceRequirements)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Args != nil {
-		in, out := &in.Args, &out.Args
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
-	if in.Controller != nil {
-		in, out := &in.Controller, &out.Controller
-		*out = new(VarnishClusterVarnishController)
-		(*in).DeepCopyInto(*out)
+	if in.DurationSeconds != nil {
+		in, out := &in.DurationSeconds, &out.DurationSeconds
+		*out = new(int32)
+		**out = **in
 	}
-	if in.MetricsExporter != nil {
-		in, out := &in.MetricsExporter, &out.MetricsExporter
-		*out = new(VarnishClusterVarnishMetricsExporter)
-		(*in).DeepCopyInto(*out)
+	if in.MaxSizeBytes != nil {
+		in, out := &in.MaxSizeBytes, &out.MaxSizeBytes
+		*out = new(int32)
+		**out = **in
 	}
-	if in.Secret != nil {
-		in, out := &in.Secret, &out.Secret
-		*out = new(VarnishClusterVarnishSecret)
-		(*in).DeepCopyInto(*out)
+	if in.TTLSecondsAfterFinished != nil {
+		in, out := &in.TTLSecondsAfterFinished, &out.TTLSecondsAfterFinished
+		*out = new(int32)
+		**out = **in
 	}
-	if in.EnvFrom != nil {
-		in, out := &in.EnvFrom, &out.EnvFrom
-		*out = make([]corev1.EnvFromSource, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishLogSessionSpec.
+func (in *VarnishLogSessionSpec) DeepCopy() *VarnishLogSessionSpec {
+	if in == nil {
+		return nil
 	}
-	if in.ExtraInitContainers != nil {
-		in, out := &in.ExtraInitContainers, &out.ExtraInitContainers
-		*out = make([]corev1.Container, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	out := new(VarnishLogSessionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishLogSessionStatus) DeepCopyInto(out *VarnishLogSessionStatus) {
+	*out = *in
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
 	}
-	if in.ExtraVolumeClaimTemplates != nil {
-		in, out := &in.ExtraVolumeClaimTemplates, &out.ExtraVolumeClaimTemplates
-		*out = make([]PVC, len(*in))
+	if in.Pods != nil {
+		in, out := &in.Pods, &out.Pods
+		*out = make([]VarnishLogSessionPodStatus, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.ExtraVolumes != nil {
-		in, out := &in.ExtraVolumes, &out.ExtraVolumes
-		*out = make([]corev1.Volume, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishLogSessionStatus.
+func (in *VarnishLogSessionStatus) DeepCopy() *VarnishLogSessionStatus {
+	if in == nil {
+		return nil
 	}
-	if in.ExtraVolumeMounts != nil {
-		in, out := &in.ExtraVolumeMounts, &out.ExtraVolumeMounts
-		*out = make([]corev1.VolumeMount, len(*in))
+	out := new(VarnishLogSessionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishParameterStatus) DeepCopyInto(out *VarnishParameterStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishParameterStatus.
+func (in *VarnishParameterStatus) DeepCopy() *VarnishParameterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishParameterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishPodStatus) DeepCopyInto(out *VarnishPodStatus) {
+	*out = *in
+	if in.LastReloadTime != nil {
+		in, out := &in.LastReloadTime, &out.LastReloadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.OutOfSyncSince != nil {
+		in, out := &in.OutOfSyncSince, &out.OutOfSyncSince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishPodStatus.
+func (in *VarnishPodStatus) DeepCopy() *VarnishPodStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishPodStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishSite) DeepCopyInto(out *VarnishSite) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSite.
+func (in *VarnishSite) DeepCopy() *VarnishSite {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishSite)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishSite) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishSiteClusterRef) DeepCopyInto(out *VarnishSiteClusterRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSiteClusterRef.
+func (in *VarnishSiteClusterRef) DeepCopy() *VarnishSiteClusterRef {
+	if in == nil {
+		return nil
+	}
+	out := new(VarnishSiteClusterRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VarnishSiteList) DeepCopyInto(out *VarnishSiteList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VarnishSite, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnish.
-func (in *VarnishClusterVarnish) DeepCopy() *VarnishClusterVarnish {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSiteList.
+func (in *VarnishSiteList) DeepCopy() *VarnishSiteList {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterVarnish)
+	out := new(VarnishSiteList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VarnishSiteList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterVarnishController) DeepCopyInto(out *VarnishClusterVarnishController) {
+func (in *VarnishSiteSpec) DeepCopyInto(out *VarnishSiteSpec) {
 	*out = *in
-	in.Resources.DeepCopyInto(&out.Resources)
+	out.VarnishCluster = in.VarnishCluster
+	if in.Hostnames != nil {
+		in, out := &in.Hostnames, &out.Hostnames
+		*out = make([]VarnishSiteHostname, len(*in))
+		copy(*out, *in)
+	}
+	out.VCL = in.VCL
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishController.
-func (in *VarnishClusterVarnishController) DeepCopy() *VarnishClusterVarnishController {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSiteSpec.
+func (in *VarnishSiteSpec) DeepCopy() *VarnishSiteSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterVarnishController)
+	out := new(VarnishSiteSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterVarnishMetricsExporter) DeepCopyInto(out *VarnishClusterVarnishMetricsExporter) {
+func (in *VarnishSiteStatus) DeepCopyInto(out *VarnishSiteStatus) {
 	*out = *in
-	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishMetricsExporter.
-func (in *VarnishClusterVarnishMetricsExporter) DeepCopy() *VarnishClusterVarnishMetricsExporter {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSiteStatus.
+func (in *VarnishSiteStatus) DeepCopy() *VarnishSiteStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterVarnishMetricsExporter)
+	out := new(VarnishSiteStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VarnishClusterVarnishSecret) DeepCopyInto(out *VarnishClusterVarnishSecret) {
+func (in *VarnishSiteVCL) DeepCopyInto(out *VarnishSiteVCL) {
 	*out = *in
-	if in.SecretName != nil {
-		in, out := &in.SecretName, &out.SecretName
-		*out = new(string)
-		**out = **in
-	}
-	if in.Key != nil {
-		in, out := &in.Key, &out.Key
-		*out = new(string)
-		**out = **in
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishClusterVarnishSecret.
-func (in *VarnishClusterVarnishSecret) DeepCopy() *VarnishClusterVarnishSecret {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VarnishSiteVCL.
+func (in *VarnishSiteVCL) DeepCopy() *VarnishSiteVCL {
 	if in == nil {
 		return nil
 	}
-	out := new(VarnishClusterVarnishSecret)
+	out := new(VarnishSiteVCL)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -14,11 +15,11 @@ import (
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/controller"
 	varnishMetrics "github.com/ibm/varnish-operator/pkg/varnishcontroller/metrics"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
 
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	controllerMetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
-
 	"github.com/go-logr/zapr"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -69,8 +70,8 @@ func main() {
 		log.Fatalf("could not load rest client config. Error: %s", err)
 	}
 
-	vMetrics := varnishMetrics.NewVarnishControllerMetrics()
-	controllerMetrics.Registry.MustRegister(vMetrics.VCLCompilationError)
+	vMetrics := varnishMetrics.NewVarnishControllerMetrics(varnishControllerConfig.VarnishClusterName, varnishControllerConfig.PodName)
+	controllerMetrics.Registry.MustRegister(vMetrics.Collectors()...)
 
 	mgr, err := ctrl.NewManager(clientConfig, ctrl.Options{
 		Scheme:                 scheme,
@@ -82,28 +83,46 @@ func main() {
 		logr.With(zap.Error(err)).Fatalf("could not initialize manager")
 	}
 
-	err = mgr.AddReadyzCheck("ping", healthz.Ping)
-	if err != nil {
-		logr.With(zap.Error(err)).Fatal("unable to set readiness check")
-	}
-
 	logr.Infow("Registering Components")
 
 	// Setup controller
-	varnishAdm := varnishadm.NewVarnishAdministartor(varnishControllerConfig.VarnishPingTimeout,
+	varnishAdm, err := varnishadm.NewCLIClient(varnishControllerConfig.VarnishPingTimeout,
 		varnishControllerConfig.VarnishPingDelay,
 		config.VCLConfigDir,
 		varnishControllerConfig.VarnishAdmArgs)
+	if err != nil {
+		logr.With(zap.Error(err)).Fatalw("could not initialize varnish CLI client")
+	}
+
+	varnishStat := varnishstat.NewVarnishStat(nil)
+
+	if varnishControllerConfig.MetricsExporter == v1alpha1.VarnishMetricsExporterTypeBuiltin {
+		vMetrics.Exporter = varnishMetrics.NewVarnishExporter(varnishStat, varnishAdm, varnishControllerConfig.MetricsNaming, varnishControllerConfig.VarnishClusterName)
+		logr.Infof("Varnish metrics exporter port: %d", v1alpha1.VarnishPrometheusExporterPort)
+		err = mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return vMetrics.Exporter.Serve(ctx, v1alpha1.VarnishPrometheusExporterPort)
+		}))
+		if err != nil {
+			logr.With(zap.Error(err)).Fatalw("could not setup varnish metrics exporter")
+		}
+	}
 
-	if err = controller.SetupVarnishReconciler(mgr, varnishControllerConfig, varnishAdm, vMetrics, logr); err != nil {
+	if err = controller.SetupVarnishReconciler(mgr, varnishControllerConfig, varnishAdm, varnishStat, vMetrics, logr); err != nil {
 		logr.With(zap.Error(err)).Fatalw("could not setup controller")
 	}
+	if err = controller.SetupInvalidationReconciler(mgr, varnishControllerConfig, varnishAdm, logr); err != nil {
+		logr.With(zap.Error(err)).Fatalw("could not setup invalidation controller")
+	}
+	if err = controller.SetupLogSessionReconciler(mgr, varnishControllerConfig, logr); err != nil {
+		logr.With(zap.Error(err)).Fatalw("could not setup log session controller")
+	}
+	ctx := signals.SetupSignalHandler()
 	logr.Infow("Looking up for a Varnish service")
-	if err = varnishAdm.Ping(); err != nil {
+	if err = varnishAdm.Ping(ctx); err != nil {
 		logr.With(err).Fatalf("Varnish is unreachable")
 	}
 	logr.Infow("Starting Varnish Controller")
-	if err = mgr.Start(signals.SetupSignalHandler()); err != nil {
+	if err = mgr.Start(ctx); err != nil {
 		logr.With(err).Fatalf("Failed to start manager")
 	}
 }
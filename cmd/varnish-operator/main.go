@@ -10,9 +10,11 @@ import (
 	"github.com/ibm/varnish-operator/pkg/logger"
 	vccfg "github.com/ibm/varnish-operator/pkg/varnishcluster/config"
 	"github.com/ibm/varnish-operator/pkg/varnishcluster/controller"
+	"github.com/ibm/varnish-operator/pkg/varnishcluster/invalidationapi"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	controllerMetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -108,9 +110,27 @@ func main() {
 		if err = (&v1alpha1.VarnishCluster{}).SetupWebhookWithManager(mgr); err != nil {
 			logr.With(zap.Error(err)).Fatal("unable to create webhook")
 		}
+		if err = (&v1alpha1.VarnishInvalidation{}).SetupWebhookWithManager(mgr); err != nil {
+			logr.With(zap.Error(err)).Fatal("unable to create webhook")
+		}
+		if err = (&v1alpha1.VarnishLogSession{}).SetupWebhookWithManager(mgr); err != nil {
+			logr.With(zap.Error(err)).Fatal("unable to create webhook")
+		}
 		v1alpha1.SetWebhookLogger(logr)
 	}
 
+	if operatorConfig.InvalidationAPIEnabled {
+		logr.Infof("Invalidation API port: %d", operatorConfig.InvalidationAPIPort)
+		apiMetrics := invalidationapi.NewMetrics()
+		controllerMetrics.Registry.MustRegister(apiMetrics.Collectors()...)
+		apiServer := invalidationapi.NewServer(mgr.GetClient(), logr,
+			controller.NewEventHandler(mgr.GetEventRecorderFor(controller.EventRecorderNameVarnishCluster)), apiMetrics,
+			operatorConfig.InvalidationAPIPort, operatorConfig.InvalidationAPICertDir, operatorConfig.Namespace, operatorConfig.InvalidationAPITokenSecret, operatorConfig.InvalidationAPITimeout)
+		if err = mgr.Add(apiServer); err != nil {
+			logr.With(zap.Error(err)).Fatal("unable to set up the invalidation API")
+		}
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	// Start the Cmd
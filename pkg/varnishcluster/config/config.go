@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	dockerref "github.com/docker/distribution/reference"
@@ -22,6 +23,12 @@ type Config struct {
 	MetricsPort           int32         `env:"METRICS_PORT" envDefault:"8329"`
 	WebhooksEnabled       bool          `env:"WEBHOOKS_ENABLED" envDefault:"true"`
 
+	InvalidationAPIEnabled     bool          `env:"INVALIDATION_API_ENABLED" envDefault:"false"`
+	InvalidationAPIPort        int32         `env:"INVALIDATION_API_PORT" envDefault:"8400"`
+	InvalidationAPITokenSecret string        `env:"INVALIDATION_API_TOKEN_SECRET" envDefault:"varnish-operator-invalidation-api"`
+	InvalidationAPITimeout     time.Duration `env:"INVALIDATION_API_TIMEOUT" envDefault:"30s"`
+	InvalidationAPICertDir     string        `env:"INVALIDATION_API_CERT_DIR"`
+
 	CoupledVarnishImage string
 }
 
@@ -38,16 +38,51 @@ func (r *ReconcileVarnishCluster) reconcileClusterRole(ctx context.Context, inst
 				Resources: []string{"pods"},
 				Verbs:     []string{"list", "watch", "get", "update"},
 			},
+			{
+				APIGroups: []string{"discovery.k8s.io"},
+				Resources: []string{"endpointslices"},
+				Verbs:     []string{"list", "watch"},
+			},
 			{
 				APIGroups: []string{"caching.ibm.com"},
-				Resources: []string{"varnishclusters"},
+				Resources: []string{"varnishclusters", "varnishsites"},
 				Verbs:     []string{"list", "watch"},
 			},
+			{
+				APIGroups: []string{"caching.ibm.com"},
+				Resources: []string{"varnishsites/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+			{
+				APIGroups: []string{"caching.ibm.com"},
+				Resources: []string{"varnishinvalidations"},
+				Verbs:     []string{"get", "list", "watch", "delete"},
+			},
+			{
+				APIGroups: []string{"caching.ibm.com"},
+				Resources: []string{"varnishinvalidations/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
+			{
+				APIGroups: []string{"caching.ibm.com"},
+				Resources: []string{"varnishlogsessions"},
+				Verbs:     []string{"get", "list", "watch", "delete"},
+			},
+			{
+				APIGroups: []string{"caching.ibm.com"},
+				Resources: []string{"varnishlogsessions/status"},
+				Verbs:     []string{"get", "update", "patch"},
+			},
 			{
 				APIGroups: []string{""},
-				Resources: []string{"secrets", "configmaps"},
+				Resources: []string{"secrets", "configmaps", "services"},
 				Verbs:     []string{"list", "get", "watch"},
 			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"create", "patch"},
+			},
 		},
 	}
 
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	annotationBackendsCount = "backendsCount"
+
+	conditionReasonPodsReady            = "PodsReady"
+	conditionReasonPodsNotReady         = "PodsNotReady"
+	conditionReasonRollingUpdate        = "RollingUpdate"
+	conditionReasonVCLRollout           = "VCLRollout"
+	conditionReasonComplete             = "Complete"
+	conditionReasonSynced               = "Synced"
+	conditionReasonOutdatedPods         = "OutdatedPods"
+	conditionReasonVCLValidationFailed  = "VCLValidationFailed"
+	conditionReasonVCLValidationPending = "VCLValidationPending"
+	conditionReasonVCLValidationStuck   = "VCLValidationStuck"
+	conditionReasonVCLCompilationFailed = "VCLCompilationFailed"
+	conditionReasonVCLRolloutAborted    = "VCLRolloutAborted"
+	conditionReasonVCLDrift             = "VCLDrift"
+	conditionReasonBackendsFound        = "BackendsFound"
+	conditionReasonNoBackends           = "NoBackends"
+	conditionReasonNotReported          = "NotReported"
+	conditionReasonInstalled            = "Installed"
+	conditionReasonNotRequested         = "NotRequested"
+	conditionReasonInstallationFailed   = "InstallationFailed"
+	conditionReasonReconcileError       = "ReconcileError"
+	conditionReasonAsExpected           = "AsExpected"
+
+	// the limit of the condition message length set by the API
+	maxConditionMessageLength = 32768
+)
+
+// reconcileConditions computes the status conditions from the state of the StatefulSet and the pods.
+// The conditions are set only once per reconcile, so their transition times don't change until the state does.
+func (r *ReconcileVarnishCluster) reconcileConditions(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster, sts *appsv1.StatefulSet, podsSelector map[string]string, monitoringIssues []string) error {
+	pods := &v1.PodList{}
+	selector := labels.SelectorFromSet(podsSelector)
+	if err := r.List(ctx, pods, client.InNamespace(instance.Namespace), &client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return errors.Wrap(err, "can't get list of pods")
+	}
+
+	vclSynced := vclSyncedCondition(instanceStatus, pods.Items)
+	setCondition(instance, instanceStatus, readyCondition(instance, sts))
+	setCondition(instance, instanceStatus, progressingCondition(instance, sts, vclSynced))
+	setCondition(instance, instanceStatus, vclSynced)
+	setCondition(instance, instanceStatus, backendsDiscoveredCondition(pods.Items))
+	setCondition(instance, instanceStatus, monitoringConfiguredCondition(instance, monitoringIssues))
+	setCondition(instance, instanceStatus, degradedCondition(vclSynced, instanceStatus.Status.Pods))
+	instanceStatus.Status.ObservedGeneration = instance.Generation
+	return nil
+}
+
+// setDegradedCondition reports a reconcile error, so it can be seen without access to the operator logs.
+// The rest of the conditions are left as they were observed by the last successful reconcile.
+func setDegradedCondition(instance, instanceStatus *vcapi.VarnishCluster, err error) {
+	setCondition(instance, instanceStatus, metav1.Condition{
+		Type:    vcapi.VarnishClusterConditionDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  conditionReasonReconcileError,
+		Message: err.Error(),
+	})
+}
+
+func setCondition(instance, instanceStatus *vcapi.VarnishCluster, condition metav1.Condition) {
+	condition.ObservedGeneration = instance.Generation
+	if len(condition.Message) > maxConditionMessageLength {
+		condition.Message = condition.Message[:maxConditionMessageLength-3] + "..."
+	}
+	meta.SetStatusCondition(&instanceStatus.Status.Conditions, condition)
+}
+
+func readyCondition(instance *vcapi.VarnishCluster, sts *appsv1.StatefulSet) metav1.Condition {
+	desired := desiredReplicas(instance)
+	message := fmt.Sprintf("%d/%d pods ready", sts.Status.ReadyReplicas, desired)
+	if sts.Status.ReadyReplicas < desired {
+		return metav1.Condition{Type: vcapi.VarnishClusterConditionReady, Status: metav1.ConditionFalse, Reason: conditionReasonPodsNotReady, Message: message}
+	}
+	return metav1.Condition{Type: vcapi.VarnishClusterConditionReady, Status: metav1.ConditionTrue, Reason: conditionReasonPodsReady, Message: message}
+}
+
+func progressingCondition(instance *vcapi.VarnishCluster, sts *appsv1.StatefulSet, vclSynced metav1.Condition) metav1.Condition {
+	desired := desiredReplicas(instance)
+	if sts.Status.ObservedGeneration < sts.Generation || sts.Status.UpdatedReplicas < desired || sts.Status.Replicas != desired {
+		return metav1.Condition{
+			Type:    vcapi.VarnishClusterConditionProgressing,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionReasonRollingUpdate,
+			Message: fmt.Sprintf("%d/%d pods updated", sts.Status.UpdatedReplicas, desired),
+		}
+	}
+	// failed VCLs are not retried until the ConfigMap changes, so only the pods that are still loading the VCL count
+	if vclSynced.Reason == conditionReasonOutdatedPods || vclSynced.Reason == conditionReasonVCLValidationPending {
+		return metav1.Condition{Type: vcapi.VarnishClusterConditionProgressing, Status: metav1.ConditionTrue, Reason: conditionReasonVCLRollout, Message: vclSynced.Message}
+	}
+	return metav1.Condition{Type: vcapi.VarnishClusterConditionProgressing, Status: metav1.ConditionFalse, Reason: conditionReasonComplete, Message: "All pods are up to date"}
+}
+
+// desiredReplicas is taken from the spec as the StatefulSet has no status right after it's created
+func desiredReplicas(instance *vcapi.VarnishCluster) int32 {
+	if instance.Spec.Replicas == nil {
+		return 1
+	}
+	return *instance.Spec.Replicas
+}
+
+func vclSyncedCondition(instanceStatus *vcapi.VarnishCluster, pods []v1.Pod) metav1.Condition {
+	vcl := instanceStatus.Status.VCL
+	condition := metav1.Condition{Type: vcapi.VarnishClusterConditionVCLSynced, Status: metav1.ConditionFalse}
+	if vcl.Validation != nil && vcl.Validation.ConfigMapVersion == vcl.ConfigMapVersion && vcl.Validation.Phase == vcapi.VCLValidationPhaseFailed {
+		condition.Reason = conditionReasonVCLValidationFailed
+		condition.Message = "ConfigMap version " + vcl.ConfigMapVersion + " failed the pre-flight compilation check"
+		if len(vcl.Validation.Diagnostics) > 0 {
+			condition.Message += ": " + vcl.Validation.Diagnostics[0].String()
+		}
+		return condition
+	}
+	// the pods don't apply the version until it passes the check
+	if vcl.Validation != nil && vcl.Validation.ConfigMapVersion == vcl.ConfigMapVersion && vcl.Validation.Phase == vcapi.VCLValidationPhasePending {
+		if vcl.Validation.Pod == "" {
+			condition.Reason = conditionReasonVCLValidationStuck
+			condition.Message = "ConfigMap version " + vcl.ConfigMapVersion + " waits for the pre-flight compilation check, but no Varnish pod is running to do it"
+			return condition
+		}
+		condition.Reason = conditionReasonVCLValidationPending
+		condition.Message = "ConfigMap version " + vcl.ConfigMapVersion + " is checked by pod " + vcl.Validation.Pod + " before it's applied"
+		return condition
+	}
+	if vcl.CompilationError != nil {
+		condition.Reason = conditionReasonVCLCompilationFailed
+		condition.Message = "ConfigMap version " + vcl.ConfigMapVersion + " failed to compile on pods " + strings.Join(vcl.CompilationError.Pods, ", ")
+		if len(vcl.CompilationError.Diagnostics) > 0 {
+			condition.Message += ": " + vcl.CompilationError.Diagnostics[0].String()
+		}
+		return condition
+	}
+	if vcl.Rollout != nil && vcl.Rollout.ConfigMapVersion == vcl.ConfigMapVersion && vcl.Rollout.Phase == vcapi.VCLRolloutPhaseAborted {
+		condition.Reason = conditionReasonVCLRolloutAborted
+		condition.Message = vcl.Rollout.Message
+		return condition
+	}
+
+	var outdated []string
+	for _, pod := range pods {
+		if pod.Annotations[annotationConfigMapVersion] != vcl.ConfigMapVersion {
+			outdated = append(outdated, pod.Name)
+		}
+	}
+	if len(outdated) > 0 {
+		sort.Strings(outdated)
+		condition.Reason = conditionReasonOutdatedPods
+		condition.Message = "Pods not on ConfigMap version " + vcl.ConfigMapVersion + ": " + strings.Join(outdated, ", ")
+		return condition
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = conditionReasonSynced
+	condition.Message = "All pods are on ConfigMap version " + vcl.ConfigMapVersion
+	return condition
+}
+
+func backendsDiscoveredCondition(pods []v1.Pod) metav1.Condition {
+	condition := metav1.Condition{Type: vcapi.VarnishClusterConditionBackendsDiscovered}
+	reported := 0
+	var withoutBackends []string
+	for _, pod := range pods {
+		value, found := pod.Annotations[annotationBackendsCount]
+		if !found {
+			continue
+		}
+		reported++
+		if count, err := strconv.Atoi(value); err != nil || count == 0 {
+			withoutBackends = append(withoutBackends, pod.Name)
+		}
+	}
+
+	switch {
+	case reported == 0:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = conditionReasonNotReported
+		condition.Message = "No pod has reported its backends yet"
+	case len(withoutBackends) > 0:
+		sort.Strings(withoutBackends)
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditionReasonNoBackends
+		condition.Message = "No backends found by pods " + strings.Join(withoutBackends, ", ")
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = conditionReasonBackendsFound
+		condition.Message = fmt.Sprintf("Backends found by %d pods", reported)
+	}
+	return condition
+}
+
+func monitoringConfiguredCondition(instance *vcapi.VarnishCluster, issues []string) metav1.Condition {
+	condition := metav1.Condition{Type: vcapi.VarnishClusterConditionMonitoringConfigured}
+	requested := instance.Spec.Monitoring.PrometheusServiceMonitor.Enabled ||
+		(instance.Spec.Monitoring.GrafanaDashboard != nil && instance.Spec.Monitoring.GrafanaDashboard.Enabled)
+	switch {
+	case len(issues) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditionReasonInstallationFailed
+		condition.Message = strings.Join(issues, "; ")
+	case !requested:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = conditionReasonNotRequested
+		condition.Message = "Neither the ServiceMonitor nor the Grafana dashboard is enabled"
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = conditionReasonInstalled
+		condition.Message = "The requested ServiceMonitor and Grafana dashboard are installed"
+	}
+	return condition
+}
+
+func degradedCondition(vclSynced metav1.Condition, pods []vcapi.VarnishPodStatus) metav1.Condition {
+	switch vclSynced.Reason {
+	case conditionReasonVCLValidationFailed, conditionReasonVCLCompilationFailed, conditionReasonVCLRolloutAborted:
+		return metav1.Condition{Type: vcapi.VarnishClusterConditionDegraded, Status: metav1.ConditionTrue, Reason: vclSynced.Reason, Message: vclSynced.Message}
+	}
+	var drifted []string
+	for _, pod := range pods {
+		if pod.Drifted {
+			drifted = append(drifted, pod.Name)
+		}
+	}
+	if len(drifted) > 0 {
+		return metav1.Condition{
+			Type:    vcapi.VarnishClusterConditionDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditionReasonVCLDrift,
+			Message: "Pods stuck on another ConfigMap version: " + strings.Join(drifted, ", "),
+		}
+	}
+	return metav1.Condition{Type: vcapi.VarnishClusterConditionDegraded, Status: metav1.ConditionFalse, Reason: conditionReasonAsExpected, Message: "The cluster is in the desired state"}
+}
@@ -0,0 +1,225 @@
+package controller
+
+import (
+	"testing"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVCLSyncedCondition(t *testing.T) {
+	pod := func(name, cmVersion string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{annotationConfigMapVersion: cmVersion}}}
+	}
+	diagnostics := []vcapi.VCLDiagnostic{{File: "entrypoint.vcl", Line: 12, Column: 9, Message: "Symbol not found: 'req.foo'"}}
+
+	cases := []struct {
+		name            string
+		vcl             vcapi.VCLStatus
+		pods            []v1.Pod
+		expectedStatus  metav1.ConditionStatus
+		expectedReason  string
+		expectedMessage string
+	}{
+		{
+			name:            "all pods synced",
+			vcl:             vcapi.VCLStatus{ConfigMapVersion: "1234"},
+			pods:            []v1.Pod{pod("varnish-0", "1234"), pod("varnish-1", "1234")},
+			expectedStatus:  metav1.ConditionTrue,
+			expectedReason:  conditionReasonSynced,
+			expectedMessage: "All pods are on ConfigMap version 1234",
+		},
+		{
+			name:            "rollout in progress",
+			vcl:             vcapi.VCLStatus{ConfigMapVersion: "1234"},
+			pods:            []v1.Pod{pod("varnish-1", "1000"), pod("varnish-0", "1234"), {ObjectMeta: metav1.ObjectMeta{Name: "varnish-2"}}},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonOutdatedPods,
+			expectedMessage: "Pods not on ConfigMap version 1234: varnish-1, varnish-2",
+		},
+		{
+			name: "pre-flight check failed",
+			vcl: vcapi.VCLStatus{ConfigMapVersion: "1234", Validation: &vcapi.VCLValidationStatus{
+				ConfigMapVersion: "1234", Phase: vcapi.VCLValidationPhaseFailed, Diagnostics: diagnostics,
+			}},
+			pods:            []v1.Pod{pod("varnish-0", "1000")},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonVCLValidationFailed,
+			expectedMessage: "ConfigMap version 1234 failed the pre-flight compilation check: entrypoint.vcl:12:9: Symbol not found: 'req.foo'",
+		},
+		{
+			name: "pre-flight check pending",
+			vcl: vcapi.VCLStatus{ConfigMapVersion: "1234", Validation: &vcapi.VCLValidationStatus{
+				ConfigMapVersion: "1234", Phase: vcapi.VCLValidationPhasePending, Pod: "varnish-0",
+			}},
+			pods:            []v1.Pod{pod("varnish-0", "1000")},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonVCLValidationPending,
+			expectedMessage: "ConfigMap version 1234 is checked by pod varnish-0 before it's applied",
+		},
+		{
+			name: "no pod to do the pre-flight check",
+			vcl: vcapi.VCLStatus{ConfigMapVersion: "1234", Validation: &vcapi.VCLValidationStatus{
+				ConfigMapVersion: "1234", Phase: vcapi.VCLValidationPhasePending,
+			}},
+			pods:            []v1.Pod{pod("varnish-0", "")},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonVCLValidationStuck,
+			expectedMessage: "ConfigMap version 1234 waits for the pre-flight compilation check, but no Varnish pod is running to do it",
+		},
+		{
+			name: "compilation failed",
+			vcl: vcapi.VCLStatus{ConfigMapVersion: "1234", CompilationError: &vcapi.VCLCompilationErrorStatus{
+				ConfigMapVersion: "1234", Pods: []string{"varnish-0"}, Diagnostics: diagnostics,
+			}},
+			pods:            []v1.Pod{pod("varnish-0", "1000")},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonVCLCompilationFailed,
+			expectedMessage: "ConfigMap version 1234 failed to compile on pods varnish-0: entrypoint.vcl:12:9: Symbol not found: 'req.foo'",
+		},
+		{
+			name: "canary rollout aborted",
+			vcl: vcapi.VCLStatus{ConfigMapVersion: "1234", Rollout: &vcapi.VCLRolloutStatus{
+				ConfigMapVersion: "1234", Phase: vcapi.VCLRolloutPhaseAborted, Message: "canary error rate exceeded",
+			}},
+			pods:            []v1.Pod{pod("varnish-0", "1000")},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  conditionReasonVCLRolloutAborted,
+			expectedMessage: "canary error rate exceeded",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		condition := vclSyncedCondition(&vcapi.VarnishCluster{Status: vcapi.VarnishClusterStatus{VCL: c.vcl}}, c.pods)
+		g.Expect(condition.Status).To(gomega.Equal(c.expectedStatus))
+		g.Expect(condition.Reason).To(gomega.Equal(c.expectedReason))
+		g.Expect(condition.Message).To(gomega.Equal(c.expectedMessage))
+
+		degraded := degradedCondition(condition, nil)
+		waiting := c.expectedReason == conditionReasonOutdatedPods || c.expectedReason == conditionReasonVCLValidationPending || c.expectedReason == conditionReasonVCLValidationStuck
+		g.Expect(degraded.Status == metav1.ConditionTrue).To(gomega.Equal(c.expectedStatus == metav1.ConditionFalse && !waiting))
+	}
+}
+
+func TestBackendsDiscoveredCondition(t *testing.T) {
+	pod := func(name string, annotations map[string]string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	}
+
+	cases := []struct {
+		name           string
+		pods           []v1.Pod
+		expectedStatus metav1.ConditionStatus
+		expectedReason string
+	}{
+		{
+			name:           "not reported yet",
+			pods:           []v1.Pod{pod("varnish-0", nil)},
+			expectedStatus: metav1.ConditionUnknown,
+			expectedReason: conditionReasonNotReported,
+		},
+		{
+			name:           "backends found",
+			pods:           []v1.Pod{pod("varnish-0", map[string]string{annotationBackendsCount: "3"}), pod("varnish-1", nil)},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: conditionReasonBackendsFound,
+		},
+		{
+			name:           "no backends",
+			pods:           []v1.Pod{pod("varnish-0", map[string]string{annotationBackendsCount: "3"}), pod("varnish-1", map[string]string{annotationBackendsCount: "0"})},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: conditionReasonNoBackends,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		condition := backendsDiscoveredCondition(c.pods)
+		g.Expect(condition.Status).To(gomega.Equal(c.expectedStatus))
+		g.Expect(condition.Reason).To(gomega.Equal(c.expectedReason))
+	}
+}
+
+func TestMonitoringConfiguredCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &vcapi.VarnishCluster{Spec: vcapi.VarnishClusterSpec{Monitoring: &vcapi.VarnishClusterMonitoring{
+		PrometheusServiceMonitor: &vcapi.VarnishClusterMonitoringPrometheusServiceMonitor{},
+	}}}
+
+	condition := monitoringConfiguredCondition(instance, nil)
+	g.Expect(condition.Status).To(gomega.Equal(metav1.ConditionTrue))
+	g.Expect(condition.Reason).To(gomega.Equal(conditionReasonNotRequested))
+
+	instance.Spec.Monitoring.PrometheusServiceMonitor.Enabled = true
+	condition = monitoringConfiguredCondition(instance, nil)
+	g.Expect(condition.Status).To(gomega.Equal(metav1.ConditionTrue))
+	g.Expect(condition.Reason).To(gomega.Equal(conditionReasonInstalled))
+
+	condition = monitoringConfiguredCondition(instance, []string{"ServiceMonitor can't be installed. Prometheus operator needs to be installed first"})
+	g.Expect(condition.Status).To(gomega.Equal(metav1.ConditionFalse))
+	g.Expect(condition.Reason).To(gomega.Equal(conditionReasonInstallationFailed))
+	g.Expect(condition.Message).To(gomega.ContainSubstring("Prometheus operator"))
+}
+
+func TestReadyAndProgressingConditions(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &vcapi.VarnishCluster{Spec: vcapi.VarnishClusterSpec{Replicas: proto.Int32(3)}}
+	synced := metav1.Condition{Reason: conditionReasonSynced}
+
+	// right after creation
+	sts := &appsv1.StatefulSet{}
+	g.Expect(readyCondition(instance, sts).Status).To(gomega.Equal(metav1.ConditionFalse))
+	g.Expect(progressingCondition(instance, sts, synced).Reason).To(gomega.Equal(conditionReasonRollingUpdate))
+
+	sts.Status = appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 3, UpdatedReplicas: 3}
+	g.Expect(readyCondition(instance, sts).Status).To(gomega.Equal(metav1.ConditionTrue))
+	g.Expect(readyCondition(instance, sts).Message).To(gomega.Equal("3/3 pods ready"))
+	g.Expect(progressingCondition(instance, sts, synced).Status).To(gomega.Equal(metav1.ConditionFalse))
+	g.Expect(progressingCondition(instance, sts, metav1.Condition{Reason: conditionReasonOutdatedPods}).Reason).To(gomega.Equal(conditionReasonVCLRollout))
+	g.Expect(progressingCondition(instance, sts, metav1.Condition{Reason: conditionReasonVCLValidationPending}).Reason).To(gomega.Equal(conditionReasonVCLRollout))
+	g.Expect(progressingCondition(instance, sts, metav1.Condition{Reason: conditionReasonVCLValidationFailed}).Status).To(gomega.Equal(metav1.ConditionFalse))
+}
+
+func TestSetDegradedCondition(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	instance := &vcapi.VarnishCluster{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+	instanceStatus := instance.DeepCopy()
+	setCondition(instance, instanceStatus, degradedCondition(metav1.Condition{Reason: conditionReasonSynced}, nil))
+	transitionTime := meta.FindStatusCondition(instanceStatus.Status.Conditions, vcapi.VarnishClusterConditionDegraded).LastTransitionTime
+
+	setDegradedCondition(instance, instanceStatus, errors.Wrap(errors.New("forbidden"), "could not update statefulset"))
+	degraded := meta.FindStatusCondition(instanceStatus.Status.Conditions, vcapi.VarnishClusterConditionDegraded)
+	g.Expect(degraded.Status).To(gomega.Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(gomega.Equal(conditionReasonReconcileError))
+	g.Expect(degraded.Message).To(gomega.Equal("could not update statefulset: forbidden"))
+	g.Expect(degraded.ObservedGeneration).To(gomega.Equal(int64(3)))
+
+	// the transition time changes only with the status
+	degraded.LastTransitionTime = transitionTime
+	setDegradedCondition(instance, instanceStatus, errors.New("could not update statefulset: conflict"))
+	g.Expect(meta.FindStatusCondition(instanceStatus.Status.Conditions, vcapi.VarnishClusterConditionDegraded).LastTransitionTime).To(gomega.Equal(transitionTime))
+}
+
+func TestDegradedConditionVCLDrift(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pods := []vcapi.VarnishPodStatus{{Name: "varnish-0"}, {Name: "varnish-1", Drifted: true}, {Name: "varnish-2", Drifted: true}}
+
+	degraded := degradedCondition(metav1.Condition{Reason: conditionReasonOutdatedPods}, pods)
+	g.Expect(degraded.Status).To(gomega.Equal(metav1.ConditionTrue))
+	g.Expect(degraded.Reason).To(gomega.Equal(conditionReasonVCLDrift))
+	g.Expect(degraded.Message).To(gomega.Equal("Pods stuck on another ConfigMap version: varnish-1, varnish-2"))
+
+	// compilation errors explain the drift better
+	degraded = degradedCondition(metav1.Condition{Reason: conditionReasonVCLCompilationFailed}, pods)
+	g.Expect(degraded.Reason).To(gomega.Equal(conditionReasonVCLCompilationFailed))
+}
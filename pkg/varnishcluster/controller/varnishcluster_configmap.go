@@ -98,9 +98,9 @@ func (r *ReconcileVarnishCluster) reconcileConfigMap(ctx context.Context, podsSe
 	latest, outdated := 0, 0
 	for _, item := range pods.Items {
 		//do not count pods that are not updated with VCL version. Those are pods that are just created and not fully functional
-		if item.Annotations["configMapVersion"] == "" {
+		if item.Annotations[annotationConfigMapVersion] == "" {
 			logr.Debugw("ConfigMapVersion annotation is not present. Skipping the pod.")
-		} else if item.Annotations["configMapVersion"] == instance.Status.VCL.ConfigMapVersion {
+		} else if item.Annotations[annotationConfigMapVersion] == instance.Status.VCL.ConfigMapVersion {
 			latest++
 		} else {
 			outdated++
@@ -108,5 +108,11 @@ func (r *ReconcileVarnishCluster) reconcileConfigMap(ctx context.Context, podsSe
 	}
 
 	instanceStatus.Status.VCL.Availability = fmt.Sprintf("%d latest / %d outdated", latest, outdated)
-	return nil
+	if err = r.reconcileVCLValidation(ctx, instance, instanceStatus, cm, pods.Items); err != nil {
+		return errors.WithStack(err)
+	}
+	r.reconcileVCLCompilationError(instanceStatus, pods.Items)
+	r.reconcileVCLRollout(ctx, instance, instanceStatus, pods.Items)
+	r.reconcileParametersStatus(instance, instanceStatus, pods.Items)
+	return r.reconcilePodsStatus(ctx, instance, instanceStatus, pods.Items)
 }
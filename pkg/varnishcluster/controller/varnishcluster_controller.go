@@ -102,6 +102,13 @@ func SetupVarnishReconciler(ctx context.Context, vcCtrl reconcile.Reconciler, mg
 		}
 	})
 
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &vcapi.VarnishCluster{}, backendServiceIndex, backendServiceIndexValue); err != nil {
+		return errors.Wrap(err, "could not index VarnishClusters by backend service")
+	}
+	backendServiceEventHandler := handler.EnqueueRequestsFromMapFunc(func(a client.Object) []ctrl.Request {
+		return backendServiceRequests(ctx, mgr.GetClient(), a)
+	})
+
 	builder := ctrl.NewControllerManagedBy(mgr)
 	builder.Named("varnishcluster")
 	builder.For(&vcapi.VarnishCluster{})
@@ -115,6 +122,7 @@ func SetupVarnishReconciler(ctx context.Context, vcCtrl reconcile.Reconciler, mg
 	builder.Owns(&v1.ServiceAccount{})
 	builder.Watches(&source.Channel{Source: reconcileChan}, &handler.EnqueueRequestForObject{})
 	builder.Watches(&source.Kind{Type: &v1.Pod{}}, varnishClusterPodsEventHandler)
+	builder.Watches(&source.Kind{Type: &v1.Service{}}, backendServiceEventHandler)
 
 	serviceMonitorList := &unstructured.UnstructuredList{}
 	serviceMonitorList.SetGroupVersionKind(serviceMonitorListGVK)
@@ -164,6 +172,12 @@ func NewVarnishReconciler(mgr manager.Manager, cfg *config.Config, logr *logger.
 // +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishclusters,verbs=list;watch;create;update;delete
 // +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishclusters/finalizers,verbs=get;update;patch
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishsites,verbs=list;watch
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishsites/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishinvalidations,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishinvalidations/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishlogsessions,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=caching.ibm.com,resources=varnishlogsessions/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
 // +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
 // +kubebuilder:rbac:groups="",resources=services;serviceaccounts,verbs=list;watch;create;update;delete
@@ -171,6 +185,7 @@ func NewVarnishReconciler(mgr manager.Manager, cfg *config.Config, logr *logger.
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups="",resources=pods,verbs=list;get;watch;update
 // +kubebuilder:rbac:groups="",resources=nodes,verbs=watch;list
+// +kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=list;watch
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=list;watch;create;update;delete
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=list;watch;create;update;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=list;watch;create;update;delete
@@ -184,7 +199,7 @@ func (r *ReconcileVarnishCluster) Reconcile(ctx context.Context, request ctrl.Re
 
 	logr.Debugw("Reconciling...")
 	start := time.Now()
-	defer logr.Debugf("Reconciled in %s", time.Since(start).String())
+	defer func() { logr.Debugf("Reconciled in %s", time.Since(start).String()) }()
 	res, err := r.reconcileWithContext(ctx, request)
 	if err != nil {
 		if statusErr, ok := errors.Cause(err).(*apierrors.StatusError); ok && statusErr.ErrStatus.Reason == metav1.StatusReasonConflict {
@@ -248,70 +263,100 @@ func (r *ReconcileVarnishCluster) reconcileWithContext(ctx context.Context, requ
 	instance.ObjectMeta.DeepCopyInto(&instanceStatus.ObjectMeta)
 	instance.Status.DeepCopyInto(&instanceStatus.Status)
 
-	err = r.reconcileServiceAccount(ctx, instance)
-	if err != nil {
+	if err = r.reconcileResources(ctx, instance, instanceStatus); err != nil {
+		// conflicts are retried right away, so they are not worth reporting
+		if kerrors.IsConflict(errors.Cause(err)) {
+			return ctrl.Result{}, err
+		}
+		setDegradedCondition(instance, instanceStatus, err)
+		if statusErr := r.updateStatus(ctx, instance, instanceStatus); statusErr != nil {
+			logger.FromContext(ctx).Warnw("Can't report the reconcile error in the VarnishCluster status", zap.Error(statusErr))
+		}
 		return ctrl.Result{}, err
 	}
+
+	return ctrl.Result{}, r.updateStatus(ctx, instance, instanceStatus)
+}
+
+// reconcileResources brings the resources of the VarnishCluster to the desired state and records their state in instanceStatus
+func (r *ReconcileVarnishCluster) reconcileResources(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster) error {
+	err := r.reconcileServiceAccount(ctx, instance)
+	if err != nil {
+		return err
+	}
 	err = r.reconcileRole(ctx, instance)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	if err = r.reconcileRoleBinding(ctx, instance); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	err = r.reconcileClusterRole(ctx, instance)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	if err = r.reconcileClusterRoleBinding(ctx, instance); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	endpointSelector, err := r.reconcileServiceNoCache(ctx, instance, instanceStatus)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	err = r.reconcileHeadlessService(ctx, instance)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	if err = r.reconcileVarnishSecret(ctx, instance); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	sts, varnishSelector, err := r.reconcileStatefulSet(ctx, instance, instanceStatus, endpointSelector)
 	if err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	if err = r.reconcileConfigMap(ctx, varnishSelector, instance, instanceStatus); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 
 	if err = r.reconcilePodDisruptionBudget(ctx, instance, varnishSelector); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 	if err = r.reconcileService(ctx, instance, instanceStatus, varnishSelector); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 
 	if err = r.reconcileDelayedRollingUpdate(ctx, instance, instanceStatus, sts); err != nil {
-		return ctrl.Result{}, err
+		return err
 	}
 
-	if err = r.reconcileServiceMonitor(ctx, instance); err != nil {
-		return ctrl.Result{}, err
+	var monitoringIssues []string
+	issue, err := r.reconcileServiceMonitor(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if issue != "" {
+		monitoringIssues = append(monitoringIssues, issue)
 	}
 
-	if err = r.reconcileGrafanaDashboard(ctx, instance); err != nil {
-		return ctrl.Result{}, err
+	issue, err = r.reconcileGrafanaDashboard(ctx, instance)
+	if err != nil {
+		return err
+	}
+	if issue != "" {
+		monitoringIssues = append(monitoringIssues, issue)
 	}
 
-	if !compare.EqualVarnishClusterStatus(&instance.Status, &instanceStatus.Status) {
-		logger.FromContext(ctx).Infoc("Updating VarnishCluster Status", "diff", compare.DiffVarnishClusterStatus(&instance.Status, &instanceStatus.Status))
-		if err = r.Status().Update(ctx, instanceStatus); err != nil {
-			return ctrl.Result{}, errors.Wrapf(err, "could not update VarnishCluster Status %s:%s, %s:%s", "name", instance.Name, "namespace", instance.Namespace)
-		}
-	} else {
+	return r.reconcileConditions(ctx, instance, instanceStatus, sts, varnishSelector, monitoringIssues)
+}
+
+func (r *ReconcileVarnishCluster) updateStatus(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster) error {
+	if compare.EqualVarnishClusterStatus(&instance.Status, &instanceStatus.Status) {
 		logger.FromContext(ctx).Debugw("No updates for VarnishCluster status")
+		return nil
 	}
 
-	return ctrl.Result{}, nil
+	logger.FromContext(ctx).Infoc("Updating VarnishCluster Status", "diff", compare.DiffVarnishClusterStatus(&instance.Status, &instanceStatus.Status))
+	if err := r.Status().Update(ctx, instanceStatus); err != nil {
+		return errors.Wrapf(err, "could not update VarnishCluster Status %s:%s, %s:%s", "name", instance.Name, "namespace", instance.Namespace)
+	}
+	return nil
 }
@@ -26,7 +26,8 @@ sub vcl_recv {
     return(synth(503, "No backends configured"));
   }
 
-  set req.backend_hint = container_rr.backend();
+  // the director is configured in .spec.backend.director of the VarnishCluster
+  call set_backend_hint;
 
   if (req.method == "GET" && req.url == "/liveness") {
     if (!std.healthy(req.backend_hint)) {
@@ -112,16 +113,19 @@ sub vcl_deliver {
 `
 
 const backendsVCLTmplFileContent = `import directors;
+{{- $shard := false }}
+{{- if eq .Clustering.Mode "shard" }}{{ range .VarnishNodes }}{{ if eq .PodName $.LocalPod.Name }}{{ $shard = true }}{{ end }}{{ end }}{{ end }}
 
 {{ if .Backends -}}
 {{ range .Backends }}
-backend {{ .PodName }} {
+backend {{ vclIdent .PodName }} {
   // backend {{ .PodName }} labels:
   {{- range $item, $key := .NodeLabels }}
   //   {{ $item }}: {{ $key -}}
   {{ end }}
   .host = "{{ .IP }}";
-  .port = "{{ $.TargetPort }}";
+  .port = "{{ .Port }}";
+  {{- with $.BackendParameters }}{{ nindent 2 . }}{{ end }}
 }
 {{ end }}
 {{- else -}}
@@ -132,15 +136,142 @@ backend dummy {
   .port = "0";
 }
 {{- end }}
+{{- range $group := .BackendGroups }}
+
+// backends of the {{ $group.Name }} group
+{{- range .Backends }}
+backend {{ $group.Name }}_{{ vclIdent .PodName }} {
+  .host = "{{ .IP }}";
+  .port = "{{ .Port }}";
+  {{- with $group.BackendParameters }}{{ nindent 2 . }}{{ end }}
+}
+{{- end }}
+{{- end }}
+{{- if $shard }}
+
+// varnish pods the objects are sharded between
+{{- range .VarnishNodes }}
+{{- if or (not $.Clustering.ZoneAware) (eq .Zone $.LocalPod.Zone) }}
+backend varnish_{{ vclIdent .PodName }} {
+  .host = "{{ .IP }}";
+  .port = "{{ $.VarnishPort }}";
+  .probe = {
+    .url = "/heartbeat";
+    .interval = 5s;
+    .timeout = 1s;
+    .window = 5;
+    .threshold = 3;
+  }
+}
+{{- end }}
+{{- end }}
+
+acl varnish_pods {
+  {{- range .VarnishNodes }}
+  "{{ .IP }}";
+  {{- end }}
+}
+{{- end }}
+
+{{- define "director" }}
+{{- $name := .Name }}{{ $prefix := .Prefix }}{{ $d := .Director }}
+{{- if eq $d.Type "fallback" }}
+  new {{ $name }} = directors.fallback({{ if $d.FallbackSticky }}sticky = true{{ end }});
+  {{ $name }}.add_backend({{ .RoundRobin }}.backend());
+  {{- range $d.FallbackGroups }}
+  {{- $group := index $.Groups . }}
+  {{ $name }}.add_backend({{ $group.Name }}_director.backend({{ if eq $group.Director.Type "shard" }}resolve = LAZY{{ end }}));
+  {{- end }}
+{{- else }}
+  new {{ $name }} = directors.{{ $d.Type }}();
+  {{- range .Backends }}
+  {{ $name }}.add_backend({{ $prefix }}{{ vclIdent .PodName }}{{ if or (eq $d.Type "random") (eq $d.Type "hash") }}, {{ printf "%.3f" .Weight }}{{ end }});
+  {{- end }}
+  {{- if eq $d.Type "shard" }}
+  {{- with $d.ShardWarmup }}
+  {{ $name }}.set_warmup({{ . }});
+  {{- end }}
+  {{- with $d.ShardRampup }}
+  {{ $name }}.set_rampup({{ . }});
+  {{- end }}
+  {{ $name }}.reconfigure();
+  {{- end }}
+{{- end }}
+{{- end }}
 
 sub init_backends {
   // The line below is generated and creates a variable that is used to build custom logic
   // when the user configured the backends incorrectly. E.g. return a custom error page that indicates the issue.
-  var.global_set("backendsFound", {{ if .Backends }}"true"{{ else }}"false"{{ end }}); //only strings are allowed to be set globally
+  {{- $found := .Backends }}
+  {{- if eq .Director.Type "fallback" }}{{ range .Director.FallbackGroups }}{{ with index $.BackendGroups . }}{{ if .Backends }}{{ $found = .Backends }}{{ end }}{{ end }}{{ end }}{{ end }}
+  var.global_set("backendsFound", {{ if $found }}"true"{{ else }}"false"{{ end }}); //only strings are allowed to be set globally
+  {{- range $group := .BackendGroups }}
+
+  // use {{ $group.Name }}_rr.backend() or {{ $group.Name }}_director.backend() to send requests to the {{ $group.Name }} group
+  new {{ $group.Name }}_rr = directors.round_robin();
+  {{- range .Backends }}
+  {{ $group.Name }}_rr.add_backend({{ $group.Name }}_{{ vclIdent .PodName }});
+  {{- end }}
+  {{- template "director" (dict "Name" (printf "%s_director" $group.Name) "Prefix" (printf "%s_" $group.Name) "RoundRobin" (printf "%s_rr" $group.Name) "Director" $group.Director "Backends" $group.Backends "Groups" $.BackendGroups) }}
+  {{- end }}
 
   new container_rr = directors.round_robin();
   {{- range .Backends }}
-  container_rr.add_backend({{ .PodName }});
+  container_rr.add_backend({{ vclIdent .PodName }});
+  {{- end }}
+  {{- template "director" (dict "Name" "container_director" "Prefix" "" "RoundRobin" "container_rr" "Director" .Director "Backends" .Backends "Groups" .BackendGroups) }}
+  {{- if $shard }}
+
+  // the varnish pod that owns an object is picked by its URL
+  new varnish_shard = directors.shard();
+  {{- range .VarnishNodes }}
+  {{- if or (not $.Clustering.ZoneAware) (eq .Zone $.LocalPod.Zone) }}
+  varnish_shard.add_backend(varnish_{{ vclIdent .PodName }});
+  {{- end }}
+  {{- end }}
+  varnish_shard.reconfigure();
+  {{- end }}
+}
+
+sub set_backend_hint {
+  {{- if eq .Director.Type "hash" }}
+  if ({{ .Director.HashKey }} ~ ".") {
+    set req.backend_hint = container_director.backend({{ .Director.HashKey }});
+  } else {
+    set req.backend_hint = container_director.backend(client.identity);
+  }
+  {{- else if eq .Director.Type "shard" }}
+  set req.backend_hint = container_director.backend(by = {{ .Director.ShardBy }});
+  {{- else }}
+  set req.backend_hint = container_director.backend();
+  {{- end }}
+  {{- if $shard }}
+
+  // send the request to the varnish pod that owns the object, unless it's this pod or the request has been forwarded by another pod
+  if (!(client.ip ~ varnish_pods && req.http.{{ .Clustering.ForwardedHeader }})) {
+    if (varnish_shard.backend(by = URL) != varnish_{{ vclIdent .LocalPod.Name }}) {
+      set req.backend_hint = varnish_shard.backend(by = URL);
+      set req.http.{{ .Clustering.ForwardedHeader }} = "{{ .LocalPod.Name }}";
+    }
+  }
   {{- end }}
 }
+{{- if $shard }}
+
+sub vcl_backend_fetch {
+  // the header is only needed by the pod the request is forwarded to
+  if (bereq.http.{{ .Clustering.ForwardedHeader }} != "{{ .LocalPod.Name }}") {
+    unset bereq.http.{{ .Clustering.ForwardedHeader }};
+  }
+}
+
+sub vcl_backend_response {
+  // the objects owned by other pods are cached only there
+  if (bereq.http.{{ .Clustering.ForwardedHeader }} == "{{ .LocalPod.Name }}") {
+    set beresp.uncacheable = true;
+    set beresp.ttl = 120s;
+    return (deliver);
+  }
+}
+{{- end }}
 `
@@ -11,6 +11,13 @@ const (
 
 	EventReasonServiceMonitorKindNotFound = "servicemonitor-not-found"
 	EventReasonNamespaceNotFound          = "namespace-not-found"
+	EventReasonVCLValidationFailed        = "vcl-validation-failed"
+	EventReasonVCLRolloutPromoted         = "vcl-rollout-promoted"
+	EventReasonVCLRolloutAborted          = "vcl-rollout-aborted"
+	EventReasonVCLDrift                   = "vcl-drift"
+	EventReasonInvalidationCompleted      = "invalidation-completed"
+	EventReasonInvalidationFailed         = "invalidation-failed"
+	EventReasonInvalidationTimedOut       = "invalidation-timed-out"
 )
 
 // EventReason is the reason why the event was create. The value appears in the 'Reason' tab of the events list
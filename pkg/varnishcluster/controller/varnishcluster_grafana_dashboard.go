@@ -22,19 +22,20 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *ReconcileVarnishCluster) reconcileGrafanaDashboard(ctx context.Context, instance *vcapi.VarnishCluster) error {
+// reconcileGrafanaDashboard returns the reason the requested dashboard can't be installed, if any
+func (r *ReconcileVarnishCluster) reconcileGrafanaDashboard(ctx context.Context, instance *vcapi.VarnishCluster) (string, error) {
 	logr := logger.FromContext(ctx).With(logger.FieldComponent, vcapi.VarnishComponentGrafanaDashboard)
 	logr = logr.With(logger.FieldComponentName, names.GrafanaDashboard(instance.Name))
 	ctx = logger.ToContext(ctx, logr)
 
 	err := r.garbageCollectGrafanaDashboards(ctx, instance)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	if instance.Spec.Monitoring.GrafanaDashboard == nil ||
 		(instance.Spec.Monitoring.GrafanaDashboard != nil && !instance.Spec.Monitoring.GrafanaDashboard.Enabled) {
-		return nil
+		return "", nil
 	}
 
 	if instance.Spec.Monitoring.GrafanaDashboard.Title == "" {
@@ -49,22 +50,22 @@ func (r *ReconcileVarnishCluster) reconcileGrafanaDashboard(ctx context.Context,
 				errMsg := fmt.Sprintf("Can't install Grafana dashboard. Namespace %q doesn't exist", installationNamespace)
 				logr.Warn(errMsg)
 				r.events.Warning(instance, EventReasonNamespaceNotFound, errMsg)
-				return nil
+				return errMsg, nil
 			}
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 	}
 
 	grafanaDashboard, err := r.createGrafanaDashboardConfigMap(instance)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	if err := r.applyGrafanaDashboardConfigMap(ctx, instance, grafanaDashboard); err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
-	return nil
+	return "", nil
 }
 
 func (r *ReconcileVarnishCluster) applyGrafanaDashboardConfigMap(ctx context.Context, instance *vcapi.VarnishCluster, grafanaDashboard *v1.ConfigMap) error {
@@ -199,7 +200,9 @@ func generateGrafanaDashboardData(instance *vcapi.VarnishCluster) (map[string]st
 		"Namespace":      instance.Namespace,
 	}
 
-	t, err := template.New("GrafanaDashboard").Parse(grafanaDashboardTemplate)
+	t, err := template.New("GrafanaDashboard").Funcs(template.FuncMap{
+		"counter": dashboardCounterName(instance.Spec.Varnish.MetricsExporter),
+	}).Parse(grafanaDashboardTemplate)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -213,3 +216,18 @@ func generateGrafanaDashboardData(instance *vcapi.VarnishCluster) (map[string]st
 
 	return dashboardData, nil
 }
+
+// dashboardCounterName returns the function that converts the names of the counters used in the dashboard,
+// which are the names of the external exporter, to the names of the native naming of the builtin exporter
+func dashboardCounterName(exporter *vcapi.VarnishClusterVarnishMetricsExporter) func(string) string {
+	return func(name string) string {
+		if exporter == nil || exporter.Type != vcapi.VarnishMetricsExporterTypeBuiltin || exporter.Naming != vcapi.VarnishMetricsNamingNative {
+			return name
+		}
+		// MAIN.s_sess is renamed only for the compatibility with the external exporter
+		if name == "varnish_main_sessions_total" {
+			return "varnish_main_s_sess_total"
+		}
+		return name + "_total"
+	}
+}
@@ -103,7 +103,7 @@ var grafanaDashboardTemplate = `
       "tableColumn": "Value",
       "targets": [
         {
-          "expr": "avg(\n  (\n    rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + rate(varnish_main_cache_miss{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))\n  )\n)",
+          "expr": "avg(\n  (\n    rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + rate({{counter "varnish_main_cache_miss"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))\n  )\n)",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -193,7 +193,7 @@ var grafanaDashboardTemplate = `
       "tableColumn": "",
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])) + sum(irate(varnish_main_cache_miss{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])) + sum(irate({{counter "varnish_main_cache_miss"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "hide": false,
           "interval": "15s",
@@ -284,7 +284,7 @@ var grafanaDashboardTemplate = `
       "tableColumn": "frontend_for",
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_sessions_total{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_sessions_total"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -374,7 +374,7 @@ var grafanaDashboardTemplate = `
       "tableColumn": "",
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "interval": "15s",
           "intervalFactor": 1,
@@ -548,7 +548,7 @@ var grafanaDashboardTemplate = `
       "tableColumn": "__name__",
       "targets": [
         {
-          "expr": "min(varnish_main_uptime{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"})",
+          "expr": "min({{counter "varnish_main_uptime"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"})",
           "format": "time_series",
           "intervalFactor": 2,
           "legendFormat": "",
@@ -709,7 +709,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_cache_hit{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) + sum(irate(varnish_main_cache_miss{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_hit"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) + sum(irate({{counter "varnish_main_cache_miss"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "hide": false,
           "intervalFactor": 1,
@@ -798,14 +798,14 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_cache_hit{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_hit"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "cache hits",
           "refId": "A"
         },
         {
-          "expr": "sum(irate(varnish_main_cache_miss{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_miss"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "cache misses",
@@ -994,7 +994,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": true,
       "targets": [
         {
-          "expr": "avg(rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate(varnish_main_cache_miss{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])+rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])))",
+          "expr": "avg(rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate({{counter "varnish_main_cache_miss"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])+rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])))",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -1004,7 +1004,7 @@ var grafanaDashboardTemplate = `
           "step": 240
         },
         {
-          "expr": "avg(1 - (rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate(varnish_main_cache_miss{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])+rate(varnish_main_cache_hit{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))))",
+          "expr": "avg(1 - (rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) / (rate({{counter "varnish_main_cache_miss"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])+rate({{counter "varnish_main_cache_hit"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))))",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -1094,7 +1094,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_cache_hitpass{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_cache_hitpass"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "hide": true,
           "intervalFactor": 1,
@@ -1183,7 +1183,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_req_dropped{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_req_dropped"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "instant": false,
           "intervalFactor": 1,
@@ -1402,7 +1402,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_n_expired{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_n_expired"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "interval": "",
           "intervalFactor": 2,
@@ -1412,7 +1412,7 @@ var grafanaDashboardTemplate = `
           "step": 240
         },
         {
-          "expr": "sum(irate(varnish_main_n_lru_moved{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_n_lru_moved"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "interval": "",
           "intervalFactor": 2,
@@ -1421,7 +1421,7 @@ var grafanaDashboardTemplate = `
           "step": 240
         },
         {
-          "expr": "sum(irate(varnish_main_n_lru_nuked{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_n_lru_nuked"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "interval": "",
           "intervalFactor": 2,
@@ -1517,7 +1517,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_n_expired{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_n_expired"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "expired objects",
@@ -1635,7 +1635,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_s_resp_hdrbytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate(varnish_main_s_resp_bodybytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_s_resp_hdrbytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate({{counter "varnish_main_s_resp_bodybytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -1645,7 +1645,7 @@ var grafanaDashboardTemplate = `
           "step": 60
         },
         {
-          "expr": "sum(irate(varnish_backend_beresp_hdrbytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate(varnish_backend_beresp_bodybytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
+          "expr": "sum(irate({{counter "varnish_backend_beresp_hdrbytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate({{counter "varnish_backend_beresp_bodybytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]))",
           "format": "time_series",
           "hide": false,
           "interval": "",
@@ -1656,7 +1656,7 @@ var grafanaDashboardTemplate = `
           "step": 60
         },
         {
-          "expr": "sum(irate(varnish_backend_beresp_hdrbytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate(varnish_backend_beresp_bodybytes{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])) by (backend)",
+          "expr": "sum(irate({{counter "varnish_backend_beresp_hdrbytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m]) + irate({{counter "varnish_backend_beresp_bodybytes"}}{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[1m])) by (backend)",
           "format": "time_series",
           "intervalFactor": 2,
           "legendFormat": "backend {{"{{ backend }}"}}",
@@ -1743,7 +1743,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_n_lru_nuked{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_n_lru_nuked"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "nuked",
@@ -1845,7 +1845,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_busy_sleep{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_busy_sleep"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "requests",
@@ -1932,7 +1932,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_backend_retry{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_backend_retry"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "retries ",
@@ -2020,7 +2020,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_threads_limited{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
+          "expr": "sum(irate({{counter "varnish_main_threads_limited"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m]))",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "threads",
@@ -2107,7 +2107,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_sessions{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) by (type)",
+          "expr": "sum(irate({{counter "varnish_main_sessions"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) by (type)",
           "format": "time_series",
           "hide": false,
           "intervalFactor": 1,
@@ -2283,7 +2283,7 @@ var grafanaDashboardTemplate = `
       "steppedLine": false,
       "targets": [
         {
-          "expr": "sum(irate(varnish_main_fetch{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) by (type)",
+          "expr": "sum(irate({{counter "varnish_main_fetch"}}{ service=\"{{.ServiceName}}\", namespace=\"{{.Namespace}}\" }[1m])) by (type)",
           "format": "time_series",
           "intervalFactor": 1,
           "legendFormat": "{{"{{type}}"}}",
@@ -2330,6 +2330,568 @@ var grafanaDashboardTemplate = `
         "align": false,
         "alignLevel": null
       }
+    },
+    {
+      "collapsed": false,
+      "gridPos": {
+        "h": 1,
+        "w": 24,
+        "x": 0,
+        "y": 40
+      },
+      "id": 94,
+      "panels": [],
+      "title": "Varnish controller",
+      "type": "row"
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "Number of VCL reloads and failed reloads by reason within 5 minutes.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 0,
+        "y": 41
+      },
+      "id": 95,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "sum(increase(varnish_controller_reloads_total{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[5m]))",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "reloads",
+          "refId": "A"
+        },
+        {
+          "expr": "sum(increase(varnish_controller_reload_failures_total{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[5m])) by (reason)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "failed: {{"{{reason}}"}}",
+          "refId": "B"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "VCL reloads",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "95th percentile of the time it took to reload the VCL and to render the VCL templates.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 8,
+        "y": 41
+      },
+      "id": 96,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "histogram_quantile(0.95, sum(rate(varnish_controller_reload_duration_seconds_bucket{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[5m])) by (le))",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "reload",
+          "refId": "A"
+        },
+        {
+          "expr": "histogram_quantile(0.95, sum(rate(varnish_controller_template_render_duration_seconds_bucket{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}[5m])) by (le))",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "template render",
+          "refId": "B"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "VCL reload and template render time (p95)",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "s",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "Time since the VCL has been successfully reloaded on the pod the last time.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 16,
+        "y": 41
+      },
+      "id": 97,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "max(varnish_controller_seconds_since_last_successful_reload{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}) by (pod)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "{{"{{pod}}"}}",
+          "refId": "A"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "Time since last successful reload",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "s",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "Number of backends discovered by the varnish pods by zone and backend group.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 0,
+        "y": 47
+      },
+      "id": 98,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "max(varnish_controller_backends{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}) by (zone, group)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "{{"{{zone}}"}} {{"{{group}}"}}",
+          "refId": "A"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "Discovered backends",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "Weight of the backends in the zone of the pod and in the other zones.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 8,
+        "y": 47
+      },
+      "id": 99,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "max(varnish_controller_local_backends_weight{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}) by (pod)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "local {{"{{pod}}"}}",
+          "refId": "A"
+        },
+        {
+          "expr": "max(varnish_controller_remote_backends_weight{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}) by (pod)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "remote {{"{{pod}}"}}",
+          "refId": "B"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "Backends weight",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
+    },
+    {
+      "aliasColors": {},
+      "bars": false,
+      "dashLength": 10,
+      "dashes": false,
+      "datasource": "{{.DatasourceName}}",
+      "description": "Number of VCLs loaded in varnish, including the ones kept to switch back to.",
+      "fill": 1,
+      "gridPos": {
+        "h": 6,
+        "w": 8,
+        "x": 16,
+        "y": 47
+      },
+      "id": 100,
+      "legend": {
+        "avg": false,
+        "current": false,
+        "max": false,
+        "min": false,
+        "show": true,
+        "total": false,
+        "values": false
+      },
+      "lines": true,
+      "linewidth": 1,
+      "links": [],
+      "nullPointMode": "null",
+      "options": {},
+      "paceLength": 10,
+      "percentage": false,
+      "pointradius": 5,
+      "points": false,
+      "renderer": "flot",
+      "seriesOverrides": [],
+      "spaceLength": 10,
+      "stack": false,
+      "steppedLine": false,
+      "targets": [
+        {
+          "expr": "max(varnish_controller_loaded_vcls{service=\"{{.ServiceName}}\", pod=~\"^$varnish_pod$\", namespace=\"{{.Namespace}}\"}) by (pod)",
+          "format": "time_series",
+          "intervalFactor": 1,
+          "legendFormat": "{{"{{pod}}"}}",
+          "refId": "A"
+        }
+      ],
+      "thresholds": [],
+      "timeFrom": null,
+      "timeRegions": [],
+      "timeShift": null,
+      "title": "Loaded VCLs",
+      "tooltip": {
+        "shared": true,
+        "sort": 0,
+        "value_type": "individual"
+      },
+      "type": "graph",
+      "xaxis": {
+        "buckets": null,
+        "mode": "time",
+        "name": null,
+        "show": true,
+        "values": []
+      },
+      "yaxes": [
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": "0",
+          "show": true
+        },
+        {
+          "format": "short",
+          "label": null,
+          "logBase": 1,
+          "max": null,
+          "min": null,
+          "show": true
+        }
+      ],
+      "yaxis": {
+        "align": false,
+        "alignLevel": null
+      }
     }
   ],
   "refresh": "5s",
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
+	"testing"
 	"time"
 
 	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
@@ -178,3 +180,51 @@ var _ = Describe("grafana dashboard", func() {
 		})
 	})
 })
+
+func TestGenerateGrafanaDashboardDataNaming(t *testing.T) {
+	cases := []struct {
+		desc     string
+		exporter *vcapi.VarnishClusterVarnishMetricsExporter
+		expected []string
+	}{
+		{
+			desc:     "external exporter",
+			exporter: &vcapi.VarnishClusterVarnishMetricsExporter{Type: vcapi.VarnishMetricsExporterTypeExternal, Naming: vcapi.VarnishMetricsNamingCompatible},
+			expected: []string{"varnish_main_cache_hit{", "varnish_main_sessions_total{", "varnish_main_sessions{", "varnish_main_vmods{", "varnish_controller_reloads_total{"},
+		},
+		{
+			desc:     "builtin exporter with compatible naming",
+			exporter: &vcapi.VarnishClusterVarnishMetricsExporter{Type: vcapi.VarnishMetricsExporterTypeBuiltin, Naming: vcapi.VarnishMetricsNamingCompatible},
+			expected: []string{"varnish_main_cache_hit{", "varnish_main_sessions_total{", "varnish_main_sessions{", "varnish_main_vmods{", "varnish_controller_reloads_total{"},
+		},
+		{
+			desc:     "builtin exporter with native naming",
+			exporter: &vcapi.VarnishClusterVarnishMetricsExporter{Type: vcapi.VarnishMetricsExporterTypeBuiltin, Naming: vcapi.VarnishMetricsNamingNative},
+			expected: []string{"varnish_main_cache_hit_total{", "varnish_main_s_sess_total{", "varnish_main_sessions_total{", "varnish_main_vmods{", "varnish_controller_reloads_total{"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(tt *testing.T) {
+			g := NewGomegaWithT(tt)
+			instance := &vcapi.VarnishCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: vcapi.VarnishClusterSpec{
+					Varnish: &vcapi.VarnishClusterVarnish{MetricsExporter: tc.exporter},
+					Monitoring: &vcapi.VarnishClusterMonitoring{
+						GrafanaDashboard: &vcapi.VarnishClusterMonitoringGrafanaDashboard{DatasourceName: proto.String("Prometheus")},
+					},
+				},
+			}
+
+			data, err := generateGrafanaDashboardData(instance)
+			g.Expect(err).ToNot(HaveOccurred())
+			dashboard := data[names.GrafanaDashboardFile(instance.Name)]
+			for _, metric := range tc.expected {
+				g.Expect(strings.Contains(dashboard, metric)).To(BeTrue(), metric)
+			}
+			g.Expect(json.Unmarshal([]byte(dashboard), &map[string]interface{}{})).To(Succeed())
+			g.Expect(strings.Count(dashboard, "_total_total")).To(BeZero())
+		})
+	}
+}
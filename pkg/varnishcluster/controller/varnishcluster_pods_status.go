@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	annotationActiveVCLConfigName         = "activeVCLConfigName"
+	annotationLocalBackendsWeight         = "localBackendsWeight"
+	annotationRemoteBackendsWeight        = "remoteBackendsWeight"
+	annotationLastReloadTime              = "lastReloadTime"
+	annotationLastReloadError             = "lastReloadError"
+	annotationVCLRevertedConfigMapVersion = "vclRevertedConfigMapVersion"
+	annotationVCLResyncRequested          = "vclResyncRequested"
+
+	vclDriftTimer = "VCLDrift"
+)
+
+// reconcilePodsStatus reports the VCL state of every pod from the annotations set by the varnish-controllers.
+// Pods that stay on another ConfigMap version for longer than the drift threshold, while nothing holds them back,
+// are flagged as drifted and, if enabled, asked to load the current version again.
+func (r *ReconcileVarnishCluster) reconcilePodsStatus(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster, pods []v1.Pod) error {
+	logr := logger.FromContext(ctx)
+	drift := instance.Spec.VCL.Drift
+	threshold := 300 * time.Second
+	if drift != nil && drift.ThresholdSeconds != nil {
+		threshold = time.Duration(*drift.ThresholdSeconds) * time.Second
+	}
+
+	now := time.Now()
+	previous := make(map[string]vcapi.VarnishPodStatus, len(instanceStatus.Status.Pods))
+	for _, status := range instanceStatus.Status.Pods {
+		previous[status.Name] = status
+	}
+
+	pods = append([]v1.Pod(nil), pods...)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	statuses := make([]vcapi.VarnishPodStatus, 0, len(pods))
+	var nextCheck time.Duration
+	for i := range pods {
+		pod := &pods[i]
+		status := varnishPodStatus(pod)
+		if pod.Annotations[annotationConfigMapVersion] != instanceStatus.Status.VCL.ConfigMapVersion && vclUpdateExpected(instanceStatus.Status.VCL, pod) {
+			status.OutOfSyncSince = previous[pod.Name].OutOfSyncSince
+			if status.OutOfSyncSince == nil {
+				status.OutOfSyncSince = &metav1.Time{Time: now}
+			}
+			driftTime := status.OutOfSyncSince.Add(threshold)
+			status.Drifted = !now.Before(driftTime)
+			if !status.Drifted && (nextCheck == 0 || driftTime.Sub(now) < nextCheck) {
+				nextCheck = driftTime.Sub(now)
+			}
+		}
+		statuses = append(statuses, status)
+
+		if !status.Drifted {
+			continue
+		}
+		if !previous[pod.Name].Drifted {
+			logr.Warnw("Pod doesn't run the current ConfigMap version", "pod", pod.Name, "configMapVersion", instanceStatus.Status.VCL.ConfigMapVersion, "outOfSyncSince", status.OutOfSyncSince)
+			r.events.Warning(instance, EventReasonVCLDrift, "Pod "+pod.Name+" doesn't run the VCL of ConfigMap version "+instanceStatus.Status.VCL.ConfigMapVersion+" since "+status.OutOfSyncSince.UTC().Format(time.RFC3339))
+		}
+		if drift != nil && drift.Resync && pod.Annotations[annotationVCLResyncRequested] != instanceStatus.Status.VCL.ConfigMapVersion {
+			if err := r.requestVCLResync(ctx, pod, instanceStatus.Status.VCL.ConfigMapVersion); err != nil {
+				return err
+			}
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = nil
+	}
+	instanceStatus.Status.Pods = statuses
+
+	// the pods that are not drifted yet have to be checked again once their threshold is reached
+	if nextCheck > 0 {
+		r.reconcileTriggerer.TriggerAfter(vclDriftTimer, nextCheck, instance)
+	} else {
+		r.reconcileTriggerer.Stop(vclDriftTimer, instance)
+	}
+	return nil
+}
+
+func varnishPodStatus(pod *v1.Pod) vcapi.VarnishPodStatus {
+	status := vcapi.VarnishPodStatus{
+		Name:                 pod.Name,
+		ActiveVCL:            pod.Annotations[annotationActiveVCLConfigName],
+		ConfigMapVersion:     pod.Annotations[annotationConfigMapVersion],
+		VCLVersion:           pod.Annotations[annotationVCLVersion],
+		LastReloadError:      pod.Annotations[annotationLastReloadError],
+		LocalBackendsWeight:  pod.Annotations[annotationLocalBackendsWeight],
+		RemoteBackendsWeight: pod.Annotations[annotationRemoteBackendsWeight],
+	}
+	if reloadTime, err := time.Parse(time.RFC3339, pod.Annotations[annotationLastReloadTime]); err == nil {
+		status.LastReloadTime = &metav1.Time{Time: reloadTime}
+	}
+	return status
+}
+
+// vclUpdateExpected returns true if nothing holds the pod back from loading the current ConfigMap version
+func vclUpdateExpected(vcl vcapi.VCLStatus, pod *v1.Pod) bool {
+	if vcl.Validation == nil || vcl.Validation.ConfigMapVersion != vcl.ConfigMapVersion || vcl.Validation.Phase != vcapi.VCLValidationPhaseSucceeded {
+		return false
+	}
+	if rollout := vcl.Rollout; rollout != nil && rollout.ConfigMapVersion == vcl.ConfigMapVersion {
+		if rollout.Phase == vcapi.VCLRolloutPhaseAborted || (rollout.Phase == vcapi.VCLRolloutPhaseCanary && !isCanaryPod(rollout, pod.Name)) {
+			return false
+		}
+	}
+	// failed compilations are reported in .status.vcl.compilationError and not retried until the ConfigMap changes
+	if vcl.CompilationError != nil {
+		for _, name := range vcl.CompilationError.Pods {
+			if name == pod.Name {
+				return false
+			}
+		}
+	}
+	return pod.Annotations[annotationVCLRevertedConfigMapVersion] != vcl.ConfigMapVersion
+}
+
+// requestVCLResync makes the varnish-controller of the pod reload the VCL even if it thinks it's up to date
+func (r *ReconcileVarnishCluster) requestVCLResync(ctx context.Context, pod *v1.Pod, cmVersion string) error {
+	podCopy := pod.DeepCopy()
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+	podCopy.Annotations[annotationVCLResyncRequested] = cmVersion
+	logger.FromContext(ctx).Infow("Requesting the drifted pod to load the current ConfigMap version again", "pod", pod.Name, "configMapVersion", cmVersion)
+	if err := r.Update(ctx, podCopy); err != nil {
+		return errors.Wrapf(err, "can't request VCL resync of pod %s", pod.Name)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	vcreconcile "github.com/ibm/varnish-operator/pkg/varnishcluster/reconcile"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcilePodsStatus(t *testing.T) {
+	pod := func(name, cmVersion string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: map[string]string{
+			annotationConfigMapVersion:     cmVersion,
+			annotationActiveVCLConfigName:  "v-" + cmVersion,
+			annotationVCLVersion:           "v1",
+			annotationLastReloadTime:       "2026-10-17T10:00:00Z",
+			annotationLocalBackendsWeight:  "1",
+			annotationRemoteBackendsWeight: "1",
+		}}}
+	}
+	validated := vcapi.VCLStatus{
+		ConfigMapVersion: "1234",
+		Validation:       &vcapi.VCLValidationStatus{ConfigMapVersion: "1234", Phase: vcapi.VCLValidationPhaseSucceeded},
+	}
+	longAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	cases := []struct {
+		name              string
+		vcl               vcapi.VCLStatus
+		drift             *vcapi.VarnishClusterVCLDrift
+		previous          []vcapi.VarnishPodStatus
+		pods              []v1.Pod
+		expectedOutOfSync []string
+		expectedDrifted   []string
+		expectedResync    []string
+		expectedEvents    int
+	}{
+		{
+			name: "all pods in sync",
+			vcl:  validated,
+			pods: []v1.Pod{pod("varnish-1", "1234"), pod("varnish-0", "1234")},
+		},
+		{
+			name:              "pod starts loading the new version",
+			vcl:               validated,
+			pods:              []v1.Pod{pod("varnish-0", "1234"), pod("varnish-1", "1000")},
+			expectedOutOfSync: []string{"varnish-1"},
+		},
+		{
+			name:              "pod out of sync longer than the threshold",
+			vcl:               validated,
+			previous:          []vcapi.VarnishPodStatus{{Name: "varnish-1", OutOfSyncSince: &longAgo}},
+			pods:              []v1.Pod{pod("varnish-0", "1234"), pod("varnish-1", "1000")},
+			expectedOutOfSync: []string{"varnish-1"},
+			expectedDrifted:   []string{"varnish-1"},
+			expectedEvents:    1,
+		},
+		{
+			name:              "drifted pod is asked to resync",
+			vcl:               validated,
+			drift:             &vcapi.VarnishClusterVCLDrift{ThresholdSeconds: proto.Int32(0), Resync: true},
+			pods:              []v1.Pod{pod("varnish-0", "1000"), pod("varnish-1", "1234")},
+			expectedOutOfSync: []string{"varnish-0"},
+			expectedDrifted:   []string{"varnish-0"},
+			expectedResync:    []string{"varnish-0"},
+			expectedEvents:    1,
+		},
+		{
+			name:     "pre-flight check not finished",
+			vcl:      vcapi.VCLStatus{ConfigMapVersion: "1234"},
+			previous: []vcapi.VarnishPodStatus{{Name: "varnish-0", OutOfSyncSince: &longAgo}},
+			pods:     []v1.Pod{pod("varnish-0", "1000")},
+		},
+		{
+			name: "pod waits for the canaries",
+			vcl: vcapi.VCLStatus{
+				ConfigMapVersion: "1234",
+				Validation:       validated.Validation,
+				Rollout:          &vcapi.VCLRolloutStatus{ConfigMapVersion: "1234", Phase: vcapi.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-0"}},
+			},
+			drift:             &vcapi.VarnishClusterVCLDrift{ThresholdSeconds: proto.Int32(0)},
+			pods:              []v1.Pod{pod("varnish-0", "1000"), pod("varnish-1", "1000")},
+			expectedOutOfSync: []string{"varnish-0"},
+			expectedDrifted:   []string{"varnish-0"},
+			expectedEvents:    1,
+		},
+		{
+			name: "pod failed to compile the VCL",
+			vcl: vcapi.VCLStatus{
+				ConfigMapVersion: "1234",
+				Validation:       validated.Validation,
+				CompilationError: &vcapi.VCLCompilationErrorStatus{ConfigMapVersion: "1234", Pods: []string{"varnish-0"}},
+			},
+			drift: &vcapi.VarnishClusterVCLDrift{ThresholdSeconds: proto.Int32(0)},
+			pods:  []v1.Pod{pod("varnish-0", "1000")},
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		instance := &vcapi.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+			Spec:       vcapi.VarnishClusterSpec{VCL: &vcapi.VarnishClusterVCL{Drift: c.drift}},
+		}
+		instanceStatus := instance.DeepCopy()
+		instanceStatus.Status.VCL = c.vcl
+		instanceStatus.Status.Pods = c.previous
+
+		builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+		for i := range c.pods {
+			builder = builder.WithObjects(c.pods[i].DeepCopy())
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &ReconcileVarnishCluster{
+			Client:             builder.Build(),
+			events:             NewEventHandler(recorder),
+			reconcileTriggerer: vcreconcile.NewReconcileTriggerer(logger.NewNopLogger(), make(chan event.GenericEvent, 1)),
+		}
+
+		g.Expect(r.reconcilePodsStatus(context.Background(), instance, instanceStatus, c.pods)).To(gomega.Succeed())
+		g.Expect(instanceStatus.Status.Pods).To(gomega.HaveLen(len(c.pods)))
+
+		var outOfSync, drifted []string
+		for i, status := range instanceStatus.Status.Pods {
+			if i > 0 {
+				g.Expect(status.Name > instanceStatus.Status.Pods[i-1].Name).To(gomega.BeTrue())
+			}
+			g.Expect(status.ActiveVCL).To(gomega.Equal("v-" + status.ConfigMapVersion))
+			g.Expect(status.LastReloadTime.UTC().Format(time.RFC3339)).To(gomega.Equal("2026-10-17T10:00:00Z"))
+			if status.OutOfSyncSince != nil {
+				outOfSync = append(outOfSync, status.Name)
+			}
+			if status.Drifted {
+				drifted = append(drifted, status.Name)
+			}
+		}
+		g.Expect(outOfSync).To(gomega.Equal(c.expectedOutOfSync))
+		g.Expect(drifted).To(gomega.Equal(c.expectedDrifted))
+		g.Expect(recorder.Events).To(gomega.HaveLen(c.expectedEvents))
+
+		var resync []string
+		for _, p := range c.pods {
+			updated := &v1.Pod{}
+			g.Expect(r.Get(context.Background(), types.NamespacedName{Name: p.Name, Namespace: p.Namespace}, updated)).To(gomega.Succeed())
+			if updated.Annotations[annotationVCLResyncRequested] == "1234" {
+				resync = append(resync, p.Name)
+			}
+		}
+		g.Expect(resync).To(gomega.Equal(c.expectedResync))
+
+		for _, status := range c.previous {
+			for _, current := range instanceStatus.Status.Pods {
+				if current.Name == status.Name && current.OutOfSyncSince != nil {
+					g.Expect(current.OutOfSyncSince).To(gomega.Equal(status.OutOfSyncSince))
+				}
+			}
+		}
+	}
+}
@@ -36,6 +36,12 @@ func (r *ReconcileVarnishCluster) reconcileRole(ctx context.Context, instance *v
 				Resources: []string{"events"},
 				Verbs:     []string{"create", "patch"},
 			},
+			{
+				// the ConfigMaps with the transactions captured for VarnishLogSessions
+				APIGroups: []string{""},
+				Resources: []string{"configmaps"},
+				Verbs:     []string{"create", "update"},
+			},
 		},
 	}
 
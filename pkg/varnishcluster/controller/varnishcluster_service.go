@@ -17,14 +17,60 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// backendServiceIndex indexes VarnishClusters by the name of the Service referenced in .spec.backend.serviceRef
+const backendServiceIndex = "spec.backend.serviceRef.name"
+
+// backendServiceIndexValue returns the name of the Service the no cache service copies its selector from
+func backendServiceIndexValue(obj client.Object) []string {
+	vc, ok := obj.(*vcapi.VarnishCluster)
+	if !ok || vc.Spec.Backend == nil || vc.Spec.Backend.ServiceRef == nil {
+		return nil
+	}
+
+	serviceRef := vc.Spec.Backend.ServiceRef
+	if serviceRef.Namespace != "" && serviceRef.Namespace != vc.Namespace {
+		return nil
+	}
+
+	return []string{serviceRef.Name}
+}
+
+// backendServiceRequests returns the VarnishClusters that reference the Service in .spec.backend.serviceRef,
+// so the selector of their no cache service follows the changes of the backend Service
+func backendServiceRequests(ctx context.Context, c client.Reader, service client.Object) []ctrl.Request {
+	vcList := &vcapi.VarnishClusterList{}
+	err := c.List(ctx, vcList, client.InNamespace(service.GetNamespace()), client.MatchingFields{backendServiceIndex: service.GetName()})
+	if err != nil {
+		logger.FromContext(ctx).With("service", service.GetName()).Errorf("could not list VarnishClusters referencing the backend service: %s", err)
+		return nil
+	}
+
+	requests := make([]ctrl.Request, 0, len(vcList.Items))
+	for _, vc := range vcList.Items {
+		requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: vc.Namespace, Name: vc.Name}})
+	}
+	return requests
+}
+
 func (r *ReconcileVarnishCluster) reconcileServiceNoCache(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster) (map[string]string, error) {
-	selector := make(map[string]string, len(instance.Spec.Backend.Selector))
-	for k, v := range instance.Spec.Backend.Selector {
+	backendSelector, err := r.backendSelector(ctx, instance)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	selector := make(map[string]string, len(backendSelector))
+	for k, v := range backendSelector {
 		selector[k] = v
 	}
+	// external only backends don't have a port, so the service just has no endpoints
+	targetPort := intstr.FromInt(int(*instance.Spec.Service.Port))
+	if instance.Spec.Backend != nil && instance.Spec.Backend.Port != nil {
+		targetPort = *instance.Spec.Backend.Port
+	}
 	selectorLabels := vclabels.ComponentLabels(instance, vcapi.VarnishComponentNoCacheService)
 	inheritedLabels := vclabels.InheritLabels(instance)
 	svcLabels := make(map[string]string, len(selectorLabels)+len(inheritedLabels))
@@ -48,7 +94,7 @@ func (r *ReconcileVarnishCluster) reconcileServiceNoCache(ctx context.Context, i
 					Name:       "backend",
 					Protocol:   v1.ProtocolTCP,
 					Port:       *instance.Spec.Service.Port,
-					TargetPort: *instance.Spec.Backend.Port,
+					TargetPort: targetPort,
 				},
 			},
 			SessionAffinity: v1.ServiceAffinityNone,
@@ -66,6 +112,36 @@ func (r *ReconcileVarnishCluster) reconcileServiceNoCache(ctx context.Context, i
 	return selectorLabels, nil
 }
 
+// backendSelector returns the selector of the backend pods. For the backends discovered by serviceRef the selector of the Service is used.
+// The no cache service can't select pods in other namespaces, so the selector is empty if the Service is in another namespace.
+// Only the default backend is exposed by the no cache service, so the selector is empty if only backend groups are set.
+func (r *ReconcileVarnishCluster) backendSelector(ctx context.Context, instance *vcapi.VarnishCluster) (map[string]string, error) {
+	if instance.Spec.Backend == nil {
+		return nil, nil
+	}
+
+	serviceRef := instance.Spec.Backend.ServiceRef
+	if serviceRef == nil {
+		return instance.Spec.Backend.Selector, nil
+	}
+
+	if serviceRef.Namespace != "" && serviceRef.Namespace != instance.Namespace {
+		return nil, nil
+	}
+
+	backendService := &v1.Service{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: instance.Namespace, Name: serviceRef.Name}, backendService)
+	if err != nil {
+		if kerrors.IsNotFound(err) {
+			logger.FromContext(ctx).Warnw("Backend service is not found", "service", serviceRef.Name)
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not get backend service %s", serviceRef.Name)
+	}
+
+	return backendService.Spec.Selector, nil
+}
+
 func (r *ReconcileVarnishCluster) reconcileService(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster, varnishSelector map[string]string) error {
 	service := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// backendServiceIndexReader applies the backend service index the fake client doesn't support
+type backendServiceIndexReader struct {
+	client.Reader
+}
+
+func (r *backendServiceIndexReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := &client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	serviceName, _ := listOpts.FieldSelector.RequiresExactMatch(backendServiceIndex)
+
+	if err := r.Reader.List(ctx, list, client.InNamespace(listOpts.Namespace)); err != nil {
+		return err
+	}
+
+	vcList := list.(*vcapi.VarnishClusterList)
+	var items []vcapi.VarnishCluster
+	for _, vc := range vcList.Items {
+		for _, name := range backendServiceIndexValue(vc.DeepCopy()) {
+			if name == serviceName {
+				items = append(items, vc)
+			}
+		}
+	}
+	vcList.Items = items
+	return nil
+}
+
+func TestBackendServiceRequests(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+	g.Expect(vcapi.AddToScheme(scheme)).To(gomega.Succeed())
+
+	vc := func(namespace, name string, backend *vcapi.VarnishClusterBackend) *vcapi.VarnishCluster {
+		return &vcapi.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       vcapi.VarnishClusterSpec{Backend: backend},
+		}
+	}
+	serviceRef := func(namespace, name string) *vcapi.VarnishClusterBackend {
+		return &vcapi.VarnishClusterBackend{ServiceRef: &vcapi.VarnishClusterBackendServiceRef{Namespace: namespace, Name: name}}
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		vc("default", "same-namespace", serviceRef("", "backend")),
+		vc("default", "explicit-namespace", serviceRef("default", "backend")),
+		vc("default", "other-service", serviceRef("", "other")),
+		vc("default", "selector", &vcapi.VarnishClusterBackend{Selector: map[string]string{"app": "backend"}}),
+		vc("default", "only-groups", nil),
+		vc("default", "other-namespace-service", serviceRef("backends", "backend")),
+		vc("other", "other-namespace", serviceRef("", "backend")),
+	).Build()
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backend"}}
+	requests := backendServiceRequests(context.Background(), &backendServiceIndexReader{Reader: c}, service)
+	g.Expect(requests).To(gomega.ConsistOf(
+		ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "same-namespace"}},
+		ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "explicit-namespace"}},
+	))
+
+	// the no cache service can't select pods in other namespaces, so Services from other namespaces are not indexed
+	service = &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "backends", Name: "backend"}}
+	g.Expect(backendServiceRequests(context.Background(), &backendServiceIndexReader{Reader: c}, service)).To(gomega.BeEmpty())
+}
@@ -34,18 +34,19 @@ var serviceMonitorListGVK = schema.GroupVersionKind{
 	Kind:    "ServiceMonitorList",
 }
 
-func (r *ReconcileVarnishCluster) reconcileServiceMonitor(ctx context.Context, instance *vcapi.VarnishCluster) error {
+// reconcileServiceMonitor returns the reason the requested ServiceMonitor can't be installed, if any
+func (r *ReconcileVarnishCluster) reconcileServiceMonitor(ctx context.Context, instance *vcapi.VarnishCluster) (string, error) {
 	logr := logger.FromContext(ctx).With(logger.FieldComponent, vcapi.VarnishComponentPrometheusServiceMonitor)
 	logr = logr.With(logger.FieldComponentName, names.ServiceMonitor(instance.Name))
 	ctx = logger.ToContext(ctx, logr)
 
 	err := r.cleanupNotNeededServiceMonitors(ctx, instance)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
 	if !instance.Spec.Monitoring.PrometheusServiceMonitor.Enabled {
-		return nil
+		return "", nil
 	}
 
 	if instance.Spec.Monitoring.PrometheusServiceMonitor.Namespace != "" {
@@ -56,25 +57,22 @@ func (r *ReconcileVarnishCluster) reconcileServiceMonitor(ctx context.Context, i
 				errMsg := fmt.Sprintf("Can't install ServiceMonitor. Namespace %q doesn't exist", installationNamespace)
 				logger.FromContext(ctx).Warn(errMsg)
 				r.events.Warning(instance, EventReasonNamespaceNotFound, errMsg)
-				return nil
+				return errMsg, nil
 			}
-			return errors.WithStack(err)
+			return "", errors.WithStack(err)
 		}
 	}
 
 	serviceMonitor, err := r.createServiceMonitorObject(instance)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if err := r.applyServiceMonitor(ctx, instance, serviceMonitor); err != nil {
-		return errors.WithStack(err)
-	}
-
-	return nil
+	issue, err := r.applyServiceMonitor(ctx, instance, serviceMonitor)
+	return issue, errors.WithStack(err)
 }
 
-func (r *ReconcileVarnishCluster) applyServiceMonitor(ctx context.Context, instance *vcapi.VarnishCluster, serviceMonitor *unstructured.Unstructured) error {
+func (r *ReconcileVarnishCluster) applyServiceMonitor(ctx context.Context, instance *vcapi.VarnishCluster, serviceMonitor *unstructured.Unstructured) (string, error) {
 	logr := logger.FromContext(ctx)
 	found := &unstructured.Unstructured{}
 	found.SetGroupVersionKind(serviceMonitorGVK)
@@ -82,25 +80,26 @@ func (r *ReconcileVarnishCluster) applyServiceMonitor(ctx context.Context, insta
 	if err != nil && kerrors.IsNotFound(err) {
 		logr.Infoc("Creating ServiceMonitor", "new", serviceMonitor)
 		if err = r.Create(ctx, serviceMonitor); err != nil {
-			return errors.Wrap(err, "Unable to create ServiceMonitor")
+			return "", errors.Wrap(err, "Unable to create ServiceMonitor")
 		}
 	} else if _, ok := errors.Cause(err).(*meta.NoKindMatchError); ok {
-		r.events.Warning(instance, EventReasonServiceMonitorKindNotFound, "ServiceMonitor can't be installed. Prometheus operator needs to be installed first")
-		logr.Warn("ServiceMonitor can't be installed. Prometheus operator needs to be installed first")
-		return nil
+		errMsg := "ServiceMonitor can't be installed. Prometheus operator needs to be installed first"
+		r.events.Warning(instance, EventReasonServiceMonitorKindNotFound, errMsg)
+		logr.Warn(errMsg)
+		return errMsg, nil
 	} else if err != nil {
-		return errors.Wrap(err, "Could not get ServiceMonitor")
+		return "", errors.Wrap(err, "Could not get ServiceMonitor")
 	} else if !compare.EqualServiceMonitor(found, serviceMonitor) {
 		logr.Infoc("Updating ServiceMonitor", "diff", compare.DiffServiceMonitor(found, serviceMonitor))
 		found.Object["spec"] = serviceMonitor.Object["spec"]
 		found.SetLabels(serviceMonitor.GetLabels())
 		if err = r.Update(ctx, found); err != nil {
-			return errors.Wrap(err, "Unable to update ServiceMonitor")
+			return "", errors.Wrap(err, "Unable to update ServiceMonitor")
 		}
 	} else {
 		logr.Debugw("No updates for ServiceMonitor")
 	}
-	return nil
+	return "", nil
 }
 
 // Deletes ServiceMonitors that are no longer needed. For example if the namespace is changed, the resource from the previous namespace should be deleted.
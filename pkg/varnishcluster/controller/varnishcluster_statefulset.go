@@ -285,6 +285,10 @@ func (r *ReconcileVarnishCluster) reconcileStatefulSet(ctx context.Context, inst
 		}
 	}
 
+	if instance.Spec.Varnish.MetricsExporter.Type == vcapi.VarnishMetricsExporterTypeBuiltin {
+		useBuiltinMetricsExporter(&desired.Spec.Template.Spec, instance.Spec.Varnish.MetricsExporter.Naming)
+	}
+
 	logr := logger.FromContext(ctx).With(logger.FieldComponent, vcapi.VarnishComponentVarnish)
 	logr = logr.With(logger.FieldComponentName, desired.Name)
 
@@ -331,6 +335,30 @@ func (r *ReconcileVarnishCluster) reconcileStatefulSet(ctx context.Context, inst
 	return found, varnishLabels, nil
 }
 
+// useBuiltinMetricsExporter removes the metrics-exporter container and makes the varnish-controller export the varnish
+// counters on the metrics port instead, so the Service and the ServiceMonitor stay the same
+func useBuiltinMetricsExporter(podSpec *v1.PodSpec, naming string) {
+	containers := make([]v1.Container, 0, len(podSpec.Containers))
+	for _, container := range podSpec.Containers {
+		switch container.Name {
+		case vcapi.VarnishMetricsExporterName:
+			continue
+		case vcapi.VarnishControllerName:
+			container.Ports = append(container.Ports, v1.ContainerPort{
+				Name:          vcapi.VarnishMetricsPortName,
+				ContainerPort: vcapi.VarnishPrometheusExporterPort,
+				Protocol:      v1.ProtocolTCP,
+			})
+			container.Env = append(container.Env,
+				v1.EnvVar{Name: "METRICS_EXPORTER", Value: vcapi.VarnishMetricsExporterTypeBuiltin},
+				v1.EnvVar{Name: "METRICS_NAMING", Value: naming},
+			)
+		}
+		containers = append(containers, container)
+	}
+	podSpec.Containers = containers
+}
+
 func imageNameGenerate(specified, base, suffix string) string {
 	if specified != "" {
 		return specified
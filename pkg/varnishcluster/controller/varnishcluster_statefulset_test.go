@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"testing"
 	"time"
 
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -351,3 +352,34 @@ func getContainerPortByName(container v1.Container, name string) (v1.ContainerPo
 
 	return v1.ContainerPort{}, fmt.Errorf("container port %q not found", name)
 }
+
+func TestUseBuiltinMetricsExporter(t *testing.T) {
+	g := NewGomegaWithT(t)
+	podSpec := &v1.PodSpec{Containers: []v1.Container{
+		{Name: vcapi.VarnishContainerName},
+		{Name: vcapi.VarnishMetricsExporterName},
+		{
+			Name:  vcapi.VarnishControllerName,
+			Ports: []v1.ContainerPort{{Name: vcapi.VarnishControllerMetricsPortName, ContainerPort: vcapi.VarnishControllerMetricsPort}},
+			Env:   []v1.EnvVar{{Name: "NAMESPACE", Value: "default"}},
+		},
+	}}
+
+	useBuiltinMetricsExporter(podSpec, vcapi.VarnishMetricsNamingNative)
+
+	g.Expect(podSpec.Containers).To(Equal([]v1.Container{
+		{Name: vcapi.VarnishContainerName},
+		{
+			Name: vcapi.VarnishControllerName,
+			Ports: []v1.ContainerPort{
+				{Name: vcapi.VarnishControllerMetricsPortName, ContainerPort: vcapi.VarnishControllerMetricsPort},
+				{Name: vcapi.VarnishMetricsPortName, ContainerPort: vcapi.VarnishPrometheusExporterPort, Protocol: v1.ProtocolTCP},
+			},
+			Env: []v1.EnvVar{
+				{Name: "NAMESPACE", Value: "default"},
+				{Name: "METRICS_EXPORTER", Value: vcapi.VarnishMetricsExporterTypeBuiltin},
+				{Name: "METRICS_NAMING", Value: vcapi.VarnishMetricsNamingNative},
+			},
+		},
+	}))
+}
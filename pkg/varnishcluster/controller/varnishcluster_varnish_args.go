@@ -53,6 +53,11 @@ func getSanitizedVarnishArgs(spec *vcapi.VarnishClusterSpec) []string {
 	varnishArgs := append(parsedArgs, varnishArgsOverrides...)
 	varnishArgs = append(varnishArgs, []string{"-a", fmt.Sprintf("0.0.0.0:%d", vcapi.VarnishPort)})
 
+	// the rest of the parameters are set at runtime by the varnish-controller, so changing them doesn't restart the pods
+	for _, name := range vcapi.VarnishParametersRequiringRestart(spec.Varnish.Parameters) {
+		varnishArgs = append(varnishArgs, []string{"-p", name + "=" + spec.Varnish.Parameters[name]})
+	}
+
 	// sort the arguments so they won't appear in different order in different reconcile loops and trigger redeployment
 	sort.SliceStable(varnishArgs, func(i, j int) bool {
 		// we can't just compare by keys as there can be multiple equal keys that set different parameters
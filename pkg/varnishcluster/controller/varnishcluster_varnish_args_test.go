@@ -142,6 +142,25 @@ func TestGetSanitizedVarnishArgs(t *testing.T) {
 				"-p", "default_ttl=3600",
 			},
 		},
+		{
+			name: "only parameters that require a restart are passed as args",
+			spec: &v1alpha1.VarnishClusterSpec{
+				VCL: vclConfigMap,
+				Varnish: &v1alpha1.VarnishClusterVarnish{
+					Args:       []string{"-p", "default_grace=3600"},
+					Parameters: map[string]string{"default_ttl": "3600", "http_max_hdr": "128", "thread_pool_max": "5000"},
+				},
+			},
+			expectedResult: []string{
+				"-F",
+				"-S", "/etc/varnish-secret/secret",
+				"-T", fmt.Sprintf("0.0.0.0:%d", v1alpha1.VarnishAdminPort),
+				"-a", fmt.Sprintf("0.0.0.0:%d", v1alpha1.VarnishPort),
+				"-b", "127.0.0.1:0",
+				"-p", "default_grace=3600",
+				"-p", "http_max_hdr=128",
+			},
+		},
 	}
 
 	for _, c := range cases {
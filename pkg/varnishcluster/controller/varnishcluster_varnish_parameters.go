@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"encoding/json"
+	"sort"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	annotationVarnishParameters          = "varnishParameters"
+	annotationVarnishEffectiveParameters = "varnishEffectiveParameters"
+)
+
+// reconcileParametersStatus reports the effective values of the parameters from .spec.varnish.parameters.
+// The varnish-controllers apply the parameters at runtime and record the applied and effective values in the pod annotations.
+func (r *ReconcileVarnishCluster) reconcileParametersStatus(instance, instanceStatus *vcapi.VarnishCluster, pods []v1.Pod) {
+	desired := instance.Spec.Varnish.Parameters
+	if len(desired) == 0 {
+		instanceStatus.Status.Parameters = nil
+		return
+	}
+
+	// the value is taken from the first updated pod by name, so it doesn't flap between reconciles
+	pods = append([]v1.Pod(nil), pods...)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	applied := make([]map[string]string, len(pods))
+	effective := make([]map[string]string, len(pods))
+	for i, pod := range pods {
+		// pods that haven't reported the parameters yet are counted as not updated
+		_ = json.Unmarshal([]byte(pod.Annotations[annotationVarnishParameters]), &applied[i])
+		_ = json.Unmarshal([]byte(pod.Annotations[annotationVarnishEffectiveParameters]), &effective[i])
+	}
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]vcapi.VarnishParameterStatus, 0, len(names))
+	for _, name := range names {
+		status := vcapi.VarnishParameterStatus{
+			Name:            name,
+			RestartRequired: vcapi.VarnishParameterRequiresRestart(name),
+		}
+		for i := range pods {
+			if value, found := applied[i][name]; !found || value != desired[name] {
+				continue
+			}
+			status.UpdatedPods++
+			if status.Value == "" {
+				status.Value = effective[i][name]
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	instanceStatus.Status.Parameters = statuses
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"testing"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileParametersStatus(t *testing.T) {
+	pod := func(name, applied, effective string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{
+			annotationVarnishParameters:          applied,
+			annotationVarnishEffectiveParameters: effective,
+		}}}
+	}
+
+	cases := []struct {
+		name       string
+		parameters map[string]string
+		pods       []v1.Pod
+		expected   []vcapi.VarnishParameterStatus
+	}{
+		{
+			name: "no parameters",
+			pods: []v1.Pod{pod("varnish-0", `{"default_ttl":"3600"}`, `{"default_ttl":"3600.000"}`)},
+		},
+		{
+			name:       "parameters applied in all pods",
+			parameters: map[string]string{"default_ttl": "3600", "http_max_hdr": "128"},
+			pods: []v1.Pod{
+				pod("varnish-1", `{"default_ttl":"3600","http_max_hdr":"128"}`, `{"default_ttl":"3600.000","http_max_hdr":"128"}`),
+				pod("varnish-0", `{"default_ttl":"3600","http_max_hdr":"128"}`, `{"default_ttl":"3600.000","http_max_hdr":"128"}`),
+			},
+			expected: []vcapi.VarnishParameterStatus{
+				{Name: "default_ttl", Value: "3600.000", UpdatedPods: 2},
+				{Name: "http_max_hdr", Value: "128", UpdatedPods: 2, RestartRequired: true},
+			},
+		},
+		{
+			name:       "change in progress",
+			parameters: map[string]string{"default_ttl": "60", "http_max_hdr": "256"},
+			pods: []v1.Pod{
+				pod("varnish-0", `{"default_ttl":"3600","http_max_hdr":"128"}`, `{"default_ttl":"3600.000","http_max_hdr":"128"}`),
+				pod("varnish-1", `{"default_ttl":"60","http_max_hdr":"128"}`, `{"default_ttl":"60.000","http_max_hdr":"128"}`),
+				{ObjectMeta: metav1.ObjectMeta{Name: "varnish-2"}},
+			},
+			expected: []vcapi.VarnishParameterStatus{
+				{Name: "default_ttl", Value: "60.000", UpdatedPods: 1},
+				{Name: "http_max_hdr", UpdatedPods: 0, RestartRequired: true},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		instance := &vcapi.VarnishCluster{Spec: vcapi.VarnishClusterSpec{Varnish: &vcapi.VarnishClusterVarnish{Parameters: c.parameters}}}
+		instanceStatus := &vcapi.VarnishCluster{Status: vcapi.VarnishClusterStatus{Parameters: []vcapi.VarnishParameterStatus{{Name: "default_grace"}}}}
+
+		r := &ReconcileVarnishCluster{}
+		r.reconcileParametersStatus(instance, instanceStatus, c.pods)
+		g.Expect(instanceStatus.Status.Parameters).To(gomega.Equal(c.expected))
+	}
+}
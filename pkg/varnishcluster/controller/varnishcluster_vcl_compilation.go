@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"encoding/json"
+	"sort"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	annotationVCLCompilationErrorConfigMapVersion = "vclCompilationErrorConfigMapVersion"
+	annotationVCLCompilationErrorMessage          = "vclCompilationErrorMessage"
+	annotationVCLCompilationErrorDiagnostics      = "vclCompilationErrorDiagnostics"
+
+	// events with longer messages are rejected
+	maxEventMessageLength = 1024
+)
+
+// reconcileVCLCompilationError reports the pods that failed to compile the current ConfigMap version.
+// The varnish-controllers record the compiler output of the failed reload in the pod annotations.
+func (r *ReconcileVarnishCluster) reconcileVCLCompilationError(instanceStatus *vcapi.VarnishCluster, pods []v1.Pod) {
+	cmVersion := instanceStatus.Status.VCL.ConfigMapVersion
+
+	// the error is taken from the first failed pod by name, so it doesn't flap between reconciles
+	pods = append([]v1.Pod(nil), pods...)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	var compilationError *vcapi.VCLCompilationErrorStatus
+	for _, pod := range pods {
+		if cmVersion == "" || pod.Annotations[annotationVCLCompilationErrorConfigMapVersion] != cmVersion {
+			continue
+		}
+		if compilationError == nil {
+			compilationError = &vcapi.VCLCompilationErrorStatus{
+				ConfigMapVersion: cmVersion,
+				Message:          pod.Annotations[annotationVCLCompilationErrorMessage],
+				Diagnostics:      unmarshalVCLDiagnostics(pod.Annotations[annotationVCLCompilationErrorDiagnostics]),
+			}
+		}
+		compilationError.Pods = append(compilationError.Pods, pod.Name)
+	}
+	instanceStatus.Status.VCL.CompilationError = compilationError
+}
+
+// unmarshalVCLDiagnostics decodes the diagnostics from a pod annotation. Malformed values are ignored.
+func unmarshalVCLDiagnostics(value string) []vcapi.VCLDiagnostic {
+	if value == "" {
+		return nil
+	}
+	var diagnostics []vcapi.VCLDiagnostic
+	if err := json.Unmarshal([]byte(value), &diagnostics); err != nil {
+		return nil
+	}
+	return diagnostics
+}
+
+func truncateEventMessage(message string) string {
+	if len(message) <= maxEventMessageLength {
+		return message
+	}
+	return message[:maxEventMessageLength-3] + "..."
+}
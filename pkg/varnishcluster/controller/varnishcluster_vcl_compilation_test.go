@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestReconcileVCLCompilationError(t *testing.T) {
+	diagnostics := `[{"file":"entrypoint.vcl","line":12,"column":9,"message":"Symbol not found: 'req.foo'"}]`
+	pod := func(name, cmVersion string) v1.Pod {
+		return v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{
+			annotationVCLCompilationErrorConfigMapVersion: cmVersion,
+			annotationVCLCompilationErrorMessage:          "VCL compilation failed on " + name,
+			annotationVCLCompilationErrorDiagnostics:      diagnostics,
+		}}}
+	}
+
+	cases := []struct {
+		name     string
+		pods     []v1.Pod
+		expected *vcapi.VCLCompilationErrorStatus
+	}{
+		{
+			name: "no errors",
+			pods: []v1.Pod{{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0"}}},
+		},
+		{
+			name: "error for an outdated ConfigMap version",
+			pods: []v1.Pod{pod("varnish-0", "1000")},
+		},
+		{
+			name: "current ConfigMap version failed to compile",
+			pods: []v1.Pod{pod("varnish-1", "1234"), pod("varnish-0", "1234"), pod("varnish-2", "1000")},
+			expected: &vcapi.VCLCompilationErrorStatus{
+				ConfigMapVersion: "1234",
+				Pods:             []string{"varnish-0", "varnish-1"},
+				Message:          "VCL compilation failed on varnish-0",
+				Diagnostics: []vcapi.VCLDiagnostic{
+					{File: "entrypoint.vcl", Line: 12, Column: 9, Message: "Symbol not found: 'req.foo'"},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		instanceStatus := &vcapi.VarnishCluster{Status: vcapi.VarnishClusterStatus{VCL: vcapi.VCLStatus{
+			ConfigMapVersion: "1234",
+			CompilationError: &vcapi.VCLCompilationErrorStatus{ConfigMapVersion: "1000", Pods: []string{"varnish-0"}},
+		}}}
+
+		r := &ReconcileVarnishCluster{}
+		r.reconcileVCLCompilationError(instanceStatus, c.pods)
+		g.Expect(instanceStatus.Status.VCL.CompilationError).To(gomega.Equal(c.expected))
+	}
+}
+
+func TestTruncateEventMessage(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(truncateEventMessage("VCL failed")).To(gomega.Equal("VCL failed"))
+	truncated := truncateEventMessage(strings.Repeat("a", 2000))
+	g.Expect(truncated).To(gomega.HaveLen(maxEventMessageLength))
+	g.Expect(truncated).To(gomega.HaveSuffix("..."))
+}
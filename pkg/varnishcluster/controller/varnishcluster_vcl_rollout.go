@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	annotationConfigMapVersion          = "configMapVersion"
+	annotationVCLCanaryConfigMapVersion = "vclCanaryConfigMapVersion"
+	annotationVCLCanaryErrorRate        = "vclCanaryErrorRate"
+
+	// key for the timer that triggers the canary promotion after the bake time
+	vclCanaryRolloutTimer = "VCLCanaryRollout"
+)
+
+// reconcileVCLRollout drives the canary rollout of the current ConfigMap version. The new version is applied to the canary pods first
+// and promoted to the rest of the pods if the canary pods stayed healthy during the bake time. The rollout is aborted if the VCL
+// failed the pre-flight check or the error rate on a canary pod exceeded the configured maximum.
+func (r *ReconcileVarnishCluster) reconcileVCLRollout(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster, pods []v1.Pod) {
+	strategy := instance.Spec.VCL.RolloutStrategy
+	if strategy == nil || strategy.Type != vcapi.VCLRolloutStrategyCanary || strategy.Canary == nil {
+		instanceStatus.Status.VCL.Rollout = nil
+		r.reconcileTriggerer.Stop(vclCanaryRolloutTimer, instance)
+		return
+	}
+
+	logr := logger.FromContext(ctx)
+	cmVersion := instanceStatus.Status.VCL.ConfigMapVersion
+
+	rollout := instanceStatus.Status.VCL.Rollout
+	if rollout == nil || rollout.ConfigMapVersion != cmVersion {
+		rollout = &vcapi.VCLRolloutStatus{
+			ConfigMapVersion: cmVersion,
+			Phase:            vcapi.VCLRolloutPhaseCanary,
+		}
+		// there's nothing to protect if none of the pods run an another version (e.g. the cluster has just been created)
+		if !previousVCLVersionRunning(pods, cmVersion) {
+			rollout.Phase = vcapi.VCLRolloutPhasePromoted
+		}
+		r.reconcileTriggerer.Stop(vclCanaryRolloutTimer, instance)
+	} else {
+		rollout = rollout.DeepCopy()
+	}
+	instanceStatus.Status.VCL.Rollout = rollout
+
+	rollout.UpdatedPods = 0
+	for _, pod := range pods {
+		if pod.Annotations[annotationConfigMapVersion] == cmVersion {
+			rollout.UpdatedPods++
+		}
+	}
+
+	if rollout.Phase != vcapi.VCLRolloutPhaseCanary {
+		if rollout.Phase == vcapi.VCLRolloutPhasePromoted {
+			rollout.Message = fmt.Sprintf("%d/%d pods on ConfigMap version %s, promoted", rollout.UpdatedPods, len(pods), cmVersion)
+		}
+		return
+	}
+
+	validation := instanceStatus.Status.VCL.Validation
+	if validation != nil && validation.ConfigMapVersion == cmVersion && validation.Phase == vcapi.VCLValidationPhaseFailed {
+		r.abortVCLRollout(ctx, instance, rollout, "VCL failed the pre-flight compilation check")
+		return
+	}
+
+	rollout.CanaryPods = vclCanaryPods(pods, rollout.CanaryPods, strategy.Canary.Pods)
+
+	if strategy.Canary.MaxErrorRatePercent != nil {
+		for _, pod := range pods {
+			if !isCanaryPod(rollout, pod.Name) || pod.Annotations[annotationVCLCanaryConfigMapVersion] != cmVersion {
+				continue
+			}
+
+			errorRate, err := strconv.ParseFloat(pod.Annotations[annotationVCLCanaryErrorRate], 64)
+			if err != nil {
+				logr.Debugw("Can't parse canary error rate", "pod", pod.Name, "errorRate", pod.Annotations[annotationVCLCanaryErrorRate])
+				continue
+			}
+
+			if errorRate > float64(*strategy.Canary.MaxErrorRatePercent) {
+				r.abortVCLRollout(ctx, instance, rollout, fmt.Sprintf("error rate on pod %s is %.2f%%, maximum allowed is %d%%", pod.Name, errorRate, *strategy.Canary.MaxErrorRatePercent))
+				return
+			}
+		}
+	}
+
+	canaryPodsUpdated := 0
+	for _, pod := range pods {
+		if isCanaryPod(rollout, pod.Name) && pod.Annotations[annotationConfigMapVersion] == cmVersion {
+			canaryPodsUpdated++
+		}
+	}
+
+	if len(rollout.CanaryPods) == 0 || canaryPodsUpdated < len(rollout.CanaryPods) {
+		rollout.BakeStartTime = nil
+		rollout.Message = fmt.Sprintf("%d/%d pods on ConfigMap version %s, canary rolling out", rollout.UpdatedPods, len(pods), cmVersion)
+		return
+	}
+
+	if rollout.BakeStartTime == nil {
+		now := metav1.Now()
+		rollout.BakeStartTime = &now
+	}
+
+	bakeTime := time.Duration(*strategy.Canary.BakeTimeSeconds) * time.Second
+	promoteTime := rollout.BakeStartTime.Add(bakeTime)
+	if time.Now().Before(promoteTime) {
+		rollout.Message = fmt.Sprintf("%d/%d pods on ConfigMap version %s, canary healthy", rollout.UpdatedPods, len(pods), cmVersion)
+		if !r.reconcileTriggerer.TimerExists(vclCanaryRolloutTimer, instance) {
+			r.reconcileTriggerer.TriggerAfter(vclCanaryRolloutTimer, time.Until(promoteTime), instance)
+		}
+		return
+	}
+
+	logr.Infow("Canary pods stayed healthy during the bake time. Promoting the new VCL to all pods", "configMapVersion", cmVersion)
+	r.events.Normal(instance, EventReasonVCLRolloutPromoted, "VCL from ConfigMap version "+cmVersion+" has been promoted to all pods")
+	rollout.Phase = vcapi.VCLRolloutPhasePromoted
+	rollout.Message = fmt.Sprintf("%d/%d pods on ConfigMap version %s, promoted", rollout.UpdatedPods, len(pods), cmVersion)
+}
+
+func (r *ReconcileVarnishCluster) abortVCLRollout(ctx context.Context, instance *vcapi.VarnishCluster, rollout *vcapi.VCLRolloutStatus, reason string) {
+	logger.FromContext(ctx).Warnw("Canary rollout has been aborted: "+reason, "configMapVersion", rollout.ConfigMapVersion)
+	r.events.Warning(instance, EventReasonVCLRolloutAborted, "Canary rollout of ConfigMap version "+rollout.ConfigMapVersion+" has been aborted: "+reason)
+	r.reconcileTriggerer.Stop(vclCanaryRolloutTimer, instance)
+	rollout.Phase = vcapi.VCLRolloutPhaseAborted
+	rollout.BakeStartTime = nil
+	rollout.Message = "canary aborted: " + reason
+}
+
+// vclCanaryPods returns the pods that get the new version first. Already selected pods are kept while they exist,
+// the rest is selected by name.
+func vclCanaryPods(pods []v1.Pod, current []string, canaryPodsNumber *intstr.IntOrString) []string {
+	if len(pods) == 0 {
+		return current
+	}
+
+	count, err := intstr.GetScaledValueFromIntOrPercent(canaryPodsNumber, len(pods), true)
+	if err != nil || count < 1 {
+		count = 1
+	}
+	if count > len(pods) {
+		count = len(pods)
+	}
+
+	existing := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			existing[pod.Name] = true
+		}
+	}
+
+	var selected []string
+	for _, name := range current {
+		if existing[name] && len(selected) < count {
+			selected = append(selected, name)
+			delete(existing, name)
+		}
+	}
+
+	var candidates []string
+	for name := range existing {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	for _, name := range candidates {
+		if len(selected) >= count {
+			break
+		}
+		selected = append(selected, name)
+	}
+
+	return selected
+}
+
+func previousVCLVersionRunning(pods []v1.Pod, cmVersion string) bool {
+	for _, pod := range pods {
+		if version := pod.Annotations[annotationConfigMapVersion]; version != "" && version != cmVersion {
+			return true
+		}
+	}
+	return false
+}
+
+func isCanaryPod(rollout *vcapi.VCLRolloutStatus, podName string) bool {
+	for _, canaryPod := range rollout.CanaryPods {
+		if canaryPod == podName {
+			return true
+		}
+	}
+	return false
+}
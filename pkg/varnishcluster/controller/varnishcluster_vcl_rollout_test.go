@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	vcreconcile "github.com/ibm/varnish-operator/pkg/varnishcluster/reconcile"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcileVCLRollout(t *testing.T) {
+	onePod := intstr.FromInt(1)
+	halfOfPods := intstr.FromString("50%")
+	canary := func(pods *intstr.IntOrString, maxErrorRate *int32) *vcapi.VarnishClusterVCLRolloutStrategy {
+		return &vcapi.VarnishClusterVCLRolloutStrategy{
+			Type: vcapi.VCLRolloutStrategyCanary,
+			Canary: &vcapi.VarnishClusterVCLCanaryRollout{
+				Pods:                pods,
+				BakeTimeSeconds:     proto.Int32(60),
+				MaxErrorRatePercent: maxErrorRate,
+			},
+		}
+	}
+	pod := func(name, cmVersion string, annotations ...string) v1.Pod {
+		p := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{annotationConfigMapVersion: cmVersion}}}
+		for i := 0; i+1 < len(annotations); i += 2 {
+			p.Annotations[annotations[i]] = annotations[i+1]
+		}
+		return p
+	}
+	bakeStarted := func(ago time.Duration) *metav1.Time {
+		t := metav1.NewTime(time.Now().Add(-ago))
+		return &t
+	}
+
+	cases := []struct {
+		name              string
+		strategy          *vcapi.VarnishClusterVCLRolloutStrategy
+		rollout           *vcapi.VCLRolloutStatus
+		validation        *vcapi.VCLValidationStatus
+		pods              []v1.Pod
+		expectedPhase     string
+		expectedCanaries  []string
+		expectedMessage   string
+		expectBakeStarted bool
+		expectEvent       bool
+	}{
+		{
+			name:     "no rollout strategy",
+			strategy: nil,
+			rollout:  &vcapi.VCLRolloutStatus{ConfigMapVersion: "1", Phase: vcapi.VCLRolloutPhaseCanary},
+			pods:     []v1.Pod{pod("varnish-0", "1")},
+		},
+		{
+			name:            "no pods run a previous version",
+			strategy:        canary(&onePod, nil),
+			pods:            []v1.Pod{pod("varnish-0", ""), pod("varnish-1", "")},
+			expectedPhase:   vcapi.VCLRolloutPhasePromoted,
+			expectedMessage: "0/2 pods on ConfigMap version 2, promoted",
+		},
+		{
+			name:             "new version starts the canary",
+			strategy:         canary(&halfOfPods, nil),
+			pods:             []v1.Pod{pod("varnish-2", "1"), pod("varnish-1", "1"), pod("varnish-0", "1"), pod("varnish-3", "1")},
+			expectedPhase:    vcapi.VCLRolloutPhaseCanary,
+			expectedCanaries: []string{"varnish-0", "varnish-1"},
+			expectedMessage:  "0/4 pods on ConfigMap version 2, canary rolling out",
+		},
+		{
+			name:              "canary pods are baking",
+			strategy:          canary(&onePod, nil),
+			rollout:           &vcapi.VCLRolloutStatus{ConfigMapVersion: "2", Phase: vcapi.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-1"}},
+			pods:              []v1.Pod{pod("varnish-0", "1"), pod("varnish-1", "2")},
+			expectedPhase:     vcapi.VCLRolloutPhaseCanary,
+			expectedCanaries:  []string{"varnish-1"},
+			expectedMessage:   "1/2 pods on ConfigMap version 2, canary healthy",
+			expectBakeStarted: true,
+		},
+		{
+			name:             "canary pods stayed healthy during the bake time",
+			strategy:         canary(&onePod, proto.Int32(5)),
+			rollout:          &vcapi.VCLRolloutStatus{ConfigMapVersion: "2", Phase: vcapi.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-0"}, BakeStartTime: bakeStarted(2 * time.Minute)},
+			pods:             []v1.Pod{pod("varnish-0", "2", annotationVCLCanaryConfigMapVersion, "2", annotationVCLCanaryErrorRate, "1.50"), pod("varnish-1", "1")},
+			expectedPhase:    vcapi.VCLRolloutPhasePromoted,
+			expectedCanaries: []string{"varnish-0"},
+			expectedMessage:  "1/2 pods on ConfigMap version 2, promoted",
+			expectEvent:      true,
+		},
+		{
+			name:             "error rate on a canary pod is too high",
+			strategy:         canary(&onePod, proto.Int32(5)),
+			rollout:          &vcapi.VCLRolloutStatus{ConfigMapVersion: "2", Phase: vcapi.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-0"}, BakeStartTime: bakeStarted(time.Second)},
+			pods:             []v1.Pod{pod("varnish-0", "2", annotationVCLCanaryConfigMapVersion, "2", annotationVCLCanaryErrorRate, "12.00"), pod("varnish-1", "1")},
+			expectedPhase:    vcapi.VCLRolloutPhaseAborted,
+			expectedCanaries: []string{"varnish-0"},
+			expectedMessage:  "canary aborted: error rate on pod varnish-0 is 12.00%, maximum allowed is 5%",
+			expectEvent:      true,
+		},
+		{
+			name:            "VCL failed the pre-flight check",
+			strategy:        canary(&onePod, nil),
+			validation:      &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhaseFailed},
+			pods:            []v1.Pod{pod("varnish-0", "1")},
+			expectedPhase:   vcapi.VCLRolloutPhaseAborted,
+			expectedMessage: "canary aborted: VCL failed the pre-flight compilation check",
+			expectEvent:     true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+
+		instance := &vcapi.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec:       vcapi.VarnishClusterSpec{VCL: &vcapi.VarnishClusterVCL{RolloutStrategy: c.strategy}},
+			Status: vcapi.VarnishClusterStatus{
+				VCL: vcapi.VCLStatus{ConfigMapVersion: "2", Rollout: c.rollout, Validation: c.validation},
+			},
+		}
+		instanceStatus := instance.DeepCopy()
+
+		recorder := record.NewFakeRecorder(10)
+		triggerer := vcreconcile.NewReconcileTriggerer(logger.NewNopLogger(), make(chan event.GenericEvent, 1))
+		r := &ReconcileVarnishCluster{events: NewEventHandler(recorder), reconcileTriggerer: triggerer}
+		r.reconcileVCLRollout(context.Background(), instance, instanceStatus, c.pods)
+
+		rollout := instanceStatus.Status.VCL.Rollout
+		if c.strategy == nil {
+			g.Expect(rollout).To(gomega.BeNil())
+			continue
+		}
+
+		g.Expect(rollout).ToNot(gomega.BeNil())
+		g.Expect(rollout.Phase).To(gomega.Equal(c.expectedPhase))
+		g.Expect(rollout.CanaryPods).To(gomega.Equal(c.expectedCanaries))
+		g.Expect(rollout.Message).To(gomega.Equal(c.expectedMessage))
+		g.Expect(len(recorder.Events) > 0).To(gomega.Equal(c.expectEvent))
+		if c.expectBakeStarted {
+			g.Expect(rollout.BakeStartTime).ToNot(gomega.BeNil())
+			g.Expect(triggerer.TimerExists(vclCanaryRolloutTimer, instance)).To(gomega.BeTrue())
+			triggerer.Stop(vclCanaryRolloutTimer, instance)
+		}
+		// the status from the previous reconcile must not be modified
+		g.Expect(instance.Status.VCL.Rollout).To(gomega.Equal(c.rollout))
+	}
+}
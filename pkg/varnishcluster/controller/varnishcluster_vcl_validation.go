@@ -0,0 +1,168 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	vclabels "github.com/ibm/varnish-operator/pkg/labels"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/names"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	annotationVCLValidationConfigMapVersion = "vclValidationConfigMapVersion"
+	annotationVCLValidationPhase            = "vclValidationPhase"
+	annotationVCLValidationMessage          = "vclValidationMessage"
+	annotationVCLValidationDiagnostics      = "vclValidationDiagnostics"
+)
+
+// reconcileVCLValidation tracks the pre-flight compilation check of the current ConfigMap version.
+// One of the Varnish pods is selected to compile the VCL. The varnish-controllers don't apply the new version
+// until the check succeeds, so a VCL that doesn't compile never reaches the rest of the pods.
+// The versions that passed the check are copied for the pods that start before the current version passes it.
+func (r *ReconcileVarnishCluster) reconcileVCLValidation(ctx context.Context, instance, instanceStatus *vcapi.VarnishCluster, cm *v1.ConfigMap, pods []v1.Pod) error {
+	logr := logger.FromContext(ctx)
+	cmVersion := instanceStatus.Status.VCL.ConfigMapVersion
+
+	validation := instanceStatus.Status.VCL.Validation
+	if validation == nil || validation.ConfigMapVersion != cmVersion {
+		validation = &vcapi.VCLValidationStatus{
+			ConfigMapVersion: cmVersion,
+			Phase:            vcapi.VCLValidationPhasePending,
+		}
+	} else {
+		validation = validation.DeepCopy()
+	}
+	instanceStatus.Status.VCL.Validation = validation
+
+	if validation.Phase == vcapi.VCLValidationPhaseSucceeded {
+		return r.reconcileLastValidatedVCL(ctx, instance, cm)
+	}
+	if validation.Phase != vcapi.VCLValidationPhasePending {
+		return nil
+	}
+
+	validator := vclValidatorPod(pods, validation.Pod)
+	if validator == nil {
+		logr.Debugw("No Varnish pods are ready to do the pre-flight VCL check")
+		validation.Pod = ""
+		return nil
+	}
+	validation.Pod = validator.Name
+
+	if validator.Annotations[annotationVCLValidationConfigMapVersion] != cmVersion {
+		return nil
+	}
+
+	switch validator.Annotations[annotationVCLValidationPhase] {
+	case vcapi.VCLValidationPhaseSucceeded:
+		validation.Phase = vcapi.VCLValidationPhaseSucceeded
+		validation.Message = ""
+		validation.Diagnostics = nil
+		logr.Infow("VCL passed the pre-flight compilation check", "configMapVersion", cmVersion)
+		return r.reconcileLastValidatedVCL(ctx, instance, cm)
+	case vcapi.VCLValidationPhaseFailed:
+		validation.Phase = vcapi.VCLValidationPhaseFailed
+		validation.Message = validator.Annotations[annotationVCLValidationMessage]
+		validation.Diagnostics = unmarshalVCLDiagnostics(validator.Annotations[annotationVCLValidationDiagnostics])
+		logr.Warnw("VCL failed the pre-flight compilation check. The new ConfigMap version will not be applied", "configMapVersion", cmVersion)
+		msg := "VCL from ConfigMap version " + cmVersion + " failed the pre-flight compilation check"
+		if len(validation.Diagnostics) > 0 {
+			msg += ": " + validation.Diagnostics[0].String()
+		}
+		r.events.Warning(instance, EventReasonVCLValidationFailed, truncateEventMessage(msg+". See .status.vcl.validation for details"))
+	}
+	return nil
+}
+
+// reconcileLastValidatedVCL copies the ConfigMap version that passed the pre-flight check. Varnish serves only errors
+// until a VCL is loaded, so the pods that start while a newer version is checked, or after it failed the check,
+// load the copy instead of waiting.
+func (r *ReconcileVarnishCluster) reconcileLastValidatedVCL(ctx context.Context, instance *vcapi.VarnishCluster, cm *v1.ConfigMap) error {
+	name := names.LastValidatedVCLConfigMap(instance.Name)
+	logr := logger.FromContext(ctx).With(logger.FieldComponent, vcapi.VarnishComponentLastValidatedVCL)
+	logr = logr.With(logger.FieldComponentName, name)
+
+	desired := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   instance.Namespace,
+			Labels:      vclabels.CombinedComponentLabels(instance, vcapi.VarnishComponentLastValidatedVCL),
+			Annotations: map[string]string{annotationConfigMapVersion: cm.GetResourceVersion()},
+		},
+		Data: cm.Data,
+	}
+	if err := controllerutil.SetControllerReference(instance, desired, r.scheme); err != nil {
+		return errors.Wrap(err, "could not set controller as the OwnerReference for the last validated VCL ConfigMap")
+	}
+
+	found := &v1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: instance.Namespace}, found)
+	if err != nil && kerrors.IsNotFound(err) {
+		logr.Infow("Creating the last validated VCL ConfigMap", "configMapVersion", cm.GetResourceVersion())
+		if err = r.Create(ctx, desired); err != nil {
+			return errors.Wrap(err, "could not create the last validated VCL ConfigMap")
+		}
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "could not get the last validated VCL ConfigMap")
+	}
+
+	if reflect.DeepEqual(found.Labels, desired.Labels) && reflect.DeepEqual(found.Annotations, desired.Annotations) &&
+		reflect.DeepEqual(found.OwnerReferences, desired.OwnerReferences) && reflect.DeepEqual(found.Data, desired.Data) {
+		logr.Debugw("No updates for the last validated VCL ConfigMap")
+		return nil
+	}
+
+	found.Labels, found.Annotations, found.OwnerReferences, found.Data = desired.Labels, desired.Annotations, desired.OwnerReferences, desired.Data
+	logr.Infow("Updating the last validated VCL ConfigMap", "configMapVersion", cm.GetResourceVersion())
+	if err = r.Update(ctx, found); err != nil {
+		return errors.Wrap(err, "could not update the last validated VCL ConfigMap")
+	}
+	return nil
+}
+
+// vclValidatorPod returns the pod that should compile the VCL. The currently selected pod is kept while it's usable,
+// otherwise the first pod (by name) with a running varnish-controller is selected.
+// The varnish-controller doesn't have to be ready: it's not ready until a VCL is loaded, and the VCL can't be loaded
+// before it passed the check, so the pods on the boot VCL have to be able to do the check.
+func vclValidatorPod(pods []v1.Pod, current string) *v1.Pod {
+	var candidates []*v1.Pod
+	for i := range pods {
+		if pods[i].DeletionTimestamp != nil || !varnishControllerRunning(pods[i]) {
+			continue
+		}
+		if pods[i].Name == current {
+			return &pods[i]
+		}
+		candidates = append(candidates, &pods[i])
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Name < candidates[j].Name })
+	return candidates[0]
+}
+
+func varnishControllerRunning(pod v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == vcapi.VarnishControllerName {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vcapi "github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/names"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func validationTestPod(name string, ready bool, annotations map[string]string) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Annotations: annotations},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  vcapi.VarnishControllerName,
+				Ready: ready,
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
+
+// bootPod is a pod that has no VCL loaded yet, so its varnish-controller is running but not ready
+func bootPod(name string, annotations map[string]string) v1.Pod {
+	return validationTestPod(name, false, annotations)
+}
+
+// stoppedPod is a pod whose varnish-controller doesn't run, e.g. because it's crashing
+func stoppedPod(name string) v1.Pod {
+	pod := validationTestPod(name, false, nil)
+	pod.Status.ContainerStatuses[0].State = v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	return pod
+}
+
+func TestVCLValidatorPod(t *testing.T) {
+	deleted := validationTestPod("varnish-0", true, nil)
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+	cases := []struct {
+		name     string
+		pods     []v1.Pod
+		current  string
+		expected string
+	}{
+		{
+			name:     "no pods",
+			pods:     nil,
+			expected: "",
+		},
+		{
+			name:     "first pod by name",
+			pods:     []v1.Pod{validationTestPod("varnish-2", true, nil), validationTestPod("varnish-1", true, nil)},
+			expected: "varnish-1",
+		},
+		{
+			name:     "current pod is kept",
+			pods:     []v1.Pod{validationTestPod("varnish-1", true, nil), validationTestPod("varnish-2", true, nil)},
+			current:  "varnish-2",
+			expected: "varnish-2",
+		},
+		{
+			name:     "current pod is replaced if not usable",
+			pods:     []v1.Pod{validationTestPod("varnish-1", true, nil), stoppedPod("varnish-2")},
+			current:  "varnish-2",
+			expected: "varnish-1",
+		},
+		{
+			name:     "pods on the boot VCL are used",
+			pods:     []v1.Pod{bootPod("varnish-2", nil), bootPod("varnish-1", nil)},
+			expected: "varnish-1",
+		},
+		{
+			name:     "pods that are not running are skipped",
+			pods:     []v1.Pod{stoppedPod("varnish-0"), bootPod("varnish-1", nil)},
+			expected: "varnish-1",
+		},
+		{
+			name:     "deleted pods are skipped",
+			pods:     []v1.Pod{deleted, validationTestPod("varnish-1", true, nil)},
+			expected: "varnish-1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		validator := vclValidatorPod(c.pods, c.current)
+		if c.expected == "" {
+			g.Expect(validator).To(gomega.BeNil())
+			continue
+		}
+		g.Expect(validator).ToNot(gomega.BeNil())
+		g.Expect(validator.Name).To(gomega.Equal(c.expected))
+	}
+}
+
+func TestReconcileVCLValidation(t *testing.T) {
+	checked := func(phase string) map[string]string {
+		return map[string]string{annotationVCLValidationConfigMapVersion: "2", annotationVCLValidationPhase: phase}
+	}
+
+	cases := []struct {
+		name                  string
+		validation            *vcapi.VCLValidationStatus
+		pods                  []v1.Pod
+		expectedPhase         string
+		expectedPod           string
+		expectEvent           bool
+		expectLastValidated   bool
+		existingLastValidated string
+	}{
+		{
+			name:          "new version is scheduled for the check",
+			validation:    &vcapi.VCLValidationStatus{ConfigMapVersion: "1", Phase: vcapi.VCLValidationPhaseSucceeded},
+			pods:          []v1.Pod{validationTestPod("varnish-0", true, nil)},
+			expectedPhase: vcapi.VCLValidationPhasePending,
+			expectedPod:   "varnish-0",
+		},
+		{
+			name:          "no pod to do the check",
+			validation:    nil,
+			pods:          []v1.Pod{stoppedPod("varnish-0")},
+			expectedPhase: vcapi.VCLValidationPhasePending,
+			expectedPod:   "",
+		},
+		{
+			name:          "all pods on the boot VCL",
+			validation:    nil,
+			pods:          []v1.Pod{bootPod("varnish-1", nil), bootPod("varnish-0", nil)},
+			expectedPhase: vcapi.VCLValidationPhasePending,
+			expectedPod:   "varnish-0",
+		},
+		{
+			name:                "check succeeded on a pod on the boot VCL",
+			validation:          &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhasePending, Pod: "varnish-0"},
+			pods:                []v1.Pod{bootPod("varnish-0", checked(vcapi.VCLValidationPhaseSucceeded)), bootPod("varnish-1", nil)},
+			expectedPhase:       vcapi.VCLValidationPhaseSucceeded,
+			expectedPod:         "varnish-0",
+			expectLastValidated: true,
+		},
+		{
+			name:          "check is not done yet",
+			validation:    &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhasePending, Pod: "varnish-0"},
+			pods:          []v1.Pod{validationTestPod("varnish-0", true, map[string]string{annotationVCLValidationConfigMapVersion: "1"})},
+			expectedPhase: vcapi.VCLValidationPhasePending,
+			expectedPod:   "varnish-0",
+		},
+		{
+			name:                "check succeeded",
+			validation:          &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhasePending, Pod: "varnish-0"},
+			pods:                []v1.Pod{validationTestPod("varnish-0", true, checked(vcapi.VCLValidationPhaseSucceeded))},
+			expectedPhase:       vcapi.VCLValidationPhaseSucceeded,
+			expectedPod:         "varnish-0",
+			expectLastValidated: true,
+		},
+		{
+			name:                  "outdated copy of the last validated version is updated",
+			validation:            &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhaseSucceeded, Pod: "varnish-0"},
+			pods:                  []v1.Pod{validationTestPod("varnish-0", true, nil)},
+			expectedPhase:         vcapi.VCLValidationPhaseSucceeded,
+			expectedPod:           "varnish-0",
+			expectLastValidated:   true,
+			existingLastValidated: "1",
+		},
+		{
+			name:                  "check failed",
+			validation:            &vcapi.VCLValidationStatus{ConfigMapVersion: "2", Phase: vcapi.VCLValidationPhasePending, Pod: "varnish-0"},
+			pods:                  []v1.Pod{validationTestPod("varnish-0", true, checked(vcapi.VCLValidationPhaseFailed))},
+			expectedPhase:         vcapi.VCLValidationPhaseFailed,
+			expectedPod:           "varnish-0",
+			expectEvent:           true,
+			existingLastValidated: "1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+
+		s := runtime.NewScheme()
+		g.Expect(clientgoscheme.AddToScheme(s)).To(gomega.Succeed())
+		g.Expect(vcapi.AddToScheme(s)).To(gomega.Succeed())
+
+		instance := &vcapi.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default", UID: "uid"},
+			Status:     vcapi.VarnishClusterStatus{VCL: vcapi.VCLStatus{ConfigMapVersion: "2", Validation: c.validation}},
+		}
+		instanceStatus := instance.DeepCopy()
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "vcl", Namespace: "default", ResourceVersion: "2"},
+			Data:       map[string]string{"entrypoint.vcl": "vcl 4.1; // 2"},
+		}
+
+		builder := fake.NewClientBuilder().WithScheme(s)
+		if c.existingLastValidated != "" {
+			builder = builder.WithObjects(&v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        names.LastValidatedVCLConfigMap(instance.Name),
+					Namespace:   "default",
+					Annotations: map[string]string{annotationConfigMapVersion: c.existingLastValidated},
+				},
+				Data: map[string]string{"entrypoint.vcl": "vcl 4.1; // " + c.existingLastValidated},
+			})
+		}
+		recorder := record.NewFakeRecorder(10)
+		r := &ReconcileVarnishCluster{Client: builder.Build(), scheme: s, events: NewEventHandler(recorder)}
+
+		g.Expect(r.reconcileVCLValidation(context.Background(), instance, instanceStatus, cm, c.pods)).To(gomega.Succeed())
+
+		validation := instanceStatus.Status.VCL.Validation
+		g.Expect(validation.ConfigMapVersion).To(gomega.Equal("2"))
+		g.Expect(validation.Phase).To(gomega.Equal(c.expectedPhase))
+		g.Expect(validation.Pod).To(gomega.Equal(c.expectedPod))
+		g.Expect(len(recorder.Events) > 0).To(gomega.Equal(c.expectEvent))
+		// the status from the previous reconcile must not be modified
+		g.Expect(instance.Status.VCL.Validation).To(gomega.Equal(c.validation))
+
+		lastValidated := &v1.ConfigMap{}
+		err := r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: names.LastValidatedVCLConfigMap(instance.Name)}, lastValidated)
+		switch {
+		case c.expectLastValidated:
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(lastValidated.Annotations[annotationConfigMapVersion]).To(gomega.Equal("2"))
+			g.Expect(lastValidated.Data).To(gomega.Equal(cm.Data))
+			g.Expect(lastValidated.OwnerReferences).To(gomega.HaveLen(1))
+		case c.existingLastValidated != "":
+			// a version that failed the check doesn't replace the last validated one
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(lastValidated.Annotations[annotationConfigMapVersion]).To(gomega.Equal(c.existingLastValidated))
+		default:
+			g.Expect(err).To(gomega.HaveOccurred())
+		}
+	}
+}
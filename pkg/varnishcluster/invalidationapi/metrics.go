@@ -0,0 +1,46 @@
+package invalidationapi
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Metrics are the Prometheus metrics of the invalidation API
+type Metrics struct {
+	Requests *prometheus.CounterVec
+	Duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the metrics of the invalidation API. They have to be registered by the caller.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "varnish_operator_invalidation_api_requests_total",
+				Help: "Number of invalidation API requests by VarnishCluster, type (ban or purge) and HTTP status code.",
+			},
+			[]string{"namespace", "varnish_cluster", "type", "code"},
+		),
+		Duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "varnish_operator_invalidation_api_request_duration_seconds",
+				Help:    "Time it took to invalidate the cache on all varnish pods of the VarnishCluster.",
+				Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+			},
+			[]string{"namespace", "varnish_cluster", "type"},
+		),
+	}
+}
+
+// Collectors returns all metrics to register
+func (m *Metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Requests, m.Duration}
+}
+
+func (m *Metrics) observe(cluster types.NamespacedName, invalidationType string, status int, duration time.Duration) {
+	m.Requests.WithLabelValues(cluster.Namespace, cluster.Name, invalidationType, strconv.Itoa(status)).Inc()
+	m.Duration.WithLabelValues(cluster.Namespace, cluster.Name, invalidationType).Observe(duration.Seconds())
+}
@@ -0,0 +1,370 @@
+package invalidationapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcluster/controller"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// AnnotationClient is set on the VarnishInvalidations created through the API. The value is the name of the token used.
+	AnnotationClient = "caching.ibm.com/invalidation-api-client"
+
+	invalidationTypeBan   = "ban"
+	invalidationTypePurge = "purge"
+
+	maxRequestBodySize = 1 << 20
+
+	// allNamespaces in Token.Namespaces allows the client to invalidate the VarnishClusters in any namespace
+	allNamespaces = "*"
+)
+
+// Token is the value of a key in the tokens Secret, in JSON. The key is the name of the client.
+type Token struct {
+	Token string `json:"token"`
+	// Namespaces the client can invalidate the VarnishClusters in. "*" allows all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// VarnishClusters the client can invalidate, in the namespace/name format
+	Clusters []string `json:"clusters,omitempty"`
+	// AllowBroad allows the client to request invalidations with allowBroad
+	AllowBroad bool `json:"allowBroad,omitempty"`
+}
+
+// BanRequest is the body of POST /clusters/{namespace}/{name}/ban
+type BanRequest struct {
+	Expression string `json:"expression"`
+	AllowBroad bool   `json:"allowBroad,omitempty"`
+}
+
+// PurgeRequest is the body of POST /clusters/{namespace}/{name}/purge
+type PurgeRequest struct {
+	URL           string   `json:"url,omitempty"`
+	URLRegex      string   `json:"urlRegex,omitempty"`
+	Host          string   `json:"host,omitempty"`
+	SurrogateKeys []string `json:"surrogateKeys,omitempty"`
+	AllowBroad    bool     `json:"allowBroad,omitempty"`
+}
+
+// Response is the result of the invalidation on every varnish pod of the cluster
+type Response struct {
+	Invalidation string                                  `json:"invalidation,omitempty"`
+	Phase        string                                  `json:"phase,omitempty"`
+	Expression   string                                  `json:"expression,omitempty"`
+	Pods         []v1alpha1.VarnishInvalidationPodStatus `json:"pods,omitempty"`
+	Error        string                                  `json:"error,omitempty"`
+}
+
+// Server serves the HTTP API that invalidates the cache of a VarnishCluster. The API creates a VarnishInvalidation,
+// waits until it has been executed on every varnish pod and returns the results.
+type Server struct {
+	client       client.Client
+	logger       *logger.Logger
+	eventHandler *controller.EventHandler
+	metrics      *Metrics
+	port         int32
+	// the directory with tls.crt and tls.key the API is served with. Plain HTTP is served if empty.
+	certDir string
+	// the Secret in tokenNamespace that holds the tokens, one per key. The key is the name of the client.
+	tokenNamespace string
+	tokenSecret    string
+	timeout        time.Duration
+	pollInterval   time.Duration
+}
+
+// NewServer creates the invalidation API server
+func NewServer(c client.Client, logr *logger.Logger, eventHandler *controller.EventHandler, metrics *Metrics, port int32, certDir, tokenNamespace, tokenSecret string, timeout time.Duration) *Server {
+	return &Server{
+		client:         c,
+		logger:         logr.With(logger.FieldComponent, "invalidation-api"),
+		eventHandler:   eventHandler,
+		metrics:        metrics,
+		port:           port,
+		certDir:        certDir,
+		tokenNamespace: tokenNamespace,
+		tokenSecret:    tokenSecret,
+		timeout:        timeout,
+		pollInterval:   500 * time.Millisecond,
+	}
+}
+
+var _ manager.Runnable = &Server{}
+var _ manager.LeaderElectionRunnable = &Server{}
+
+// NeedLeaderElection returns false so every operator replica serves the API
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves the API until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	if s.certDir == "" {
+		go func() {
+			s.logger.Infof("Serving the invalidation API on port %d", s.port)
+			errs <- srv.ListenAndServe()
+		}()
+	} else {
+		// the certificate is reloaded when it's rotated, the same way the webhook server does it
+		watcher, err := certwatcher.New(filepath.Join(s.certDir, "tls.crt"), filepath.Join(s.certDir, "tls.key"))
+		if err != nil {
+			return errors.Wrap(err, "could not load the invalidation API certificate")
+		}
+		go func() {
+			if err := watcher.Start(ctx); err != nil {
+				s.logger.Errorw("Can't watch the invalidation API certificate", zap.Error(err))
+			}
+		}()
+		srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: watcher.GetCertificate}
+		go func() {
+			s.logger.Infof("Serving the invalidation API over TLS on port %d", s.port)
+			errs <- srv.ListenAndServeTLS("", "")
+		}()
+	}
+
+	select {
+	case err := <-errs:
+		return errors.Wrap(err, "invalidation API server failed")
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return errors.WithStack(srv.Shutdown(shutdownCtx))
+	}
+}
+
+// ServeHTTP handles POST /clusters/{namespace}/{name}/ban and POST /clusters/{namespace}/{name}/purge
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 4 || parts[0] != "clusters" || (parts[3] != invalidationTypeBan && parts[3] != invalidationTypePurge) {
+		writeResponse(w, http.StatusNotFound, Response{Error: "not found"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, Response{Error: "method not allowed"})
+		return
+	}
+
+	cluster := types.NamespacedName{Namespace: parts[1], Name: parts[2]}
+	invalidationType := parts[3]
+	start := time.Now()
+	status, resp := s.invalidate(r, cluster, invalidationType)
+	if status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound {
+		// don't let unauthenticated clients create metric series for arbitrary clusters
+		cluster = types.NamespacedName{}
+	}
+	s.metrics.observe(cluster, invalidationType, status, time.Since(start))
+	writeResponse(w, status, resp)
+}
+
+func (s *Server) invalidate(r *http.Request, cluster types.NamespacedName, invalidationType string) (int, Response) {
+	ctx := r.Context()
+	logr := s.logger.With(logger.FieldNamespace, cluster.Namespace, logger.FieldVarnishCluster, cluster.Name)
+
+	clientName, token, err := s.authenticate(ctx, r)
+	if err != nil {
+		logr.Warnw("Unauthorized invalidation request", zap.Error(err))
+		return http.StatusUnauthorized, Response{Error: "unauthorized"}
+	}
+	logr = logr.With("client", clientName)
+
+	spec, err := invalidationSpec(r, invalidationType)
+	if err != nil {
+		return http.StatusBadRequest, Response{Error: err.Error()}
+	}
+	spec.VarnishCluster = cluster.Name
+
+	// checked before the VarnishCluster is read, to not reveal which clusters exist
+	if err = authorize(token, cluster, spec); err != nil {
+		logr.Warnw("Forbidden invalidation request", zap.Error(err))
+		return http.StatusForbidden, Response{Error: err.Error()}
+	}
+
+	vc := &v1alpha1.VarnishCluster{}
+	if err = s.client.Get(ctx, cluster, vc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return http.StatusNotFound, Response{Error: fmt.Sprintf("VarnishCluster %s not found", cluster)}
+		}
+		logr.Errorw("Can't get VarnishCluster", zap.Error(err))
+		return http.StatusInternalServerError, Response{Error: "can't get VarnishCluster"}
+	}
+
+	inv := &v1alpha1.VarnishInvalidation{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: cluster.Name + "-" + invalidationType + "-",
+			Namespace:    cluster.Namespace,
+			Annotations:  map[string]string{AnnotationClient: clientName},
+		},
+		Spec: spec,
+	}
+	// the validating webhook may be disabled, so validate here as well
+	if _, err = inv.BanExpression(); err != nil {
+		return http.StatusBadRequest, Response{Error: err.Error()}
+	}
+	if err = s.client.Create(ctx, inv); err != nil {
+		if apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) || apierrors.IsForbidden(err) {
+			return http.StatusBadRequest, Response{Error: err.Error()}
+		}
+		logr.Errorw("Can't create VarnishInvalidation", zap.Error(err))
+		return http.StatusInternalServerError, Response{Error: "can't create VarnishInvalidation"}
+	}
+	logr = logr.With("varnishInvalidation", inv.Name)
+	logr.Infow("Invalidation requested", "type", invalidationType)
+
+	inv, err = s.waitFinished(ctx, inv)
+	if err != nil {
+		logr.Errorw("Can't get VarnishInvalidation", zap.Error(err))
+		return http.StatusInternalServerError, Response{Invalidation: inv.Name, Error: "can't get the invalidation status"}
+	}
+
+	resp := Response{Invalidation: inv.Name, Phase: inv.Status.Phase, Expression: inv.Status.Expression, Pods: inv.Status.Pods}
+	msg := fmt.Sprintf("Invalidation %s requested by %s: %s", inv.Name, clientName, inv.Status.Phase)
+	switch inv.Status.Phase {
+	case v1alpha1.VarnishInvalidationPhaseCompleted:
+		s.eventHandler.Normal(vc, controller.EventReasonInvalidationCompleted, msg)
+		return http.StatusOK, resp
+	case v1alpha1.VarnishInvalidationPhaseFailed:
+		s.eventHandler.Warning(vc, controller.EventReasonInvalidationFailed, msg)
+		return http.StatusBadGateway, resp
+	default:
+		// the invalidation is still being executed and its progress can be followed through the VarnishInvalidation
+		s.eventHandler.Warning(vc, controller.EventReasonInvalidationTimedOut, msg)
+		return http.StatusAccepted, resp
+	}
+}
+
+// authenticate checks the bearer token against the tokens in the Secret and returns the name of the client and its token
+func (s *Server) authenticate(ctx context.Context, r *http.Request) (string, Token, error) {
+	bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if bearer == "" || bearer == r.Header.Get("Authorization") {
+		return "", Token{}, errors.New("no bearer token")
+	}
+
+	secret := &v1.Secret{}
+	if err := s.client.Get(ctx, types.NamespacedName{Namespace: s.tokenNamespace, Name: s.tokenSecret}, secret); err != nil {
+		return "", Token{}, errors.Wrapf(err, "can't get the tokens Secret %s/%s", s.tokenNamespace, s.tokenSecret)
+	}
+
+	for name, value := range secret.Data {
+		token := Token{}
+		if err := json.Unmarshal(value, &token); err != nil {
+			s.logger.Warnw("Ignoring invalid token", "client", name, zap.Error(err))
+			continue
+		}
+		if token.Token != "" && subtle.ConstantTimeCompare([]byte(token.Token), []byte(bearer)) == 1 {
+			return name, token, nil
+		}
+	}
+	return "", Token{}, errors.New("unknown token")
+}
+
+// authorize checks that the token is allowed to invalidate the cluster and to request a broad invalidation
+func authorize(token Token, cluster types.NamespacedName, spec v1alpha1.VarnishInvalidationSpec) error {
+	allowed := false
+	for _, namespace := range token.Namespaces {
+		if namespace == allNamespaces || namespace == cluster.Namespace {
+			allowed = true
+		}
+	}
+	for _, c := range token.Clusters {
+		if c == cluster.String() {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return errors.Errorf("the token is not allowed to invalidate VarnishCluster %s", cluster)
+	}
+
+	if spec.AllowBroad && !token.AllowBroad {
+		return errors.New("the token is not allowed to request broad invalidations")
+	}
+	return nil
+}
+
+// waitFinished polls the invalidation until it's finished on all pods or the timeout expires
+func (s *Server) waitFinished(ctx context.Context, inv *v1alpha1.VarnishInvalidation) (*v1alpha1.VarnishInvalidation, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return inv, nil
+		case <-ticker.C:
+		}
+
+		latest := &v1alpha1.VarnishInvalidation{}
+		if err := s.client.Get(ctx, client.ObjectKeyFromObject(inv), latest); err != nil {
+			if apierrors.IsNotFound(err) || ctx.Err() != nil {
+				// not in the cache yet, or the request timed out
+				continue
+			}
+			return inv, errors.WithStack(err)
+		}
+		inv = latest
+		if inv.Finished() {
+			return inv, nil
+		}
+	}
+}
+
+func invalidationSpec(r *http.Request, invalidationType string) (v1alpha1.VarnishInvalidationSpec, error) {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+
+	if invalidationType == invalidationTypeBan {
+		req := BanRequest{}
+		if err := decoder.Decode(&req); err != nil {
+			return v1alpha1.VarnishInvalidationSpec{}, errors.Wrap(err, "invalid request body")
+		}
+		if req.Expression == "" {
+			return v1alpha1.VarnishInvalidationSpec{}, errors.New("expression is required")
+		}
+		return v1alpha1.VarnishInvalidationSpec{Ban: req.Expression, AllowBroad: req.AllowBroad}, nil
+	}
+
+	req := PurgeRequest{}
+	if err := decoder.Decode(&req); err != nil {
+		return v1alpha1.VarnishInvalidationSpec{}, errors.Wrap(err, "invalid request body")
+	}
+	return v1alpha1.VarnishInvalidationSpec{
+		URL:           req.URL,
+		URLRegex:      req.URLRegex,
+		Host:          req.Host,
+		SurrogateKeys: req.SurrogateKeys,
+		AllowBroad:    req.AllowBroad,
+	}, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
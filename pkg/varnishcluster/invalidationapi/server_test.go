@@ -0,0 +1,259 @@
+package invalidationapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcluster/controller"
+
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServeHTTP(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		finishPhase    string
+		expectedStatus int
+		expectedSpec   *v1alpha1.VarnishInvalidationSpec
+	}{
+		{
+			name:           "purge completed on all pods",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "ci-token",
+			body:           `{"url": "/products/1", "host": "shop.example.com"}`,
+			finishPhase:    v1alpha1.VarnishInvalidationPhaseCompleted,
+			expectedStatus: http.StatusOK,
+			expectedSpec:   &v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", URL: "/products/1", Host: "shop.example.com"},
+		},
+		{
+			name:           "ban failed on some pods",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/ban",
+			token:          "cms-token",
+			body:           `{"expression": "obj.http.Content-Type ~ \"^image/\""}`,
+			finishPhase:    v1alpha1.VarnishInvalidationPhaseFailed,
+			expectedStatus: http.StatusBadGateway,
+			expectedSpec:   &v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", Ban: `obj.http.Content-Type ~ "^image/"`},
+		},
+		{
+			name:           "invalidation not finished in time",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "ci-token",
+			body:           `{"surrogateKeys": ["product-1"]}`,
+			expectedStatus: http.StatusAccepted,
+			expectedSpec:   &v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", SurrogateKeys: []string{"product-1"}},
+		},
+		{
+			name:           "unknown token",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "wrong",
+			body:           `{"url": "/"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "no token",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			body:           `{"url": "/"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "too broad",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "ci-token",
+			body:           `{"urlRegex": ".*"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown field",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/ban",
+			token:          "ci-token",
+			body:           `{"expresion": "req.url ~ \"^/a\""}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "broad invalidation allowed for the token",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "cms-token",
+			body:           `{"urlRegex": ".*", "allowBroad": true}`,
+			finishPhase:    v1alpha1.VarnishInvalidationPhaseCompleted,
+			expectedStatus: http.StatusOK,
+			expectedSpec:   &v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", URLRegex: ".*", AllowBroad: true},
+		},
+		{
+			name:           "broad invalidation not allowed for the token",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "ci-token",
+			body:           `{"urlRegex": ".*", "allowBroad": true}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "cluster in a namespace not allowed for the token",
+			method:         http.MethodPost,
+			path:           "/clusters/shop/varnish/purge",
+			token:          "ci-token",
+			body:           `{"url": "/"}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "cluster not allowed for the token",
+			method:         http.MethodPost,
+			path:           "/clusters/shop/varnish/ban",
+			token:          "cms-token",
+			body:           `{"expression": "obj.http.Content-Type ~ \"^image/\""}`,
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "cluster allowed by all namespaces",
+			method:         http.MethodPost,
+			path:           "/clusters/shop/varnish/purge",
+			token:          "admin-token",
+			body:           `{"url": "/"}`,
+			finishPhase:    v1alpha1.VarnishInvalidationPhaseCompleted,
+			expectedStatus: http.StatusOK,
+			expectedSpec:   &v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", URL: "/"},
+		},
+		{
+			name:           "token not in the structured format",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/purge",
+			token:          "legacy-token",
+			body:           `{"url": "/"}`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown cluster",
+			method:         http.MethodPost,
+			path:           "/clusters/default/other/purge",
+			token:          "ci-token",
+			body:           `{"url": "/"}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "unknown path",
+			method:         http.MethodPost,
+			path:           "/clusters/default/varnish/flush",
+			token:          "ci-token",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "wrong method",
+			method:         http.MethodGet,
+			path:           "/clusters/default/varnish/purge",
+			token:          "ci-token",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				&v1alpha1.VarnishCluster{ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"}},
+				&v1alpha1.VarnishCluster{ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "shop"}},
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "tokens", Namespace: "operator"},
+					Data: map[string][]byte{
+						"ci":     []byte(`{"token": "ci-token", "namespaces": ["default"]}`),
+						"cms":    []byte(`{"token": "cms-token", "clusters": ["default/varnish"], "allowBroad": true}`),
+						"admin":  []byte(`{"token": "admin-token", "namespaces": ["*"]}`),
+						"legacy": []byte("legacy-token"),
+					},
+				},
+			).Build()
+			recorder := record.NewFakeRecorder(10)
+			metrics := NewMetrics()
+			s := NewServer(k8sClient, logger.NewNopLogger(), controller.NewEventHandler(recorder), metrics, 0, "", "operator", "tokens", 300*time.Millisecond)
+			s.pollInterval = 10 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if c.finishPhase != "" {
+				// play the role of the varnish pods
+				go finishInvalidations(ctx, k8sClient, c.finishPhase)
+			}
+
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, req)
+			g.Expect(rec.Code).To(gomega.Equal(c.expectedStatus), rec.Body.String())
+
+			resp := Response{}
+			g.Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(gomega.Succeed())
+
+			invs := &v1alpha1.VarnishInvalidationList{}
+			g.Expect(k8sClient.List(context.Background(), invs)).To(gomega.Succeed())
+			if c.expectedSpec == nil {
+				g.Expect(invs.Items).To(gomega.BeEmpty())
+				g.Expect(resp.Error).ToNot(gomega.BeEmpty())
+				return
+			}
+
+			g.Expect(invs.Items).To(gomega.HaveLen(1))
+			g.Expect(invs.Items[0].Spec).To(gomega.Equal(*c.expectedSpec))
+			g.Expect(invs.Items[0].Annotations[AnnotationClient]).To(gomega.Equal(strings.TrimSuffix(c.token, "-token")))
+			g.Expect(resp.Invalidation).To(gomega.Equal(invs.Items[0].Name))
+			g.Expect(resp.Phase).To(gomega.Equal(c.finishPhase))
+			g.Expect(recorder.Events).To(gomega.HaveLen(1))
+			g.Expect(testutil.CollectAndCount(metrics.Requests)).To(gomega.Equal(1))
+		})
+	}
+}
+
+func finishInvalidations(ctx context.Context, c client.Client, phase string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		invs := &v1alpha1.VarnishInvalidationList{}
+		if err := c.List(ctx, invs); err != nil {
+			continue
+		}
+		for i := range invs.Items {
+			inv := &invs.Items[i]
+			inv.Status.Phase = phase
+			inv.Status.Pods = []v1alpha1.VarnishInvalidationPodStatus{{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseSucceeded, Attempts: 1}}
+			_ = c.Status().Update(ctx, inv)
+		}
+	}
+}
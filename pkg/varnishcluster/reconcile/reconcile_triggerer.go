@@ -30,7 +30,7 @@ func (q *ReconcileTriggerer) TriggerAfter(key string, triggerAfter time.Duration
 	q.logger.Debugf("Setting timer to trigger in %s", triggerAfter)
 	timer := time.AfterFunc(triggerAfter, func() {
 		q.Lock()
-		delete(q.timetable, namespacedName)
+		delete(q.timetable[namespacedName], key)
 		q.Unlock()
 		q.logger.Debugf("Triggering reconcile")
 		q.reconcileChan <- event.GenericEvent{
@@ -42,7 +42,7 @@ func (q *ReconcileTriggerer) TriggerAfter(key string, triggerAfter time.Duration
 	if timers, exists := q.timetable[namespacedName]; exists {
 		if timer, exists := timers[key]; exists {
 			timer.Stop()
-			delete(q.timetable, namespacedName)
+			delete(timers, key)
 		}
 	}
 
@@ -55,12 +55,12 @@ func (q *ReconcileTriggerer) TriggerAfter(key string, triggerAfter time.Duration
 
 func (q *ReconcileTriggerer) Stop(key string, instance *vcapi.VarnishCluster) {
 	namespacedName := instance.Namespace + "/" + instance.Name
+	q.Lock()
+	defer q.Unlock()
 	if timers, exists := q.timetable[namespacedName]; exists {
 		if timer, exists := timers[key]; exists {
 			timer.Stop()
-			q.Lock()
-			delete(q.timetable, namespacedName)
-			q.Unlock()
+			delete(timers, key)
 		}
 	}
 }
@@ -0,0 +1,280 @@
+// Package accesslog writes the access log of the client requests, read from the varnish shared memory log with varnishncsa.
+package accesslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// VarnishNCSABinary is the binary that reads the requests from the varnish shared memory log
+	VarnishNCSABinary = "varnishncsa"
+
+	redactedValue = "REDACTED"
+	// varnishncsa is restarted after that delay if it exits, e.g. because varnish restarted
+	defaultRestartDelay = 10 * time.Second
+	maxLineLength       = 1024 * 1024
+)
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindInt
+	kindFloat
+)
+
+type field struct {
+	// format is the varnishncsa format specifier
+	format string
+	kind   fieldKind
+	// header is the name of the header the value comes from, to redact it
+	header string
+}
+
+var fields = map[string]field{
+	v1alpha1.AccessLogFieldClientIP:   {format: "%h"},
+	v1alpha1.AccessLogFieldMethod:     {format: "%m"},
+	v1alpha1.AccessLogFieldHost:       {format: "%{Host}i", header: "Host"},
+	v1alpha1.AccessLogFieldURL:        {format: "%U"},
+	v1alpha1.AccessLogFieldQuery:      {format: "%q"},
+	v1alpha1.AccessLogFieldProtocol:   {format: "%H"},
+	v1alpha1.AccessLogFieldStatus:     {format: "%s", kind: kindInt},
+	v1alpha1.AccessLogFieldBytes:      {format: "%b", kind: kindInt},
+	v1alpha1.AccessLogFieldDurationUs: {format: "%D", kind: kindInt},
+	v1alpha1.AccessLogFieldTTFB:       {format: "%{Varnish:time_firstbyte}x", kind: kindFloat},
+	v1alpha1.AccessLogFieldHandling:   {format: "%{Varnish:handling}x"},
+	v1alpha1.AccessLogFieldVXID:       {format: "%{Varnish:vxid}x", kind: kindInt},
+	v1alpha1.AccessLogFieldUserAgent:  {format: "%{User-Agent}i", header: "User-Agent"},
+	v1alpha1.AccessLogFieldReferer:    {format: "%{Referer}i", header: "Referer"},
+}
+
+// lookupField returns the format of one of the predefined fields or of a req.http.<name> or resp.http.<name> header field
+func lookupField(name string) (field, bool) {
+	switch {
+	case strings.HasPrefix(name, v1alpha1.AccessLogRequestHeaderPrefix):
+		header := strings.TrimPrefix(name, v1alpha1.AccessLogRequestHeaderPrefix)
+		return field{format: "%{" + header + "}i", header: header}, true
+	case strings.HasPrefix(name, v1alpha1.AccessLogResponseHeaderPrefix):
+		header := strings.TrimPrefix(name, v1alpha1.AccessLogResponseHeaderPrefix)
+		return field{format: "%{" + header + "}o", header: header}, true
+	}
+	f, found := fields[name]
+	return f, found
+}
+
+// Streamer runs varnishncsa and logs every request it reports as a structured log entry.
+// The configuration is changed with Update, which restarts varnishncsa if needed.
+type Streamer struct {
+	logger       *logger.Logger
+	accessLogger *logger.Logger
+	binary       string
+	args         []string
+	command      func(ctx context.Context, name string, args ...string) *exec.Cmd
+	restartDelay time.Duration
+	sample       func(percent int32) bool
+
+	mu      sync.Mutex
+	config  *v1alpha1.VarnishClusterAccessLogging
+	updates chan *v1alpha1.VarnishClusterAccessLogging
+}
+
+var _ manager.Runnable = &Streamer{}
+
+// NewStreamer returns the access log streamer. The operational messages are written to logr, the requests to accessLogr.
+// Accepts varnishncsa CLI parameters (e.g. -n to set the varnish working directory).
+func NewStreamer(logr, accessLogr *logger.Logger, args []string) *Streamer {
+	return &Streamer{
+		logger:       logr,
+		accessLogger: accessLogr,
+		binary:       VarnishNCSABinary,
+		args:         args,
+		command:      exec.CommandContext,
+		restartDelay: defaultRestartDelay,
+		sample:       func(percent int32) bool { return rand.Int31n(100) < percent },
+		updates:      make(chan *v1alpha1.VarnishClusterAccessLogging, 1),
+	}
+}
+
+// Update sets the access log configuration. The access log is stopped if it is nil or not enabled.
+func (s *Streamer) Update(config *v1alpha1.VarnishClusterAccessLogging) {
+	if config != nil && !config.Enabled {
+		config = nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if reflect.DeepEqual(s.config, config) {
+		return
+	}
+	s.config = config.DeepCopy()
+
+	// only the latest configuration matters
+	select {
+	case <-s.updates:
+	default:
+	}
+	s.updates <- s.config
+}
+
+// Start applies the configuration updates until the context is done
+func (s *Streamer) Start(ctx context.Context) error {
+	stop := func() {}
+	for {
+		select {
+		case <-ctx.Done():
+			stop()
+			return nil
+		case config := <-s.updates:
+			stop()
+			if config == nil {
+				s.logger.Infow("Access log is disabled")
+				stop = func() {}
+				continue
+			}
+
+			s.logger.Infow("Access log is enabled", "fields", config.Fields, "query", config.Query)
+			runCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				s.run(runCtx, config)
+			}()
+			stop = func() {
+				cancel()
+				<-done
+			}
+		}
+	}
+}
+
+// run keeps varnishncsa running until the context is done
+func (s *Streamer) run(ctx context.Context, config *v1alpha1.VarnishClusterAccessLogging) {
+	for {
+		err := s.stream(ctx, config)
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Warnw("varnishncsa exited. Restarting", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.restartDelay):
+		}
+	}
+}
+
+func (s *Streamer) stream(ctx context.Context, config *v1alpha1.VarnishClusterAccessLogging) error {
+	var stderr bytes.Buffer
+	cmd := s.command(ctx, s.binary, s.varnishNCSAArgs(config)...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = cmd.Start(); err != nil {
+		return errors.Wrap(err, "can't start varnishncsa")
+	}
+
+	redacted := NewRedactor(config.RedactHeaders)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		if config.SamplingPercent != nil && *config.SamplingPercent < 100 && !s.sample(*config.SamplingPercent) {
+			continue
+		}
+		s.logRequest(scanner.Bytes(), config.Fields, redacted)
+	}
+	if err = scanner.Err(); err != nil {
+		// stop varnishncsa, as its output is not read anymore
+		_ = cmd.Process.Kill()
+	}
+
+	if waitErr := cmd.Wait(); waitErr != nil {
+		return errors.Wrap(waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return errors.WithStack(err)
+}
+
+// varnishNCSAArgs makes varnishncsa print every request as a JSON object with the values of the fields as strings
+func (s *Streamer) varnishNCSAArgs(config *v1alpha1.VarnishClusterAccessLogging) []string {
+	format := make([]string, 0, len(config.Fields))
+	for _, name := range config.Fields {
+		f, found := lookupField(name)
+		if !found {
+			continue
+		}
+		format = append(format, strconv.Quote(name)+`:"`+f.format+`"`)
+	}
+
+	// -j escapes the values for JSON, -t off waits for varnish to start
+	args := append([]string{}, s.args...)
+	args = append(args, "-j", "-t", "off", "-F", "{"+strings.Join(format, ",")+"}")
+	if config.Query != "" {
+		args = append(args, "-q", config.Query)
+	}
+	return args
+}
+
+func (s *Streamer) logRequest(line []byte, names []string, redacted *Redactor) {
+	values := map[string]string{}
+	if err := json.Unmarshal(line, &values); err != nil {
+		s.logger.Debugw("Can't parse the varnishncsa output", "line", string(line), zap.Error(err))
+		return
+	}
+
+	keysAndValues := make([]interface{}, 0, 2*len(names))
+	for _, name := range names {
+		f, found := lookupField(name)
+		if !found {
+			continue
+		}
+		value := values[name]
+		if value == "-" {
+			value = ""
+		}
+
+		switch {
+		case f.header != "" && redacted.Redacted(f.header) && value != "":
+			keysAndValues = append(keysAndValues, name, redactedValue)
+		case f.kind == kindInt:
+			keysAndValues = append(keysAndValues, name, parseNumber(value, func(v string) (interface{}, error) { return strconv.ParseInt(v, 10, 64) }))
+		case f.kind == kindFloat:
+			keysAndValues = append(keysAndValues, name, parseNumber(value, func(v string) (interface{}, error) { return strconv.ParseFloat(v, 64) }))
+		default:
+			keysAndValues = append(keysAndValues, name, value)
+		}
+	}
+
+	s.accessLogger.Infow("Request", keysAndValues...)
+}
+
+// parseNumber returns nil for empty values and the value as is if it's not a number
+func parseNumber(value string, parse func(string) (interface{}, error)) interface{} {
+	if value == "" {
+		return nil
+	}
+	number, err := parse(value)
+	if err != nil {
+		return value
+	}
+	return number
+}
@@ -0,0 +1,91 @@
+package accesslog
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestVarnishNCSAArgs(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	s := NewStreamer(logger.NewNopLogger(), logger.NewNopLogger(), []string{"-n", "/var/lib/varnish"})
+
+	args := s.varnishNCSAArgs(&v1alpha1.VarnishClusterAccessLogging{
+		Fields: []string{"method", "status", "req.http.X-Forwarded-For", "resp.http.Age"},
+		Query:  "RespStatus >= 500",
+	})
+	g.Expect(args).To(gomega.Equal([]string{
+		"-n", "/var/lib/varnish", "-j", "-t", "off",
+		"-F", `{"method":"%m","status":"%s","req.http.X-Forwarded-For":"%{X-Forwarded-For}i","resp.http.Age":"%{Age}o"}`,
+		"-q", "RespStatus >= 500",
+	}))
+}
+
+func TestLogRequest(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s := NewStreamer(logger.NewNopLogger(), &logger.Logger{SugaredLogger: zap.New(core).Sugar()}, nil)
+
+	s.logRequest(
+		[]byte(`{"method":"GET","url":"/products","status":"200","bytes":"","ttfb_seconds":"0.000123","req.http.Authorization":"Bearer token","req.http.Cookie":""}`),
+		[]string{"method", "url", "status", "bytes", "ttfb_seconds", "req.http.Authorization", "req.http.Cookie"},
+		NewRedactor([]string{"Authorization", "cookie"}),
+	)
+	s.logRequest([]byte("Can't open log"), []string{"method"}, NewRedactor(nil))
+
+	g.Expect(logs.Len()).To(gomega.Equal(1))
+	g.Expect(logs.All()[0].ContextMap()).To(gomega.Equal(map[string]interface{}{
+		"method":                 "GET",
+		"url":                    "/products",
+		"status":                 int64(200),
+		"bytes":                  nil,
+		"ttfb_seconds":           0.000123,
+		"req.http.Authorization": redactedValue,
+		"req.http.Cookie":        "",
+	}))
+}
+
+func TestStreamer(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s := NewStreamer(logger.NewNopLogger(), &logger.Logger{SugaredLogger: zap.New(core).Sugar()}, nil)
+	var calledWith []string
+	s.command = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		calledWith = append([]string{name}, args...)
+		return exec.CommandContext(ctx, "echo", `{"method":"GET","status":"404"}`)
+	}
+	s.restartDelay = time.Hour
+	sampled := false
+	s.sample = func(percent int32) bool {
+		sampled = true
+		return percent == 50
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	s.Update(&v1alpha1.VarnishClusterAccessLogging{Enabled: true, Fields: []string{"method", "status"}, SamplingPercent: proto.Int32(50)})
+	g.Eventually(logs.Len).Should(gomega.Equal(1))
+	g.Expect(logs.All()[0].ContextMap()).To(gomega.Equal(map[string]interface{}{"method": "GET", "status": int64(404)}))
+	g.Expect(calledWith[0]).To(gomega.Equal(VarnishNCSABinary))
+	g.Expect(sampled).To(gomega.BeTrue())
+
+	// not sampled
+	s.Update(&v1alpha1.VarnishClusterAccessLogging{Enabled: true, Fields: []string{"method"}, SamplingPercent: proto.Int32(10)})
+	g.Consistently(logs.Len, 200*time.Millisecond).Should(gomega.Equal(1))
+
+	s.Update(&v1alpha1.VarnishClusterAccessLogging{Enabled: false})
+	cancel()
+	g.Eventually(done).Should(gomega.Receive(gomega.BeNil()))
+}
@@ -0,0 +1,40 @@
+package accesslog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CredentialHeaders carry credentials, so they are always redacted in the transactions captured with varnishlog
+var CredentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// the header records of varnishlog, e.g. "-   ReqHeader      Authorization: Bearer token"
+var varnishLogHeaderRecord = regexp.MustCompile(`^(\s*[-*]+\s+(?:Req|Resp|Bereq|Beresp|Obj)(?:Header|Unset)\s+([^:\s]+):)`)
+
+// Redactor replaces the values of the headers with REDACTED. The header names are case insensitive.
+type Redactor struct {
+	headers map[string]bool
+}
+
+// NewRedactor returns a redactor of the headers
+func NewRedactor(headers []string) *Redactor {
+	r := &Redactor{headers: make(map[string]bool, len(headers))}
+	for _, header := range headers {
+		r.headers[strings.ToLower(header)] = true
+	}
+	return r
+}
+
+// Redacted returns true if the value of the header has to be redacted
+func (r *Redactor) Redacted(header string) bool {
+	return r.headers[strings.ToLower(header)]
+}
+
+// VarnishLogLine redacts the value of the header if the varnishlog line is a record of a redacted header
+func (r *Redactor) VarnishLogLine(line []byte) []byte {
+	match := varnishLogHeaderRecord.FindSubmatch(line)
+	if match == nil || !r.Redacted(string(match[2])) {
+		return line
+	}
+	return append(append([]byte{}, match[1]...), " "+redactedValue...)
+}
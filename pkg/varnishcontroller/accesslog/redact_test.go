@@ -0,0 +1,26 @@
+package accesslog
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestRedactVarnishLogLine(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	r := NewRedactor(append(CredentialHeaders, "X-Api-Key"))
+
+	cases := map[string]string{
+		"-   ReqHeader      Authorization: Bearer token":       "-   ReqHeader      Authorization: REDACTED",
+		"--  BereqHeader    Cookie: session=abc":               "--  BereqHeader    Cookie: REDACTED",
+		"-   RespHeader     set-cookie: session=abc; HttpOnly": "-   RespHeader     set-cookie: REDACTED",
+		"-   ReqUnset       x-api-key: 1234":                   "-   ReqUnset       x-api-key: REDACTED",
+		"-   ReqHeader      Host: shop.example.com":            "-   ReqHeader      Host: shop.example.com",
+		"-   ReqURL         /login?Authorization: token":       "-   ReqURL         /login?Authorization: token",
+		"*   << Request  >> 32770":                             "*   << Request  >> 32770",
+		"":                                                     "",
+	}
+	for line, expected := range cases {
+		g.Expect(string(r.VarnishLogLine([]byte(line)))).To(gomega.Equal(expected), line)
+	}
+}
@@ -29,6 +29,9 @@ type Config struct {
 	VarnishPingDelay      time.Duration `env:"VARNISHADM_PING_DELAY" envDefault:"200ms"`
 	LogFormat             string        `env:"LOG_FORMAT,required"`
 	LogLevel              zapcore.Level `env:"LOG_LEVEL,required"`
+	// MetricsExporter is builtin if the varnish-controller exports the varnish counters instead of the metrics-exporter container
+	MetricsExporter string `env:"METRICS_EXPORTER" envDefault:"external"`
+	MetricsNaming   string `env:"METRICS_NAMING" envDefault:"compatible"`
 }
 
 // Load uses the caarlos0/env library to read in environment variables into a struct
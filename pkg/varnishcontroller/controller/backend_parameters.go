@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+)
+
+// backendParameters renders the probe and connection parameters of the backends into VCL backend attributes, one per line.
+// Returns an empty string if none are configured. Usage in templates: {{ with $.BackendParameters }}{{ nindent 2 . }}{{ end }}
+func backendParameters(backend *v1alpha1.VarnishClusterBackend) string {
+	if backend == nil {
+		return ""
+	}
+
+	var lines []string
+	if c := backend.Connection; c != nil {
+		lines = appendDuration(lines, ".connect_timeout", c.ConnectTimeout)
+		lines = appendDuration(lines, ".first_byte_timeout", c.FirstByteTimeout)
+		lines = appendDuration(lines, ".between_bytes_timeout", c.BetweenBytesTimeout)
+		if c.MaxConnections != nil {
+			lines = append(lines, fmt.Sprintf(".max_connections = %d;", *c.MaxConnections))
+		}
+	}
+
+	if p := backend.Probe; p != nil {
+		var probe []string
+		if p.URL != "" {
+			probe = append(probe, fmt.Sprintf(`.url = "%s";`, p.URL))
+		}
+		if len(p.Request) > 0 {
+			probe = append(probe, `.request =`)
+			for i, line := range p.Request {
+				end := ""
+				if i == len(p.Request)-1 {
+					end = ";"
+				}
+				probe = append(probe, fmt.Sprintf(`  "%s"%s`, line, end))
+			}
+		}
+		probe = appendDuration(probe, ".interval", p.Interval)
+		probe = appendDuration(probe, ".timeout", p.Timeout)
+		probe = appendInt(probe, ".window", p.Window)
+		probe = appendInt(probe, ".threshold", p.Threshold)
+		probe = appendInt(probe, ".initial", p.Initial)
+		probe = appendInt(probe, ".expected_response", p.ExpectedResponse)
+
+		lines = append(lines, ".probe = {")
+		for _, line := range probe {
+			lines = append(lines, "  "+line)
+		}
+		lines = append(lines, "}")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func appendDuration(lines []string, attribute string, duration v1alpha1.VCLDuration) []string {
+	if duration == "" {
+		return lines
+	}
+	return append(lines, fmt.Sprintf("%s = %s;", attribute, duration))
+}
+
+func appendInt(lines []string, attribute string, value *int32) []string {
+	if value == nil {
+		return lines
+	}
+	return append(lines, fmt.Sprintf("%s = %d;", attribute, *value))
+}
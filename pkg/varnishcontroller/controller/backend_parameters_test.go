@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+)
+
+func TestBackendParameters(t *testing.T) {
+	cases := []struct {
+		name     string
+		backend  *v1alpha1.VarnishClusterBackend
+		expected string
+	}{
+		{
+			name:     "no parameters",
+			backend:  &v1alpha1.VarnishClusterBackend{},
+			expected: "",
+		},
+		{
+			name: "probe with url and connection parameters",
+			backend: &v1alpha1.VarnishClusterBackend{
+				Connection: &v1alpha1.VarnishClusterBackendConnection{
+					ConnectTimeout:   "1s",
+					FirstByteTimeout: "60s",
+					MaxConnections:   proto.Int32(200),
+				},
+				Probe: &v1alpha1.VarnishClusterBackendProbe{
+					URL:              "/health",
+					Interval:         "5s",
+					Window:           proto.Int32(5),
+					Threshold:        proto.Int32(3),
+					ExpectedResponse: proto.Int32(204),
+				},
+			},
+			expected: `.connect_timeout = 1s;
+.first_byte_timeout = 60s;
+.max_connections = 200;
+.probe = {
+  .url = "/health";
+  .interval = 5s;
+  .window = 5;
+  .threshold = 3;
+  .expected_response = 204;
+}`,
+		},
+		{
+			name: "probe with custom request",
+			backend: &v1alpha1.VarnishClusterBackend{
+				Probe: &v1alpha1.VarnishClusterBackendProbe{
+					Request: []string{"GET /health HTTP/1.1", "Host: example.com", "Connection: close"},
+					Timeout: "500ms",
+				},
+			},
+			expected: `.probe = {
+  .request =
+    "GET /health HTTP/1.1"
+    "Host: example.com"
+    "Connection: close";
+  .timeout = 500ms;
+}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			g.Expect(backendParameters(c.backend)).To(gomega.Equal(c.expected))
+		})
+	}
+}
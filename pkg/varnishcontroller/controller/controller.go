@@ -3,6 +3,7 @@ package controller
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -10,20 +11,26 @@ import (
 
 	"github.com/ibm/varnish-operator/api/v1alpha1"
 	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/accesslog"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/metrics"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/predicates"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
 
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/pkg/errors"
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
@@ -36,40 +43,166 @@ import (
 
 // PodInfo represents the relevant information of a pod for VCL code
 type PodInfo struct {
-	IP         string
+	IP string
+	// Port is the port of the backend the requests are sent to
+	Port       int32
 	NodeLabels map[string]string
 	PodName    string
-	Weight     float64
+	Namespace  string
+	// Hostname is the DNS name the address has been resolved from. Set only for external backends
+	Hostname string
+	// Labels and Annotations are set only for backends
+	Labels      map[string]string
+	Annotations map[string]string
+	Zone        string
+	// ZoneHints are the zones the endpoint should serve according to its EndpointSlice. Set only for backends discovered by serviceRef
+	ZoneHints []string
+	Ready     bool
+	// Ports are the named container ports of the pod
+	Ports  map[string]int32
+	Owner  *OwnerInfo
+	Weight float64
+}
+
+// BackendGroupInfo represents a named group of backends defined in spec.backends
+type BackendGroupInfo struct {
+	Name       string
+	Backends   []PodInfo
+	TargetPort int32
+	// BackendParameters are the probe and connection parameters of the group rendered as VCL backend attributes
+	BackendParameters string
+	Director          DirectorInfo
+}
+
+// DirectorInfo represents the director configured for the backends
+type DirectorInfo struct {
+	// Type is round_robin, random, hash, shard or fallback
+	Type string
+	// HashKey is the VCL expression the hash director picks the backend by, e.g. req.http.X-User
+	HashKey string
+	// ShardBy is HASH or URL
+	ShardBy string
+	// ShardWarmup is the warmup probability of the shard director, e.g. 0.10. Empty if not set
+	ShardWarmup string
+	// ShardRampup is the rampup duration of the shard director. Empty if not set
+	ShardRampup string
+	// FallbackGroups are the backend groups the fallback director falls back to, in order
+	FallbackGroups []string
+	FallbackSticky bool
+}
+
+// OwnerInfo represents the workload that manages a pod
+type OwnerInfo struct {
+	Kind string
+	Name string
+}
+
+// LocalPodInfo represents the varnish pod the controller runs in
+type LocalPodInfo struct {
+	Name     string
+	NodeName string
+	Zone     string
+}
+
+// ClusteringInfo represents how the varnish pods share the cache
+type ClusteringInfo struct {
+	// Mode is none or shard
+	Mode string
+	// ZoneAware is set if the objects are sharded only between the pods in the same zone
+	ZoneAware bool
+	// ForwardedHeader is the request header the requests forwarded to the owner pod are marked with
+	ForwardedHeader string
+}
+
+// VarnishClusterInfo represents the relevant information of the VarnishCluster for VCL code
+type VarnishClusterInfo struct {
+	Name      string
+	Namespace string
+	Replicas  int32
+	Labels    map[string]string
 }
 
 // SetupVarnishReconciler creates a new VarnishCluster Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func SetupVarnishReconciler(mgr manager.Manager, cfg *config.Config, varnish varnishadm.VarnishAdministrator, metrics *metrics.VarnishControllerMetrics, logr *logger.Logger) error {
+func SetupVarnishReconciler(mgr manager.Manager, cfg *config.Config, varnish varnishadm.VarnishAdministrator, varnishStat varnishstat.Reader, metrics *metrics.VarnishControllerMetrics, logr *logger.Logger) error {
 	// stub, backends selector will be set and updated on reconcile
 	backendsSelector := labels.SelectorFromSet(labels.Set{})
 	backendNamespacePredicate := predicates.NewNamespacesMatcherPredicate([]string{cfg.Namespace}, logr)
 	backendLabelsPredicate := predicates.NewLabelMatcherPredicate(backendsSelector, logr)
+	// labels and annotations of backends are available in VCL templates
+	backendLabelsPredicate.MetadataSignificant = true
+	// stub, the services of the backends discovered by serviceRef will be set on reconcile
+	backendServicesNamespacePredicate := predicates.NewNamespacesMatcherPredicate([]string{cfg.Namespace}, logr)
+	backendServicesPredicate := predicates.NewLabelMatcherPredicate(labels.Nothing(), logr)
+
+	// the requests are logged regardless of the log level of the controller
+	accessLogger := logger.NewLogger(cfg.LogFormat, zapcore.InfoLevel).With(logger.FieldComponent, "access_log", logger.FieldPodName, cfg.PodName)
+	accessLog := accesslog.NewStreamer(logr, accessLogger, nil)
+	if err := mgr.Add(accessLog); err != nil {
+		return errors.WithStack(err)
+	}
 
 	r := &ReconcileVarnish{
-		config:                     cfg,
-		logger:                     logr,
-		Client:                     mgr.GetClient(),
-		scheme:                     mgr.GetScheme(),
-		varnish:                    varnish,
-		eventHandler:               events.NewEventHandler(mgr.GetEventRecorderFor(events.EventRecorderName), cfg.PodName),
-		metrics:                    metrics,
-		backendsSelectorPredicate:  backendLabelsPredicate,
-		backendsNamespacePredicate: backendNamespacePredicate,
+		config:                            cfg,
+		logger:                            logr,
+		Client:                            mgr.GetClient(),
+		scheme:                            mgr.GetScheme(),
+		varnish:                           varnish,
+		varnishStat:                       varnishStat,
+		eventHandler:                      events.NewEventHandler(mgr.GetEventRecorderFor(events.EventRecorderName), cfg.PodName),
+		metrics:                           metrics,
+		backendsSelectorPredicate:         backendLabelsPredicate,
+		backendsNamespacePredicate:        backendNamespacePredicate,
+		backendServicesPredicate:          backendServicesPredicate,
+		backendServicesNamespacePredicate: backendServicesNamespacePredicate,
+		failedSiteVersions:                make(map[string]string),
+		resolver:                          net.DefaultResolver,
+		resolvedHosts:                     make(map[string][]string),
+		resolvedSRVs:                      make(map[string][]PodInfo),
+		lookedUpNames:                     make(map[string]bool),
+		unresolvedNames:                   make(map[string]bool),
+		accessLog:                         accessLog,
+	}
+
+	// the pod gets traffic only after the VCL from the ConfigMap is loaded
+	if err := mgr.AddReadyzCheck("vcl", r.checkReadiness); err != nil {
+		return errors.WithStack(err)
+	}
+
+	podRequest := []reconcile.Request{
+		{NamespacedName: types.NamespacedName{
+			Namespace: cfg.Namespace,
+			Name:      cfg.PodName,
+		}},
 	}
 
 	podMapFunc := handler.EnqueueRequestsFromMapFunc(
 		func(a client.Object) []reconcile.Request {
-			return []reconcile.Request{
-				{NamespacedName: types.NamespacedName{
-					Namespace: cfg.Namespace,
-					Name:      cfg.PodName,
-				}},
+			return podRequest
+		})
+
+	siteMapFunc := handler.EnqueueRequestsFromMapFunc(
+		func(a client.Object) []reconcile.Request {
+			site, ok := a.(*v1alpha1.VarnishSite)
+			if !ok || !r.isClusterSite(site) {
+				return nil
+			}
+			return podRequest
+		})
+
+	siteConfigMapMapFunc := handler.EnqueueRequestsFromMapFunc(
+		func(a client.Object) []reconcile.Request {
+			sites := &v1alpha1.VarnishSiteList{}
+			if err := r.List(context.Background(), sites, client.InNamespace(a.GetNamespace())); err != nil {
+				logr.Warnw("Can't list VarnishSites", zap.Error(err))
+				return nil
+			}
+			for _, site := range sites.Items {
+				if site.Spec.VCL.ConfigMapName == a.GetName() && r.isClusterSite(&site) {
+					return podRequest
+				}
 			}
+			return nil
 		})
 
 	builder := ctrl.NewControllerManagedBy(mgr)
@@ -99,6 +232,33 @@ func SetupVarnishReconciler(mgr manager.Manager, cfg *config.Config, varnish var
 			predicates.NewLabelMatcherPredicate(varnishPodsSelector, logr),
 		),
 	)
+
+	// the operator asks the pod to load the current ConfigMap version again if it's stuck on an another one
+	builder.Watches(
+		&source.Kind{Type: &v1.Pod{}},
+		podMapFunc,
+		ctrlBuilder.WithPredicates(predicate.Funcs{
+			CreateFunc:  func(event.CreateEvent) bool { return false },
+			DeleteFunc:  func(event.DeleteEvent) bool { return false },
+			GenericFunc: func(event.GenericEvent) bool { return false },
+			UpdateFunc: func(e event.UpdateEvent) bool {
+				return e.ObjectNew.GetNamespace() == cfg.Namespace && e.ObjectNew.GetName() == cfg.PodName &&
+					e.ObjectNew.GetAnnotations()[annotationVCLResyncRequested] != e.ObjectOld.GetAnnotations()[annotationVCLResyncRequested]
+			},
+		}),
+	)
+
+	builder.Watches(
+		&source.Kind{Type: &discoveryv1.EndpointSlice{}},
+		podMapFunc,
+		ctrlBuilder.WithPredicates(
+			backendServicesNamespacePredicate,
+			backendServicesPredicate,
+		),
+	)
+
+	builder.Watches(&source.Kind{Type: &v1alpha1.VarnishSite{}}, siteMapFunc)
+	builder.Watches(&source.Kind{Type: &v1.ConfigMap{}}, siteConfigMapMapFunc)
 	//builder.WithEventFilter(predicates.NewDebugPredicate(logr))
 
 	return builder.Complete(r)
@@ -113,9 +273,42 @@ type ReconcileVarnish struct {
 	scheme                     *runtime.Scheme
 	eventHandler               *events.EventHandler
 	varnish                    varnishadm.VarnishAdministrator
+	varnishStat                varnishstat.Reader
 	metrics                    *metrics.VarnishControllerMetrics
 	backendsNamespacePredicate *predicates.NamespacesMatcherPredicate
 	backendsSelectorPredicate  *predicates.LabelMatcherPredicate
+	// match the EndpointSlices of the Services the backends are discovered from
+	backendServicesNamespacePredicate *predicates.NamespacesMatcherPredicate
+	backendServicesPredicate          *predicates.LabelMatcherPredicate
+	canaryCounters                    *canaryCounters
+	panicWatch                        *vclPanicWatch
+	// the last reload of a ConfigMap version that didn't compile, reported in the pod annotations
+	vclCompilationError *vclCompilationError
+	// the time and the error of the last VCL reload, reported in the pod annotations
+	lastReloadTime  time.Time
+	lastReloadError string
+	// the start of the last reload, successful or not, to keep the minimum interval between the reloads
+	lastReloadAttempt time.Time
+	// the changes collected to be applied with the next reload
+	pendingReload *pendingVCLReload
+	// the addresses of the backends in the VCL files on disk, to reload without waiting when any of them is removed
+	appliedBackends map[string]bool
+	readiness       vclReadiness
+	// ConfigMap versions of the VarnishSites that failed to load, to not retry them until the ConfigMap changes
+	failedSiteVersions map[string]string
+	resolver           Resolver
+	// the last successfully resolved addresses of the external backends, used if the DNS names can't be resolved
+	resolvedHosts map[string][]string
+	resolvedSRVs  map[string][]PodInfo
+	// the names looked up since the resolved addresses were last pruned
+	lookedUpNames map[string]bool
+	// the names that can't be resolved, to report them only when they stop resolving
+	unresolvedNames map[string]bool
+	// how often the external backends are resolved again
+	resolveInterval time.Duration
+	accessLog       *accesslog.Streamer
+	// the ConfigMap versions of the loaded VCLs, to render them again with the current backends while the current version can't be applied
+	appliedConfigMaps map[string]*v1.ConfigMap
 }
 
 func (r *ReconcileVarnish) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
@@ -125,9 +318,13 @@ func (r *ReconcileVarnish) Reconcile(ctx context.Context, request reconcile.Requ
 
 	logr.Debugw("Reconciling...")
 	start := time.Now()
-	defer logr.Debugf("Reconciled in %s", time.Since(start).String())
+	defer func() { logr.Debugf("Reconciled in %s", time.Since(start).String()) }()
 	ctx = logger.ToContext(ctx, logr)
 	res, err := r.reconcileWithContext(ctx, request)
+	// resolve the DNS names of the external backends again, even if the reconcile stopped early
+	if r.resolveInterval > 0 && (res.RequeueAfter == 0 || res.RequeueAfter > r.resolveInterval) {
+		res.RequeueAfter = r.resolveInterval
+	}
 	if err != nil {
 		if statusErr, ok := errors.Cause(err).(*apierrors.StatusError); ok && statusErr.ErrStatus.Reason == metav1.StatusReasonConflict {
 			logr.Info("Conflict occurred. Retrying...", zap.Error(err))
@@ -156,15 +353,12 @@ func (r *ReconcileVarnish) reconcileWithContext(ctx context.Context, request rec
 
 	r.scheme.Default(vc)
 
-	if len(vc.Spec.Backend.Namespaces) > 0 {
-		r.backendsNamespacePredicate.Namespaces = vc.Spec.Backend.Namespaces
-	} else {
-		r.backendsNamespacePredicate.Namespaces = []string{r.config.Namespace}
-	}
-	r.backendsSelectorPredicate.Selector = labels.SelectorFromSet(vc.Spec.Backend.Selector)
+	r.updateBackendPredicates(vc)
+	r.resolveInterval = externalResolveInterval(vc)
+	r.reconcileAccessLog(vc)
+	r.reconcileReadiness(vc)
 
 	varnishPort := int32(v1alpha1.VarnishPort)
-	entrypointFileName := *vc.Spec.VCL.EntrypointFileName
 
 	pod := &v1.Pod{}
 	err = r.Get(ctx, types.NamespacedName{Namespace: request.Namespace, Name: r.config.PodName}, pod)
@@ -172,69 +366,211 @@ func (r *ReconcileVarnish) reconcileWithContext(ctx context.Context, request rec
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
+	if err = r.reconcileParameters(ctx, vc, pod); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
 	cm, err := r.getConfigMap(ctx, r.config.Namespace, *vc.Spec.VCL.ConfigMapName)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	newFiles, newTemplates := r.filesAndTemplates(cm.Data)
-
-	if err = r.verifyEntrypointExists(newFiles, newTemplates, entrypointFileName); err != nil {
+	bks, backendPortNumber, localWeight, remoteWeight, err := r.getBackendEndpoints(ctx, vc, vc.Spec.Backend)
+	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	bks, backendPortNumber, localWeight, remoteWeight, err := r.getBackendEndpoints(ctx, vc)
+	backendGroups, err := r.getBackendGroups(ctx, vc)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
+	// all the external backends are resolved at this point
+	r.pruneResolvedNames()
 
 	varnishNodes, err := r.getVarnishEndpoints(ctx, vc)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	templatizedFiles, err := r.resolveTemplates(newTemplates, backendPortNumber, varnishPort, bks, varnishNodes)
+	localPod, err := r.getLocalPodInfo(ctx)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
+	r.updateMetricsExporterLabels(localPod, bks, backendGroups, varnishNodes)
+	r.updateBackendsMetrics(bks, backendGroups, localWeight, remoteWeight)
 
-	for fileName, contents := range templatizedFiles {
-		if _, found := newFiles[fileName]; found {
-			errMsg := fmt.Sprintf("VCL ConfigMap %s has %s and %s.tmpl entries. Cannot include file and template with same name",
-				*vc.Spec.VCL.ConfigMapName, fileName, fileName)
-			r.eventHandler.Warning(vc, events.EventReasonInvalidVCLConfigMap, errMsg)
-			return reconcile.Result{}, errors.Errorf(errMsg)
-		}
-		newFiles[fileName] = contents
+	routes, err := r.reconcileSites(ctx, config.VCLConfigDir, vc, pod)
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	currFiles, err := getCurrentFiles(config.VCLConfigDir)
+	renderStart := time.Now()
+	data := templateData(vc, localPod, backendPortNumber, varnishPort, bks, varnishNodes, backendGroups)
+	newFiles, err := r.vclFiles(ctx, vc, cm, data, routes)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
+	r.metrics.ObserveTemplateRender(time.Since(renderStart))
 
-	filesTouched, err := r.reconcileFiles(ctx, config.VCLConfigDir, currFiles, newFiles)
+	currFiles, err := getCurrentFiles(config.VCLConfigDir)
 	if err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	configName, err := r.varnish.GetActiveConfigurationName()
+	configName, err := r.varnish.GetActiveConfigurationName(ctx)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	// reload if files changed, or we didn't load the VCL yet (happens when only the container restarted and not the whole pod)
-	if filesTouched || configName == "boot" {
-		if err = r.reconcileVarnish(ctx, vc, pod, cm); err != nil {
+	reverted, err := r.reconcileVCLRevert(ctx, vc, pod, configName)
+	if err != nil || reverted {
+		// the pod update triggers an another reconcile
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	applicable, err := r.applicableConfigMap(ctx, config.VCLConfigDir, vc, pod, cm, configName, currFiles, newFiles)
+	if err != nil || applicable == nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+	if applicable != cm {
+		// the backends are still updated while the pod keeps running the previous version
+		cm = applicable
+		if newFiles, err = r.vclFiles(ctx, vc, cm, data, routes); err != nil {
+			return reconcile.Result{}, errors.WithStack(err)
+		}
+	}
+
+	// the changes are collected for a while to not reload the VCL on every change of the backends
+	backends := backendAddresses(bks, backendGroups, varnishNodes)
+	var reloadDelay time.Duration
+	if sameFiles(currFiles, newFiles) {
+		r.pendingReload = nil
+	} else {
+		reloadDelay = r.vclReloadDelay(vc, newFiles, backends, configName == "boot" || resyncRequested(pod, cm), time.Now())
+	}
+
+	filesTouched := false
+	if reloadDelay > 0 {
+		logr.Debugw("Delaying the VCL reload to collect more changes", "delay", reloadDelay.String())
+	} else {
+		filesTouched, err = r.reconcileFiles(ctx, config.VCLConfigDir, currFiles, newFiles)
+		if err != nil {
 			return reconcile.Result{}, errors.WithStack(err)
 		}
+		r.appliedBackends = backends
+
+		// reload if files changed, or we didn't load the VCL yet (happens when only the container restarted and not the whole pod),
+		// or the operator found the pod stuck on an another ConfigMap version
+		if filesTouched || configName == "boot" || resyncRequested(pod, cm) {
+			if err = r.reconcileVarnish(ctx, vc, pod, cm); err != nil {
+				return reconcile.Result{}, errors.WithStack(err)
+			}
+		}
 	}
 
-	if err := r.reconcilePod(ctx, filesTouched, pod, cm, localWeight, remoteWeight); err != nil {
+	// the operator reports the clusters without backends in the VarnishCluster status
+	backendsCount := len(bks)
+	for _, group := range backendGroups {
+		backendsCount += len(group.Backends)
+	}
+	// the delayed changes are not applied yet, so the pod doesn't run the current ConfigMap version
+	if err := r.reconcilePod(ctx, filesTouched || reloadDelay > 0, pod, cm, localWeight, remoteWeight, backendsCount); err != nil {
 		return reconcile.Result{}, errors.WithStack(err)
 	}
 
-	return reconcile.Result{}, nil
+	res, err := r.reconcileVCLCanaryHealth(ctx, vc, cm)
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	// check periodically if the new VCL made varnish panic
+	if r.panicWatch != nil && (res.RequeueAfter == 0 || res.RequeueAfter > vclPanicCheckInterval) {
+		res.RequeueAfter = vclPanicCheckInterval
+	}
+
+	if reloadDelay > 0 && (res.RequeueAfter == 0 || res.RequeueAfter > reloadDelay) {
+		res.RequeueAfter = reloadDelay
+	}
+
+	return res, nil
+}
+
+// updateBackendPredicates makes the watches trigger on the changes of the backends of all backend groups
+func (r *ReconcileVarnish) updateBackendPredicates(vc *v1alpha1.VarnishCluster) {
+	var namespaces, serviceNamespaces []string
+	var selectors, serviceSelectors []labels.Selector
+	for _, backend := range clusterBackends(vc) {
+		if backend.ServiceRef != nil {
+			namespace := backend.ServiceRef.Namespace
+			if namespace == "" {
+				namespace = r.config.Namespace
+			}
+			if !containsString(serviceNamespaces, namespace) {
+				serviceNamespaces = append(serviceNamespaces, namespace)
+			}
+			serviceSelectors = append(serviceSelectors, labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: backend.ServiceRef.Name}))
+			continue
+		}
+
+		// external only backends
+		if len(backend.Selector) == 0 {
+			continue
+		}
+
+		for _, ns := range r.backendNamespaces(backend) {
+			if !containsString(namespaces, ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		selectors = append(selectors, labels.SelectorFromSet(backend.Selector))
+	}
+
+	r.backendsNamespacePredicate.Namespaces = namespaces
+	r.backendsSelectorPredicate.Selectors = selectors
+	r.backendServicesNamespacePredicate.Namespaces = serviceNamespaces
+	r.backendServicesPredicate.Selectors = serviceSelectors
+}
+
+// vclFiles returns the VCL files of the ConfigMap with the templates rendered and the VarnishSites router added to the entrypoint
+func (r *ReconcileVarnish) vclFiles(ctx context.Context, vc *v1alpha1.VarnishCluster, cm *v1.ConfigMap, data map[string]interface{}, routes []siteRoute) (map[string]string, error) {
+	entrypointFileName := *vc.Spec.VCL.EntrypointFileName
+	files, templates := r.filesAndTemplates(cm.Data)
+
+	if err := r.verifyEntrypointExists(files, templates, entrypointFileName); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	templatizedFiles, err := r.resolveTemplates(templates, data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for fileName, contents := range templatizedFiles {
+		if _, found := files[fileName]; found {
+			errMsg := fmt.Sprintf("VCL ConfigMap %s has %s and %s.tmpl entries. Cannot include file and template with same name",
+				cm.Name, fileName, fileName)
+			r.eventHandler.Warning(vc, events.EventReasonInvalidVCLConfigMap, errMsg)
+			return nil, errors.Errorf(errMsg)
+		}
+		files[fileName] = contents
+	}
+
+	if files[entrypointFileName], err = addSiteRouter(files[entrypointFileName], routes); err != nil {
+		logger.FromContext(ctx).Warnw("Can't route requests to VarnishSites", zap.Error(err))
+	}
+	return files, nil
+}
+
+// clusterBackends returns the default backend, if set, and the backends of all backend groups
+func clusterBackends(vc *v1alpha1.VarnishCluster) []*v1alpha1.VarnishClusterBackend {
+	var backends []*v1alpha1.VarnishClusterBackend
+	if vc.Spec.Backend != nil {
+		backends = append(backends, vc.Spec.Backend)
+	}
+	for i := range vc.Spec.Backends {
+		backends = append(backends, &vc.Spec.Backends[i].VarnishClusterBackend)
+	}
+	return backends
 }
 
 func (r *ReconcileVarnish) filesAndTemplates(data map[string]string) (files, templates map[string]string) {
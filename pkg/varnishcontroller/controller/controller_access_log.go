@@ -0,0 +1,17 @@
+package controller
+
+import (
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+)
+
+// reconcileAccessLog starts, reconfigures or stops the access log according to .spec.logging.access
+func (r *ReconcileVarnish) reconcileAccessLog(vc *v1alpha1.VarnishCluster) {
+	if r.accessLog == nil {
+		return
+	}
+	if vc.Spec.Logging == nil {
+		r.accessLog.Update(nil)
+		return
+	}
+	r.accessLog.Update(vc.Spec.Logging.Access)
+}
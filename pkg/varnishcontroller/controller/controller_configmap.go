@@ -3,9 +3,13 @@ package controller
 import (
 	"context"
 
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/names"
+
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -21,3 +25,53 @@ func (r *ReconcileVarnish) getConfigMap(ctx context.Context, namespace, name str
 
 	return found, nil
 }
+
+// getLastValidatedConfigMap returns the copy of the last ConfigMap version that passed the pre-flight check, made by the operator.
+// The copy has the resource version of the original ConfigMap, so the VCL and the pod annotations refer to the copied version.
+// Returns nil if no version passed the check yet.
+func (r *ReconcileVarnish) getLastValidatedConfigMap(ctx context.Context, vc *v1alpha1.VarnishCluster) (*v1.ConfigMap, error) {
+	found := &v1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: names.LastValidatedVCLConfigMap(vc.Name)}, found)
+	if err != nil && kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not Get the last validated VCL ConfigMap")
+	}
+
+	version := found.Annotations[annotationConfigMapVersion]
+	if version == "" {
+		return nil, nil
+	}
+
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            *vc.Spec.VCL.ConfigMapName,
+			Namespace:       found.Namespace,
+			ResourceVersion: version,
+		},
+		Data: found.Data,
+	}, nil
+}
+
+// runningConfigMap returns the ConfigMap version of the active VCL, so its VCL can be rendered again with the current backends.
+// A pod that has no VCL loaded yet gets the last version that passed the pre-flight check, as varnish serves only errors until a VCL is loaded.
+// Returns nil if the version is not known, e.g. because the controller restarted since the VCL has been loaded.
+func (r *ReconcileVarnish) runningConfigMap(ctx context.Context, vc *v1alpha1.VarnishCluster, activeVCLName string) (*v1.ConfigMap, error) {
+	lastValidated, err := r.getLastValidatedConfigMap(ctx, vc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if activeVCLName == "boot" {
+		return lastValidated, nil
+	}
+
+	version := extractConfigMapVersion(activeVCLName)
+	if cm, found := r.appliedConfigMaps[version]; found {
+		return cm, nil
+	}
+	if lastValidated != nil && lastValidated.GetResourceVersion() == version {
+		return lastValidated, nil
+	}
+	return nil, nil
+}
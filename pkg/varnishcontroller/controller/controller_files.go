@@ -49,17 +49,20 @@ func (r *ReconcileVarnish) reconcileFiles(ctx context.Context, dir string, currF
 			if err := os.Remove(fullpath); err != nil {
 				return filesTouched, errors.Wrapf(err, "could not delete file %s", fullpath)
 			}
+			r.metrics.FileRemoved()
 		} else if status == 0 && strings.Compare(currFiles[fileName], newFiles[fileName]) != 0 {
 			filesTouched = true
 			if err := os.WriteFile(fullpath, []byte(newFiles[fileName]), 0644); err != nil {
 				return filesTouched, errors.Wrapf(err, "could not write file %s", fullpath)
 			}
+			r.metrics.FileWritten()
 			logr.Infow("Rewriting file")
 		} else if status == 1 {
 			filesTouched = true
 			if err := os.WriteFile(fullpath, []byte(newFiles[fileName]), 0644); err != nil {
 				return filesTouched, errors.Wrapf(err, "could not write file %s", fullpath)
 			}
+			r.metrics.FileWritten()
 			logr.Infow("Writing new file")
 		}
 	}
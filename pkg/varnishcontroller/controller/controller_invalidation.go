@@ -0,0 +1,310 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlBuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// how often the phase of an unfinished invalidation is recalculated, e.g. to not wait for pods that have been deleted
+	invalidationResyncInterval = 30 * time.Second
+	invalidationMaxRetryDelay  = 5 * time.Minute
+)
+
+// SetupInvalidationReconciler creates a controller that executes the VarnishInvalidations of the VarnishCluster on the local varnish instance
+func SetupInvalidationReconciler(mgr manager.Manager, cfg *config.Config, varnish varnishadm.VarnishAdministrator, logr *logger.Logger) error {
+	r := &ReconcileInvalidation{
+		Client:       mgr.GetClient(),
+		config:       cfg,
+		logger:       logr,
+		varnish:      varnish,
+		eventHandler: events.NewEventHandler(mgr.GetEventRecorderFor(events.EventRecorderName), cfg.PodName),
+		now:          time.Now,
+	}
+
+	clusterInvalidations := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		inv, ok := o.(*v1alpha1.VarnishInvalidation)
+		return ok && r.isClusterInvalidation(inv)
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("varnish-invalidation-controller").
+		For(&v1alpha1.VarnishInvalidation{}, ctrlBuilder.WithPredicates(clusterInvalidations)).
+		Complete(r)
+}
+
+var _ reconcile.Reconciler = &ReconcileInvalidation{}
+
+// ReconcileInvalidation executes the bans of the VarnishInvalidations and records the result of the local pod in their status
+type ReconcileInvalidation struct {
+	client.Client
+	config       *config.Config
+	logger       *logger.Logger
+	varnish      varnishadm.VarnishAdministrator
+	eventHandler *events.EventHandler
+	now          func() time.Time
+}
+
+func (r *ReconcileInvalidation) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	logr := r.logger.With(logger.FieldVarnishCluster, r.config.VarnishClusterName)
+	logr = logr.With(logger.FieldPodName, r.config.PodName)
+	logr = logr.With(logger.FieldNamespace, request.Namespace)
+	logr = logr.With("varnishInvalidation", request.Name)
+	ctx = logger.ToContext(ctx, logr)
+
+	res, err := r.reconcileInvalidation(ctx, request)
+	if err != nil {
+		logr.Errorf("%+v", err)
+		return reconcile.Result{}, err
+	}
+	return res, nil
+}
+
+func (r *ReconcileInvalidation) reconcileInvalidation(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	logr := logger.FromContext(ctx)
+	inv := &v1alpha1.VarnishInvalidation{}
+	if err := r.Get(ctx, request.NamespacedName, inv); err != nil {
+		if apierrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	if !r.isClusterInvalidation(inv) || inv.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	if inv.Finished() {
+		return r.reconcileInvalidationTTL(ctx, inv)
+	}
+
+	pod := &v1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: r.config.PodName}, pod); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	podStatus := invalidationPodStatus(inv, r.config.PodName)
+	if wait := r.retryDelay(inv, podStatus); wait > 0 {
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+
+	var result *v1alpha1.VarnishInvalidationPodStatus
+	if podStatus == nil && pod.CreationTimestamp.After(inv.CreationTimestamp.Time) {
+		// the pod started with an empty cache, so there is nothing to invalidate
+		result = &v1alpha1.VarnishInvalidationPodStatus{
+			Name:            r.config.PodName,
+			Phase:           v1alpha1.VarnishInvalidationPodPhaseSucceeded,
+			Message:         "Pod started after the invalidation was created",
+			LastAttemptTime: metav1.NewTime(r.now()),
+		}
+	} else if podStatus == nil || (podStatus.Phase == v1alpha1.VarnishInvalidationPodPhaseFailed && podStatus.Attempts <= inv.MaxRetries()) {
+		result = r.ban(ctx, inv, podStatus)
+	}
+
+	if err := r.updateInvalidationStatus(ctx, inv, result); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	if inv.Finished() {
+		logr.Infow("Invalidation finished", "phase", inv.Status.Phase)
+		return r.reconcileInvalidationTTL(ctx, inv)
+	}
+
+	if wait := r.retryDelay(inv, invalidationPodStatus(inv, r.config.PodName)); wait > 0 {
+		return reconcile.Result{RequeueAfter: wait}, nil
+	}
+	return reconcile.Result{RequeueAfter: invalidationResyncInterval}, nil
+}
+
+// ban executes the ban on the local varnish instance and returns the result
+func (r *ReconcileInvalidation) ban(ctx context.Context, inv *v1alpha1.VarnishInvalidation, previous *v1alpha1.VarnishInvalidationPodStatus) *v1alpha1.VarnishInvalidationPodStatus {
+	logr := logger.FromContext(ctx)
+	result := &v1alpha1.VarnishInvalidationPodStatus{
+		Name:            r.config.PodName,
+		Phase:           v1alpha1.VarnishInvalidationPodPhaseSucceeded,
+		Attempts:        1,
+		LastAttemptTime: metav1.NewTime(r.now()),
+	}
+	if previous != nil {
+		result.Attempts = previous.Attempts + 1
+	}
+
+	expression, err := inv.BanExpression()
+	if err != nil {
+		// an invalid spec can't succeed on a retry
+		result.Phase = v1alpha1.VarnishInvalidationPodPhaseFailed
+		result.Attempts = inv.MaxRetries() + 1
+		result.Message = err.Error()
+		r.eventHandler.Warning(inv, events.EventReasonInvalidationFailed, fmt.Sprintf("Invalid invalidation: %s", err))
+		return result
+	}
+
+	if err = r.varnish.Ban(ctx, expression); err != nil {
+		result.Phase = v1alpha1.VarnishInvalidationPodPhaseFailed
+		result.Message = err.Error()
+		logr.Warnw("Ban failed", "expression", expression, "attempt", result.Attempts, zap.Error(err))
+		if result.Attempts > inv.MaxRetries() {
+			r.eventHandler.Warning(inv, events.EventReasonInvalidationFailed, fmt.Sprintf("Ban failed after %d attempts: %s", result.Attempts, err))
+		}
+		return result
+	}
+
+	logr.Infow("Ban executed", "expression", expression)
+	return result
+}
+
+// updateInvalidationStatus records the result of the local pod, if any, and recalculates the phase of the invalidation
+func (r *ReconcileInvalidation) updateInvalidationStatus(ctx context.Context, inv *v1alpha1.VarnishInvalidation, result *v1alpha1.VarnishInvalidationPodStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.VarnishInvalidation{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(inv), latest); err != nil {
+			return err
+		}
+
+		if result != nil {
+			setInvalidationPodStatus(latest, *result)
+		}
+		changed := result != nil
+		if latest.Status.Expression == "" {
+			latest.Status.Expression, _ = latest.BanExpression()
+			changed = changed || latest.Status.Expression != ""
+		}
+
+		pods := &v1.PodList{}
+		selector := client.MatchingLabels{
+			v1alpha1.LabelVarnishOwner:     r.config.VarnishClusterName,
+			v1alpha1.LabelVarnishComponent: v1alpha1.VarnishComponentVarnish,
+			v1alpha1.LabelVarnishUID:       string(r.config.VarnishClusterUID),
+		}
+		if err := r.List(ctx, pods, client.InNamespace(r.config.Namespace), selector); err != nil {
+			return errors.Wrap(err, "can't list varnish pods")
+		}
+		podNames := make([]string, 0, len(pods.Items))
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp == nil {
+				podNames = append(podNames, pod.Name)
+			}
+		}
+
+		phase := invalidationPhase(latest, podNames)
+		if phase == latest.Status.Phase && !changed {
+			latest.DeepCopyInto(inv)
+			return nil
+		}
+		latest.Status.Phase = phase
+		if latest.Finished() && latest.Status.CompletionTime == nil {
+			now := metav1.NewTime(r.now())
+			latest.Status.CompletionTime = &now
+		}
+
+		if err := r.Status().Update(ctx, latest); err != nil {
+			return err
+		}
+		latest.DeepCopyInto(inv)
+		return nil
+	})
+}
+
+// reconcileInvalidationTTL deletes the finished invalidation once its TTL expires
+func (r *ReconcileInvalidation) reconcileInvalidationTTL(ctx context.Context, inv *v1alpha1.VarnishInvalidation) (reconcile.Result, error) {
+	if inv.Status.CompletionTime == nil {
+		return reconcile.Result{}, nil
+	}
+
+	expiresIn := inv.Status.CompletionTime.Add(time.Duration(inv.TTLSecondsAfterFinished()) * time.Second).Sub(r.now())
+	if expiresIn > 0 {
+		return reconcile.Result{RequeueAfter: expiresIn}, nil
+	}
+
+	// every pod of the cluster tries to delete the expired invalidation, so the first one wins
+	if err := r.Delete(ctx, inv, client.Preconditions{UID: &inv.UID}); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return reconcile.Result{}, errors.Wrap(err, "can't delete expired VarnishInvalidation")
+	}
+	logger.FromContext(ctx).Infow("Expired invalidation deleted")
+	return reconcile.Result{}, nil
+}
+
+// retryDelay returns how long to wait before the failed ban is retried. Returns 0 if it can be retried now or there is nothing to retry.
+func (r *ReconcileInvalidation) retryDelay(inv *v1alpha1.VarnishInvalidation, podStatus *v1alpha1.VarnishInvalidationPodStatus) time.Duration {
+	if podStatus == nil || podStatus.Phase != v1alpha1.VarnishInvalidationPodPhaseFailed || podStatus.Attempts > inv.MaxRetries() {
+		return 0
+	}
+
+	delay := time.Duration(1<<uint(podStatus.Attempts)) * time.Second
+	if delay > invalidationMaxRetryDelay || delay <= 0 {
+		delay = invalidationMaxRetryDelay
+	}
+	return podStatus.LastAttemptTime.Add(delay).Sub(r.now())
+}
+
+func (r *ReconcileInvalidation) isClusterInvalidation(inv *v1alpha1.VarnishInvalidation) bool {
+	return inv.Namespace == r.config.Namespace && inv.Spec.VarnishCluster == r.config.VarnishClusterName
+}
+
+// invalidationPhase calculates the phase of the invalidation from the results of the given pods.
+// The invalidation is finished when every pod either succeeded or exhausted its retries.
+func invalidationPhase(inv *v1alpha1.VarnishInvalidation, podNames []string) string {
+	if len(inv.Status.Pods) == 0 {
+		return v1alpha1.VarnishInvalidationPhasePending
+	}
+
+	finished, failed := true, false
+	for _, name := range podNames {
+		podStatus := invalidationPodStatus(inv, name)
+		switch {
+		case podStatus == nil:
+			finished = false
+		case podStatus.Phase == v1alpha1.VarnishInvalidationPodPhaseFailed && podStatus.Attempts > inv.MaxRetries():
+			failed = true
+		case podStatus.Phase == v1alpha1.VarnishInvalidationPodPhaseFailed:
+			finished = false
+		}
+	}
+
+	switch {
+	case !finished:
+		return v1alpha1.VarnishInvalidationPhaseInProgress
+	case failed:
+		return v1alpha1.VarnishInvalidationPhaseFailed
+	default:
+		return v1alpha1.VarnishInvalidationPhaseCompleted
+	}
+}
+
+func invalidationPodStatus(inv *v1alpha1.VarnishInvalidation, podName string) *v1alpha1.VarnishInvalidationPodStatus {
+	for i := range inv.Status.Pods {
+		if inv.Status.Pods[i].Name == podName {
+			return &inv.Status.Pods[i]
+		}
+	}
+	return nil
+}
+
+func setInvalidationPodStatus(inv *v1alpha1.VarnishInvalidation, podStatus v1alpha1.VarnishInvalidationPodStatus) {
+	if existing := invalidationPodStatus(inv, podStatus.Name); existing != nil {
+		*existing = podStatus
+		return
+	}
+	inv.Status.Pods = append(inv.Status.Pods, podStatus)
+}
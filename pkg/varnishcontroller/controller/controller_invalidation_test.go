@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileInvalidation(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := metav1.NewTime(now.Add(-time.Minute))
+
+	varnishPod := func(name string, createdAt time.Time) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(createdAt),
+			Labels: map[string]string{
+				v1alpha1.LabelVarnishOwner:     "varnish",
+				v1alpha1.LabelVarnishComponent: v1alpha1.VarnishComponentVarnish,
+				v1alpha1.LabelVarnishUID:       "uid",
+			},
+		}}
+	}
+	invalidation := func(pods ...v1alpha1.VarnishInvalidationPodStatus) *v1alpha1.VarnishInvalidation {
+		return &v1alpha1.VarnishInvalidation{
+			ObjectMeta: metav1.ObjectMeta{Name: "purge", Namespace: "default", CreationTimestamp: created},
+			Spec:       v1alpha1.VarnishInvalidationSpec{VarnishCluster: "varnish", URL: "/products/1"},
+			Status:     v1alpha1.VarnishInvalidationStatus{Pods: pods},
+		}
+	}
+	podResult := func(name, phase string, attempts int32, lastAttempt time.Time) v1alpha1.VarnishInvalidationPodStatus {
+		return v1alpha1.VarnishInvalidationPodStatus{Name: name, Phase: phase, Attempts: attempts, LastAttemptTime: metav1.NewTime(lastAttempt)}
+	}
+
+	cases := []struct {
+		name             string
+		objects          []client.Object
+		varnish          *varnishMock
+		expectedBans     []string
+		expectedPhase    string
+		expectedPod      *v1alpha1.VarnishInvalidationPodStatus
+		expectedRequeue  time.Duration
+		expectDeleted    bool
+		expectedComplete bool
+	}{
+		{
+			name:            "ban executed, waiting for the other pods",
+			objects:         []client.Object{invalidation(), varnishPod("varnish-0", now.Add(-time.Hour)), varnishPod("varnish-1", now.Add(-time.Hour))},
+			varnish:         &varnishMock{},
+			expectedBans:    []string{`req.url == "/products/1"`},
+			expectedPhase:   v1alpha1.VarnishInvalidationPhaseInProgress,
+			expectedPod:     &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseSucceeded, Attempts: 1},
+			expectedRequeue: invalidationResyncInterval,
+		},
+		{
+			name: "ban executed on the last pod",
+			objects: []client.Object{
+				invalidation(podResult("varnish-1", v1alpha1.VarnishInvalidationPodPhaseSucceeded, 1, now)),
+				varnishPod("varnish-0", now.Add(-time.Hour)), varnishPod("varnish-1", now.Add(-time.Hour)),
+			},
+			varnish:          &varnishMock{},
+			expectedBans:     []string{`req.url == "/products/1"`},
+			expectedPhase:    v1alpha1.VarnishInvalidationPhaseCompleted,
+			expectedPod:      &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseSucceeded, Attempts: 1},
+			expectedRequeue:  24 * time.Hour,
+			expectedComplete: true,
+		},
+		{
+			name:             "pod started after the invalidation has nothing to invalidate",
+			objects:          []client.Object{invalidation(), varnishPod("varnish-0", now)},
+			varnish:          &varnishMock{},
+			expectedPhase:    v1alpha1.VarnishInvalidationPhaseCompleted,
+			expectedPod:      &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseSucceeded, Message: "Pod started after the invalidation was created"},
+			expectedRequeue:  24 * time.Hour,
+			expectedComplete: true,
+		},
+		{
+			name:            "ban failed, retried later",
+			objects:         []client.Object{invalidation(), varnishPod("varnish-0", now.Add(-time.Hour))},
+			varnish:         &varnishMock{banError: errors.New("varnish is unreachable")},
+			expectedBans:    []string{`req.url == "/products/1"`},
+			expectedPhase:   v1alpha1.VarnishInvalidationPhaseInProgress,
+			expectedPod:     &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseFailed, Attempts: 1, Message: "varnish is unreachable"},
+			expectedRequeue: 2 * time.Second,
+		},
+		{
+			name: "retry is not due yet",
+			objects: []client.Object{
+				invalidation(podResult("varnish-0", v1alpha1.VarnishInvalidationPodPhaseFailed, 3, now.Add(-time.Second))),
+				varnishPod("varnish-0", now.Add(-time.Hour)),
+			},
+			varnish:         &varnishMock{},
+			expectedPod:     &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseFailed, Attempts: 3},
+			expectedRequeue: 7 * time.Second,
+		},
+		{
+			name: "retries exhausted",
+			objects: []client.Object{
+				invalidation(podResult("varnish-0", v1alpha1.VarnishInvalidationPodPhaseFailed, 5, now.Add(-time.Hour))),
+				varnishPod("varnish-0", now.Add(-time.Hour)),
+			},
+			varnish:          &varnishMock{banError: errors.New("varnish is unreachable")},
+			expectedBans:     []string{`req.url == "/products/1"`},
+			expectedPhase:    v1alpha1.VarnishInvalidationPhaseFailed,
+			expectedPod:      &v1alpha1.VarnishInvalidationPodStatus{Name: "varnish-0", Phase: v1alpha1.VarnishInvalidationPodPhaseFailed, Attempts: 6, Message: "varnish is unreachable"},
+			expectedRequeue:  24 * time.Hour,
+			expectedComplete: true,
+		},
+		{
+			name: "expired invalidation is deleted",
+			objects: []client.Object{
+				func() *v1alpha1.VarnishInvalidation {
+					inv := invalidation(podResult("varnish-0", v1alpha1.VarnishInvalidationPodPhaseSucceeded, 1, now.Add(-48*time.Hour)))
+					inv.Status.Phase = v1alpha1.VarnishInvalidationPhaseCompleted
+					completed := metav1.NewTime(now.Add(-25 * time.Hour))
+					inv.Status.CompletionTime = &completed
+					return inv
+				}(),
+				varnishPod("varnish-0", now.Add(-time.Hour)),
+			},
+			varnish:       &varnishMock{},
+			expectDeleted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+			g.Expect(v1alpha1.AddToScheme(scheme)).To(gomega.Succeed())
+
+			r := &ReconcileInvalidation{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.objects...).Build(),
+				config: &config.Config{
+					Namespace:          "default",
+					PodName:            "varnish-0",
+					VarnishClusterName: "varnish",
+					VarnishClusterUID:  "uid",
+				},
+				logger:       logger.NewNopLogger(),
+				varnish:      c.varnish,
+				eventHandler: varnishEvents.NewEventHandler(record.NewFakeRecorder(10), "varnish-0"),
+				now:          func() time.Time { return now },
+			}
+
+			key := types.NamespacedName{Namespace: "default", Name: "purge"}
+			res, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(res.RequeueAfter).To(gomega.Equal(c.expectedRequeue))
+			g.Expect(c.varnish.bans).To(gomega.Equal(c.expectedBans))
+
+			inv := &v1alpha1.VarnishInvalidation{}
+			err = r.Get(context.Background(), key, inv)
+			if c.expectDeleted {
+				g.Expect(apierrors.IsNotFound(err)).To(gomega.BeTrue())
+				return
+			}
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(inv.Status.Phase).To(gomega.Equal(c.expectedPhase))
+			g.Expect(inv.Status.CompletionTime != nil).To(gomega.Equal(c.expectedComplete))
+
+			podStatus := invalidationPodStatus(inv, "varnish-0")
+			g.Expect(podStatus).ToNot(gomega.BeNil())
+			podStatus.LastAttemptTime = metav1.Time{}
+			g.Expect(podStatus).To(gomega.Equal(c.expectedPod))
+		})
+	}
+}
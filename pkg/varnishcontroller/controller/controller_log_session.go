@@ -0,0 +1,565 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/accesslog"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlBuilder "sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// VarnishLogBinary is the binary that captures the transactions from the varnish shared memory log
+	VarnishLogBinary = "varnishlog"
+
+	// how often the phase of an unfinished session is recalculated, e.g. to not wait for pods that have been deleted
+	logSessionResyncInterval = 30 * time.Second
+	// how often the number of the captured transactions is reported in the status
+	logSessionProgressInterval = 10 * time.Second
+	varnishLogMaxLineLength    = 1024 * 1024
+)
+
+// SetupLogSessionReconciler creates a controller that captures the transactions of the local varnish instance for the VarnishLogSessions of the VarnishCluster
+func SetupLogSessionReconciler(mgr manager.Manager, cfg *config.Config, logr *logger.Logger) error {
+	r := &ReconcileLogSession{
+		Client:           mgr.GetClient(),
+		config:           cfg,
+		logger:           logr,
+		eventHandler:     events.NewEventHandler(mgr.GetEventRecorderFor(events.EventRecorderName), cfg.PodName),
+		now:              time.Now,
+		command:          exec.CommandContext,
+		progressInterval: logSessionProgressInterval,
+		captures:         map[types.NamespacedName]*logCapture{},
+	}
+
+	clusterSessions := predicate.NewPredicateFuncs(func(o client.Object) bool {
+		session, ok := o.(*v1alpha1.VarnishLogSession)
+		return ok && r.isClusterLogSession(session)
+	})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("varnish-log-session-controller").
+		For(&v1alpha1.VarnishLogSession{}, ctrlBuilder.WithPredicates(clusterSessions)).
+		Complete(r)
+}
+
+var _ reconcile.Reconciler = &ReconcileLogSession{}
+
+// ReconcileLogSession runs varnishlog for the VarnishLogSessions, stores the captured transactions in ConfigMaps
+// and records the progress of the local pod in their status
+type ReconcileLogSession struct {
+	client.Client
+	config           *config.Config
+	logger           *logger.Logger
+	eventHandler     *events.EventHandler
+	now              func() time.Time
+	command          func(ctx context.Context, name string, args ...string) *exec.Cmd
+	progressInterval time.Duration
+
+	mu sync.Mutex
+	// the captures started by this pod. Kept until the session is finished or deleted
+	captures map[types.NamespacedName]*logCapture
+}
+
+// logCapture is a varnishlog run in the background
+type logCapture struct {
+	uid    types.UID
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (r *ReconcileLogSession) Reconcile(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	logr := r.logger.With(logger.FieldVarnishCluster, r.config.VarnishClusterName)
+	logr = logr.With(logger.FieldPodName, r.config.PodName)
+	logr = logr.With(logger.FieldNamespace, request.Namespace)
+	logr = logr.With("varnishLogSession", request.Name)
+	ctx = logger.ToContext(ctx, logr)
+
+	res, err := r.reconcileLogSession(ctx, request)
+	if err != nil {
+		logr.Errorf("%+v", err)
+		return reconcile.Result{}, err
+	}
+	return res, nil
+}
+
+func (r *ReconcileLogSession) reconcileLogSession(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+	session := &v1alpha1.VarnishLogSession{}
+	if err := r.Get(ctx, request.NamespacedName, session); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.stopCapture(request.NamespacedName)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	if !r.isClusterLogSession(session) || session.DeletionTimestamp != nil {
+		r.stopCapture(request.NamespacedName)
+		return reconcile.Result{}, nil
+	}
+
+	if session.Finished() {
+		r.stopCapture(request.NamespacedName)
+		return r.reconcileLogSessionTTL(ctx, session)
+	}
+
+	pod := &v1.Pod{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: r.config.PodName}, pod); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	podStatus := logSessionPodStatus(session, r.config.PodName)
+	running := r.runningCapture(request.NamespacedName, session.UID) != nil
+	start := false
+	var update func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus
+	switch {
+	case !logSessionTargetsPod(session, pod):
+		// the other pods capture the transactions, this one only keeps the phase up to date
+	case podStatus == nil && r.now().Before(session.WindowEnd()):
+		start = true
+		update = func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus {
+			if current != nil {
+				return nil
+			}
+			now := metav1.NewTime(r.now())
+			return &v1alpha1.VarnishLogSessionPodStatus{Name: r.config.PodName, Phase: v1alpha1.VarnishLogSessionPodPhaseCapturing, StartTime: &now}
+		}
+	case podStatus == nil:
+		update = r.failCapture(false, "The capture window ended before the capture could be started")
+	case podStatus.Phase == v1alpha1.VarnishLogSessionPodPhaseCapturing && !running:
+		// the captured transactions are kept in memory, so they are lost if the varnish-controller restarts
+		update = r.failCapture(true, "The capture was interrupted by a restart of the varnish-controller")
+	}
+
+	applied, err := r.updateLogSessionStatus(ctx, session, update)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if start && applied {
+		r.startCapture(ctx, session)
+	}
+
+	if session.Finished() {
+		logger.FromContext(ctx).Infow("Log session finished", "phase", session.Status.Phase)
+		return r.reconcileLogSessionTTL(ctx, session)
+	}
+	return reconcile.Result{RequeueAfter: logSessionResyncInterval}, nil
+}
+
+// failCapture returns a status update that fails the capture of the local pod.
+// It's applied only if the pod is capturing or, if capturing is false, has no status yet.
+func (r *ReconcileLogSession) failCapture(capturing bool, message string) func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus {
+	return func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus {
+		failed := &v1alpha1.VarnishLogSessionPodStatus{Name: r.config.PodName}
+		switch {
+		case capturing && (current == nil || current.Phase != v1alpha1.VarnishLogSessionPodPhaseCapturing):
+			return nil
+		case !capturing && current != nil:
+			return nil
+		case current != nil:
+			current.DeepCopyInto(failed)
+		}
+		now := metav1.NewTime(r.now())
+		failed.Phase = v1alpha1.VarnishLogSessionPodPhaseFailed
+		failed.Message = message
+		failed.CompletionTime = &now
+		return failed
+	}
+}
+
+// startCapture runs varnishlog in the background until the capture window ends
+func (r *ReconcileLogSession) startCapture(ctx context.Context, session *v1alpha1.VarnishLogSession) {
+	key := client.ObjectKeyFromObject(session)
+	captureCtx, cancel := context.WithCancel(ctx)
+	c := &logCapture{uid: session.UID, cancel: cancel, done: make(chan struct{})}
+
+	r.mu.Lock()
+	r.captures[key] = c
+	r.mu.Unlock()
+
+	session = session.DeepCopy()
+	go func() {
+		defer close(c.done)
+		r.capture(captureCtx, session)
+	}()
+}
+
+// runningCapture returns the capture of the session started by this pod, if any
+func (r *ReconcileLogSession) runningCapture(key types.NamespacedName, uid types.UID) *logCapture {
+	r.mu.Lock()
+	c, found := r.captures[key]
+	r.mu.Unlock()
+	if !found {
+		return nil
+	}
+	if c.uid != uid {
+		// the session has been recreated with the same name
+		r.stopCapture(key)
+		return nil
+	}
+	return c
+}
+
+// stopCapture stops the capture of the session, if any, and forgets about it
+func (r *ReconcileLogSession) stopCapture(key types.NamespacedName) {
+	r.mu.Lock()
+	c, found := r.captures[key]
+	delete(r.captures, key)
+	r.mu.Unlock()
+	if found {
+		c.cancel()
+		<-c.done
+	}
+}
+
+// capture runs varnishlog, stores the captured transactions in a ConfigMap and records the result in the session status
+func (r *ReconcileLogSession) capture(ctx context.Context, session *v1alpha1.VarnishLogSession) {
+	logr := logger.FromContext(ctx)
+	logr.Infow("Capture started", "query", session.Spec.Query, "until", session.WindowEnd())
+
+	buf := &transactionBuffer{maxSize: int(session.MaxSizeBytes())}
+	redactor, err := r.redactor(ctx)
+	if err == nil {
+		err = r.runVarnishLog(ctx, session, buf, redactor)
+	}
+	if ctx.Err() != nil {
+		// the session has been deleted or the varnish-controller is shutting down
+		return
+	}
+
+	configMapName := ""
+	if err == nil {
+		configMapName, err = r.storeTransactions(ctx, session, buf)
+	}
+	if err != nil {
+		logr.Warnw("Capture failed", zap.Error(err))
+		r.eventHandler.Warning(session, events.EventReasonLogSessionFailed, fmt.Sprintf("Capture failed: %s", err))
+	}
+
+	transactions, size, truncated := buf.progress()
+	_, updateErr := r.updateLogSessionStatus(ctx, session, func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus {
+		if current == nil || current.Phase != v1alpha1.VarnishLogSessionPodPhaseCapturing {
+			return nil
+		}
+		podStatus := current.DeepCopy()
+		now := metav1.NewTime(r.now())
+		podStatus.CompletionTime = &now
+		podStatus.Transactions, podStatus.Bytes, podStatus.Truncated = transactions, size, truncated
+		if err != nil {
+			podStatus.Phase, podStatus.Message = v1alpha1.VarnishLogSessionPodPhaseFailed, err.Error()
+		} else {
+			podStatus.Phase, podStatus.ConfigMap = v1alpha1.VarnishLogSessionPodPhaseCompleted, configMapName
+		}
+		return podStatus
+	})
+	if updateErr != nil {
+		logr.Errorf("%+v", updateErr)
+		return
+	}
+	logr.Infow("Capture finished", "transactions", transactions, "bytes", size, "truncated", truncated)
+}
+
+// redactor returns the redactor of the headers in the captured transactions: the headers with credentials
+// and the headers redacted in the access log of the VarnishCluster
+func (r *ReconcileLogSession) redactor(ctx context.Context) (*accesslog.Redactor, error) {
+	vc := &v1alpha1.VarnishCluster{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: r.config.VarnishClusterName}, vc); err != nil {
+		return nil, errors.Wrap(err, "can't get the VarnishCluster")
+	}
+
+	headers := append([]string{}, accesslog.CredentialHeaders...)
+	if vc.Spec.Logging != nil && vc.Spec.Logging.Access != nil {
+		headers = append(headers, vc.Spec.Logging.Access.RedactHeaders...)
+	}
+	return accesslog.NewRedactor(headers), nil
+}
+
+// runVarnishLog captures the transactions until the capture window ends or the buffer is full.
+// The header values are redacted before the transactions are buffered, so they are not stored in the ConfigMap.
+// The progress is reported in the session status while capturing.
+func (r *ReconcileLogSession) runVarnishLog(ctx context.Context, session *v1alpha1.VarnishLogSession, buf *transactionBuffer, redactor *accesslog.Redactor) error {
+	windowCtx, cancel := context.WithTimeout(ctx, session.WindowEnd().Sub(r.now()))
+	defer cancel()
+
+	// -g request groups the backend requests with the client request, -t off waits for varnish to start
+	args := []string{"-g", "request", "-t", "off"}
+	if session.Spec.Query != "" {
+		args = append(args, "-q", session.Spec.Query)
+	}
+
+	var stderr bytes.Buffer
+	cmd := r.command(windowCtx, VarnishLogBinary, args...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err = cmd.Start(); err != nil {
+		return errors.Wrap(err, "can't start varnishlog")
+	}
+
+	read := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), varnishLogMaxLineLength)
+		for scanner.Scan() {
+			if !buf.add(redactor.VarnishLogLine(scanner.Bytes())) {
+				// stop varnishlog, as its output is not read anymore
+				cancel()
+				break
+			}
+		}
+		if scanner.Err() != nil {
+			cancel()
+		}
+		read <- scanner.Err()
+	}()
+
+	ticker := time.NewTicker(r.progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			transactions, size, _ := buf.progress()
+			_, err := r.updateLogSessionStatus(ctx, session, func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus {
+				if current == nil || current.Phase != v1alpha1.VarnishLogSessionPodPhaseCapturing {
+					return nil
+				}
+				progress := current.DeepCopy()
+				progress.Transactions, progress.Bytes = transactions, size
+				return progress
+			})
+			if err != nil {
+				logger.FromContext(ctx).Warnw("Can't report the capture progress", zap.Error(err))
+			}
+		case readErr := <-read:
+			waitErr := cmd.Wait()
+			switch {
+			case readErr != nil:
+				return errors.Wrap(readErr, "can't read the varnishlog output")
+			case waitErr != nil && ctx.Err() == nil && windowCtx.Err() == nil:
+				// varnishlog exited by itself, e.g. because of an invalid query
+				return errors.Wrap(waitErr, strings.TrimSpace(stderr.String()))
+			}
+			return nil
+		}
+	}
+}
+
+// storeTransactions creates or updates the ConfigMap of the local pod with the captured transactions. Returns the ConfigMap name.
+func (r *ReconcileLogSession) storeTransactions(ctx context.Context, session *v1alpha1.VarnishLogSession, buf *transactionBuffer) (string, error) {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", session.Name, r.config.PodName),
+			Namespace: session.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Labels = map[string]string{
+			v1alpha1.LabelVarnishLogSession: session.Name,
+			v1alpha1.LabelVarnishOwner:      r.config.VarnishClusterName,
+		}
+		cm.Data = map[string]string{v1alpha1.VarnishLogSessionDataKey: buf.String()}
+		// the ConfigMap is garbage collected with the session
+		return controllerutil.SetControllerReference(session, cm, r.Scheme())
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "can't store the captured transactions")
+	}
+	return cm.Name, nil
+}
+
+// updateLogSessionStatus applies the update to the status of the local pod, if any, and recalculates the phase of the session.
+// The update returns nil if the status doesn't have to be changed. Returns true if the update has been applied.
+func (r *ReconcileLogSession) updateLogSessionStatus(ctx context.Context, session *v1alpha1.VarnishLogSession, update func(current *v1alpha1.VarnishLogSessionPodStatus) *v1alpha1.VarnishLogSessionPodStatus) (bool, error) {
+	applied := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.VarnishLogSession{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(session), latest); err != nil {
+			return err
+		}
+
+		applied = false
+		if update != nil {
+			if podStatus := update(logSessionPodStatus(latest, r.config.PodName)); podStatus != nil {
+				setLogSessionPodStatus(latest, *podStatus)
+				applied = true
+			}
+		}
+
+		pods := &v1.PodList{}
+		selector := client.MatchingLabels{
+			v1alpha1.LabelVarnishOwner:     r.config.VarnishClusterName,
+			v1alpha1.LabelVarnishComponent: v1alpha1.VarnishComponentVarnish,
+			v1alpha1.LabelVarnishUID:       string(r.config.VarnishClusterUID),
+		}
+		if err := r.List(ctx, pods, client.InNamespace(r.config.Namespace), selector); err != nil {
+			return errors.Wrap(err, "can't list varnish pods")
+		}
+
+		phase := logSessionPhase(latest, pods.Items)
+		if phase == latest.Status.Phase && !applied {
+			latest.DeepCopyInto(session)
+			return nil
+		}
+		latest.Status.Phase = phase
+		if latest.Finished() && latest.Status.CompletionTime == nil {
+			now := metav1.NewTime(r.now())
+			latest.Status.CompletionTime = &now
+		}
+
+		if err := r.Status().Update(ctx, latest); err != nil {
+			return err
+		}
+		latest.DeepCopyInto(session)
+		return nil
+	})
+	return applied, errors.WithStack(err)
+}
+
+// reconcileLogSessionTTL deletes the finished session once its TTL expires. The ConfigMaps are garbage collected with it.
+func (r *ReconcileLogSession) reconcileLogSessionTTL(ctx context.Context, session *v1alpha1.VarnishLogSession) (reconcile.Result, error) {
+	if session.Status.CompletionTime == nil {
+		return reconcile.Result{}, nil
+	}
+
+	expiresIn := session.Status.CompletionTime.Add(time.Duration(session.TTLSecondsAfterFinished()) * time.Second).Sub(r.now())
+	if expiresIn > 0 {
+		return reconcile.Result{RequeueAfter: expiresIn}, nil
+	}
+
+	// every pod of the cluster tries to delete the expired session, so the first one wins
+	if err := r.Delete(ctx, session, client.Preconditions{UID: &session.UID}); err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return reconcile.Result{}, errors.Wrap(err, "can't delete expired VarnishLogSession")
+	}
+	logger.FromContext(ctx).Infow("Expired log session deleted")
+	return reconcile.Result{}, nil
+}
+
+func (r *ReconcileLogSession) isClusterLogSession(session *v1alpha1.VarnishLogSession) bool {
+	return session.Namespace == r.config.Namespace && session.Spec.VarnishCluster == r.config.VarnishClusterName
+}
+
+// logSessionTargetsPod returns true if the transactions are captured on the pod.
+// Pods created after the capture window ended have nothing to capture.
+func logSessionTargetsPod(session *v1alpha1.VarnishLogSession, pod *v1.Pod) bool {
+	return session.TargetsPod(pod.Name) && pod.DeletionTimestamp == nil && !pod.CreationTimestamp.After(session.WindowEnd())
+}
+
+// logSessionPhase calculates the phase of the session from the progress of the given pods.
+// The session is finished when the capture is completed or failed on every targeted pod.
+func logSessionPhase(session *v1alpha1.VarnishLogSession, pods []v1.Pod) string {
+	if len(session.Status.Pods) == 0 {
+		return v1alpha1.VarnishLogSessionPhasePending
+	}
+
+	finished, failed := true, false
+	for i := range pods {
+		if !logSessionTargetsPod(session, &pods[i]) {
+			continue
+		}
+		podStatus := logSessionPodStatus(session, pods[i].Name)
+		switch {
+		case podStatus == nil || podStatus.Phase == v1alpha1.VarnishLogSessionPodPhaseCapturing:
+			finished = false
+		case podStatus.Phase == v1alpha1.VarnishLogSessionPodPhaseFailed:
+			failed = true
+		}
+	}
+
+	switch {
+	case !finished:
+		return v1alpha1.VarnishLogSessionPhaseRunning
+	case failed:
+		return v1alpha1.VarnishLogSessionPhaseFailed
+	default:
+		return v1alpha1.VarnishLogSessionPhaseCompleted
+	}
+}
+
+func logSessionPodStatus(session *v1alpha1.VarnishLogSession, podName string) *v1alpha1.VarnishLogSessionPodStatus {
+	for i := range session.Status.Pods {
+		if session.Status.Pods[i].Name == podName {
+			return &session.Status.Pods[i]
+		}
+	}
+	return nil
+}
+
+func setLogSessionPodStatus(session *v1alpha1.VarnishLogSession, podStatus v1alpha1.VarnishLogSessionPodStatus) {
+	if existing := logSessionPodStatus(session, podStatus.Name); existing != nil {
+		*existing = podStatus
+		return
+	}
+	session.Status.Pods = append(session.Status.Pods, podStatus)
+}
+
+// transactionBuffer keeps the complete varnishlog transactions that fit into the maximum size
+type transactionBuffer struct {
+	maxSize int
+
+	mu           sync.Mutex
+	data         bytes.Buffer
+	transaction  bytes.Buffer
+	transactions int32
+	truncated    bool
+}
+
+// add adds a line of the varnishlog output. Returns false once a transaction didn't fit.
+func (b *transactionBuffer) add(line []byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.transaction.Write(line)
+	b.transaction.WriteByte('\n')
+	// varnishlog separates the transactions with an empty line
+	if len(bytes.TrimSpace(line)) != 0 {
+		return true
+	}
+
+	if b.data.Len()+b.transaction.Len() > b.maxSize {
+		b.truncated = true
+		return false
+	}
+	b.data.Write(b.transaction.Bytes())
+	b.transaction.Reset()
+	b.transactions++
+	return true
+}
+
+// progress returns the number and the size of the captured transactions and if a transaction didn't fit
+func (b *transactionBuffer) progress() (int32, int32, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.transactions, int32(b.data.Len()), b.truncated
+}
+
+func (b *transactionBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.data.String()
+}
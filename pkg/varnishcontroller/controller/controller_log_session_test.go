@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const varnishLogOutput = `*   << Request  >> 32770
+-   Begin          req 32769 rxreq
+-   ReqHeader      Cookie: session=abc
+-   ReqHeader      X-Api-Key: key-1
+-   RespStatus     503
+-   End
+
+*   << Request  >> 32772
+-   Begin          req 32771 rxreq
+-   ReqHeader      Cookie: session=xyz
+-   ReqHeader      X-Api-Key: key-2
+-   RespStatus     500
+-   End
+
+`
+
+// the headers with credentials and the ones redacted in the access log are redacted
+const redactedVarnishLogOutput = `*   << Request  >> 32770
+-   Begin          req 32769 rxreq
+-   ReqHeader      Cookie: REDACTED
+-   ReqHeader      X-Api-Key: REDACTED
+-   RespStatus     503
+-   End
+
+*   << Request  >> 32772
+-   Begin          req 32771 rxreq
+-   ReqHeader      Cookie: REDACTED
+-   ReqHeader      X-Api-Key: REDACTED
+-   RespStatus     500
+-   End
+
+`
+
+func TestReconcileLogSession(t *testing.T) {
+	now := time.Now()
+
+	varnishPod := func(name string) *v1.Pod {
+		return &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			Labels: map[string]string{
+				v1alpha1.LabelVarnishOwner:     "varnish",
+				v1alpha1.LabelVarnishComponent: v1alpha1.VarnishComponentVarnish,
+				v1alpha1.LabelVarnishUID:       "uid",
+			},
+		}}
+	}
+	logSession := func(created time.Time, modify func(s *v1alpha1.VarnishLogSession)) *v1alpha1.VarnishLogSession {
+		s := &v1alpha1.VarnishLogSession{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default", UID: "session-uid", CreationTimestamp: metav1.NewTime(created)},
+			Spec:       v1alpha1.VarnishLogSessionSpec{VarnishCluster: "varnish", Query: "RespStatus >= 500"},
+		}
+		if modify != nil {
+			modify(s)
+		}
+		return s
+	}
+	varnishCluster := &v1alpha1.VarnishCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+		Spec: v1alpha1.VarnishClusterSpec{Logging: &v1alpha1.VarnishClusterLogging{
+			Access: &v1alpha1.VarnishClusterAccessLogging{RedactHeaders: []string{"X-Api-Key"}},
+		}},
+	}
+	shell := func(script string) func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		return func(ctx context.Context, name string, args ...string) *exec.Cmd {
+			return exec.CommandContext(ctx, "sh", "-c", script)
+		}
+	}
+
+	cases := []struct {
+		name              string
+		session           *v1alpha1.VarnishLogSession
+		command           func(ctx context.Context, name string, args ...string) *exec.Cmd
+		expectedPhase     string
+		expectedPod       *v1alpha1.VarnishLogSessionPodStatus
+		expectedConfigMap string
+	}{
+		{
+			name:          "transactions captured",
+			session:       logSession(now, nil),
+			command:       shell("printf '" + varnishLogOutput + "'"),
+			expectedPhase: v1alpha1.VarnishLogSessionPhaseCompleted,
+			expectedPod: &v1alpha1.VarnishLogSessionPodStatus{
+				Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseCompleted, Transactions: 2, Bytes: int32(len(redactedVarnishLogOutput)), ConfigMap: "debug-varnish-0",
+			},
+			expectedConfigMap: redactedVarnishLogOutput,
+		},
+		{
+			name:          "capture truncated",
+			session:       logSession(now, func(s *v1alpha1.VarnishLogSession) { s.Spec.MaxSizeBytes = proto.Int32(200) }),
+			command:       shell("printf '" + varnishLogOutput + "'; exec sleep 60"),
+			expectedPhase: v1alpha1.VarnishLogSessionPhaseCompleted,
+			expectedPod: &v1alpha1.VarnishLogSessionPodStatus{
+				Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseCompleted, Transactions: 1, Bytes: int32(len(redactedVarnishLogOutput) / 2), Truncated: true, ConfigMap: "debug-varnish-0",
+			},
+			expectedConfigMap: redactedVarnishLogOutput[:len(redactedVarnishLogOutput)/2],
+		},
+		{
+			name:          "invalid query",
+			session:       logSession(now, nil),
+			command:       shell("echo 'Query expression error' >&2; exit 1"),
+			expectedPhase: v1alpha1.VarnishLogSessionPhaseFailed,
+			expectedPod: &v1alpha1.VarnishLogSessionPodStatus{
+				Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseFailed, Message: "Query expression error: exit status 1",
+			},
+		},
+		{
+			name:          "capture window ended",
+			session:       logSession(now.Add(-time.Hour), nil),
+			expectedPhase: v1alpha1.VarnishLogSessionPhaseFailed,
+			expectedPod: &v1alpha1.VarnishLogSessionPodStatus{
+				Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseFailed, Message: "The capture window ended before the capture could be started",
+			},
+		},
+		{
+			name: "interrupted capture",
+			session: logSession(now, func(s *v1alpha1.VarnishLogSession) {
+				s.Status.Pods = []v1alpha1.VarnishLogSessionPodStatus{{Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseCapturing, Transactions: 3}}
+			}),
+			expectedPhase: v1alpha1.VarnishLogSessionPhaseFailed,
+			expectedPod: &v1alpha1.VarnishLogSessionPodStatus{
+				Name: "varnish-0", Phase: v1alpha1.VarnishLogSessionPodPhaseFailed, Transactions: 3, Message: "The capture was interrupted by a restart of the varnish-controller",
+			},
+		},
+		{
+			name:          "pod not targeted",
+			session:       logSession(now, func(s *v1alpha1.VarnishLogSession) { s.Spec.Pods = []string{"varnish-1"} }),
+			expectedPhase: v1alpha1.VarnishLogSessionPhasePending,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+			g.Expect(v1alpha1.AddToScheme(scheme)).To(gomega.Succeed())
+
+			r := &ReconcileLogSession{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.session, varnishPod("varnish-0"), varnishCluster).Build(),
+				config: &config.Config{
+					Namespace:          "default",
+					PodName:            "varnish-0",
+					VarnishClusterName: "varnish",
+					VarnishClusterUID:  "uid",
+				},
+				logger:           logger.NewNopLogger(),
+				eventHandler:     varnishEvents.NewEventHandler(record.NewFakeRecorder(10), "varnish-0"),
+				now:              time.Now,
+				command:          c.command,
+				progressInterval: time.Hour,
+				captures:         map[types.NamespacedName]*logCapture{},
+			}
+
+			key := types.NamespacedName{Namespace: "default", Name: "debug"}
+			_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			if capture := r.captures[key]; capture != nil {
+				g.Eventually(capture.done, 5*time.Second).Should(gomega.BeClosed())
+			}
+
+			session := &v1alpha1.VarnishLogSession{}
+			g.Expect(r.Get(context.Background(), key, session)).To(gomega.Succeed())
+			g.Expect(session.Status.Phase).To(gomega.Equal(c.expectedPhase))
+			g.Expect(session.Finished()).To(gomega.Equal(session.Status.CompletionTime != nil))
+
+			podStatus := logSessionPodStatus(session, "varnish-0")
+			if podStatus != nil {
+				podStatus.StartTime, podStatus.CompletionTime = nil, nil
+			}
+			g.Expect(podStatus).To(gomega.Equal(c.expectedPod))
+
+			cm := &v1.ConfigMap{}
+			err = r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "debug-varnish-0"}, cm)
+			if c.expectedConfigMap == "" {
+				g.Expect(err).To(gomega.HaveOccurred())
+				return
+			}
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(cm.Data).To(gomega.Equal(map[string]string{v1alpha1.VarnishLogSessionDataKey: c.expectedConfigMap}))
+			g.Expect(cm.Labels).To(gomega.HaveKeyWithValue(v1alpha1.LabelVarnishLogSession, "debug"))
+			g.Expect(metav1.IsControlledBy(cm, session)).To(gomega.BeTrue())
+		})
+	}
+}
+
+func TestReconcileLogSessionStopsCaptureOfDeletedSession(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+	g.Expect(v1alpha1.AddToScheme(scheme)).To(gomega.Succeed())
+
+	session := &v1alpha1.VarnishLogSession{
+		ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "default", CreationTimestamp: metav1.Now()},
+		Spec:       v1alpha1.VarnishLogSessionSpec{VarnishCluster: "varnish"},
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default", CreationTimestamp: metav1.Now()}}
+	varnishCluster := &v1alpha1.VarnishCluster{ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"}}
+	var calledWith []string
+	r := &ReconcileLogSession{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(session, pod, varnishCluster).Build(),
+		config: &config.Config{Namespace: "default", PodName: "varnish-0", VarnishClusterName: "varnish", VarnishClusterUID: "uid"},
+		logger: logger.NewNopLogger(),
+		now:    time.Now,
+		command: func(ctx context.Context, name string, args ...string) *exec.Cmd {
+			calledWith = append([]string{name}, args...)
+			return exec.CommandContext(ctx, "sleep", "60")
+		},
+		progressInterval: time.Hour,
+		captures:         map[types.NamespacedName]*logCapture{},
+	}
+
+	key := types.NamespacedName{Namespace: "default", Name: "debug"}
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	capture := r.captures[key]
+	g.Expect(capture).ToNot(gomega.BeNil())
+
+	g.Expect(r.Delete(context.Background(), session)).To(gomega.Succeed())
+	_, err = r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(capture.done).To(gomega.BeClosed())
+	g.Expect(r.captures).To(gomega.BeEmpty())
+	g.Expect(calledWith).To(gomega.Equal([]string{VarnishLogBinary, "-g", "request", "-t", "off"}))
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/metrics"
+)
+
+// updateMetricsExporterLabels passes the zone of the pod and the pods behind the backends of the default VCL
+// to the builtin metrics exporter, so the backend metrics are labeled with the pods instead of the VCL backend names
+func (r *ReconcileVarnish) updateMetricsExporterLabels(localPod LocalPodInfo, backends []PodInfo, groups map[string]BackendGroupInfo, varnishNodes []PodInfo) {
+	if r.metrics == nil || r.metrics.Exporter == nil {
+		return
+	}
+
+	labels := make(map[string]metrics.BackendLabels, len(backends)+len(varnishNodes))
+	add := func(name string, pod PodInfo) {
+		labels[name] = metrics.BackendLabels{Pod: pod.PodName, Namespace: pod.Namespace, Zone: pod.Zone}
+	}
+	// the backend names are the same as in the default VCL
+	for _, backend := range backends {
+		add(vclIdent(backend.PodName), backend)
+	}
+	for _, group := range groups {
+		for _, backend := range group.Backends {
+			add(group.Name+"_"+vclIdent(backend.PodName), backend)
+		}
+	}
+	for _, node := range varnishNodes {
+		add("varnish_"+vclIdent(node.PodName), node)
+	}
+
+	r.metrics.Exporter.SetZone(localPod.Zone)
+	r.metrics.Exporter.SetBackends(labels)
+}
+
+// updateBackendsMetrics counts the discovered backends by zone and group and sets the current weights of the backends
+func (r *ReconcileVarnish) updateBackendsMetrics(backends []PodInfo, groups map[string]BackendGroupInfo, localWeight, remoteWeight float64) {
+	counts := make(map[metrics.BackendsKey]int)
+	for _, backend := range backends {
+		counts[metrics.BackendsKey{Zone: backend.Zone}]++
+	}
+	for _, group := range groups {
+		for _, backend := range group.Backends {
+			counts[metrics.BackendsKey{Zone: backend.Zone, Group: group.Name}]++
+		}
+	}
+
+	r.metrics.SetBackends(counts)
+	r.metrics.SetWeights(localWeight, remoteWeight)
+}
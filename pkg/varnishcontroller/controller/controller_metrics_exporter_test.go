@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/metrics"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
+
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type varnishStatMock struct {
+	stats []varnishstat.Counter
+}
+
+func (v *varnishStatMock) Counters(...string) (map[string]uint64, error) {
+	return nil, nil
+}
+
+func (v *varnishStatMock) Stats() ([]varnishstat.Counter, error) {
+	return v.stats, nil
+}
+
+type varnishVersionMock struct{}
+
+func (varnishVersionMock) Version(context.Context) (string, string, error) {
+	return "6.5.1", "1dae233", nil
+}
+
+func TestUpdateMetricsExporterLabels(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	stats := &varnishStatMock{stats: []varnishstat.Counter{
+		{Name: "VBE.v-1.web_pod-1.req", Description: "Backend requests sent", Flag: varnishstat.FlagCounter, Value: 1},
+		{Name: "VBE.v-1.api_api-0.req", Description: "Backend requests sent", Flag: varnishstat.FlagCounter, Value: 2},
+		{Name: "VBE.v-1.varnish_cache-varnish-1.req", Description: "Backend requests sent", Flag: varnishstat.FlagCounter, Value: 3},
+		{Name: "VBE.v-1.dummy.req", Description: "Backend requests sent", Flag: varnishstat.FlagCounter, Value: 4},
+	}}
+	r := &ReconcileVarnish{metrics: &metrics.VarnishControllerMetrics{
+		Exporter: metrics.NewVarnishExporter(stats, varnishVersionMock{}, metrics.NamingCompatible, "cache"),
+	}}
+
+	r.updateMetricsExporterLabels(
+		LocalPodInfo{Name: "cache-varnish-0", Zone: "zone1"},
+		[]PodInfo{{PodName: "web.pod-1", Namespace: "shop", Zone: "zone1"}},
+		map[string]BackendGroupInfo{"api": {Name: "api", Backends: []PodInfo{{PodName: "api-0", Namespace: "api", Zone: "zone2"}}}},
+		[]PodInfo{{PodName: "cache-varnish-1", Namespace: "cache", Zone: "zone2"}},
+	)
+
+	expected := `
+# HELP varnish_backend_req Backend requests sent
+# TYPE varnish_backend_req counter
+varnish_backend_req{backend="api_api-0",backend_namespace="api",backend_pod="api-0",backend_zone="zone2",server="v-1",varnish_cluster="cache",zone="zone1"} 2
+varnish_backend_req{backend="dummy",backend_namespace="",backend_pod="",backend_zone="",server="v-1",varnish_cluster="cache",zone="zone1"} 4
+varnish_backend_req{backend="varnish_cache-varnish-1",backend_namespace="cache",backend_pod="cache-varnish-1",backend_zone="zone2",server="v-1",varnish_cluster="cache",zone="zone1"} 3
+varnish_backend_req{backend="web_pod-1",backend_namespace="shop",backend_pod="web.pod-1",backend_zone="zone1",server="v-1",varnish_cluster="cache",zone="zone1"} 1
+`
+	g.Expect(testutil.CollectAndCompare(r.metrics.Exporter, strings.NewReader(expected), "varnish_backend_req")).To(gomega.Succeed())
+
+	// nothing to update without the builtin exporter
+	r.metrics.Exporter = nil
+	r.updateMetricsExporterLabels(LocalPodInfo{}, nil, nil, nil)
+}
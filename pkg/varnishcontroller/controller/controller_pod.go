@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ibm/varnish-operator/pkg/logger"
 
@@ -18,10 +20,15 @@ const (
 	annotationActiveVCLConfigName  = "activeVCLConfigName"
 	annotationLocalBackendsWeight  = "localBackendsWeight"
 	annotationRemoteBackendsWeight = "remoteBackendsWeight"
+	annotationBackendsCount        = "backendsCount"
+	annotationLastReloadTime       = "lastReloadTime"
+	annotationLastReloadError      = "lastReloadError"
+	// set by the operator to the ConfigMap version a drifted pod has to load again
+	annotationVCLResyncRequested = "vclResyncRequested"
 )
 
-func (r *ReconcileVarnish) reconcilePod(ctx context.Context, filesChanged bool, pod *v1.Pod, cm *v1.ConfigMap, localWeight float64, remoteWeight float64) error {
-	activeVCLName, err := r.varnish.GetActiveConfigurationName()
+func (r *ReconcileVarnish) reconcilePod(ctx context.Context, filesChanged bool, pod *v1.Pod, cm *v1.ConfigMap, localWeight float64, remoteWeight float64, backendsCount int) error {
+	activeVCLName, err := r.varnish.GetActiveConfigurationName(ctx)
 	if err != nil {
 		return err
 	}
@@ -49,9 +56,35 @@ func (r *ReconcileVarnish) reconcilePod(ctx context.Context, filesChanged bool,
 		podCopy.Annotations[annotationConfigMapVersion] = cm.GetResourceVersion()
 	}
 
+	if latestConfigMapInUse {
+		delete(podCopy.Annotations, annotationVCLRevertedConfigMapVersion)
+		delete(podCopy.Annotations, annotationVCLResyncRequested)
+	}
+
+	// nothing is known about the reloads done before the container restarted, so keep what was reported then
+	if !r.lastReloadTime.IsZero() {
+		podCopy.Annotations[annotationLastReloadTime] = r.lastReloadTime.UTC().Format(time.RFC3339)
+	}
+	if r.lastReloadError != "" {
+		podCopy.Annotations[annotationLastReloadError] = r.lastReloadError
+	} else if !r.lastReloadTime.IsZero() {
+		delete(podCopy.Annotations, annotationLastReloadError)
+	}
+
+	if r.vclCompilationError != nil {
+		podCopy.Annotations[annotationVCLCompilationErrorConfigMapVersion] = r.vclCompilationError.configMapVersion
+		podCopy.Annotations[annotationVCLCompilationErrorMessage] = r.vclCompilationError.message
+		podCopy.Annotations[annotationVCLCompilationErrorDiagnostics] = marshalVCLDiagnostics(r.vclCompilationError.diagnostics)
+	} else {
+		delete(podCopy.Annotations, annotationVCLCompilationErrorConfigMapVersion)
+		delete(podCopy.Annotations, annotationVCLCompilationErrorMessage)
+		delete(podCopy.Annotations, annotationVCLCompilationErrorDiagnostics)
+	}
+
 	podCopy.Annotations[annotationActiveVCLConfigName] = activeVCLName
 	podCopy.Annotations[annotationLocalBackendsWeight] = fmt.Sprintf("%f", localWeight)
 	podCopy.Annotations[annotationRemoteBackendsWeight] = fmt.Sprintf("%f", remoteWeight)
+	podCopy.Annotations[annotationBackendsCount] = strconv.Itoa(backendsCount)
 	logger.FromContext(ctx).Debugf("Local backends weight: %f", localWeight)
 	logger.FromContext(ctx).Debugf("Remote backends weight: %f", remoteWeight)
 	if !reflect.DeepEqual(pod.Annotations, podCopy.Annotations) {
@@ -75,3 +108,9 @@ func extractConfigMapVersion(VCLConfigName string) string {
 	}
 	return parts[len(parts)-2]
 }
+
+// resyncRequested returns true if the operator asked to load the ConfigMap version again as the pod hasn't picked it up
+func resyncRequested(pod *v1.Pod, cm *v1.ConfigMap) bool {
+	return pod.Annotations[annotationVCLResyncRequested] == cm.GetResourceVersion() &&
+		pod.Annotations[annotationConfigMapVersion] != cm.GetResourceVersion()
+}
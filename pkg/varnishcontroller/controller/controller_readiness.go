@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/pkg/errors"
+)
+
+// how long the readiness check waits for varnish before it uses the result of the previous check
+const readinessCheckTimeout = 2 * time.Second
+
+type vclReadiness struct {
+	mu                    sync.Mutex
+	requireHealthyBackend bool
+	checked               bool
+	lastResult            error
+}
+
+func (r *ReconcileVarnish) reconcileReadiness(vc *v1alpha1.VarnishCluster) {
+	r.readiness.mu.Lock()
+	defer r.readiness.mu.Unlock()
+	r.readiness.requireHealthyBackend = vc.Spec.Varnish.Readiness != nil && vc.Spec.Varnish.Readiness.RequireHealthyBackend
+}
+
+// checkReadiness is the readiness check of the varnish-controller container, and so of the pod. The pod is ready
+// once the VCL loaded from the ConfigMap is active, as the VCL varnish starts with has no backends.
+// The varnish CLI is not available while a VCL is compiled, so the previous result is used if varnish doesn't respond in time.
+func (r *ReconcileVarnish) checkReadiness(req *http.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), readinessCheckTimeout)
+	defer cancel()
+	err := r.vclReady(ctx)
+
+	r.readiness.mu.Lock()
+	defer r.readiness.mu.Unlock()
+	if err != nil && ctx.Err() != nil {
+		if !r.readiness.checked {
+			return errors.New("varnish doesn't respond")
+		}
+		return r.readiness.lastResult
+	}
+	r.readiness.checked, r.readiness.lastResult = true, err
+	return err
+}
+
+func (r *ReconcileVarnish) vclReady(ctx context.Context) error {
+	activeVCLName, err := r.varnish.GetActiveConfigurationName(ctx)
+	if err != nil {
+		return errors.Wrap(err, "can't get the active VCL")
+	}
+	// the first VCL is always loaded from the current ConfigMap version, even during a canary rollout,
+	// so the pod stays ready if a newer version is held back or fails to compile
+	if !strings.HasPrefix(activeVCLName, VCLVersionPrefix) || extractConfigMapVersion(activeVCLName) == "" {
+		return errors.Errorf("the VCL from the ConfigMap is not loaded yet, the active VCL is %q", activeVCLName)
+	}
+
+	r.readiness.mu.Lock()
+	requireHealthyBackend := r.readiness.requireHealthyBackend
+	r.readiness.mu.Unlock()
+	if !requireHealthyBackend {
+		return nil
+	}
+
+	backends, err := r.varnish.BackendList(ctx)
+	if err != nil {
+		return errors.Wrap(err, "can't get the backends")
+	}
+	for _, backend := range backends {
+		if backend.Type == "backend" && backend.Healthy && strings.HasPrefix(backend.Name, activeVCLName+".") {
+			return nil
+		}
+	}
+	return errors.Errorf("no healthy backends in the active VCL %q", activeVCLName)
+}
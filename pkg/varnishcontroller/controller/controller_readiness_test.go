@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	backends := []varnishadm.Backend{
+		{Name: "boot.default", Type: "backend", Healthy: true},
+		{Name: "v-1234-1651406100.app_0", Type: "backend", Healthy: false},
+		{Name: "v-1234-1651406100.app", Type: "round-robin", Healthy: true},
+		{Name: "v-1000-1651406000.app_0", Type: "backend", Healthy: true},
+	}
+
+	cases := []struct {
+		name                  string
+		varnish               *varnishMock
+		requireHealthyBackend bool
+		expectedReady         bool
+	}{
+		{
+			name:    "varnish is not running",
+			varnish: &varnishMock{activeVCLConfigError: errors.New("connection refused")},
+		},
+		{
+			name:    "VCL from the ConfigMap not loaded yet",
+			varnish: &varnishMock{activeVCLConfigName: "boot"},
+		},
+		{
+			name:          "VCL loaded",
+			varnish:       &varnishMock{activeVCLConfigName: "v-1234-1651406100"},
+			expectedReady: true,
+		},
+		{
+			name:                  "no healthy backends in the active VCL",
+			varnish:               &varnishMock{activeVCLConfigName: "v-1234-1651406100", backends: backends},
+			requireHealthyBackend: true,
+		},
+		{
+			name: "healthy backend",
+			varnish: &varnishMock{activeVCLConfigName: "v-1234-1651406100", backends: append(backends,
+				varnishadm.Backend{Name: "v-1234-1651406100.app_1", Type: "backend", Healthy: true})},
+			requireHealthyBackend: true,
+			expectedReady:         true,
+		},
+		{
+			name:                  "backends can't be listed",
+			varnish:               &varnishMock{activeVCLConfigName: "v-1234-1651406100", backendListError: errors.New("timeout")},
+			requireHealthyBackend: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			r := &ReconcileVarnish{varnish: c.varnish}
+			r.reconcileReadiness(&v1alpha1.VarnishCluster{Spec: v1alpha1.VarnishClusterSpec{Varnish: &v1alpha1.VarnishClusterVarnish{
+				Readiness: &v1alpha1.VarnishClusterVarnishReadiness{RequireHealthyBackend: c.requireHealthyBackend},
+			}}})
+
+			err := r.checkReadiness(httptest.NewRequest(http.MethodGet, "/readyz/vcl", nil))
+			if c.expectedReady {
+				g.Expect(err).ToNot(gomega.HaveOccurred())
+			} else {
+				g.Expect(err).To(gomega.HaveOccurred())
+			}
+		})
+	}
+}
+
+type busyVarnishMock struct {
+	*varnishMock
+	release chan struct{}
+}
+
+func (v *busyVarnishMock) GetActiveConfigurationName(ctx context.Context) (string, error) {
+	select {
+	case <-v.release:
+		return v.varnishMock.GetActiveConfigurationName(ctx)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestCheckReadinessBusyVarnish(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	varnish := &busyVarnishMock{varnishMock: &varnishMock{activeVCLConfigName: "v-1234-1651406100"}, release: make(chan struct{})}
+	defer close(varnish.release)
+	r := &ReconcileVarnish{varnish: varnish}
+
+	req := httptest.NewRequest(http.MethodGet, "/readyz/vcl", nil)
+	g.Expect(r.checkReadiness(req)).To(gomega.MatchError("varnish doesn't respond"))
+
+	r.readiness.checked, r.readiness.lastResult = true, nil
+	g.Expect(r.checkReadiness(req)).To(gomega.Succeed())
+}
@@ -2,30 +2,87 @@ package controller
 
 import (
 	"bytes"
+	"sort"
 	"strings"
 	"text/template"
 
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
 	"github.com/pkg/errors"
 )
 
-func (r *ReconcileVarnish) resolveTemplates(tmplStrs map[string]string, targetPort, varnishPort int32, backends, varnishNodes []PodInfo) (map[string]string, error) {
-	data := map[string]interface{}{
-		"Backends":     backends,
-		"TargetPort":   targetPort,
-		"VarnishNodes": varnishNodes,
-		"VarnishPort":  varnishPort,
+// requests forwarded to the pod that owns the object are marked with this header, so they are not forwarded again
+const clusterForwardedHeader = "X-Varnish-Cluster-Forwarded"
+
+// templateData returns the data available in the VCL templates
+func templateData(vc *v1alpha1.VarnishCluster, localPod LocalPodInfo, targetPort, varnishPort int32, backends, varnishNodes []PodInfo, backendGroups map[string]BackendGroupInfo) map[string]interface{} {
+	var replicas int32
+	if vc.Spec.Replicas != nil {
+		replicas = *vc.Spec.Replicas
 	}
 
-	out := make(map[string]string, len(tmplStrs))
-	for tmplFileName, tmplStr := range tmplStrs {
-		tmpl, err := template.New(tmplFileName).Option("missingkey=error").Parse(tmplStr)
+	return map[string]interface{}{
+		"Backends":          backends,
+		"TargetPort":        targetPort,
+		"BackendParameters": backendParameters(vc.Spec.Backend),
+		"Director":          director(vc.Spec.Backend),
+		"BackendGroups":     backendGroups,
+		"VarnishNodes":      varnishNodes,
+		"VarnishPort":       varnishPort,
+		"LocalPod":          localPod,
+		"Clustering":        clustering(vc.Spec.Clustering),
+		"VarnishCluster": VarnishClusterInfo{
+			Name:      vc.Name,
+			Namespace: vc.Namespace,
+			Replicas:  replicas,
+			Labels:    vc.Labels,
+		},
+	}
+}
+
+// resolveTemplates renders the templates into files named without the .tmpl suffix. All templates are parsed into one set,
+// so the templates defined with {{ define }} in one file can be used in any other. Files that start with an underscore
+// (e.g. _helpers.tmpl) only hold such definitions and are not rendered.
+func (r *ReconcileVarnish) resolveTemplates(tmplStrs map[string]string, data map[string]interface{}) (map[string]string, error) {
+	tmplFileNames := make([]string, 0, len(tmplStrs))
+	for tmplFileName := range tmplStrs {
+		tmplFileNames = append(tmplFileNames, tmplFileName)
+	}
+	sort.Strings(tmplFileNames)
+
+	templates := template.New("").Option("missingkey=error")
+	templates.Funcs(templateFuncs(templates))
+
+	definedIn := make(map[string]string)
+	for _, tmplFileName := range tmplFileNames {
+		tmpl, err := template.New(tmplFileName).Option("missingkey=error").Funcs(templateFuncs(templates)).Parse(tmplStrs[tmplFileName])
 		if err != nil {
 			return nil, errors.Wrapf(err, "could not parse template %s", tmplFileName)
 		}
 
+		for _, t := range tmpl.Templates() {
+			if t.Tree == nil {
+				continue
+			}
+			if fileName, found := definedIn[t.Name()]; found {
+				return nil, errors.Errorf("template %q is defined in both %s and %s", t.Name(), fileName, tmplFileName)
+			}
+			definedIn[t.Name()] = tmplFileName
+			if _, err = templates.AddParseTree(t.Name(), t.Tree); err != nil {
+				return nil, errors.Wrapf(err, "could not add template %s", t.Name())
+			}
+		}
+	}
+
+	out := make(map[string]string, len(tmplStrs))
+	for _, tmplFileName := range tmplFileNames {
+		if strings.HasPrefix(tmplFileName, "_") {
+			continue
+		}
+
 		var b bytes.Buffer
 		b.WriteString("// This file is generated. Do not edit manually, as changes will be destroyed\n\n")
-		if err = tmpl.ExecuteTemplate(&b, tmplFileName, data); err != nil {
+		if err := templates.ExecuteTemplate(&b, tmplFileName, data); err != nil {
 			return nil, errors.Wrapf(err, "problem resolving template %s", tmplFileName)
 		}
 		fileName := strings.TrimSuffix(tmplFileName, ".tmpl")
@@ -33,3 +90,16 @@ func (r *ReconcileVarnish) resolveTemplates(tmplStrs map[string]string, targetPo
 	}
 	return out, nil
 }
+
+// clustering returns the clustering mode of the varnish pods. Every pod caches independently if it's not configured.
+func clustering(c *v1alpha1.VarnishClusterClustering) ClusteringInfo {
+	info := ClusteringInfo{Mode: v1alpha1.VarnishClusterClusteringModeNone, ForwardedHeader: clusterForwardedHeader}
+	if c == nil {
+		return info
+	}
+	if c.Mode != "" {
+		info.Mode = c.Mode
+	}
+	info.ZoneAware = c.ZoneAware
+	return info
+}
@@ -0,0 +1,218 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	vclIdentInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+	wordBoundary         = regexp.MustCompile(`[^A-Za-z0-9]+|([a-z0-9])([A-Z])`)
+)
+
+// templateFuncs returns the functions available in the VCL templates.
+// The templates set is needed for the include function that renders a named template into a string.
+func templateFuncs(templates *template.Template) template.FuncMap {
+	return template.FuncMap{
+		// strings
+		"lower":      strings.ToLower,
+		"upper":      strings.ToUpper,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"quote":      func(s interface{}) string { return fmt.Sprintf("%q", fmt.Sprint(s)) },
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"snakecase":  func(s string) string { return strings.Join(words(s), "_") },
+		"kebabcase":  func(s string) string { return strings.Join(words(s), "-") },
+		"camelcase":  camelcase,
+
+		// regular expressions
+		"regexMatch":   regexMatch,
+		"regexReplace": regexReplace,
+
+		// defaults and data structures
+		"default": defaultValue,
+		"empty":   isEmpty,
+		"dict":    dict,
+		"list":    func(items ...interface{}) []interface{} { return items },
+		"toJson":  toJSON,
+
+		// hashing
+		"sha256sum": func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha1sum":   func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"md5sum":    func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"crc32sum":  func(s string) uint32 { return crc32.ChecksumIEEE([]byte(s)) },
+
+		// pods
+		"groupByZone":  groupByZone,
+		"groupByLabel": groupByLabel,
+
+		// VCL
+		"vclIdent": vclIdent,
+
+		"include": func(name string, data interface{}) (string, error) {
+			var b bytes.Buffer
+			if err := templates.ExecuteTemplate(&b, name, data); err != nil {
+				return "", err
+			}
+			return b.String(), nil
+		},
+	}
+}
+
+// vclIdent turns a string into a valid VCL identifier (e.g. a backend name) by replacing the not allowed characters with underscores.
+// Identifiers have to start with a letter, so "v" is prepended otherwise.
+func vclIdent(s string) string {
+	ident := vclIdentInvalidChars.ReplaceAllString(s, "_")
+	if ident == "" || !unicode.IsLetter(rune(ident[0])) {
+		ident = "v" + ident
+	}
+	return ident
+}
+
+// groupByZone groups pods by the zone of the nodes they run on
+func groupByZone(pods []PodInfo) map[string][]PodInfo {
+	groups := make(map[string][]PodInfo)
+	for _, pod := range pods {
+		groups[pod.Zone] = append(groups[pod.Zone], pod)
+	}
+	return groups
+}
+
+// groupByLabel groups pods by the value of the given pod label. Pods without the label are grouped under the empty string.
+func groupByLabel(label string, pods []PodInfo) map[string][]PodInfo {
+	groups := make(map[string][]PodInfo)
+	for _, pod := range pods {
+		groups[pod.Labels[label]] = append(groups[pod.Labels[label]], pod)
+	}
+	return groups
+}
+
+func regexMatch(regex, s string) (bool, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return re.MatchString(s), nil
+}
+
+func regexReplace(regex, replacement, s string) (string, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return re.ReplaceAllString(s, replacement), nil
+}
+
+// defaultValue returns the given value, or the default one if the value is empty. Usage: {{ .Value | default "none" }}
+func defaultValue(def interface{}, value ...interface{}) interface{} {
+	if len(value) == 0 || isEmpty(value[0]) {
+		return def
+	}
+	return value[0]
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return v.IsZero()
+	}
+}
+
+// join joins the elements of a list of any type with the separator
+func join(sep string, list interface{}) (string, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", errors.Errorf("join: can't join %T", list)
+	}
+	items := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		items[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(items, sep), nil
+}
+
+// dict creates a map from key value pairs. Useful to pass multiple values to a named template.
+func dict(keyValues ...interface{}) (map[string]interface{}, error) {
+	if len(keyValues)%2 != 0 {
+		return nil, errors.New("dict: odd number of arguments")
+	}
+	d := make(map[string]interface{}, len(keyValues)/2)
+	for i := 0; i < len(keyValues); i += 2 {
+		key, ok := keyValues[i].(string)
+		if !ok {
+			return nil, errors.Errorf("dict: key %v is not a string", keyValues[i])
+		}
+		d[key] = keyValues[i+1]
+	}
+	return d, nil
+}
+
+func toJSON(value interface{}) (string, error) {
+	out, err := json.Marshal(value)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(out), nil
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func title(s string) string {
+	out := []rune(s)
+	for i := range out {
+		if i == 0 || !unicode.IsLetter(out[i-1]) && !unicode.IsDigit(out[i-1]) {
+			out[i] = unicode.ToUpper(out[i])
+		}
+	}
+	return string(out)
+}
+
+func camelcase(s string) string {
+	parts := words(s)
+	for i := 1; i < len(parts); i++ {
+		parts[i] = title(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// words splits a string into lower case words on non alphanumeric characters and lower to upper case transitions
+func words(s string) []string {
+	var parts []string
+	for _, part := range strings.Fields(wordBoundary.ReplaceAllString(s, "$1 $2")) {
+		parts = append(parts, strings.ToLower(part))
+	}
+	return parts
+}
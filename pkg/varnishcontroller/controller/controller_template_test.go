@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+	"text/template"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestResolveTemplates(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	vc := &v1alpha1.VarnishCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cache", Namespace: "default", Labels: map[string]string{"team": "web"}},
+		Spec:       v1alpha1.VarnishClusterSpec{Replicas: proto.Int32(3)},
+	}
+	localPod := LocalPodInfo{Name: "cache-varnish-0", NodeName: "node1", Zone: "zone1"}
+	backends := []PodInfo{
+		{
+			IP:          "10.0.0.1",
+			PodName:     "web-6f4c6cbc6c-mjpck",
+			Namespace:   "shop",
+			Labels:      map[string]string{"version": "v2"},
+			Annotations: map[string]string{"weight": "5"},
+			Zone:        "zone1",
+			Ready:       true,
+			Ports:       map[string]int32{"http": 8080},
+			Owner:       &OwnerInfo{Kind: "Deployment", Name: "web"},
+		},
+	}
+
+	templates := map[string]string{
+		"backends.vcl.tmpl": `{{ range .Backends }}{{ .Namespace }}/{{ .Owner.Kind }}/{{ .Owner.Name }} {{ .Labels.version }} {{ .Annotations.weight }} {{ .Ports.http }} {{ .Ready }} {{ if eq .Zone $.LocalPod.Zone }}local{{ end }}{{ end }}
+{{ .LocalPod.Name }} {{ .VarnishCluster.Namespace }}/{{ .VarnishCluster.Name }} {{ .VarnishCluster.Replicas }} {{ .VarnishCluster.Labels.team }}`,
+	}
+
+	r := &ReconcileVarnish{}
+	files, err := r.resolveTemplates(templates, templateData(vc, localPod, 8080, 6081, backends, nil, nil))
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(files).To(gomega.Equal(map[string]string{
+		"backends.vcl": "// This file is generated. Do not edit manually, as changes will be destroyed\n\n" +
+			"shop/Deployment/web v2 5 8080 true local\ncache-varnish-0 default/cache 3 web",
+	}))
+}
+
+func TestClustering(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+
+	g.Expect(clustering(nil)).To(gomega.Equal(ClusteringInfo{Mode: v1alpha1.VarnishClusterClusteringModeNone, ForwardedHeader: "X-Varnish-Cluster-Forwarded"}))
+	g.Expect(clustering(&v1alpha1.VarnishClusterClustering{Mode: v1alpha1.VarnishClusterClusteringModeShard, ZoneAware: true})).To(gomega.Equal(ClusteringInfo{
+		Mode:            v1alpha1.VarnishClusterClusteringModeShard,
+		ZoneAware:       true,
+		ForwardedHeader: "X-Varnish-Cluster-Forwarded",
+	}))
+}
+
+func TestResolveTemplatesPartials(t *testing.T) {
+	header := "// This file is generated. Do not edit manually, as changes will be destroyed\n\n"
+	backends := []PodInfo{
+		{PodName: "web-1", IP: "10.0.0.1", Zone: "zone1"},
+		{PodName: "web.2", IP: "10.0.0.2", Zone: "zone2"},
+	}
+
+	cases := []struct {
+		name          string
+		templates     map[string]string
+		expectedFiles map[string]string
+		expectErr     bool
+	}{
+		{
+			name: "definitions from helpers are available in all templates",
+			templates: map[string]string{
+				"_helpers.tmpl":     `{{ define "backend" }}backend {{ vclIdent .Pod.PodName }} { .host = "{{ .Pod.IP }}"; .port = "{{ .Port }}"; }{{ end }}`,
+				"backends.vcl.tmpl": `{{ range .Backends }}{{ template "backend" (dict "Pod" . "Port" $.TargetPort) }}{{ "\n" }}{{ end }}`,
+				"zones.vcl.tmpl":    `{{ range $zone, $pods := groupByZone .Backends }}{{ $zone }}:{{ include "backend" (dict "Pod" (index $pods 0) "Port" 80) | upper }}{{ "\n" }}{{ end }}`,
+			},
+			expectedFiles: map[string]string{
+				"backends.vcl": header + "backend web-1 { .host = \"10.0.0.1\"; .port = \"8080\"; }\nbackend web_2 { .host = \"10.0.0.2\"; .port = \"8080\"; }\n",
+				"zones.vcl":    header + "zone1:BACKEND WEB-1 { .HOST = \"10.0.0.1\"; .PORT = \"80\"; }\nzone2:BACKEND WEB_2 { .HOST = \"10.0.0.2\"; .PORT = \"80\"; }\n",
+			},
+		},
+		{
+			name: "definition from an another template",
+			templates: map[string]string{
+				"a.vcl.tmpl": `{{ define "greeting" }}hello{{ end }}a`,
+				"b.vcl.tmpl": `{{ template "greeting" }}`,
+			},
+			expectedFiles: map[string]string{
+				"a.vcl": header + "a",
+				"b.vcl": header + "hello",
+			},
+		},
+		{
+			name: "template is defined twice",
+			templates: map[string]string{
+				"a.vcl.tmpl": `{{ define "greeting" }}hello{{ end }}`,
+				"b.vcl.tmpl": `{{ define "greeting" }}hi{{ end }}`,
+			},
+			expectErr: true,
+		},
+		{
+			name: "unknown function",
+			templates: map[string]string{
+				"a.vcl.tmpl": `{{ unknown . }}`,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		r := &ReconcileVarnish{}
+		files, err := r.resolveTemplates(c.templates, templateData(&v1alpha1.VarnishCluster{}, LocalPodInfo{}, 8080, 6081, backends, nil, nil))
+		if c.expectErr {
+			g.Expect(err).To(gomega.HaveOccurred())
+			continue
+		}
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(files).To(gomega.Equal(c.expectedFiles))
+	}
+}
+
+// defaultBackendsTemplate returns the backends template of the default ConfigMap created by the operator
+func defaultBackendsTemplate(t *testing.T) string {
+	file, err := parser.ParseFile(token.NewFileSet(), "../../varnishcluster/controller/varnishcluster_default_vcl.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			if valueSpec.Names[0].Name != "backendsVCLTmplFileContent" {
+				continue
+			}
+			content, err := strconv.Unquote(valueSpec.Values[0].(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return content
+		}
+	}
+	t.Fatal("backends template of the default ConfigMap is not found")
+	return ""
+}
+
+func TestDefaultBackendsTemplateShardDirector(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	vc := &v1alpha1.VarnishCluster{
+		Spec: v1alpha1.VarnishClusterSpec{
+			Backend: &v1alpha1.VarnishClusterBackend{
+				Director: &v1alpha1.VarnishClusterBackendDirector{
+					Type:  v1alpha1.VarnishClusterBackendDirectorTypeShard,
+					Shard: &v1alpha1.VarnishClusterBackendDirectorShard{By: "URL"},
+				},
+			},
+		},
+	}
+	backends := []PodInfo{{PodName: "web-1", IP: "10.0.0.1"}, {PodName: "web-2", IP: "10.0.0.2"}}
+
+	r := &ReconcileVarnish{}
+	files, err := r.resolveTemplates(map[string]string{"backends.vcl.tmpl": defaultBackendsTemplate(t)},
+		templateData(vc, LocalPodInfo{Name: "cache-varnish-0"}, 8080, 6081, backends, nil, nil))
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(files["backends.vcl"]).To(gomega.ContainSubstring("new container_director = directors.shard();"))
+	g.Expect(files["backends.vcl"]).To(gomega.ContainSubstring("container_director.reconfigure();"))
+	// vmod_directors fails the request if resolve=LAZY is combined with other arguments on the client side
+	g.Expect(files["backends.vcl"]).To(gomega.ContainSubstring("set req.backend_hint = container_director.backend(by = URL);"))
+	g.Expect(files["backends.vcl"]).ToNot(gomega.ContainSubstring("LAZY"))
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	cases := []struct {
+		template string
+		data     interface{}
+		expected string
+	}{
+		{template: `{{ "Foo" | lower }} {{ "foo" | upper }} {{ "foo bar" | title }} {{ "  foo " | trim }}`, expected: "foo FOO Foo Bar foo"},
+		{template: `{{ "www.example.com" | trimPrefix "www." }} {{ "a.vcl" | trimSuffix ".vcl" }} {{ "a.b.c" | replace "." "-" }}`, expected: "example.com a a-b-c"},
+		{template: `{{ "myBackend-name" | snakecase }} {{ "myBackend_name" | kebabcase }} {{ "my-backend name" | camelcase }}`, expected: "my_backend_name my-backend-name myBackendName"},
+		{template: `{{ contains "b" "abc" }} {{ hasPrefix "a" "abc" }} {{ hasSuffix "a" "abc" }} {{ split "," "a,b" | join "|" }}`, expected: "true true false a|b"},
+		{template: `{{ .Missing | default "none" }} {{ .Set | default "none" }} {{ empty .Missing }}`, data: map[string]interface{}{"Missing": "", "Set": "x"}, expected: "none x true"},
+		{template: `{{ list 1 "a" 2.5 | join "," }} {{ quote 8080 }}`, expected: `1,a,2.5 "8080"`},
+		{template: `{{ regexReplace "[^a-z]+" "_" "web-1.prod" }} {{ regexMatch "^web" "web-1" }}`, expected: "web_prod true"},
+		{template: `{{ sha256sum "varnish" }} {{ sha1sum "varnish" }} {{ md5sum "varnish" }} {{ crc32sum "varnish" }}`,
+			expected: "7c94ead29310cb0fa801f3dd923d1a6c84199538671905d2c40c03b155718d03 1ce6e92b5b4f29fe31ae99a79d7d8e6d207998a5 c23bd2a0047189e89aa9bea67adbc1f0 2313873151"},
+		{template: `{{ toJson (dict "port" 80 "hosts" (list "a" "b")) }}`, expected: `{"hosts":["a","b"],"port":80}`},
+		{template: `{{ vclIdent "web.pod-1" }} {{ vclIdent "1web" }} {{ vclIdent "" }}`, expected: "web_pod-1 v1web v"},
+		{template: "a{{ \"b\\nc\" | nindent 2 }}", expected: "a\n  b\n  c"},
+		{
+			template: `{{ range $version, $pods := groupByLabel "version" . }}{{ $version }}={{ len $pods }};{{ end }}`,
+			data:     []PodInfo{{Labels: map[string]string{"version": "v1"}}, {Labels: map[string]string{"version": "v2"}}, {Labels: map[string]string{"version": "v1"}}, {}},
+			expected: "=1;v1=2;v2=1;",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.template)
+		g := gomega.NewGomegaWithT(t)
+		tmpl := template.New("")
+		tmpl, err := tmpl.Funcs(templateFuncs(tmpl)).Parse(c.template)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		var b bytes.Buffer
+		g.Expect(tmpl.Execute(&b, c.data)).To(gomega.Succeed())
+		g.Expect(b.String()).To(gomega.Equal(c.expected))
+	}
+}
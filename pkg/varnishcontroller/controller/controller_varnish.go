@@ -11,6 +11,7 @@ import (
 	"github.com/ibm/varnish-operator/api/v1alpha1"
 	"github.com/ibm/varnish-operator/pkg/logger"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/metrics"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
 
 	"github.com/pkg/errors"
@@ -28,48 +29,61 @@ func (r *ReconcileVarnish) reconcileVarnish(ctx context.Context, vc *v1alpha1.Va
 	logr := logger.FromContext(ctx)
 	logr.Debugw("Starting varnish reload...")
 	start := time.Now()
-	out, err := r.varnish.Reload(createVCLConfigName(cm.GetResourceVersion()), *vc.Spec.VCL.EntrypointFileName)
+	r.lastReloadAttempt = start
+	// a panic that happens after the reload is a sign that the new VCL has to be reverted
+	if err := r.varnish.PanicClear(ctx); err != nil {
+		logr.Warnw("Can't clear the last varnish panic", zap.Error(err))
+	}
+
+	vclName := createVCLConfigName(cm.GetResourceVersion())
+	out, err := r.varnish.Reload(ctx, vclName, *vc.Spec.VCL.EntrypointFileName)
 	if err != nil {
-		if strings.Contains(string(out), "VCL compilation failed") {
+		if varnishadm.IsVCLCompilationError(err, out) {
 			r.metrics.VCLCompilationError.Set(1)
-			vcEventMsg := "VarnishClusterVCL compilation failed for pod " + pod.Name + ". See pod logs for details"
-			podEventMsg := "VarnishClusterVCL compilation failed. See logs for details"
-			r.eventHandler.Warning(pod, events.EventReasonVCLCompilationError, podEventMsg)
-			r.eventHandler.Warning(vc, events.EventReasonVCLCompilationError, vcEventMsg)
+			r.metrics.ObserveReload(time.Since(start), metrics.ReloadFailureCompilationError)
+			diagnostics := parseVCLDiagnostics(out)
+			r.vclCompilationError = &vclCompilationError{
+				configMapVersion: cm.GetResourceVersion(),
+				message:          truncate(string(out), maxVCLValidationMessageLength),
+				diagnostics:      diagnostics,
+			}
+			summary := vclDiagnosticsSummary(diagnostics)
+			r.lastReloadError = truncate(summary, maxEventMessageLength)
+			vcEventMsg := "VCL from ConfigMap version " + cm.GetResourceVersion() + " failed to compile on pod " + pod.Name + ": " + summary
+			podEventMsg := "VCL from ConfigMap version " + cm.GetResourceVersion() + " failed to compile: " + summary
+			r.eventHandler.Warning(pod, events.EventReasonVCLCompilationError, truncate(podEventMsg, maxEventMessageLength))
+			r.eventHandler.Warning(vc, events.EventReasonVCLCompilationError, truncate(vcEventMsg, maxEventMessageLength))
 			logr.Warnw(string(out))
+			logr.Infow("Keeping the currently active VCL")
 			return nil
 		}
 
-		podEventMsg := "VarnishClusterVarnish reload failed for pod " + pod.Name + ". See pod logs for details"
-		vcEventMsg := "VarnishClusterVarnish reload failed. See logs for details"
-		r.eventHandler.Warning(pod, events.EventReasonReloadError, podEventMsg)
-		r.eventHandler.Warning(vc, events.EventReasonReloadError, vcEventMsg)
+		reason := strings.TrimSpace(string(out))
+		if reason == "" {
+			reason = err.Error()
+		}
+		r.lastReloadError = truncate(reason, maxEventMessageLength)
+		r.metrics.ObserveReload(time.Since(start), metrics.ReloadFailureReloadError)
+		podEventMsg := "Varnish reload failed: " + reason
+		vcEventMsg := "Varnish reload failed for pod " + pod.Name + ": " + reason
+		r.eventHandler.Warning(pod, events.EventReasonReloadError, truncate(podEventMsg, maxEventMessageLength))
+		r.eventHandler.Warning(vc, events.EventReasonReloadError, truncate(vcEventMsg, maxEventMessageLength))
 		return errors.Wrap(err, string(out))
 	}
 
+	r.vclCompilationError = nil
+	r.lastReloadTime = time.Now()
+	r.lastReloadError = ""
 	r.metrics.VCLCompilationError.Set(0)
+	r.metrics.ObserveReload(time.Since(start), "")
 	logr.Debugf("VarnishClusterVarnish successfully reloaded in %f seconds", time.Since(start).Seconds())
-
-	logr.Debugf("Cleaning up old VCL configs...")
-	cleanedUpVCLs := 0
-	configsList, err := r.varnish.List()
-	if err != nil {
-		return errors.WithStack(err)
-	}
-
-	// cleanup unused VCLs. It cleans up only VCLs created by varnish controller (those that start with our prefix)
-	for _, vclConfig := range configsList {
-		if vclConfig.Status == varnishadm.VCLStatusAvailable && strings.HasPrefix(vclConfig.Name, VCLVersionPrefix) {
-			if err := r.varnish.Discard(vclConfig.Name); err != nil {
-				logr.Error(fmt.Sprintf("Can't delete VCL config %q", vclConfig.Name), zap.Error(err))
-			} else {
-				cleanedUpVCLs++
-			}
-		}
+	r.panicWatch = &vclPanicWatch{vclName: vclName, until: time.Now().Add(vclPanicWatchPeriod)}
+	if r.appliedConfigMaps == nil {
+		r.appliedConfigMaps = make(map[string]*v1.ConfigMap)
 	}
+	r.appliedConfigMaps[cm.GetResourceVersion()] = cm
 
-	logr.Debugf("Cleaned up %d VCL config(s)", cleanedUpVCLs)
-	return nil
+	return errors.WithStack(r.cleanupVCLs(ctx, vc, cm))
 }
 
 // creates the VarnishClusterVCL config name from config map version
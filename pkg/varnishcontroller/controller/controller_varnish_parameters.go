@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationVarnishParameters holds the parameters from .spec.varnish.parameters applied in varnish,
+	// as a JSON object of the parameter names and the values from the spec
+	annotationVarnishParameters = "varnishParameters"
+	// annotationVarnishEffectiveParameters holds the values of the applied parameters as reported by varnish
+	annotationVarnishEffectiveParameters = "varnishEffectiveParameters"
+)
+
+// reconcileParameters applies the parameters from .spec.varnish.parameters at runtime with param.set.
+// The parameters varnish reads only on start are passed as varnishd args by the operator, so they are applied on pod restart.
+// Parameters removed from the spec are set back to their defaults.
+// The applied and effective values are recorded in the pod annotations for the operator to report them in the VarnishCluster status.
+func (r *ReconcileVarnish) reconcileParameters(ctx context.Context, vc *v1alpha1.VarnishCluster, pod *v1.Pod) error {
+	logr := logger.FromContext(ctx)
+	desired := vc.Spec.Varnish.Parameters
+	previous := map[string]string{}
+	if raw, found := pod.Annotations[annotationVarnishParameters]; found {
+		if err := json.Unmarshal([]byte(raw), &previous); err != nil {
+			logr.Warnw("Can't parse the previously applied varnish parameters", zap.Error(err))
+		}
+	}
+
+	if len(desired) == 0 && len(previous) == 0 {
+		return nil
+	}
+
+	params, err := r.varnish.ParamShow(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defaults := make(map[string]string, len(params))
+	for _, param := range params {
+		defaults[param.Name] = param.Default
+	}
+
+	for _, name := range sortedKeys(previous) {
+		if _, found := desired[name]; found || v1alpha1.VarnishParameterRequiresRestart(name) {
+			continue
+		}
+		if err := r.varnish.ParamSet(ctx, name, defaults[name]); err != nil {
+			logr.Warnw("Can't reset varnish parameter to the default", "parameter", name, zap.Error(err))
+			continue
+		}
+		logr.Infow("Varnish parameter has been reset to the default", "parameter", name, "value", defaults[name])
+	}
+
+	startupParams := varnishdStartupParameters(pod)
+	applied := make(map[string]string, len(desired))
+	var failed []string
+	for _, name := range sortedKeys(desired) {
+		value := desired[name]
+		if v1alpha1.VarnishParameterRequiresRestart(name) {
+			if startupValue, found := startupParams[name]; found {
+				applied[name] = startupValue
+			}
+			continue
+		}
+
+		if err := r.varnish.ParamSet(ctx, name, value); err != nil {
+			logr.Warnw("Can't set varnish parameter", "parameter", name, "value", value, zap.Error(err))
+			failed = append(failed, name+"="+value+": "+err.Error())
+			continue
+		}
+		if previous[name] != value {
+			logr.Infow("Varnish parameter has been set", "parameter", name, "value", value)
+		}
+		applied[name] = value
+	}
+
+	if len(failed) > 0 {
+		msg := "Can't set varnish parameters: " + strings.Join(failed, "; ")
+		r.eventHandler.Warning(pod, events.EventReasonParameterError, msg)
+		r.eventHandler.Warning(vc, events.EventReasonParameterError, msg+". Pod: "+pod.Name)
+	}
+
+	if params, err = r.varnish.ParamShow(ctx); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(r.updateParameterAnnotations(ctx, pod, applied, effectiveParameters(params, applied)))
+}
+
+func (r *ReconcileVarnish) updateParameterAnnotations(ctx context.Context, pod *v1.Pod, applied, effective map[string]string) error {
+	podCopy := pod.DeepCopy()
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+
+	if len(applied) == 0 {
+		delete(podCopy.Annotations, annotationVarnishParameters)
+		delete(podCopy.Annotations, annotationVarnishEffectiveParameters)
+	} else {
+		appliedJSON, err := json.Marshal(applied)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		effectiveJSON, err := json.Marshal(effective)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		podCopy.Annotations[annotationVarnishParameters] = string(appliedJSON)
+		podCopy.Annotations[annotationVarnishEffectiveParameters] = string(effectiveJSON)
+	}
+
+	if reflect.DeepEqual(pod.Annotations, podCopy.Annotations) {
+		return nil
+	}
+
+	if err := r.Update(ctx, podCopy); err != nil {
+		return errors.Wrap(err, "failed to update pod")
+	}
+	// keep the resource version up to date for the following pod updates
+	podCopy.DeepCopyInto(pod)
+	return nil
+}
+
+// effectiveParameters returns the values varnish reports for the applied parameters
+func effectiveParameters(params []varnishadm.Parameter, applied map[string]string) map[string]string {
+	effective := make(map[string]string, len(applied))
+	for _, param := range params {
+		if _, found := applied[param.Name]; found {
+			effective[param.Name] = param.Value
+		}
+	}
+	return effective
+}
+
+// varnishdStartupParameters returns the parameters passed to varnishd with -p in the varnish container of the pod
+func varnishdStartupParameters(pod *v1.Pod) map[string]string {
+	params := make(map[string]string)
+	for _, container := range pod.Spec.Containers {
+		if container.Name != v1alpha1.VarnishContainerName {
+			continue
+		}
+		for i := 0; i+1 < len(container.Args); i++ {
+			if container.Args[i] != "-p" {
+				continue
+			}
+			if parts := strings.SplitN(container.Args[i+1], "=", 2); len(parts) == 2 {
+				params[parts[0]] = parts[1]
+			}
+		}
+	}
+	return params
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
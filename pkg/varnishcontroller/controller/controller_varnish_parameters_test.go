@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileParameters(t *testing.T) {
+	params := func() []varnishadm.Parameter {
+		return []varnishadm.Parameter{
+			{Name: "default_ttl", Value: "120.000", Default: "120.000", Units: "seconds"},
+			{Name: "default_grace", Value: "60.000", Default: "10.000", Units: "seconds"},
+			{Name: "thread_pool_max", Value: "5000", Default: "5000", Units: "threads"},
+			{Name: "http_max_hdr", Value: "128", Default: "64", Units: "header lines"},
+		}
+	}
+
+	cases := []struct {
+		name                string
+		parameters          map[string]string
+		podAnnotations      map[string]string
+		varnishArgs         []string
+		paramSetError       error
+		expectedSets        map[string]string
+		expectedAnnotations map[string]string
+		expectEvent         bool
+	}{
+		{
+			name: "no parameters",
+		},
+		{
+			name:         "parameters are set at runtime",
+			parameters:   map[string]string{"default_ttl": "3600", "thread_pool_max": "1000"},
+			expectedSets: map[string]string{"default_ttl": "3600", "thread_pool_max": "1000"},
+			expectedAnnotations: map[string]string{
+				annotationVarnishParameters:          `{"default_ttl":"3600","thread_pool_max":"1000"}`,
+				annotationVarnishEffectiveParameters: `{"default_ttl":"3600","thread_pool_max":"1000"}`,
+			},
+		},
+		{
+			name:           "removed parameter is set back to the default",
+			parameters:     map[string]string{"default_ttl": "3600"},
+			podAnnotations: map[string]string{annotationVarnishParameters: `{"default_grace":"60","default_ttl":"3600"}`},
+			expectedSets:   map[string]string{"default_ttl": "3600", "default_grace": "10.000"},
+			expectedAnnotations: map[string]string{
+				annotationVarnishParameters:          `{"default_ttl":"3600"}`,
+				annotationVarnishEffectiveParameters: `{"default_ttl":"3600"}`,
+			},
+		},
+		{
+			name:           "all parameters removed",
+			podAnnotations: map[string]string{annotationVarnishParameters: `{"default_grace":"60"}`, annotationVarnishEffectiveParameters: `{"default_grace":"60.000"}`},
+			expectedSets:   map[string]string{"default_grace": "10.000"},
+		},
+		{
+			name:        "parameter that requires a restart is applied from the varnishd args",
+			parameters:  map[string]string{"http_max_hdr": "128"},
+			varnishArgs: []string{"-F", "-p", "http_max_hdr=128"},
+			expectedAnnotations: map[string]string{
+				annotationVarnishParameters:          `{"http_max_hdr":"128"}`,
+				annotationVarnishEffectiveParameters: `{"http_max_hdr":"128"}`,
+			},
+		},
+		{
+			name:       "parameter that requires a restart waits for the pod restart",
+			parameters: map[string]string{"http_max_hdr": "256"},
+		},
+		{
+			name:          "parameter can't be set",
+			parameters:    map[string]string{"default_ttl": "3600"},
+			paramSetError: errors.New("Must be at least 0.000"),
+			expectedSets:  map[string]string{"default_ttl": "3600"},
+			expectEvent:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		pod := &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default", Annotations: c.podAnnotations},
+			Spec:       v1.PodSpec{Containers: []v1.Container{{Name: v1alpha1.VarnishContainerName, Args: c.varnishArgs}}},
+		}
+		vc := &v1alpha1.VarnishCluster{
+			Spec: v1alpha1.VarnishClusterSpec{Varnish: &v1alpha1.VarnishClusterVarnish{Parameters: c.parameters}},
+		}
+		events := &eventsObserver{}
+		varnish := &varnishMock{params: params(), paramSetError: c.paramSetError}
+		r := &ReconcileVarnish{
+			Client:       fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build(),
+			config:       &config.Config{PodName: "varnish-0", Namespace: "default"},
+			logger:       logger.NewNopLogger(),
+			varnish:      varnish,
+			eventHandler: &varnishEvents.EventHandler{Recorder: events},
+		}
+
+		g.Expect(r.reconcileParameters(context.Background(), vc, pod)).To(gomega.Succeed())
+		g.Expect(varnish.paramSets).To(gomega.Equal(c.expectedSets))
+		g.Expect(events.eventsObserved).To(gomega.Equal(c.expectEvent))
+
+		updatedPod := &v1.Pod{}
+		g.Expect(r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "varnish-0"}, updatedPod)).To(gomega.Succeed())
+		if c.expectedAnnotations == nil {
+			g.Expect(updatedPod.Annotations).To(gomega.BeEmpty())
+		} else {
+			g.Expect(updatedPod.Annotations).To(gomega.Equal(c.expectedAnnotations))
+			// the pod is kept up to date for the following updates in the same reconcile
+			g.Expect(pod.ResourceVersion).To(gomega.Equal(updatedPod.ResourceVersion))
+		}
+	}
+}
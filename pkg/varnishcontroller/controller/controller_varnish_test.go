@@ -25,30 +25,113 @@ type varnishMock struct {
 	listError            error
 	discardError         error
 	reloadError          error
+	loadResponse         string
+	loadError            error
+	useResponse          string
+	useError             error
+	setStateError        error
+	panicResponse        string
+	panicError           error
+	discarded            []string
+	stateChanges         map[string]string
+	loaded               []string
+	labels               map[string]string
+	labelError           error
 	activeVCLConfigName  string
 	activeVCLConfigError error
+	bans                 []string
+	banError             error
+	params               []varnishadm.Parameter
+	paramShowError       error
+	paramSets            map[string]string
+	paramSetError        error
+	backends             []varnishadm.Backend
+	backendListError     error
 }
 
-func (v *varnishMock) Ping() error {
+func (v *varnishMock) Ping(_ context.Context) error {
 	return v.pingError
 }
 
-func (v *varnishMock) List() ([]varnishadm.VCLConfig, error) {
+func (v *varnishMock) List(_ context.Context) ([]varnishadm.VCLConfig, error) {
 	return v.listResponse, v.listError
 }
 
-func (v *varnishMock) Reload(version, entry string) ([]byte, error) {
+func (v *varnishMock) Reload(_ context.Context, version, entry string) ([]byte, error) {
 	return []byte(v.reloadResponse), v.reloadError
 }
 
-func (v *varnishMock) GetActiveConfigurationName() (string, error) {
+func (v *varnishMock) Load(_ context.Context, version, entry string) ([]byte, error) {
+	v.loaded = append(v.loaded, entry)
+	return []byte(v.loadResponse), v.loadError
+}
+
+func (v *varnishMock) Use(_ context.Context, version string) ([]byte, error) {
+	return []byte(v.useResponse), v.useError
+}
+
+func (v *varnishMock) GetActiveConfigurationName(_ context.Context) (string, error) {
 	return v.activeVCLConfigName, v.activeVCLConfigError
 }
 
-func (v *varnishMock) Discard(vclConfigName string) error {
+func (v *varnishMock) Discard(_ context.Context, vclConfigName string) error {
+	v.discarded = append(v.discarded, vclConfigName)
 	return v.discardError
 }
 
+func (v *varnishMock) SetState(_ context.Context, vclConfigName, state string) error {
+	if v.stateChanges == nil {
+		v.stateChanges = make(map[string]string)
+	}
+	v.stateChanges[vclConfigName] = state
+	return v.setStateError
+}
+
+func (v *varnishMock) Label(_ context.Context, label, vclConfigName string) error {
+	if v.labels == nil {
+		v.labels = make(map[string]string)
+	}
+	v.labels[label] = vclConfigName
+	return v.labelError
+}
+
+func (v *varnishMock) PanicShow(_ context.Context) (string, error) {
+	return v.panicResponse, v.panicError
+}
+
+func (v *varnishMock) PanicClear(_ context.Context) error {
+	return nil
+}
+
+func (v *varnishMock) Ban(_ context.Context, expression string) error {
+	v.bans = append(v.bans, expression)
+	return v.banError
+}
+
+func (v *varnishMock) BackendList(_ context.Context) ([]varnishadm.Backend, error) {
+	return v.backends, v.backendListError
+}
+
+func (v *varnishMock) ParamShow(_ context.Context) ([]varnishadm.Parameter, error) {
+	return v.params, v.paramShowError
+}
+
+func (v *varnishMock) ParamSet(_ context.Context, name, value string) error {
+	if v.paramSets == nil {
+		v.paramSets = make(map[string]string)
+	}
+	v.paramSets[name] = value
+	if v.paramSetError != nil {
+		return v.paramSetError
+	}
+	for i := range v.params {
+		if v.params[i].Name == name {
+			v.params[i].Value = value
+		}
+	}
+	return nil
+}
+
 type eventsObserver struct {
 	eventsObserved bool
 }
@@ -82,6 +165,7 @@ func TestReconcileVarnish(t *testing.T) {
 		expectEventSent                   bool
 		expectedError                     error
 		expectedVCLCompilationErrorMetric int
+		expectCompilationError            bool
 	}{
 		{
 			varnish: &varnishMock{
@@ -104,6 +188,7 @@ func TestReconcileVarnish(t *testing.T) {
 			expectedError:                     nil,
 			expectEventSent:                   true,
 			expectedVCLCompilationErrorMetric: 1,
+			expectCompilationError:            true,
 		},
 		{
 			varnish: &varnishMock{
@@ -131,7 +216,7 @@ func TestReconcileVarnish(t *testing.T) {
 
 	for _, c := range cases {
 		events := &eventsObserver{}
-		controllerMetrics := metrics.NewVarnishControllerMetrics()
+		controllerMetrics := metrics.NewVarnishControllerMetrics("cache", "varnish-0")
 		testReconciler := &ReconcileVarnish{
 			varnish:      c.varnish,
 			eventHandler: &varnishEvents.EventHandler{Recorder: events},
@@ -144,5 +229,12 @@ func TestReconcileVarnish(t *testing.T) {
 		err = controllerMetrics.VCLCompilationError.Write(m)
 		g.Expect(err).To(gomega.Succeed())
 		g.Expect(*m.Gauge.Value).To(gomega.BeNumerically("==", c.expectedVCLCompilationErrorMetric))
+		if c.expectCompilationError {
+			g.Expect(testReconciler.vclCompilationError).ToNot(gomega.BeNil())
+			g.Expect(testReconciler.vclCompilationError.configMapVersion).To(gomega.Equal(cmResourceVersion))
+			g.Expect(testReconciler.vclCompilationError.diagnostics).ToNot(gomega.BeEmpty())
+		} else {
+			g.Expect(testReconciler.vclCompilationError).To(gomega.BeNil())
+		}
 	}
 }
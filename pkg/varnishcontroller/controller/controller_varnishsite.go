@@ -0,0 +1,433 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// VCLSitePrefix is the prefix of the VCL configs loaded from VarnishSite ConfigMaps
+	VCLSitePrefix = "site-"
+	// VCLSiteLabelPrefix is the prefix of the labels the router in the top-level VCL switches to the site VCLs with
+	VCLSiteLabelPrefix = "label-"
+
+	// directory inside the VCL config dir the site VCL files are written to. Each site has its own subdirectory.
+	vclSitesDir = "sites"
+
+	siteConditionReasonAccepted            = "Accepted"
+	siteConditionReasonNamespaceNotAllowed = "NamespaceNotAllowed"
+	siteConditionReasonHostnameConflict    = "HostnameConflict"
+)
+
+var (
+	vclVersionDeclarationRegexp = regexp.MustCompile(`(?m)^\s*vcl\s+[0-9]+\.[0-9]+\s*;[^\n]*\n?`)
+	// include "file.vcl"; or include {"file.vcl"}; with an optional +glob flag
+	vclIncludeRegexp = regexp.MustCompile(`\binclude(\s+\+glob)?\s+(?:"([^"\n]*)"|\{"([\s\S]*?)"\})`)
+)
+
+// siteRoute is a VarnishSite that has a VCL loaded and labeled
+type siteRoute struct {
+	label     string
+	hostnames []v1alpha1.VarnishSiteHostname
+}
+
+// reconcileSites loads the VCLs of the VarnishSites that reference the VarnishCluster and labels them, so the router
+// in the top-level VCL can switch to them. A site that fails to load keeps serving its previously loaded VCL, if any,
+// and doesn't affect the other sites. Returns the sites that have a VCL loaded.
+func (r *ReconcileVarnish) reconcileSites(ctx context.Context, dir string, vc *v1alpha1.VarnishCluster, pod *v1.Pod) ([]siteRoute, error) {
+	siteList := &v1alpha1.VarnishSiteList{}
+	if err := r.List(ctx, siteList); err != nil {
+		return nil, errors.Wrap(err, "can't list VarnishSites")
+	}
+
+	var clusterSites []v1alpha1.VarnishSite
+	for _, site := range siteList.Items {
+		if r.isClusterSite(&site) && site.DeletionTimestamp == nil {
+			clusterSites = append(clusterSites, site)
+		}
+	}
+
+	sites, conditions := admitSites(vc, clusterSites)
+	for i := range clusterSites {
+		if err := r.updateSiteStatus(ctx, &clusterSites[i], conditions[siteKey(&clusterSites[i])]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		return siteKey(&sites[i]) < siteKey(&sites[j])
+	})
+
+	configsList, err := r.varnish.List(ctx)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	labels := make(map[string]bool)
+	for _, vclConfig := range configsList {
+		if vclConfig.Label {
+			labels[vclConfig.Name] = true
+		}
+	}
+
+	var routes []siteRoute
+	siteKeys := make(map[string]bool, len(sites))
+	for i := range sites {
+		key := siteKey(&sites[i])
+		siteKeys[key] = true
+		labeled, err := r.reconcileSite(ctx, dir, vc, pod, &sites[i], labels[siteLabel(key)])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if labeled {
+			routes = append(routes, siteRoute{label: siteLabel(key), hostnames: sites[i].Spec.Hostnames})
+		}
+	}
+
+	return routes, errors.WithStack(r.cleanupSites(ctx, dir, siteKeys))
+}
+
+// admitSites returns the sites the VarnishCluster serves and the Accepted conditions of all sites by their keys.
+// The sites from the namespaces that are not allowed are rejected. A hostname is served by the site that claimed it first,
+// so the sites that claim a hostname of an older site are rejected as a whole.
+func admitSites(vc *v1alpha1.VarnishCluster, sites []v1alpha1.VarnishSite) ([]v1alpha1.VarnishSite, map[string]metav1.Condition) {
+	sorted := make([]*v1alpha1.VarnishSite, 0, len(sites))
+	for i := range sites {
+		sorted = append(sorted, &sites[i])
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if !sorted[i].CreationTimestamp.Equal(&sorted[j].CreationTimestamp) {
+			return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+		}
+		return siteKey(sorted[i]) < siteKey(sorted[j])
+	})
+
+	allowedNamespaces := map[string]bool{vc.Namespace: true}
+	if vc.Spec.Sites != nil {
+		for _, namespace := range vc.Spec.Sites.AllowedNamespaces {
+			allowedNamespaces[namespace] = true
+		}
+	}
+
+	var admitted []v1alpha1.VarnishSite
+	conditions := make(map[string]metav1.Condition, len(sites))
+	claimedBy := make(map[v1alpha1.VarnishSiteHostname]*v1alpha1.VarnishSite)
+	for _, site := range sorted {
+		condition := metav1.Condition{Type: v1alpha1.VarnishSiteConditionAccepted, ObservedGeneration: site.Generation}
+		if !allowedNamespaces[site.Namespace] {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = siteConditionReasonNamespaceNotAllowed
+			condition.Message = fmt.Sprintf("Namespace %s is not allowed by VarnishCluster %s/%s in .spec.sites.allowedNamespaces", site.Namespace, vc.Namespace, vc.Name)
+			conditions[siteKey(site)] = condition
+			continue
+		}
+
+		var conflicts []string
+		for _, hostname := range site.Spec.Hostnames {
+			if owner, found := claimedBy[hostname]; found {
+				conflicts = append(conflicts, fmt.Sprintf("%s (VarnishSite %s/%s)", hostname, owner.Namespace, owner.Name))
+			}
+		}
+		if len(conflicts) > 0 {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = siteConditionReasonHostnameConflict
+			condition.Message = "Hostnames already served by older sites: " + strings.Join(conflicts, ", ")
+			conditions[siteKey(site)] = condition
+			continue
+		}
+
+		for _, hostname := range site.Spec.Hostnames {
+			claimedBy[hostname] = site
+		}
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = siteConditionReasonAccepted
+		condition.Message = fmt.Sprintf("Served by VarnishCluster %s/%s", vc.Namespace, vc.Name)
+		conditions[siteKey(site)] = condition
+		admitted = append(admitted, *site)
+	}
+	return admitted, conditions
+}
+
+// updateSiteStatus sets the Accepted condition of the site. Every pod of the cluster comes to the same result,
+// so only the pod that changes the condition reports a rejection in an event.
+func (r *ReconcileVarnish) updateSiteStatus(ctx context.Context, site *v1alpha1.VarnishSite, condition metav1.Condition) error {
+	current := meta.FindStatusCondition(site.Status.Conditions, condition.Type)
+	if current != nil && current.Status == condition.Status && current.Reason == condition.Reason &&
+		current.Message == condition.Message && current.ObservedGeneration == condition.ObservedGeneration {
+		return nil
+	}
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &v1alpha1.VarnishSite{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(site), latest); err != nil {
+			return err
+		}
+		meta.SetStatusCondition(&latest.Status.Conditions, condition)
+		return r.Status().Update(ctx, latest)
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "can't update the status of VarnishSite %s/%s", site.Namespace, site.Name)
+	}
+
+	if condition.Status == metav1.ConditionFalse {
+		logger.FromContext(ctx).Warnw("VarnishSite is rejected", "varnishSite", site.Namespace+"/"+site.Name, "reason", condition.Reason, "message", condition.Message)
+		r.eventHandler.Warning(site, events.EventReasonVarnishSiteRejected, condition.Message)
+	}
+	return nil
+}
+
+// reconcileSite writes the VCL files of the site and loads them if they have changed or varnish has no VCL loaded for the site.
+// Returns true if the site has a labeled VCL the requests can be routed to.
+func (r *ReconcileVarnish) reconcileSite(ctx context.Context, dir string, vc *v1alpha1.VarnishCluster, pod *v1.Pod, site *v1alpha1.VarnishSite, labeled bool) (bool, error) {
+	key := siteKey(site)
+	logr := logger.FromContext(ctx).With("varnishSite", site.Namespace+"/"+site.Name)
+
+	cm, err := r.getConfigMap(ctx, site.Namespace, site.Spec.VCL.ConfigMapName)
+	if err != nil {
+		logr.Warnw("Can't get the VarnishSite ConfigMap", zap.Error(err))
+		r.eventHandler.Warning(site, events.EventReasonInvalidVCLConfigMap, fmt.Sprintf("Can't get ConfigMap %s: %s", site.Spec.VCL.ConfigMapName, err))
+		return labeled, nil
+	}
+
+	newFiles := make(map[string]string, len(cm.Data))
+	for fileName, contents := range cm.Data {
+		if filepath.Ext(fileName) == ".vcl" {
+			newFiles[fileName] = contents
+		}
+	}
+
+	if _, found := newFiles[site.Spec.VCL.EntrypointFileName]; !found {
+		errMsg := fmt.Sprintf("VCL ConfigMap %s doesn't have the entrypoint file %s", cm.Name, site.Spec.VCL.EntrypointFileName)
+		logr.Warnw(errMsg)
+		r.eventHandler.Warning(site, events.EventReasonInvalidVCLConfigMap, errMsg)
+		return labeled, nil
+	}
+
+	siteDir := filepath.Join(dir, vclSitesDir, key)
+	if newFiles, err = siteIncludes(siteDir, newFiles); err != nil {
+		errMsg := fmt.Sprintf("VCL ConfigMap %s can't be loaded: %s", cm.Name, err)
+		logr.Warnw(errMsg)
+		r.eventHandler.Warning(site, events.EventReasonInvalidVCLConfigMap, errMsg)
+		return labeled, nil
+	}
+
+	if err = os.MkdirAll(siteDir, 0755); err != nil {
+		return labeled, errors.Wrapf(err, "can't create dir %s", siteDir)
+	}
+
+	currFiles, err := getCurrentFiles(siteDir)
+	if err != nil {
+		return labeled, errors.WithStack(err)
+	}
+
+	filesTouched, err := r.reconcileFiles(ctx, siteDir, currFiles, newFiles)
+	if err != nil {
+		return labeled, errors.WithStack(err)
+	}
+
+	if !filesTouched && (labeled || r.failedSiteVersions[key] == cm.GetResourceVersion()) {
+		return labeled, nil
+	}
+
+	vclName := fmt.Sprintf("%s%s-%s-%d", VCLSitePrefix, key, cm.GetResourceVersion(), time.Now().Unix())
+	out, err := r.varnish.Load(ctx, vclName, filepath.Join(vclSitesDir, key, site.Spec.VCL.EntrypointFileName))
+	if err != nil {
+		if !varnishadm.IsVCLCompilationError(err, out) {
+			return labeled, errors.Wrap(err, string(out))
+		}
+
+		r.failedSiteVersions[key] = cm.GetResourceVersion()
+		logr.Warnw(string(out))
+		summary := vclDiagnosticsSummary(parseVCLDiagnostics(out))
+		r.eventHandler.Warning(site, events.EventReasonVCLCompilationError, truncate("VarnishSite VCL compilation failed for pod "+pod.Name+": "+summary, maxEventMessageLength))
+		r.eventHandler.Warning(pod, events.EventReasonVCLCompilationError, truncate("VarnishSite "+site.Namespace+"/"+site.Name+" VCL compilation failed: "+summary, maxEventMessageLength))
+		return labeled, nil
+	}
+
+	if err = r.varnish.Label(ctx, siteLabel(key), vclName); err != nil {
+		r.failedSiteVersions[key] = cm.GetResourceVersion()
+		logr.Warnw("Can't label the VarnishSite VCL", zap.Error(err))
+		r.eventHandler.Warning(site, events.EventReasonVCLCompilationError, truncate("VarnishSite VCL can't be labeled for pod "+pod.Name+": "+err.Error(), maxEventMessageLength))
+		if err = r.varnish.Discard(ctx, vclName); err != nil {
+			logr.Warnw(fmt.Sprintf("Can't delete VCL config %q", vclName), zap.Error(err))
+		}
+		return labeled, nil
+	}
+
+	delete(r.failedSiteVersions, key)
+	logr.Infow("VarnishSite VCL loaded", "vclName", vclName)
+	return true, nil
+}
+
+// siteIncludes rewrites the includes of the site VCL files to the absolute paths of the files in the site dir.
+// Relative includes are resolved through the vcl_path of varnish, i.e. the dir of the cluster VCL, so otherwise a site
+// could include the VCL of the cluster or of other sites. Only the files of the site ConfigMap can be included.
+func siteIncludes(siteDir string, files map[string]string) (map[string]string, error) {
+	fileNames := make([]string, 0, len(files))
+	for fileName := range files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+
+	rewritten := make(map[string]string, len(files))
+	for _, fileName := range fileNames {
+		var includeErr error
+		rewritten[fileName] = vclIncludeRegexp.ReplaceAllStringFunc(files[fileName], func(include string) string {
+			match := vclIncludeRegexp.FindStringSubmatch(include)
+			path := strings.TrimPrefix(match[2]+match[3], "./")
+			if _, found := files[path]; match[1] != "" || path != filepath.Base(path) || !found {
+				if includeErr == nil {
+					includeErr = errors.Errorf("%s includes %q. Only the VCL files of the same ConfigMap can be included", fileName, path)
+				}
+				return include
+			}
+			return `include "` + filepath.Join(siteDir, path) + `"`
+		})
+		if includeErr != nil {
+			return nil, includeErr
+		}
+	}
+	return rewritten, nil
+}
+
+// cleanupSites discards the site VCLs that are not referenced by the site labels anymore and the labels of the removed sites.
+// A label can't be discarded while it's used by a loaded top-level VCL, so it will be retried on the next reconcile.
+func (r *ReconcileVarnish) cleanupSites(ctx context.Context, dir string, siteKeys map[string]bool) error {
+	logr := logger.FromContext(ctx)
+	configsList, err := r.varnish.List(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	labeledVCLs := make(map[string]bool)
+	for _, vclConfig := range configsList {
+		if !vclConfig.Label || !strings.HasPrefix(vclConfig.Name, VCLSiteLabelPrefix) {
+			continue
+		}
+
+		if siteKeys[strings.TrimPrefix(vclConfig.Name, VCLSiteLabelPrefix)] {
+			if vclConfig.ReferencedVCL != nil {
+				labeledVCLs[*vclConfig.ReferencedVCL] = true
+			}
+			continue
+		}
+
+		if err := r.varnish.Discard(ctx, vclConfig.Name); err != nil {
+			logr.Debugw(fmt.Sprintf("Can't delete VCL label %q of a removed VarnishSite", vclConfig.Name), zap.Error(err))
+			if vclConfig.ReferencedVCL != nil {
+				labeledVCLs[*vclConfig.ReferencedVCL] = true
+			}
+		}
+	}
+
+	for _, vclConfig := range configsList {
+		if vclConfig.Label || !strings.HasPrefix(vclConfig.Name, VCLSitePrefix) || labeledVCLs[vclConfig.Name] ||
+			vclConfig.Status != varnishadm.VCLStatusAvailable {
+			continue
+		}
+
+		if err := r.varnish.Discard(ctx, vclConfig.Name); err != nil {
+			logr.Error(fmt.Sprintf("Can't delete VCL config %q", vclConfig.Name), zap.Error(err))
+		}
+	}
+
+	sitesDir := filepath.Join(dir, vclSitesDir)
+	dirs, err := os.ReadDir(sitesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "incorrect dir: %s", sitesDir)
+	}
+
+	for _, dir := range dirs {
+		if dir.IsDir() && !siteKeys[dir.Name()] {
+			logr.Infow("Removing VarnishSite files", logger.FieldFilePath, filepath.Join(sitesDir, dir.Name()))
+			if err = os.RemoveAll(filepath.Join(sitesDir, dir.Name())); err != nil {
+				return errors.Wrapf(err, "could not delete dir %s", dir.Name())
+			}
+		}
+	}
+
+	return nil
+}
+
+// isClusterSite returns true if the VarnishSite references the VarnishCluster the pod belongs to. The cluster
+// doesn't necessarily serve the site, see admitSites.
+func (r *ReconcileVarnish) isClusterSite(site *v1alpha1.VarnishSite) bool {
+	return site.Spec.VarnishCluster.Name == r.config.VarnishClusterName && site.ClusterNamespace() == r.config.Namespace
+}
+
+// addSiteRouter adds a vcl_recv subroutine that switches to the site VCLs by the Host header right after the VCL version declaration,
+// so it runs before any other vcl_recv code. Requests for other hosts are handled by the top-level VCL as usual.
+func addSiteRouter(entrypoint string, routes []siteRoute) (string, error) {
+	if len(routes) == 0 {
+		return entrypoint, nil
+	}
+
+	loc := vclVersionDeclarationRegexp.FindStringIndex(entrypoint)
+	if loc == nil {
+		return entrypoint, errors.New("VCL version declaration is not found in the entrypoint file")
+	}
+
+	return entrypoint[:loc[1]] + siteRouter(routes) + entrypoint[loc[1]:], nil
+}
+
+// siteRouter generates the router VCL code. Exact hostnames take precedence over wildcards.
+func siteRouter(routes []siteRoute) string {
+	router := &strings.Builder{}
+	router.WriteString("\n# Routes the requests to the VarnishSite VCLs. Generated by varnish-controller\nsub vcl_recv {\n")
+	for _, wildcard := range []bool{false, true} {
+		for _, route := range routes {
+			var hosts []string
+			for _, hostname := range route.hostnames {
+				if strings.HasPrefix(string(hostname), "*.") != wildcard {
+					continue
+				}
+				if wildcard {
+					hosts = append(hosts, `[^.]+\.`+regexp.QuoteMeta(strings.TrimPrefix(string(hostname), "*.")))
+				} else {
+					hosts = append(hosts, regexp.QuoteMeta(string(hostname)))
+				}
+			}
+			if len(hosts) == 0 {
+				continue
+			}
+			fmt.Fprintf(router, "    if (req.http.host ~ \"(?i)^(%s)(:[0-9]+)?$\") {\n        return (vcl(%s));\n    }\n", strings.Join(hosts, "|"), route.label)
+		}
+	}
+	router.WriteString("}\n")
+	return router.String()
+}
+
+// siteKey identifies the site in VCL config names, labels and file paths.
+// Namespaces can't contain underscores and dots, so the key is unique.
+func siteKey(site *v1alpha1.VarnishSite) string {
+	return site.Namespace + "_" + strings.ReplaceAll(site.Name, ".", "_")
+}
+
+func siteLabel(key string) string {
+	return VCLSiteLabelPrefix + key
+}
@@ -0,0 +1,318 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileSites(t *testing.T) {
+	site := func(namespace, name, clusterName string) *v1alpha1.VarnishSite {
+		return &v1alpha1.VarnishSite{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, CreationTimestamp: metav1.Now()},
+			Spec: v1alpha1.VarnishSiteSpec{
+				VarnishCluster: v1alpha1.VarnishSiteClusterRef{Name: clusterName, Namespace: "default"},
+				Hostnames:      []v1alpha1.VarnishSiteHostname{"www.example.com"},
+				VCL:            v1alpha1.VarnishSiteVCL{ConfigMapName: name + "-vcl", EntrypointFileName: "site.vcl"},
+			},
+		}
+	}
+	siteConfigMap := func(namespace, name, contents string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-vcl", Namespace: namespace, ResourceVersion: "5"},
+			Data:       map[string]string{"site.vcl": contents, "README.md": "docs"},
+		}
+	}
+	referenced := func(name string) *string { return &name }
+	olderSite := func(namespace, name string) *v1alpha1.VarnishSite {
+		s := site(namespace, name, "varnish")
+		s.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+		return s
+	}
+
+	cases := []struct {
+		name             string
+		objects          []client.Object
+		currFiles        map[string]map[string]string
+		varnish          *varnishMock
+		expectedRoutes   []siteRoute
+		expectedLoaded   []string
+		expectedLabels   map[string]string
+		expectedDiscards []string
+		expectedDirs     []string
+		expectEvent      bool
+		// the reasons of the Accepted conditions by site namespace and name
+		expectedReasons map[string]string
+	}{
+		{
+			name:            "new site",
+			objects:         []client.Object{site("team-a", "shop", "varnish"), siteConfigMap("team-a", "shop", "vcl 4.1;")},
+			varnish:         &varnishMock{},
+			expectedRoutes:  []siteRoute{{label: "label-team-a_shop", hostnames: []v1alpha1.VarnishSiteHostname{"www.example.com"}}},
+			expectedLoaded:  []string{"sites/team-a_shop/site.vcl"},
+			expectedLabels:  map[string]string{"label-team-a_shop": "site-team-a_shop-5-"},
+			expectedDirs:    []string{"team-a_shop"},
+			expectedReasons: map[string]string{"team-a/shop": siteConditionReasonAccepted},
+		},
+		{
+			name:            "site from a namespace that is not allowed",
+			objects:         []client.Object{site("team-b", "shop", "varnish"), siteConfigMap("team-b", "shop", "vcl 4.1;")},
+			varnish:         &varnishMock{},
+			expectEvent:     true,
+			expectedReasons: map[string]string{"team-b/shop": siteConditionReasonNamespaceNotAllowed},
+		},
+		{
+			name: "hostname served by an older site",
+			objects: []client.Object{
+				site("team-a", "shop", "varnish"), siteConfigMap("team-a", "shop", "vcl 4.1;"),
+				olderSite("team-a", "www"), siteConfigMap("team-a", "www", "vcl 4.1;"),
+			},
+			varnish:         &varnishMock{},
+			expectedRoutes:  []siteRoute{{label: "label-team-a_www", hostnames: []v1alpha1.VarnishSiteHostname{"www.example.com"}}},
+			expectedLoaded:  []string{"sites/team-a_www/site.vcl"},
+			expectedLabels:  map[string]string{"label-team-a_www": "site-team-a_www-5-"},
+			expectedDirs:    []string{"team-a_www"},
+			expectEvent:     true,
+			expectedReasons: map[string]string{"team-a/shop": siteConditionReasonHostnameConflict, "team-a/www": siteConditionReasonAccepted},
+		},
+		{
+			name:    "site of an another cluster",
+			objects: []client.Object{site("team-a", "shop", "other"), siteConfigMap("team-a", "shop", "vcl 4.1;")},
+			varnish: &varnishMock{},
+		},
+		{
+			name:    "site VCL doesn't compile",
+			objects: []client.Object{site("team-a", "shop", "varnish"), siteConfigMap("team-a", "shop", "vcl 4.1; broken")},
+			varnish: &varnishMock{
+				loadResponse: "Message from VCC-compiler:\nVCL compilation failed",
+				loadError:    errors.New("exit status 1"),
+			},
+			expectedLoaded: []string{"sites/team-a_shop/site.vcl"},
+			expectedDirs:   []string{"team-a_shop"},
+			expectEvent:    true,
+		},
+		{
+			name:        "site includes the cluster VCL",
+			objects:     []client.Object{site("team-a", "shop", "varnish"), siteConfigMap("team-a", "shop", "vcl 4.1; include \"../../entrypoint.vcl\";")},
+			varnish:     &varnishMock{},
+			expectEvent: true,
+		},
+		{
+			name:    "site ConfigMap doesn't exist",
+			objects: []client.Object{site("team-a", "shop", "varnish")},
+			varnish: &varnishMock{
+				listResponse: []varnishadm.VCLConfig{
+					{Name: "label-team-a_shop", Label: true, ReferencedVCL: referenced("site-team-a_shop-4-1561381200"), Status: varnishadm.VCLStatusAvailable},
+					{Name: "site-team-a_shop-4-1561381200", Status: varnishadm.VCLStatusAvailable},
+				},
+			},
+			expectedRoutes: []siteRoute{{label: "label-team-a_shop", hostnames: []v1alpha1.VarnishSiteHostname{"www.example.com"}}},
+			expectEvent:    true,
+		},
+		{
+			name:      "site is loaded already",
+			objects:   []client.Object{site("team-a", "shop", "varnish"), siteConfigMap("team-a", "shop", "vcl 4.1;")},
+			currFiles: map[string]map[string]string{"team-a_shop": {"site.vcl": "vcl 4.1;"}},
+			varnish: &varnishMock{
+				listResponse: []varnishadm.VCLConfig{
+					{Name: "label-team-a_shop", Label: true, ReferencedVCL: referenced("site-team-a_shop-5-1561381200"), Status: varnishadm.VCLStatusAvailable},
+					{Name: "site-team-a_shop-5-1561381200", Status: varnishadm.VCLStatusAvailable},
+					{Name: "site-team-a_shop-4-1561381100", Status: varnishadm.VCLStatusAvailable},
+				},
+			},
+			expectedRoutes:   []siteRoute{{label: "label-team-a_shop", hostnames: []v1alpha1.VarnishSiteHostname{"www.example.com"}}},
+			expectedDiscards: []string{"site-team-a_shop-4-1561381100"},
+			expectedDirs:     []string{"team-a_shop"},
+		},
+		{
+			name:      "site has been removed",
+			currFiles: map[string]map[string]string{"team-a_shop": {"site.vcl": "vcl 4.1;"}},
+			varnish: &varnishMock{
+				listResponse: []varnishadm.VCLConfig{
+					{Name: "v-1-1561381000", Status: varnishadm.VCLStatusActive},
+					{Name: "label-team-a_shop", Label: true, ReferencedVCL: referenced("site-team-a_shop-5-1561381200"), Status: varnishadm.VCLStatusAvailable},
+					{Name: "site-team-a_shop-5-1561381200", Status: varnishadm.VCLStatusAvailable},
+				},
+			},
+			expectedDiscards: []string{"label-team-a_shop", "site-team-a_shop-5-1561381200"},
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	gomega.NewGomegaWithT(t).Expect(clientgoscheme.AddToScheme(scheme)).To(gomega.Succeed())
+	gomega.NewGomegaWithT(t).Expect(v1alpha1.AddToScheme(scheme)).To(gomega.Succeed())
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+
+		dir := t.TempDir()
+		for key, files := range c.currFiles {
+			g.Expect(os.MkdirAll(filepath.Join(dir, vclSitesDir, key), 0755)).To(gomega.Succeed())
+			for name, contents := range files {
+				g.Expect(os.WriteFile(filepath.Join(dir, vclSitesDir, key, name), []byte(contents), 0644)).To(gomega.Succeed())
+			}
+		}
+
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default"}}
+		vc := &v1alpha1.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+			Spec:       v1alpha1.VarnishClusterSpec{Sites: &v1alpha1.VarnishClusterSites{AllowedNamespaces: []string{"team-a"}}},
+		}
+		events := &eventsObserver{}
+		r := &ReconcileVarnish{
+			Client:             fake.NewClientBuilder().WithScheme(scheme).WithObjects(c.objects...).Build(),
+			config:             &config.Config{PodName: "varnish-0", Namespace: "default", VarnishClusterName: "varnish"},
+			logger:             logger.NewNopLogger(),
+			varnish:            c.varnish,
+			eventHandler:       &varnishEvents.EventHandler{Recorder: events},
+			failedSiteVersions: make(map[string]string),
+		}
+
+		routes, err := r.reconcileSites(context.Background(), dir, vc, pod)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(routes).To(gomega.Equal(c.expectedRoutes))
+		g.Expect(c.varnish.loaded).To(gomega.Equal(c.expectedLoaded))
+		g.Expect(c.varnish.discarded).To(gomega.Equal(c.expectedDiscards))
+		g.Expect(events.eventsObserved).To(gomega.Equal(c.expectEvent))
+
+		g.Expect(c.varnish.labels).To(gomega.HaveLen(len(c.expectedLabels)))
+		for label, vclNamePrefix := range c.expectedLabels {
+			g.Expect(strings.HasPrefix(c.varnish.labels[label], vclNamePrefix)).To(gomega.BeTrue())
+		}
+
+		var dirs []string
+		entries, _ := os.ReadDir(filepath.Join(dir, vclSitesDir))
+		for _, entry := range entries {
+			dirs = append(dirs, entry.Name())
+			files, err := getCurrentFiles(filepath.Join(dir, vclSitesDir, entry.Name()))
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(files).To(gomega.HaveKey("site.vcl"))
+			g.Expect(files).ToNot(gomega.HaveKey("README.md"))
+		}
+		g.Expect(dirs).To(gomega.Equal(c.expectedDirs))
+
+		for key, reason := range c.expectedReasons {
+			updated := &v1alpha1.VarnishSite{}
+			namespace, name, _ := strings.Cut(key, "/")
+			g.Expect(r.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, updated)).To(gomega.Succeed())
+			accepted := meta.FindStatusCondition(updated.Status.Conditions, v1alpha1.VarnishSiteConditionAccepted)
+			g.Expect(accepted).ToNot(gomega.BeNil())
+			g.Expect(accepted.Reason).To(gomega.Equal(reason))
+		}
+	}
+}
+
+func TestSiteIncludes(t *testing.T) {
+	cases := []struct {
+		name      string
+		files     map[string]string
+		expected  map[string]string
+		expectErr bool
+	}{
+		{
+			name:     "no includes",
+			files:    map[string]string{"site.vcl": "vcl 4.1;"},
+			expected: map[string]string{"site.vcl": "vcl 4.1;"},
+		},
+		{
+			name:  "includes of the site files",
+			files: map[string]string{"site.vcl": "vcl 4.1;\ninclude \"./backends.vcl\";\ninclude {\"acl.vcl\"};", "backends.vcl": "", "acl.vcl": ""},
+			expected: map[string]string{
+				"site.vcl":     "vcl 4.1;\ninclude \"/etc/varnish/sites/team-a_shop/backends.vcl\";\ninclude \"/etc/varnish/sites/team-a_shop/acl.vcl\";",
+				"backends.vcl": "",
+				"acl.vcl":      "",
+			},
+		},
+		{
+			name:      "include of a file not in the site ConfigMap",
+			files:     map[string]string{"site.vcl": "vcl 4.1; include \"entrypoint.vcl\";"},
+			expectErr: true,
+		},
+		{
+			name:      "include of a file in an another dir",
+			files:     map[string]string{"site.vcl": "vcl 4.1; include \"../team-b_shop/site.vcl\";"},
+			expectErr: true,
+		},
+		{
+			name:      "include with an absolute path",
+			files:     map[string]string{"site.vcl": "vcl 4.1; include \"/etc/varnish/site.vcl\";"},
+			expectErr: true,
+		},
+		{
+			name:      "include of a glob",
+			files:     map[string]string{"site.vcl": "vcl 4.1; include +glob \"*.vcl\";", "*.vcl": ""},
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		files, err := siteIncludes("/etc/varnish/sites/team-a_shop", c.files)
+		if c.expectErr {
+			g.Expect(err).To(gomega.HaveOccurred())
+			continue
+		}
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(files).To(gomega.Equal(c.expected))
+	}
+}
+
+func TestAddSiteRouter(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	entrypoint := "vcl 4.1;\n\nimport directors;\n\nsub vcl_recv {\n}\n"
+	routes := []siteRoute{
+		{label: "label-team-a_shop", hostnames: []v1alpha1.VarnishSiteHostname{"shop.example.com", "*.shop.example.com"}},
+		{label: "label-team-b_blog", hostnames: []v1alpha1.VarnishSiteHostname{"blog.example.com"}},
+	}
+
+	withRouter, err := addSiteRouter(entrypoint, routes)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(withRouter).To(gomega.Equal(`vcl 4.1;
+
+# Routes the requests to the VarnishSite VCLs. Generated by varnish-controller
+sub vcl_recv {
+    if (req.http.host ~ "(?i)^(shop\.example\.com)(:[0-9]+)?$") {
+        return (vcl(label-team-a_shop));
+    }
+    if (req.http.host ~ "(?i)^(blog\.example\.com)(:[0-9]+)?$") {
+        return (vcl(label-team-b_blog));
+    }
+    if (req.http.host ~ "(?i)^([^.]+\.shop\.example\.com)(:[0-9]+)?$") {
+        return (vcl(label-team-a_shop));
+    }
+}
+
+import directors;
+
+sub vcl_recv {
+}
+`))
+
+	unchanged, err := addSiteRouter(entrypoint, nil)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(unchanged).To(gomega.Equal(entrypoint))
+
+	_, err = addSiteRouter("sub vcl_recv {}", routes)
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+)
+
+const (
+	annotationVCLCompilationErrorConfigMapVersion = "vclCompilationErrorConfigMapVersion"
+	annotationVCLCompilationErrorMessage          = "vclCompilationErrorMessage"
+	annotationVCLCompilationErrorDiagnostics      = "vclCompilationErrorDiagnostics"
+
+	// the compiler usually stops at the first error, the rest are most likely caused by it
+	maxVCLDiagnostics = 10
+	// leaves room for the "..." of truncated messages as events with messages over 1024 characters are rejected
+	maxEventMessageLength = 1021
+)
+
+var (
+	// the position of an error, e.g. ('/etc/varnish/entrypoint.vcl' Line 12 Pos 5)
+	vclDiagnosticLocationRegexp = regexp.MustCompile(`^\('?([^']*?)'? Line (\d+) Pos (\d+)\)`)
+	// the line under the source excerpt that marks the error, e.g. --------#######---
+	vclDiagnosticMarkerRegexp = regexp.MustCompile(`^[-#\s]*#[-#\s]*$`)
+	// the lines of the compiler output that are not part of the error messages
+	vclCompilerOutputNoise = []*regexp.Regexp{
+		regexp.MustCompile(`^Message from VCC-compiler:$`),
+		regexp.MustCompile(`^Running VCC-compiler failed`),
+		regexp.MustCompile(`^VCL compilation failed$`),
+		regexp.MustCompile(`^\(program line \d+\), at$`),
+		regexp.MustCompile(`^Command failed with error code \d+$`),
+		regexp.MustCompile(`included from`),
+	}
+)
+
+// vclCompilationError is the result of the last reload of a ConfigMap version that failed to compile
+type vclCompilationError struct {
+	configMapVersion string
+	message          string
+	diagnostics      []v1alpha1.VCLDiagnostic
+}
+
+// parseVCLDiagnostics extracts the errors from the VCC compiler output. Every error is a message followed by its
+// location and the source excerpt. If no locations are found, the whole output is returned as a single error.
+func parseVCLDiagnostics(out []byte) []v1alpha1.VCLDiagnostic {
+	var diagnostics []v1alpha1.VCLDiagnostic
+	var message []string
+	inExcerpt := false
+	for _, line := range strings.Split(string(out), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if inExcerpt {
+			// the excerpt ends with the error marker or an empty line
+			inExcerpt = trimmed != "" && !vclDiagnosticMarkerRegexp.MatchString(trimmed)
+			continue
+		}
+
+		if match := vclDiagnosticLocationRegexp.FindStringSubmatch(trimmed); match != nil {
+			inExcerpt = true
+			if len(message) == 0 {
+				// locations without a message are the include chain of the previous error
+				continue
+			}
+			lineNumber, _ := strconv.ParseInt(match[2], 10, 32)
+			column, _ := strconv.ParseInt(match[3], 10, 32)
+			diagnostics = append(diagnostics, v1alpha1.VCLDiagnostic{
+				File:    strings.TrimPrefix(match[1], config.VCLConfigDir+"/"),
+				Line:    int32(lineNumber),
+				Column:  int32(column),
+				Message: diagnosticMessage(message),
+			})
+			message = nil
+			continue
+		}
+
+		if trimmed == "" || isCompilerOutputNoise(trimmed) {
+			continue
+		}
+		message = append(message, trimmed)
+	}
+
+	if len(diagnostics) == 0 && len(message) > 0 {
+		diagnostics = append(diagnostics, v1alpha1.VCLDiagnostic{Message: diagnosticMessage(message)})
+	}
+	if len(diagnostics) > maxVCLDiagnostics {
+		diagnostics = diagnostics[:maxVCLDiagnostics]
+	}
+	return diagnostics
+}
+
+// diagnosticMessage joins the lines of the message, e.g. "Found: 'foo' at" is followed by the location
+func diagnosticMessage(lines []string) string {
+	message := strings.Join(lines, " ")
+	message = strings.TrimSuffix(message, " at")
+	return strings.TrimSuffix(message, ":")
+}
+
+func isCompilerOutputNoise(line string) bool {
+	for _, noise := range vclCompilerOutputNoise {
+		if noise.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// vclDiagnosticsSummary describes the first error for the events, e.g. entrypoint.vcl:12:5: Symbol not found: 'req.foo' (and 2 more errors)
+func vclDiagnosticsSummary(diagnostics []v1alpha1.VCLDiagnostic) string {
+	if len(diagnostics) == 0 {
+		return "unknown error"
+	}
+	summary := diagnostics[0].String()
+	if len(diagnostics) > 1 {
+		summary += " (and " + strconv.Itoa(len(diagnostics)-1) + " more errors)"
+	}
+	return summary
+}
+
+// marshalVCLDiagnostics encodes the diagnostics for a pod annotation
+func marshalVCLDiagnostics(diagnostics []v1alpha1.VCLDiagnostic) string {
+	if len(diagnostics) == 0 {
+		return ""
+	}
+	encoded, err := json.Marshal(diagnostics)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseVCLDiagnostics(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		expected []v1alpha1.VCLDiagnostic
+	}{
+		{
+			name: "symbol not found",
+			output: `Message from VCC-compiler:
+Symbol not found: 'req.foo' (expected type BOOL):
+('/etc/varnish/entrypoint.vcl' Line 12 Pos 9)
+    if (req.foo) {
+--------#######---
+
+Running VCC-compiler failed, exited with 2
+VCL compilation failed`,
+			expected: []v1alpha1.VCLDiagnostic{
+				{File: "entrypoint.vcl", Line: 12, Column: 9, Message: "Symbol not found: 'req.foo' (expected type BOOL)"},
+			},
+		},
+		{
+			name: "error in an included file",
+			output: `Message from VCC-compiler:
+Expected one of
+	'acl', 'sub', 'backend', 'probe', 'import', 'vcl',  or 'default'
+Found: 'foo' at
+('/etc/varnish/backends.vcl' Line 3 Pos 1)
+foo
+###
+
+-- ('/etc/varnish/entrypoint.vcl' Line 4 Pos 9) -- included from
+include "backends.vcl";
+        ##############
+
+Running VCC-compiler failed, exited with 2
+VCL compilation failed`,
+			expected: []v1alpha1.VCLDiagnostic{
+				{File: "backends.vcl", Line: 3, Column: 1, Message: "Expected one of 'acl', 'sub', 'backend', 'probe', 'import', 'vcl',  or 'default' Found: 'foo'"},
+			},
+		},
+		{
+			name: "multiple errors",
+			output: `Message from VCC-compiler:
+Unused backend default, defined:
+('/etc/varnish/entrypoint.vcl' Line 3 Pos 9)
+backend default {
+--------#######--
+
+Unused acl purge, defined:
+('/etc/varnish/entrypoint.vcl' Line 8 Pos 5)
+acl purge {
+----#####--
+
+Running VCC-compiler failed, exited with 2
+VCL compilation failed`,
+			expected: []v1alpha1.VCLDiagnostic{
+				{File: "entrypoint.vcl", Line: 3, Column: 9, Message: "Unused backend default, defined"},
+				{File: "entrypoint.vcl", Line: 8, Column: 5, Message: "Unused acl purge, defined"},
+			},
+		},
+		{
+			name: "no location",
+			output: `Message from VCC-compiler:
+Could not load VMOD nonexistent
+	File name: libvmod_nonexistent.so
+VCL compilation failed`,
+			expected: []v1alpha1.VCLDiagnostic{
+				{Message: "Could not load VMOD nonexistent File name: libvmod_nonexistent.so"},
+			},
+		},
+		{
+			name:     "empty output",
+			output:   "",
+			expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			g.Expect(parseVCLDiagnostics([]byte(c.output))).To(gomega.Equal(c.expected))
+		})
+	}
+}
+
+func TestParseVCLDiagnosticsLimit(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	out := strings.Repeat("Unused sub vcl_foo, defined:\n('/etc/varnish/entrypoint.vcl' Line 1 Pos 5)\nsub vcl_foo {}\n----#######---\n\n", 20)
+	diagnostics := parseVCLDiagnostics([]byte(out))
+	g.Expect(diagnostics).To(gomega.HaveLen(maxVCLDiagnostics))
+	g.Expect(vclDiagnosticsSummary(diagnostics)).To(gomega.Equal("entrypoint.vcl:1:5: Unused sub vcl_foo, defined (and 9 more errors)"))
+	g.Expect(vclDiagnosticsSummary(nil)).To(gomega.Equal("unknown error"))
+}
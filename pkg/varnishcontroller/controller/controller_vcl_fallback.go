@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// annotationRevertVCL is set by the user on the VarnishCluster to switch the pods running the VCL
+	// from the specified ConfigMap version back to the previous VCL
+	annotationRevertVCL = "revertVCL"
+	// annotationVCLRevertedConfigMapVersion marks the ConfigMap version the pod switched back from.
+	// That version is not applied again until the ConfigMap changes.
+	annotationVCLRevertedConfigMapVersion = "vclRevertedConfigMapVersion"
+
+	// a varnish panic during that period after a VCL is applied switches varnish back to the previous VCL
+	vclPanicWatchPeriod    = time.Minute
+	vclPanicCheckInterval  = 5 * time.Second
+	defaultVCLFallbackSize = 1
+)
+
+// vclPanicWatch tracks a just applied VCL that caused no panic yet
+type vclPanicWatch struct {
+	vclName string
+	until   time.Time
+}
+
+// reconcileVCLRevert switches varnish back to the previous VCL if the active VCL made varnish panic shortly after it has been applied,
+// or if the user requested it using the revertVCL annotation on the VarnishCluster. Returns true if varnish has been switched.
+func (r *ReconcileVarnish) reconcileVCLRevert(ctx context.Context, vc *v1alpha1.VarnishCluster, pod *v1.Pod, activeVCLName string) (bool, error) {
+	logr := logger.FromContext(ctx)
+	activeConfigMapVersion := extractConfigMapVersion(activeVCLName)
+	if !strings.HasPrefix(activeVCLName, VCLVersionPrefix) || activeConfigMapVersion == "" {
+		return false, nil
+	}
+
+	if vc.Annotations[annotationRevertVCL] == activeConfigMapVersion {
+		return r.switchToPreviousVCL(ctx, vc, pod, activeVCLName, "requested by the "+annotationRevertVCL+" annotation")
+	}
+
+	if r.panicWatch == nil {
+		return false, nil
+	}
+
+	if r.panicWatch.vclName != activeVCLName || time.Now().After(r.panicWatch.until) {
+		r.panicWatch = nil
+		return false, nil
+	}
+
+	panicMsg, err := r.varnish.PanicShow(ctx)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	if panicMsg == "" {
+		return false, nil
+	}
+
+	r.panicWatch = nil
+	logr.Warnw("Varnish panicked after the VCL has been applied", "panic", panicMsg)
+	return r.switchToPreviousVCL(ctx, vc, pod, activeVCLName, "varnish panicked after the VCL has been applied")
+}
+
+// switchToPreviousVCL switches varnish to the most recently loaded VCL that is not created from the ConfigMap version of the active VCL
+// and marks the pod so that version is not applied again. Returns false if there's no VCL to switch to.
+func (r *ReconcileVarnish) switchToPreviousVCL(ctx context.Context, vc *v1alpha1.VarnishCluster, pod *v1.Pod, activeVCLName, reason string) (bool, error) {
+	logr := logger.FromContext(ctx)
+	activeConfigMapVersion := extractConfigMapVersion(activeVCLName)
+	configsList, err := r.varnish.List(ctx)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	previousVCLName := previousVCLConfigName(configsList, activeConfigMapVersion, pod.Annotations[annotationVCLRevertedConfigMapVersion])
+	if previousVCLName == "" {
+		logr.Warnw("Can't switch back to the previous VCL as it's not available anymore. Keeping the current VCL", "reason", reason)
+		return false, nil
+	}
+
+	if out, err := r.varnish.Use(ctx, previousVCLName); err != nil {
+		return false, errors.Wrap(err, string(out))
+	}
+
+	msg := fmt.Sprintf("Switched back from VCL %s to %s: %s", activeVCLName, previousVCLName, reason)
+	logr.Infow(msg)
+	r.eventHandler.Warning(pod, events.EventReasonVCLReverted, msg)
+	r.eventHandler.Warning(vc, events.EventReasonVCLReverted, msg+". Pod: "+pod.Name)
+
+	podCopy := pod.DeepCopy()
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+	podCopy.Annotations[annotationConfigMapVersion] = extractConfigMapVersion(previousVCLName)
+	podCopy.Annotations[annotationActiveVCLConfigName] = previousVCLName
+	podCopy.Annotations[annotationVCLRevertedConfigMapVersion] = activeConfigMapVersion
+	// the user defined version of the previous VCL is not known anymore
+	delete(podCopy.Annotations, annotationVCLVersion)
+	delete(podCopy.Annotations, annotationVCLCanaryConfigMapVersion)
+	delete(podCopy.Annotations, annotationVCLCanaryErrorRate)
+	if err = r.Update(ctx, podCopy); err != nil {
+		return true, errors.Wrap(err, "failed to update pod")
+	}
+
+	return true, nil
+}
+
+// cleanupVCLs discards the VCLs created by the controller that are not needed anymore.
+// The latest previously active VCLs are kept loaded at the configured temperature to be able to switch back to them.
+func (r *ReconcileVarnish) cleanupVCLs(ctx context.Context, vc *v1alpha1.VarnishCluster, cm *v1.ConfigMap) error {
+	logr := logger.FromContext(ctx)
+	logr.Debugf("Cleaning up old VCL configs...")
+
+	keep, state := defaultVCLFallbackSize, varnishadm.VCLStateWarm
+	if vc.Spec.VCL.Fallback != nil {
+		if vc.Spec.VCL.Fallback.Count != nil {
+			keep = int(*vc.Spec.VCL.Fallback.Count)
+		}
+		if vc.Spec.VCL.Fallback.Temperature == v1alpha1.VCLFallbackTemperatureCold {
+			state = varnishadm.VCLStateCold
+		}
+	}
+
+	// the previous VCL is needed to switch back if the canary rollout is aborted
+	if keep < 1 && r.vclCanaryInProgress(vc, cm) {
+		keep = 1
+	}
+
+	configsList, err := r.varnish.List(ctx)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// only VCLs created by varnish controller (those that start with our prefix) are managed
+	var available []varnishadm.VCLConfig
+	for _, vclConfig := range configsList {
+		if vclConfig.Status == varnishadm.VCLStatusAvailable && strings.HasPrefix(vclConfig.Name, VCLVersionPrefix) {
+			available = append(available, vclConfig)
+		}
+	}
+
+	// the most recently loaded first
+	sort.SliceStable(available, func(i, j int) bool {
+		return vclLoadTime(available[i].Name) > vclLoadTime(available[j].Name)
+	})
+
+	// the ConfigMap versions of the VCLs that stay loaded
+	loadedVersions := make(map[string]bool)
+	for _, vclConfig := range configsList {
+		if vclConfig.Status == varnishadm.VCLStatusActive {
+			loadedVersions[extractConfigMapVersion(vclConfig.Name)] = true
+		}
+	}
+
+	cleanedUpVCLs := 0
+	for i, vclConfig := range available {
+		if i < keep {
+			loadedVersions[extractConfigMapVersion(vclConfig.Name)] = true
+			if vclConfig.State != state {
+				if err := r.varnish.SetState(ctx, vclConfig.Name, state); err != nil {
+					logr.Error(fmt.Sprintf("Can't set state of VCL config %q", vclConfig.Name), zap.Error(err))
+				}
+			}
+			continue
+		}
+
+		if err := r.varnish.Discard(ctx, vclConfig.Name); err != nil {
+			logr.Error(fmt.Sprintf("Can't delete VCL config %q", vclConfig.Name), zap.Error(err))
+		} else {
+			cleanedUpVCLs++
+		}
+	}
+
+	loaded := 0
+	for _, vclConfig := range configsList {
+		if vclConfig.Status != varnishadm.VCLStatusDiscarded {
+			loaded++
+		}
+	}
+	r.metrics.SetVCLs(loaded-cleanedUpVCLs, cleanedUpVCLs)
+
+	for version := range r.appliedConfigMaps {
+		if !loadedVersions[version] {
+			delete(r.appliedConfigMaps, version)
+		}
+	}
+
+	logr.Debugf("Cleaned up %d VCL config(s), kept %d", cleanedUpVCLs, len(available)-cleanedUpVCLs)
+	return nil
+}
+
+// previousVCLConfigName returns the most recently loaded VCL that is not created from any of the given ConfigMap versions
+func previousVCLConfigName(configsList []varnishadm.VCLConfig, excludedConfigMapVersions ...string) string {
+	var previous string
+	for _, vclConfig := range configsList {
+		if !strings.HasPrefix(vclConfig.Name, VCLVersionPrefix) || vclConfig.Status == varnishadm.VCLStatusDiscarded ||
+			vclLoadTime(vclConfig.Name) == 0 || stringInSlice(extractConfigMapVersion(vclConfig.Name), excludedConfigMapVersions) {
+			continue
+		}
+
+		if previous == "" || vclLoadTime(vclConfig.Name) > vclLoadTime(previous) {
+			previous = vclConfig.Name
+		}
+	}
+
+	return previous
+}
+
+// returns the time the VCL has been loaded at as encoded in the VCL config name. 0 if not known.
+func vclLoadTime(vclConfigName string) int64 {
+	parts := strings.Split(vclConfigName, "-")
+	loadTime, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return loadTime
+}
+
+func stringInSlice(s string, slice []string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCleanupVCLs(t *testing.T) {
+	configsList := []varnishadm.VCLConfig{
+		{Name: "boot", Status: varnishadm.VCLStatusAvailable, State: varnishadm.VCLStateAuto},
+		{Name: "v-1-1561381100", Status: varnishadm.VCLStatusAvailable, State: varnishadm.VCLStateAuto},
+		{Name: "v-2-1561381200", Status: varnishadm.VCLStatusAvailable, State: varnishadm.VCLStateWarm},
+		{Name: "v-3-1561381300", Status: varnishadm.VCLStatusAvailable, State: varnishadm.VCLStateAuto},
+		{Name: "v-4-1561381400", Status: varnishadm.VCLStatusActive, State: varnishadm.VCLStateAuto},
+	}
+
+	cases := []struct {
+		name                 string
+		fallback             *v1alpha1.VarnishClusterVCLFallback
+		expectedDiscarded    []string
+		expectedStateChanges map[string]string
+		expectedVersions     []string
+	}{
+		{
+			name:                 "defaults",
+			expectedDiscarded:    []string{"v-2-1561381200", "v-1-1561381100"},
+			expectedStateChanges: map[string]string{"v-3-1561381300": varnishadm.VCLStateWarm},
+			expectedVersions:     []string{"3", "4"},
+		},
+		{
+			name:                 "keep two cold VCLs",
+			fallback:             &v1alpha1.VarnishClusterVCLFallback{Count: proto.Int32(2), Temperature: v1alpha1.VCLFallbackTemperatureCold},
+			expectedDiscarded:    []string{"v-1-1561381100"},
+			expectedStateChanges: map[string]string{"v-3-1561381300": varnishadm.VCLStateCold, "v-2-1561381200": varnishadm.VCLStateCold},
+			expectedVersions:     []string{"2", "3", "4"},
+		},
+		{
+			name:              "keep none",
+			fallback:          &v1alpha1.VarnishClusterVCLFallback{Count: proto.Int32(0)},
+			expectedDiscarded: []string{"v-3-1561381300", "v-2-1561381200", "v-1-1561381100"},
+			expectedVersions:  []string{"4"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		varnish := &varnishMock{listResponse: configsList}
+		r := &ReconcileVarnish{varnish: varnish, config: &config.Config{PodName: "varnish-0"}, appliedConfigMaps: map[string]*v1.ConfigMap{}}
+		for _, version := range []string{"1", "2", "3", "4"} {
+			r.appliedConfigMaps[version] = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: version}}
+		}
+		vc := &v1alpha1.VarnishCluster{Spec: v1alpha1.VarnishClusterSpec{VCL: &v1alpha1.VarnishClusterVCL{Fallback: c.fallback}}}
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "4"}}
+
+		g.Expect(r.cleanupVCLs(context.Background(), vc, cm)).To(gomega.Succeed())
+		g.Expect(varnish.discarded).To(gomega.Equal(c.expectedDiscarded))
+		g.Expect(varnish.stateChanges).To(gomega.Equal(c.expectedStateChanges))
+		// only the ConfigMap versions of the loaded VCLs are kept
+		var versions []string
+		for version := range r.appliedConfigMaps {
+			versions = append(versions, version)
+		}
+		g.Expect(versions).To(gomega.ConsistOf(c.expectedVersions))
+	}
+}
+
+func TestReconcileVCLRevert(t *testing.T) {
+	configsList := []varnishadm.VCLConfig{
+		{Name: "boot", Status: varnishadm.VCLStatusAvailable},
+		{Name: "v-1-1561381100", Status: varnishadm.VCLStatusAvailable},
+		{Name: "v-2-1561381200", Status: varnishadm.VCLStatusActive},
+	}
+
+	cases := []struct {
+		name            string
+		configs         []varnishadm.VCLConfig
+		vcAnnotations   map[string]string
+		panicWatch      *vclPanicWatch
+		panicResponse   string
+		activeVCLName   string
+		expectReverted  bool
+		expectWatchKept bool
+	}{
+		{
+			name:          "nothing to revert",
+			activeVCLName: "v-2-1561381200",
+		},
+		{
+			name:           "revert requested",
+			vcAnnotations:  map[string]string{annotationRevertVCL: "2"},
+			activeVCLName:  "v-2-1561381200",
+			expectReverted: true,
+		},
+		{
+			name:          "revert requested for an another version",
+			vcAnnotations: map[string]string{annotationRevertVCL: "1"},
+			activeVCLName: "v-2-1561381200",
+		},
+		{
+			name:           "varnish panicked after the VCL has been applied",
+			panicWatch:     &vclPanicWatch{vclName: "v-2-1561381200", until: time.Now().Add(time.Minute)},
+			panicResponse:  "Last panic at: Tue, 20 Jan 2023 11:01:24 GMT\nAssert error in VRT_...",
+			activeVCLName:  "v-2-1561381200",
+			expectReverted: true,
+		},
+		{
+			name:            "no panic yet",
+			panicWatch:      &vclPanicWatch{vclName: "v-2-1561381200", until: time.Now().Add(time.Minute)},
+			activeVCLName:   "v-2-1561381200",
+			expectWatchKept: true,
+		},
+		{
+			name:          "panic after the watch period",
+			panicWatch:    &vclPanicWatch{vclName: "v-2-1561381200", until: time.Now().Add(-time.Second)},
+			panicResponse: "Last panic at: Tue, 20 Jan 2023 11:01:24 GMT\nAssert error in VRT_...",
+			activeVCLName: "v-2-1561381200",
+		},
+		{
+			name:          "no previous VCL",
+			configs:       []varnishadm.VCLConfig{{Name: "boot", Status: varnishadm.VCLStatusAvailable}, {Name: "v-2-1561381200", Status: varnishadm.VCLStatusActive}},
+			vcAnnotations: map[string]string{annotationRevertVCL: "2"},
+			activeVCLName: "v-2-1561381200",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		if c.configs == nil {
+			c.configs = configsList
+		}
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default"}}
+		vc := &v1alpha1.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Annotations: c.vcAnnotations},
+			Spec:       v1alpha1.VarnishClusterSpec{VCL: &v1alpha1.VarnishClusterVCL{}},
+		}
+		events := &eventsObserver{}
+		r := &ReconcileVarnish{
+			Client:       fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build(),
+			config:       &config.Config{PodName: "varnish-0", Namespace: "default"},
+			logger:       logger.NewNopLogger(),
+			varnish:      &varnishMock{listResponse: c.configs, panicResponse: c.panicResponse},
+			eventHandler: &varnishEvents.EventHandler{Recorder: events},
+			panicWatch:   c.panicWatch,
+		}
+
+		reverted, err := r.reconcileVCLRevert(context.Background(), vc, pod, c.activeVCLName)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(reverted).To(gomega.Equal(c.expectReverted))
+		g.Expect(events.eventsObserved).To(gomega.Equal(c.expectReverted))
+		g.Expect(r.panicWatch != nil).To(gomega.Equal(c.expectWatchKept))
+
+		updatedPod := &v1.Pod{}
+		g.Expect(r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "varnish-0"}, updatedPod)).To(gomega.Succeed())
+		if c.expectReverted {
+			g.Expect(updatedPod.Annotations[annotationActiveVCLConfigName]).To(gomega.Equal("v-1-1561381100"))
+			g.Expect(updatedPod.Annotations[annotationVCLRevertedConfigMapVersion]).To(gomega.Equal("2"))
+		} else {
+			g.Expect(updatedPod.Annotations).To(gomega.BeEmpty())
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+)
+
+// pendingVCLReload holds the changes that are collected to be applied with a single reload
+type pendingVCLReload struct {
+	files map[string]string
+	// when the first and the latest of the collected changes have been seen
+	since      time.Time
+	lastChange time.Time
+}
+
+// vclReloadDelay returns how long the reload of the changed files has to wait for more changes, or 0 if they have
+// to be applied now. The first load, a resync requested by the operator and the removal of backends are not delayed,
+// so varnish doesn't keep sending requests to the backends that are gone.
+func (r *ReconcileVarnish) vclReloadDelay(vc *v1alpha1.VarnishCluster, newFiles map[string]string, backends map[string]bool, immediate bool, now time.Time) time.Duration {
+	if immediate || backendsRemoved(r.appliedBackends, backends) {
+		r.pendingReload = nil
+		return 0
+	}
+
+	if r.pendingReload == nil {
+		r.pendingReload = &pendingVCLReload{files: newFiles, since: now, lastChange: now}
+	} else if !sameFiles(r.pendingReload.files, newFiles) {
+		r.pendingReload.files = newFiles
+		r.pendingReload.lastChange = now
+	}
+
+	debounce, maxDelay, minInterval := vclReloadSettings(vc)
+	due := r.pendingReload.lastChange.Add(debounce)
+	if limit := r.pendingReload.since.Add(maxDelay); limit.Before(due) {
+		due = limit
+	}
+	if earliest := r.lastReloadAttempt.Add(minInterval); earliest.After(due) {
+		due = earliest
+	}
+
+	if now.Before(due) {
+		return due.Sub(now)
+	}
+	r.pendingReload = nil
+	return 0
+}
+
+func vclReloadSettings(vc *v1alpha1.VarnishCluster) (debounce, maxDelay, minInterval time.Duration) {
+	reload := vc.Spec.VCL.Reload
+	if reload == nil {
+		return 0, 0, 0
+	}
+	seconds := func(value *int32) time.Duration {
+		if value == nil {
+			return 0
+		}
+		return time.Duration(*value) * time.Second
+	}
+	return seconds(reload.DebounceSeconds), seconds(reload.MaxDelaySeconds), seconds(reload.MinIntervalSeconds)
+}
+
+// backendAddresses returns the addresses of all backends and varnish pods the VCL is rendered with
+func backendAddresses(backends []PodInfo, groups map[string]BackendGroupInfo, varnishNodes []PodInfo) map[string]bool {
+	addresses := make(map[string]bool)
+	add := func(pods []PodInfo) {
+		for _, pod := range pods {
+			addresses[net.JoinHostPort(pod.IP, strconv.Itoa(int(pod.Port)))] = true
+		}
+	}
+	add(backends)
+	for _, group := range groups {
+		add(group.Backends)
+	}
+	add(varnishNodes)
+	return addresses
+}
+
+// backendsRemoved returns true if any of the backends of the applied VCL is not discovered anymore.
+// Nothing is known about the applied VCL after the container restarted, so no backends are considered removed then.
+func backendsRemoved(applied, current map[string]bool) bool {
+	for address := range applied {
+		if !current[address] {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFiles(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, contents := range a {
+		if other, found := b[name]; !found || other != contents {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+)
+
+func TestVCLReloadDelay(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	vc := &v1alpha1.VarnishCluster{Spec: v1alpha1.VarnishClusterSpec{VCL: &v1alpha1.VarnishClusterVCL{Reload: &v1alpha1.VarnishClusterVCLReload{
+		DebounceSeconds:    proto.Int32(2),
+		MaxDelaySeconds:    proto.Int32(5),
+		MinIntervalSeconds: proto.Int32(10),
+	}}}}
+	files := func(backends string) map[string]string {
+		return map[string]string{"backends.vcl": backends}
+	}
+	backends := map[string]bool{"10.0.0.1:8080": true}
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	r := &ReconcileVarnish{}
+	// first load
+	g.Expect(r.vclReloadDelay(vc, files("a"), backends, true, at(0))).To(gomega.BeZero())
+	r.lastReloadAttempt, r.appliedBackends = at(0), backends
+
+	// the minimum interval since the last reload
+	added := map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true}
+	g.Expect(r.vclReloadDelay(vc, files("ab"), added, false, at(1))).To(gomega.Equal(9 * time.Second))
+	g.Expect(r.vclReloadDelay(vc, files("ab"), added, false, at(10))).To(gomega.BeZero())
+	g.Expect(r.pendingReload).To(gomega.BeNil())
+	r.lastReloadAttempt, r.appliedBackends = at(10), added
+
+	// changes are debounced, but not longer than the max delay
+	added = map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true, "10.0.0.3:8080": true}
+	g.Expect(r.vclReloadDelay(vc, files("abc"), added, false, at(20))).To(gomega.Equal(2 * time.Second))
+	g.Expect(r.vclReloadDelay(vc, files("abc"), added, false, at(21))).To(gomega.Equal(time.Second))
+	added["10.0.0.4:8080"] = true
+	g.Expect(r.vclReloadDelay(vc, files("abcd"), added, false, at(21))).To(gomega.Equal(2 * time.Second))
+	added["10.0.0.5:8080"] = true
+	g.Expect(r.vclReloadDelay(vc, files("abcde"), added, false, at(24))).To(gomega.Equal(time.Second))
+	g.Expect(r.vclReloadDelay(vc, files("abcde"), added, false, at(25))).To(gomega.BeZero())
+	r.lastReloadAttempt, r.appliedBackends = at(25), added
+
+	// removed backends are applied without waiting
+	g.Expect(r.vclReloadDelay(vc, files("ab"), map[string]bool{"10.0.0.1:8080": true, "10.0.0.2:8080": true}, false, at(26))).To(gomega.BeZero())
+
+	// not delayed if not configured
+	r = &ReconcileVarnish{lastReloadAttempt: at(0)}
+	g.Expect(r.vclReloadDelay(&v1alpha1.VarnishCluster{Spec: v1alpha1.VarnishClusterSpec{VCL: &v1alpha1.VarnishClusterVCL{}}}, files("a"), backends, false, at(0))).To(gomega.BeZero())
+}
+
+func TestBackendAddresses(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	addresses := backendAddresses(
+		[]PodInfo{{IP: "10.0.0.1", Port: 8080}},
+		map[string]BackendGroupInfo{"api": {Name: "api", Backends: []PodInfo{{IP: "10.0.1.1", Port: 9090}}}},
+		[]PodInfo{{IP: "fd00::1", Port: 6081}},
+	)
+	g.Expect(addresses).To(gomega.Equal(map[string]bool{"10.0.0.1:8080": true, "10.0.1.1:9090": true, "[fd00::1]:6081": true}))
+	g.Expect(backendsRemoved(nil, addresses)).To(gomega.BeFalse())
+	g.Expect(backendsRemoved(map[string]bool{"10.0.0.1:8080": true}, addresses)).To(gomega.BeFalse())
+	g.Expect(backendsRemoved(map[string]bool{"10.0.0.2:8080": true}, addresses)).To(gomega.BeTrue())
+}
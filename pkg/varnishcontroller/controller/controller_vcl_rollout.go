@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	annotationVCLCanaryConfigMapVersion = "vclCanaryConfigMapVersion"
+	annotationVCLCanaryErrorRate        = "vclCanaryErrorRate"
+
+	// how often canary pods report their error rate during the bake time
+	vclCanaryReportInterval = 15 * time.Second
+)
+
+// canaryCounters are the varnish counters collected when the pod switched to the canary VCL.
+// The error rate is calculated from the difference between them and the current counters.
+type canaryCounters struct {
+	configMapVersion string
+	counters         map[string]uint64
+}
+
+// vclRolloutAllowed returns true if the pod can apply the current ConfigMap version according to the VCL rollout strategy.
+// During the canary phase only the canary pods and the pods that don't have any VCL loaded yet get the new version.
+func (r *ReconcileVarnish) vclRolloutAllowed(ctx context.Context, vc *v1alpha1.VarnishCluster, cm *v1.ConfigMap, activeVCLName string) bool {
+	if !canaryRolloutEnabled(vc) {
+		return true
+	}
+
+	logr := logger.FromContext(ctx)
+	rollout := vc.Status.VCL.Rollout
+	if rollout == nil || rollout.ConfigMapVersion != cm.GetResourceVersion() {
+		logr.Debugw("Waiting for the canary rollout to start", "configMapVersion", cm.GetResourceVersion())
+		return false
+	}
+
+	switch rollout.Phase {
+	case v1alpha1.VCLRolloutPhasePromoted:
+		return true
+	case v1alpha1.VCLRolloutPhaseCanary:
+		if isCanaryPod(rollout, r.config.PodName) || activeVCLName == "boot" {
+			return true
+		}
+		logr.Debugw("Waiting for the canary rollout to be promoted", "configMapVersion", cm.GetResourceVersion())
+	}
+
+	return false
+}
+
+// revertVCLCanary switches a canary pod back to the VCL it ran before the aborted ConfigMap version. Returns true if varnish has been switched.
+func (r *ReconcileVarnish) revertVCLCanary(ctx context.Context, vc *v1alpha1.VarnishCluster, pod *v1.Pod, cm *v1.ConfigMap, activeVCLName string) (bool, error) {
+	if !canaryRolloutEnabled(vc) || vc.Status.VCL.Rollout == nil || vc.Status.VCL.Rollout.Phase != v1alpha1.VCLRolloutPhaseAborted ||
+		vc.Status.VCL.Rollout.ConfigMapVersion != cm.GetResourceVersion() || extractConfigMapVersion(activeVCLName) != cm.GetResourceVersion() {
+		return false, nil
+	}
+
+	switched, err := r.switchToPreviousVCL(ctx, vc, pod, activeVCLName, "canary rollout has been aborted")
+	return switched, errors.WithStack(err)
+}
+
+// reconcileVCLCanaryHealth reports the error rate of a canary pod during the bake time, so the operator can abort the rollout.
+// The error rate is the percentage of failed backend fetches relative to client requests since the pod switched to the new VCL.
+func (r *ReconcileVarnish) reconcileVCLCanaryHealth(ctx context.Context, vc *v1alpha1.VarnishCluster, cm *v1.ConfigMap) (reconcile.Result, error) {
+	if !r.vclCanaryInProgress(vc, cm) || vc.Spec.VCL.RolloutStrategy.Canary.MaxErrorRatePercent == nil {
+		r.canaryCounters = nil
+		return reconcile.Result{}, nil
+	}
+
+	logr := logger.FromContext(ctx)
+	activeVCLName, err := r.varnish.GetActiveConfigurationName(ctx)
+	if err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	if extractConfigMapVersion(activeVCLName) != cm.GetResourceVersion() {
+		logr.Debugw("Canary VCL is not active yet. Not reporting the error rate")
+		return reconcile.Result{}, nil
+	}
+
+	counters, err := r.varnishStat.Counters(varnishstat.CounterClientRequests, varnishstat.CounterFetchFailed)
+	if err != nil {
+		logr.Warnw("Can't read varnish counters to report the canary error rate", zap.Error(err))
+		return reconcile.Result{RequeueAfter: vclCanaryReportInterval}, nil
+	}
+
+	if r.canaryCounters == nil || r.canaryCounters.configMapVersion != cm.GetResourceVersion() {
+		r.canaryCounters = &canaryCounters{configMapVersion: cm.GetResourceVersion(), counters: counters}
+	}
+
+	errorRate := canaryErrorRate(r.canaryCounters.counters, counters)
+
+	pod := &v1.Pod{}
+	if err = r.Get(ctx, types.NamespacedName{Namespace: r.config.Namespace, Name: r.config.PodName}, pod); err != nil {
+		return reconcile.Result{}, errors.WithStack(err)
+	}
+
+	podCopy := pod.DeepCopy()
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+	podCopy.Annotations[annotationVCLCanaryConfigMapVersion] = cm.GetResourceVersion()
+	podCopy.Annotations[annotationVCLCanaryErrorRate] = strconv.FormatFloat(errorRate, 'f', 2, 64)
+	if podCopy.Annotations[annotationVCLCanaryErrorRate] != pod.Annotations[annotationVCLCanaryErrorRate] ||
+		podCopy.Annotations[annotationVCLCanaryConfigMapVersion] != pod.Annotations[annotationVCLCanaryConfigMapVersion] {
+		logr.Debugf("Canary error rate: %.2f%%", errorRate)
+		if err = r.Update(ctx, podCopy); err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "failed to update pod with the canary error rate")
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: vclCanaryReportInterval}, nil
+}
+
+// vclCanaryInProgress returns true if the pod is a canary for the current ConfigMap version and the canary hasn't been promoted yet
+func (r *ReconcileVarnish) vclCanaryInProgress(vc *v1alpha1.VarnishCluster, cm *v1.ConfigMap) bool {
+	if !canaryRolloutEnabled(vc) {
+		return false
+	}
+
+	rollout := vc.Status.VCL.Rollout
+	return rollout != nil &&
+		rollout.Phase == v1alpha1.VCLRolloutPhaseCanary &&
+		rollout.ConfigMapVersion == cm.GetResourceVersion() &&
+		isCanaryPod(rollout, r.config.PodName)
+}
+
+func canaryRolloutEnabled(vc *v1alpha1.VarnishCluster) bool {
+	return vc.Spec.VCL.RolloutStrategy != nil &&
+		vc.Spec.VCL.RolloutStrategy.Type == v1alpha1.VCLRolloutStrategyCanary &&
+		vc.Spec.VCL.RolloutStrategy.Canary != nil
+}
+
+func isCanaryPod(rollout *v1alpha1.VCLRolloutStatus, podName string) bool {
+	for _, canaryPod := range rollout.CanaryPods {
+		if canaryPod == podName {
+			return true
+		}
+	}
+	return false
+}
+
+func canaryErrorRate(baseline, current map[string]uint64) float64 {
+	requests := current[varnishstat.CounterClientRequests] - baseline[varnishstat.CounterClientRequests]
+	failures := current[varnishstat.CounterFetchFailed] - baseline[varnishstat.CounterFetchFailed]
+	// counters are reset if varnish restarted
+	if current[varnishstat.CounterClientRequests] < baseline[varnishstat.CounterClientRequests] ||
+		current[varnishstat.CounterFetchFailed] < baseline[varnishstat.CounterFetchFailed] || requests == 0 {
+		return 0
+	}
+
+	return float64(failures) / float64(requests) * 100
+}
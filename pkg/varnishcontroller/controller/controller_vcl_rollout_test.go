@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestVCLRolloutAllowed(t *testing.T) {
+	cases := []struct {
+		name          string
+		rollout       *v1alpha1.VCLRolloutStatus
+		activeVCLName string
+		expected      bool
+	}{
+		{
+			name:     "rollout not started yet",
+			expected: false,
+		},
+		{
+			name:     "rollout started for an another version",
+			rollout:  &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "1", Phase: v1alpha1.VCLRolloutPhasePromoted},
+			expected: false,
+		},
+		{
+			name:     "canary pod",
+			rollout:  &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-0"}},
+			expected: true,
+		},
+		{
+			name:          "not a canary pod",
+			rollout:       &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-1"}},
+			activeVCLName: "v-1-1561381196",
+			expected:      false,
+		},
+		{
+			name:          "pod without loaded VCL",
+			rollout:       &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-1"}},
+			activeVCLName: "boot",
+			expected:      true,
+		},
+		{
+			name:     "promoted",
+			rollout:  &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhasePromoted, CanaryPods: []string{"varnish-1"}},
+			expected: true,
+		},
+		{
+			name:     "aborted",
+			rollout:  &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseAborted, CanaryPods: []string{"varnish-0"}},
+			expected: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+		vc := &v1alpha1.VarnishCluster{
+			Spec: v1alpha1.VarnishClusterSpec{
+				VCL: &v1alpha1.VarnishClusterVCL{
+					RolloutStrategy: &v1alpha1.VarnishClusterVCLRolloutStrategy{
+						Type:   v1alpha1.VCLRolloutStrategyCanary,
+						Canary: &v1alpha1.VarnishClusterVCLCanaryRollout{},
+					},
+				},
+			},
+			Status: v1alpha1.VarnishClusterStatus{VCL: v1alpha1.VCLStatus{Rollout: c.rollout}},
+		}
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}}
+		r := &ReconcileVarnish{config: &config.Config{PodName: "varnish-0"}}
+		g.Expect(r.vclRolloutAllowed(context.Background(), vc, cm, c.activeVCLName)).To(gomega.Equal(c.expected))
+	}
+}
+
+func TestRevertVCLCanary(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default", Annotations: map[string]string{
+		annotationConfigMapVersion:    "2",
+		annotationActiveVCLConfigName: "v-2-1561381300",
+		annotationVCLVersion:          "v2.0",
+	}}}
+	vc := &v1alpha1.VarnishCluster{
+		Spec: v1alpha1.VarnishClusterSpec{
+			VCL: &v1alpha1.VarnishClusterVCL{
+				RolloutStrategy: &v1alpha1.VarnishClusterVCLRolloutStrategy{
+					Type:   v1alpha1.VCLRolloutStrategyCanary,
+					Canary: &v1alpha1.VarnishClusterVCLCanaryRollout{},
+				},
+			},
+		},
+		Status: v1alpha1.VarnishClusterStatus{VCL: v1alpha1.VCLStatus{Rollout: &v1alpha1.VCLRolloutStatus{
+			ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseAborted, CanaryPods: []string{"varnish-0"},
+		}}},
+	}
+	cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}}
+	varnish := &varnishMock{listResponse: []varnishadm.VCLConfig{
+		{Name: "boot", Status: varnishadm.VCLStatusAvailable},
+		{Name: "v-0-1561381100", Status: varnishadm.VCLStatusDiscarded},
+		{Name: "v-1-1561381200", Status: varnishadm.VCLStatusAvailable},
+		{Name: "v-2-1561381300", Status: varnishadm.VCLStatusActive},
+	}}
+	events := &eventsObserver{}
+	r := &ReconcileVarnish{
+		Client:       fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build(),
+		config:       &config.Config{PodName: "varnish-0", Namespace: "default"},
+		logger:       logger.NewNopLogger(),
+		varnish:      varnish,
+		eventHandler: &varnishEvents.EventHandler{Recorder: events},
+	}
+
+	switched, err := r.revertVCLCanary(context.Background(), vc, pod, cm, "v-2-1561381300")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(switched).To(gomega.BeTrue())
+	g.Expect(events.eventsObserved).To(gomega.BeTrue())
+
+	updatedPod := &v1.Pod{}
+	g.Expect(r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "varnish-0"}, updatedPod)).To(gomega.Succeed())
+	g.Expect(updatedPod.Annotations).To(gomega.Equal(map[string]string{
+		annotationConfigMapVersion:            "1",
+		annotationActiveVCLConfigName:         "v-1-1561381200",
+		annotationVCLRevertedConfigMapVersion: "2",
+	}))
+}
+
+func TestCanaryErrorRate(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	baseline := map[string]uint64{varnishstat.CounterClientRequests: 1000, varnishstat.CounterFetchFailed: 10}
+
+	g.Expect(canaryErrorRate(baseline, map[string]uint64{varnishstat.CounterClientRequests: 1200, varnishstat.CounterFetchFailed: 20})).To(gomega.Equal(float64(5)))
+	g.Expect(canaryErrorRate(baseline, baseline)).To(gomega.Equal(float64(0)))
+	// varnish restarted and the counters have been reset
+	g.Expect(canaryErrorRate(baseline, map[string]uint64{varnishstat.CounterClientRequests: 10, varnishstat.CounterFetchFailed: 5})).To(gomega.Equal(float64(0)))
+}
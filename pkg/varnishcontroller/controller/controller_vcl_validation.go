@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishadm"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// VCLValidationPrefix is prepended to the names of VCLs loaded only to check if they compile.
+	// Those VCLs are discarded right after the check.
+	VCLValidationPrefix = "preflight-"
+
+	annotationVCLValidationConfigMapVersion = "vclValidationConfigMapVersion"
+	annotationVCLValidationPhase            = "vclValidationPhase"
+	annotationVCLValidationMessage          = "vclValidationMessage"
+	annotationVCLValidationDiagnostics      = "vclValidationDiagnostics"
+
+	// annotations are limited in size, so keep only the beginning of the compiler output
+	maxVCLValidationMessageLength = 2048
+)
+
+// reconcileVCLValidation returns true if the current ConfigMap version passed the pre-flight check and can be applied.
+// The check itself is done only by the pod the operator selected in the VarnishCluster status. The rest of the pods
+// keep running the previous VCL until the operator reports the check succeeded.
+func (r *ReconcileVarnish) reconcileVCLValidation(ctx context.Context, dir string, vc *v1alpha1.VarnishCluster, pod *v1.Pod, cm *v1.ConfigMap, currFiles, newFiles map[string]string) (bool, error) {
+	logr := logger.FromContext(ctx)
+	validation := vc.Status.VCL.Validation
+	if validation == nil || validation.ConfigMapVersion != cm.GetResourceVersion() {
+		logr.Debugw("Pre-flight VCL check is not scheduled yet for the ConfigMap version", "configMapVersion", cm.GetResourceVersion())
+		return false, nil
+	}
+
+	switch validation.Phase {
+	case v1alpha1.VCLValidationPhaseSucceeded:
+		return true, nil
+	case v1alpha1.VCLValidationPhaseFailed:
+		logr.Debugw("ConfigMap version failed the pre-flight VCL check. Keeping the current VCL", "configMapVersion", cm.GetResourceVersion())
+		return false, nil
+	}
+
+	if validation.Pod != r.config.PodName {
+		logr.Debugw("Waiting for the pre-flight VCL check to finish", "validatorPod", validation.Pod)
+		return false, nil
+	}
+
+	if pod.Annotations[annotationVCLValidationConfigMapVersion] == cm.GetResourceVersion() {
+		logr.Debugw("Pre-flight VCL check is done. Waiting for the operator to pick up the result")
+		return false, nil
+	}
+
+	phase, message, err := r.validateVCL(ctx, dir, *vc.Spec.VCL.EntrypointFileName, cm, currFiles, newFiles)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	var diagnostics []v1alpha1.VCLDiagnostic
+	if phase == v1alpha1.VCLValidationPhaseFailed {
+		diagnostics = parseVCLDiagnostics([]byte(message))
+		eventMsg := "VCL from ConfigMap version " + cm.GetResourceVersion() + " failed the pre-flight compilation check: " + vclDiagnosticsSummary(diagnostics)
+		r.eventHandler.Warning(vc, events.EventReasonVCLValidationError, truncate(eventMsg, maxEventMessageLength))
+		logr.Warnw(message)
+	} else {
+		logr.Infow("VCL passed the pre-flight compilation check", "configMapVersion", cm.GetResourceVersion())
+	}
+
+	podCopy := pod.DeepCopy()
+	if podCopy.Annotations == nil {
+		podCopy.Annotations = make(map[string]string)
+	}
+	podCopy.Annotations[annotationVCLValidationConfigMapVersion] = cm.GetResourceVersion()
+	podCopy.Annotations[annotationVCLValidationPhase] = phase
+	podCopy.Annotations[annotationVCLValidationMessage] = truncate(message, maxVCLValidationMessageLength)
+	if encoded := marshalVCLDiagnostics(diagnostics); encoded != "" {
+		podCopy.Annotations[annotationVCLValidationDiagnostics] = encoded
+	} else {
+		delete(podCopy.Annotations, annotationVCLValidationDiagnostics)
+	}
+	if !reflect.DeepEqual(pod.Annotations, podCopy.Annotations) {
+		if err = r.Update(ctx, podCopy); err != nil {
+			return false, errors.Wrap(err, "failed to update pod with pre-flight VCL check results")
+		}
+	}
+
+	return false, nil
+}
+
+// applicableConfigMap returns the ConfigMap version the pod should run. That's the current version once it passed the pre-flight check
+// and the rollout strategy allows the pod to apply it. Until then the pod keeps running the version of the active VCL, rendered with
+// the current backends, so the changes of the backends are still applied. That's also the case after the pod has been switched back
+// from the current version. Returns nil if the pod has to wait, e.g. because the version of the active VCL is not known or the pod has
+// just been switched back from an aborted canary version.
+func (r *ReconcileVarnish) applicableConfigMap(ctx context.Context, dir string, vc *v1alpha1.VarnishCluster, pod *v1.Pod, cm *v1.ConfigMap, activeVCLName string, currFiles, newFiles map[string]string) (*v1.ConfigMap, error) {
+	logr := logger.FromContext(ctx)
+	// varnish has been switched back from that version, so don't apply it again unless varnish has restarted and has no VCL loaded
+	if pod.Annotations[annotationVCLRevertedConfigMapVersion] == cm.GetResourceVersion() && activeVCLName != "boot" {
+		logr.Debugw("ConfigMap version has been reverted. Waiting for a new version", "configMapVersion", cm.GetResourceVersion())
+	} else {
+		applyAllowed, err := r.reconcileVCLValidation(ctx, dir, vc, pod, cm, currFiles, newFiles)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if applyAllowed {
+			if r.vclRolloutAllowed(ctx, vc, cm, activeVCLName) {
+				return cm, nil
+			}
+			switched, err := r.revertVCLCanary(ctx, vc, pod, cm, activeVCLName)
+			if err != nil || switched {
+				// the pod update triggers an another reconcile
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+
+	running, err := r.runningConfigMap(ctx, vc, activeVCLName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch {
+	case running == nil:
+		logr.Debugw("ConfigMap version of the active VCL is not known. Not updating the backends until the current version can be applied")
+	case activeVCLName == "boot":
+		logr.Infow("Loading the last ConfigMap version that passed the pre-flight VCL check", "configMapVersion", running.GetResourceVersion())
+	default:
+		logr.Debugw("Updating the backends in the VCL of the running ConfigMap version", "configMapVersion", running.GetResourceVersion())
+	}
+	return running, nil
+}
+
+// validateVCL writes the new files, compiles them under a temporary name and restores the previous files,
+// so the check doesn't change what Varnish runs.
+func (r *ReconcileVarnish) validateVCL(ctx context.Context, dir, entrypoint string, cm *v1.ConfigMap, currFiles, newFiles map[string]string) (phase string, message string, err error) {
+	logr := logger.FromContext(ctx)
+	if _, err = r.reconcileFiles(ctx, dir, currFiles, newFiles); err != nil {
+		return "", "", errors.WithStack(err)
+	}
+	defer func() {
+		if _, restoreErr := r.reconcileFiles(ctx, dir, newFiles, currFiles); restoreErr != nil && err == nil {
+			err = errors.WithStack(restoreErr)
+		}
+	}()
+
+	vclName := VCLValidationPrefix + createVCLConfigName(cm.GetResourceVersion())
+	out, loadErr := r.varnish.Load(ctx, vclName, entrypoint)
+	if loadErr != nil {
+		if varnishadm.IsVCLCompilationError(loadErr, out) {
+			return v1alpha1.VCLValidationPhaseFailed, string(out), nil
+		}
+		return "", "", errors.Wrap(loadErr, string(out))
+	}
+
+	if err := r.varnish.Discard(ctx, vclName); err != nil {
+		logr.Error("Can't delete VCL config used for the pre-flight check", zap.Error(err))
+	}
+
+	return v1alpha1.VCLValidationPhaseSucceeded, "", nil
+}
+
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
@@ -0,0 +1,382 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/names"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	varnishEvents "github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileVCLValidation(t *testing.T) {
+	entrypointFileName := "entrypoint.vcl"
+	cmVersion := "1234"
+	currFiles := map[string]string{entrypointFileName: "vcl 4.0; // old"}
+	newFiles := map[string]string{entrypointFileName: "vcl 4.0; // new", "backends.vcl": "// backends"}
+
+	cases := []struct {
+		name                string
+		validation          *v1alpha1.VCLValidationStatus
+		varnish             *varnishMock
+		expectApplyAllowed  bool
+		expectPodAnnotation string
+		expectEventSent     bool
+		expectErr           bool
+	}{
+		{
+			name:               "check not scheduled yet",
+			validation:         nil,
+			varnish:            &varnishMock{},
+			expectApplyAllowed: false,
+		},
+		{
+			name:               "check scheduled for an another version",
+			validation:         &v1alpha1.VCLValidationStatus{ConfigMapVersion: "1000", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			varnish:            &varnishMock{},
+			expectApplyAllowed: false,
+		},
+		{
+			name:               "check succeeded",
+			validation:         &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			varnish:            &varnishMock{},
+			expectApplyAllowed: true,
+		},
+		{
+			name:               "check failed",
+			validation:         &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhaseFailed},
+			varnish:            &varnishMock{},
+			expectApplyAllowed: false,
+		},
+		{
+			name:               "check is done by an another pod",
+			validation:         &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhasePending, Pod: "varnish-1"},
+			varnish:            &varnishMock{},
+			expectApplyAllowed: false,
+		},
+		{
+			name:                "VCL compiles",
+			validation:          &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhasePending, Pod: "varnish-0"},
+			varnish:             &varnishMock{loadResponse: "VCL compiled."},
+			expectApplyAllowed:  false,
+			expectPodAnnotation: v1alpha1.VCLValidationPhaseSucceeded,
+		},
+		{
+			name:                "VCL doesn't compile",
+			validation:          &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhasePending, Pod: "varnish-0"},
+			varnish:             &varnishMock{loadResponse: "Message from VCC-compiler:\nVCL compilation failed", loadError: errors.New("exit status 1")},
+			expectApplyAllowed:  false,
+			expectPodAnnotation: v1alpha1.VCLValidationPhaseFailed,
+			expectEventSent:     true,
+		},
+		{
+			name:               "varnish is not reachable",
+			validation:         &v1alpha1.VCLValidationStatus{ConfigMapVersion: cmVersion, Phase: v1alpha1.VCLValidationPhasePending, Pod: "varnish-0"},
+			varnish:            &varnishMock{loadResponse: "Connection refused", loadError: errors.New("exit status 1")},
+			expectApplyAllowed: false,
+			expectErr:          true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+
+		dir := t.TempDir()
+		for name, contents := range currFiles {
+			g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)).To(gomega.Succeed())
+		}
+
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default"}}
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "vcl", Namespace: "default", ResourceVersion: cmVersion}}
+		vc := &v1alpha1.VarnishCluster{
+			Spec: v1alpha1.VarnishClusterSpec{
+				VCL: &v1alpha1.VarnishClusterVCL{EntrypointFileName: &entrypointFileName},
+			},
+			Status: v1alpha1.VarnishClusterStatus{
+				VCL: v1alpha1.VCLStatus{Validation: c.validation},
+			},
+		}
+
+		events := &eventsObserver{}
+		testReconciler := &ReconcileVarnish{
+			Client:       fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build(),
+			config:       &config.Config{PodName: "varnish-0", Namespace: "default"},
+			logger:       logger.NewNopLogger(),
+			varnish:      c.varnish,
+			eventHandler: &varnishEvents.EventHandler{Recorder: events},
+		}
+
+		applyAllowed, err := testReconciler.reconcileVCLValidation(context.Background(), dir, vc, pod, cm, currFiles, newFiles)
+		if c.expectErr {
+			g.Expect(err).To(gomega.HaveOccurred())
+		} else {
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+		}
+		g.Expect(applyAllowed).To(gomega.Equal(c.expectApplyAllowed))
+		g.Expect(events.eventsObserved).To(gomega.Equal(c.expectEventSent))
+
+		updatedPod := &v1.Pod{}
+		g.Expect(testReconciler.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "varnish-0"}, updatedPod)).To(gomega.Succeed())
+		g.Expect(updatedPod.Annotations[annotationVCLValidationPhase]).To(gomega.Equal(c.expectPodAnnotation))
+
+		// the check must not change the files Varnish runs
+		files, err := getCurrentFiles(dir)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(files).To(gomega.Equal(currFiles))
+	}
+}
+
+func TestGetLastValidatedConfigMap(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	cmName := "vcl"
+	vc := &v1alpha1.VarnishCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+		Spec:       v1alpha1.VarnishClusterSpec{VCL: &v1alpha1.VarnishClusterVCL{ConfigMapName: &cmName}},
+	}
+	testReconciler := &ReconcileVarnish{
+		Client: fake.NewClientBuilder().Build(),
+		config: &config.Config{PodName: "varnish-0", Namespace: "default"},
+	}
+
+	// no version passed the check yet
+	cm, err := testReconciler.getLastValidatedConfigMap(context.Background(), vc)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(cm).To(gomega.BeNil())
+
+	data := map[string]string{"entrypoint.vcl": "vcl 4.1;"}
+	g.Expect(testReconciler.Create(context.Background(), &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        names.LastValidatedVCLConfigMap(vc.Name),
+			Namespace:   "default",
+			Annotations: map[string]string{annotationConfigMapVersion: "1234"},
+		},
+		Data: data,
+	})).To(gomega.Succeed())
+
+	// the copy is loaded as the version it has been copied from
+	cm, err = testReconciler.getLastValidatedConfigMap(context.Background(), vc)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(cm.Name).To(gomega.Equal(cmName))
+	g.Expect(cm.GetResourceVersion()).To(gomega.Equal("1234"))
+	g.Expect(cm.Data).To(gomega.Equal(data))
+}
+
+func TestApplicableConfigMap(t *testing.T) {
+	entrypointFileName := "entrypoint.vcl"
+	cmName := "vcl"
+	running := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string]string{entrypointFileName + ".tmpl": "vcl 4.1;{{ range .Backends }} backend {{ .IP }}{{ end }}"},
+	}
+	lastValidated := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        names.LastValidatedVCLConfigMap("varnish"),
+			Namespace:   "default",
+			Annotations: map[string]string{annotationConfigMapVersion: "1"},
+		},
+		Data: running.Data,
+	}
+
+	cases := []struct {
+		name              string
+		validation        *v1alpha1.VCLValidationStatus
+		rollout           *v1alpha1.VCLRolloutStatus
+		revertedVersion   string
+		activeVCLName     string
+		appliedConfigMaps map[string]*v1.ConfigMap
+		lastValidated     *v1.ConfigMap
+		expectedVersion   string
+	}{
+		{
+			name:            "check succeeded",
+			validation:      &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			activeVCLName:   "v-1-1000",
+			expectedVersion: "2",
+		},
+		{
+			name:              "check failed",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseFailed},
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "1",
+		},
+		{
+			name:              "check pending",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhasePending, Pod: "varnish-1"},
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "1",
+		},
+		{
+			name:            "check failed and the active version is the last validated one",
+			validation:      &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseFailed},
+			activeVCLName:   "v-1-1000",
+			lastValidated:   lastValidated,
+			expectedVersion: "1",
+		},
+		{
+			name:            "check failed on a pod without VCL",
+			validation:      &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseFailed},
+			activeVCLName:   "boot",
+			lastValidated:   lastValidated,
+			expectedVersion: "1",
+		},
+		{
+			name:              "canary pod",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			rollout:           &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-0"}},
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "2",
+		},
+		{
+			name:              "not a canary pod",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			rollout:           &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseCanary, CanaryPods: []string{"varnish-1"}},
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "1",
+		},
+		{
+			name:              "canary rollout aborted",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			rollout:           &v1alpha1.VCLRolloutStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLRolloutPhaseAborted, CanaryPods: []string{"varnish-1"}},
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "1",
+		},
+		{
+			name:              "switched back from the current version",
+			validation:        &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			revertedVersion:   "2",
+			activeVCLName:     "v-1-1000",
+			appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+			expectedVersion:   "1",
+		},
+		{
+			name:            "switched back from the current version and restarted",
+			validation:      &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseSucceeded},
+			revertedVersion: "2",
+			activeVCLName:   "boot",
+			expectedVersion: "2",
+		},
+		{
+			name:            "check failed and the active version is not known",
+			validation:      &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseFailed},
+			activeVCLName:   "v-1-1000",
+			expectedVersion: "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Log(c.name)
+		g := gomega.NewGomegaWithT(t)
+
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default"}}
+		if c.revertedVersion != "" {
+			pod.Annotations = map[string]string{annotationVCLRevertedConfigMapVersion: c.revertedVersion}
+		}
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: "default", ResourceVersion: "2"}}
+		vc := &v1alpha1.VarnishCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+			Spec: v1alpha1.VarnishClusterSpec{
+				VCL: &v1alpha1.VarnishClusterVCL{ConfigMapName: &cmName, EntrypointFileName: &entrypointFileName},
+			},
+			Status: v1alpha1.VarnishClusterStatus{
+				VCL: v1alpha1.VCLStatus{Validation: c.validation, Rollout: c.rollout},
+			},
+		}
+		if c.rollout != nil {
+			vc.Spec.VCL.RolloutStrategy = &v1alpha1.VarnishClusterVCLRolloutStrategy{
+				Type:   v1alpha1.VCLRolloutStrategyCanary,
+				Canary: &v1alpha1.VarnishClusterVCLCanaryRollout{},
+			}
+		}
+		clientBuilder := fake.NewClientBuilder().WithObjects(pod.DeepCopy())
+		if c.lastValidated != nil {
+			clientBuilder = clientBuilder.WithObjects(c.lastValidated.DeepCopy())
+		}
+		testReconciler := &ReconcileVarnish{
+			Client:            clientBuilder.Build(),
+			config:            &config.Config{PodName: "varnish-0", Namespace: "default"},
+			logger:            logger.NewNopLogger(),
+			varnish:           &varnishMock{},
+			eventHandler:      &varnishEvents.EventHandler{Recorder: &eventsObserver{}},
+			appliedConfigMaps: c.appliedConfigMaps,
+		}
+
+		applicable, err := testReconciler.applicableConfigMap(context.Background(), t.TempDir(), vc, pod, cm, c.activeVCLName, nil, nil)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		if c.expectedVersion == "" {
+			g.Expect(applicable).To(gomega.BeNil())
+			continue
+		}
+		g.Expect(applicable).ToNot(gomega.BeNil())
+		g.Expect(applicable.GetResourceVersion()).To(gomega.Equal(c.expectedVersion))
+	}
+}
+
+func TestBackendsUpdatedWhileVCLCheckFailed(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	entrypointFileName := "entrypoint.vcl"
+	cmName := "vcl"
+	running := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string]string{entrypointFileName + ".tmpl": "vcl 4.1;{{ range .Backends }} backend {{ .IP }}{{ end }}"},
+	}
+	broken := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: "default", ResourceVersion: "2"},
+		Data:       map[string]string{entrypointFileName: "vcl 4.1; syntax error"},
+	}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "varnish-0", Namespace: "default"}}
+	vc := &v1alpha1.VarnishCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "varnish", Namespace: "default"},
+		Spec: v1alpha1.VarnishClusterSpec{
+			VCL: &v1alpha1.VarnishClusterVCL{ConfigMapName: &cmName, EntrypointFileName: &entrypointFileName},
+		},
+		Status: v1alpha1.VarnishClusterStatus{
+			VCL: v1alpha1.VCLStatus{Validation: &v1alpha1.VCLValidationStatus{ConfigMapVersion: "2", Phase: v1alpha1.VCLValidationPhaseFailed}},
+		},
+	}
+	testReconciler := &ReconcileVarnish{
+		Client:            fake.NewClientBuilder().WithObjects(pod.DeepCopy()).Build(),
+		config:            &config.Config{PodName: "varnish-0", Namespace: "default"},
+		logger:            logger.NewNopLogger(),
+		varnish:           &varnishMock{},
+		eventHandler:      &varnishEvents.EventHandler{Recorder: &eventsObserver{}},
+		appliedConfigMaps: map[string]*v1.ConfigMap{"1": running},
+	}
+
+	render := func(backendIPs ...string) map[string]string {
+		var backends []PodInfo
+		for _, ip := range backendIPs {
+			backends = append(backends, PodInfo{IP: ip})
+		}
+		data := templateData(vc, LocalPodInfo{}, 8080, v1alpha1.VarnishPort, backends, nil, nil)
+		files, err := testReconciler.vclFiles(context.Background(), vc, broken, data, nil)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+
+		applicable, err := testReconciler.applicableConfigMap(context.Background(), t.TempDir(), vc, pod, broken, "v-1-1000", nil, files)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		g.Expect(applicable).To(gomega.Equal(running))
+		files, err = testReconciler.vclFiles(context.Background(), vc, applicable, data, nil)
+		g.Expect(err).ToNot(gomega.HaveOccurred())
+		return files
+	}
+
+	// the endpoints change while the current version failed the check
+	g.Expect(render("10.0.0.1", "10.0.0.2")[entrypointFileName]).To(gomega.HaveSuffix("vcl 4.1; backend 10.0.0.1 backend 10.0.0.2"))
+	g.Expect(render("10.0.0.2", "10.0.0.3")[entrypointFileName]).To(gomega.HaveSuffix("vcl 4.1; backend 10.0.0.2 backend 10.0.0.3"))
+}
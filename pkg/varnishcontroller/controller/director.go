@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+)
+
+// director returns the director parameters of the backends in the form they are used in the VCL templates.
+// The round robin director is used if none is configured.
+func director(backend *v1alpha1.VarnishClusterBackend) DirectorInfo {
+	info := DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeRoundRobin}
+	if backend == nil || backend.Director == nil {
+		return info
+	}
+	d := backend.Director
+	if d.Type != "" {
+		info.Type = d.Type
+	}
+
+	if d.Hash != nil {
+		if d.Hash.Header != "" {
+			info.HashKey = "req.http." + d.Hash.Header
+		} else if d.Hash.Cookie != "" {
+			// the value of the cookie, or an empty string if the request doesn't have it
+			info.HashKey = fmt.Sprintf(`regsub(req.http.Cookie, "^(.*;)?\s*%s=([^;]*).*$|^.*$", "\2")`, d.Hash.Cookie)
+		}
+	}
+
+	if info.Type == v1alpha1.VarnishClusterBackendDirectorTypeShard {
+		info.ShardBy = "HASH"
+	}
+	if d.Shard != nil {
+		if d.Shard.By != "" {
+			info.ShardBy = d.Shard.By
+		}
+		if d.Shard.WarmupPercent != nil {
+			info.ShardWarmup = fmt.Sprintf("%.2f", float64(*d.Shard.WarmupPercent)/100)
+		}
+		info.ShardRampup = string(d.Shard.Rampup)
+	}
+
+	if d.Fallback != nil {
+		info.FallbackGroups = d.Fallback.Groups
+		info.FallbackSticky = d.Fallback.Sticky
+	}
+	return info
+}
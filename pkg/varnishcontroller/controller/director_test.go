@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+)
+
+func TestDirector(t *testing.T) {
+	cases := []struct {
+		name     string
+		director *v1alpha1.VarnishClusterBackendDirector
+		expected DirectorInfo
+	}{
+		{
+			name:     "round robin director if not configured",
+			director: nil,
+			expected: DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeRoundRobin},
+		},
+		{
+			name: "hash director by header",
+			director: &v1alpha1.VarnishClusterBackendDirector{
+				Type: v1alpha1.VarnishClusterBackendDirectorTypeHash,
+				Hash: &v1alpha1.VarnishClusterBackendDirectorHash{Header: "X-User"},
+			},
+			expected: DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeHash, HashKey: "req.http.X-User"},
+		},
+		{
+			name: "hash director by cookie",
+			director: &v1alpha1.VarnishClusterBackendDirector{
+				Type: v1alpha1.VarnishClusterBackendDirectorTypeHash,
+				Hash: &v1alpha1.VarnishClusterBackendDirectorHash{Cookie: "session_id"},
+			},
+			expected: DirectorInfo{
+				Type:    v1alpha1.VarnishClusterBackendDirectorTypeHash,
+				HashKey: `regsub(req.http.Cookie, "^(.*;)?\s*session_id=([^;]*).*$|^.*$", "\2")`,
+			},
+		},
+		{
+			name:     "shard director with defaults",
+			director: &v1alpha1.VarnishClusterBackendDirector{Type: v1alpha1.VarnishClusterBackendDirectorTypeShard},
+			expected: DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeShard, ShardBy: "HASH"},
+		},
+		{
+			name: "shard director with warmup and rampup",
+			director: &v1alpha1.VarnishClusterBackendDirector{
+				Type:  v1alpha1.VarnishClusterBackendDirectorTypeShard,
+				Shard: &v1alpha1.VarnishClusterBackendDirectorShard{By: "URL", WarmupPercent: proto.Int32(5), Rampup: "30s"},
+			},
+			expected: DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeShard, ShardBy: "URL", ShardWarmup: "0.05", ShardRampup: "30s"},
+		},
+		{
+			name: "fallback director",
+			director: &v1alpha1.VarnishClusterBackendDirector{
+				Type:     v1alpha1.VarnishClusterBackendDirectorTypeFallback,
+				Fallback: &v1alpha1.VarnishClusterBackendDirectorFallback{Groups: []string{"dr"}, Sticky: true},
+			},
+			expected: DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeFallback, FallbackGroups: []string{"dr"}, FallbackSticky: true},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			g.Expect(director(&v1alpha1.VarnishClusterBackend{Director: c.director})).To(gomega.Equal(c.expected))
+		})
+	}
+}
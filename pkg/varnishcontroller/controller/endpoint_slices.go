@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/pkg/errors"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getServiceEndpoints returns the endpoints of the Service referenced by the backend using the EndpointSlice API.
+// Unlike for the backends found by the selector, pods and nodes are not read, so labels, annotations and the owner
+// of the pods are not known and the node labels only contain the zone of the endpoint.
+func (r *ReconcileVarnish) getServiceEndpoints(ctx context.Context, vc *v1alpha1.VarnishCluster, backend *v1alpha1.VarnishClusterBackend, zoneLabel string) ([]PodInfo, int32, error) {
+	namespace := backend.ServiceRef.Namespace
+	if namespace == "" {
+		namespace = r.config.Namespace
+	}
+
+	slices := &discoveryv1.EndpointSliceList{}
+	err := r.List(ctx, slices, client.InNamespace(namespace), client.MatchingLabels{discoveryv1.LabelServiceName: backend.ServiceRef.Name})
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "could not retrieve endpoint slices of service %s/%s", namespace, backend.ServiceRef.Name)
+	}
+
+	var portNumber int32
+	var podInfoList []PodInfo
+
+	if len(slices.Items) == 0 {
+		r.logger.Infof("No endpoint slices found for service %s/%s", namespace, backend.ServiceRef.Name)
+		return podInfoList, 0, nil
+	}
+
+	for _, slice := range slices.Items {
+		if slice.AddressType != discoveryv1.AddressTypeIPv4 && slice.AddressType != discoveryv1.AddressTypeIPv6 {
+			continue
+		}
+
+		port, found := endpointSlicePort(slice.Ports, *backend.Port)
+		if !found {
+			errMsg := fmt.Sprintf("Endpoint slice %s/%s ignored since it doesn't have port %q defined", slice.Namespace, slice.Name, backend.Port.String())
+			r.eventHandler.Warning(vc, events.EventReasonBackendIgnored, errMsg)
+			r.logger.Warnf(errMsg)
+			continue
+		}
+		portNumber = port
+
+		ports := make(map[string]int32)
+		for _, p := range slice.Ports {
+			if p.Name != nil && *p.Name != "" && p.Port != nil {
+				ports[*p.Name] = *p.Port
+			}
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			ready, include := endpointState(endpoint.Conditions, backend.OnlyReady)
+			if !include || len(endpoint.Addresses) == 0 {
+				continue
+			}
+
+			b := PodInfo{
+				IP:        endpoint.Addresses[0],
+				Port:      port,
+				PodName:   endpoint.Addresses[0],
+				Namespace: slice.Namespace,
+				Ready:     ready,
+				Ports:     ports,
+				Weight:    1.0,
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				b.PodName = endpoint.TargetRef.Name
+			}
+			if endpoint.Zone != nil {
+				b.Zone = *endpoint.Zone
+				b.NodeLabels = map[string]string{zoneLabel: *endpoint.Zone}
+			}
+			if endpoint.Hints != nil {
+				for _, zone := range endpoint.Hints.ForZones {
+					b.ZoneHints = append(b.ZoneHints, zone.Name)
+				}
+			}
+			podInfoList = append(podInfoList, b)
+		}
+	}
+
+	// the same endpoint can temporarily be in multiple slices
+	sort.SliceStable(podInfoList, func(i, j int) bool {
+		return podInfoList[i].IP < podInfoList[j].IP
+	})
+	uniqueList := podInfoList[:0]
+	for i, b := range podInfoList {
+		if i == 0 || b.IP != podInfoList[i-1].IP {
+			uniqueList = append(uniqueList, b)
+		}
+	}
+
+	return uniqueList, portNumber, nil
+}
+
+// endpointSlicePort returns the port number of the endpoint slice port matching the backend port by name or number
+func endpointSlicePort(ports []discoveryv1.EndpointPort, validPort intstr.IntOrString) (int32, bool) {
+	for _, port := range ports {
+		if port.Port == nil {
+			continue
+		}
+		if validPort.Type == intstr.String && port.Name != nil && *port.Name == validPort.StrVal {
+			return *port.Port, true
+		}
+		if validPort.Type == intstr.Int && *port.Port == validPort.IntVal {
+			return *port.Port, true
+		}
+	}
+	return 0, false
+}
+
+// endpointState returns if the endpoint is ready and if it should be included in the backends.
+// Terminating endpoints are included only while they are still serving, and only ready endpoints if onlyReady is set.
+// Unknown conditions are treated as ready, as the API defines.
+func endpointState(conditions discoveryv1.EndpointConditions, onlyReady bool) (ready bool, include bool) {
+	ready = conditions.Ready == nil || *conditions.Ready
+	serving := ready
+	if conditions.Serving != nil {
+		serving = *conditions.Serving
+	}
+	terminating := conditions.Terminating != nil && *conditions.Terminating
+
+	if onlyReady {
+		return ready, ready
+	}
+	return ready, !terminating || serving
+}
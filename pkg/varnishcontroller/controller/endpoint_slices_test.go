@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetServiceEndpoints(t *testing.T) {
+	baseScheme := scheme.Scheme
+	utilruntime.Must(clientgoscheme.AddToScheme(baseScheme))
+	utilruntime.Must(v1alpha1.AddToScheme(baseScheme))
+	portName := intstr.FromString("http")
+
+	endpoint := func(ip, zone string, ready, serving, terminating bool, hints ...string) discoveryv1.Endpoint {
+		e := discoveryv1.Endpoint{
+			Addresses: []string{ip},
+			Conditions: discoveryv1.EndpointConditions{
+				Ready:       proto.Bool(ready),
+				Serving:     proto.Bool(serving),
+				Terminating: proto.Bool(terminating),
+			},
+			Zone:      proto.String(zone),
+			TargetRef: &v1.ObjectReference{Kind: "Pod", Name: "web-" + ip},
+		}
+		if len(hints) > 0 {
+			e.Hints = &discoveryv1.EndpointHints{}
+			for _, hint := range hints {
+				e.Hints.ForZones = append(e.Hints.ForZones, discoveryv1.ForZone{Name: hint})
+			}
+		}
+		return e
+	}
+
+	slices := &discoveryv1.EndpointSliceList{Items: []discoveryv1.EndpointSlice{
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "web-abc", Namespace: "shop", Labels: map[string]string{discoveryv1.LabelServiceName: "web"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: proto.String("http"), Port: proto.Int32(8080)}},
+			Endpoints: []discoveryv1.Endpoint{
+				endpoint("10.0.0.1", "zone1", true, true, false, "zone1"),
+				endpoint("10.0.0.2", "zone2", false, false, false),
+				endpoint("10.0.0.3", "zone2", false, true, true),
+				endpoint("10.0.0.4", "zone2", false, false, true),
+			},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "web-def", Namespace: "shop", Labels: map[string]string{discoveryv1.LabelServiceName: "web"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: proto.String("http"), Port: proto.Int32(8080)}},
+			Endpoints:   []discoveryv1.Endpoint{endpoint("10.0.0.1", "zone1", true, true, false, "zone1")},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "other-abc", Namespace: "shop", Labels: map[string]string{discoveryv1.LabelServiceName: "other"}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: proto.String("http"), Port: proto.Int32(8080)}},
+			Endpoints:   []discoveryv1.Endpoint{endpoint("10.0.1.1", "zone1", true, true, false)},
+		},
+	}}
+
+	ports := map[string]int32{"http": 8080}
+	expected := func(ip, zone string, ready bool, hints ...string) PodInfo {
+		return PodInfo{IP: ip, Port: 8080, PodName: "web-" + ip, Namespace: "shop", NodeLabels: map[string]string{v1.LabelTopologyZone: zone},
+			Zone: zone, ZoneHints: hints, Ready: ready, Ports: ports, Weight: 1}
+	}
+
+	cases := []struct {
+		name              string
+		onlyReady         bool
+		expectedPodInfo   []PodInfo
+		expectedPodNumber int32
+	}{
+		{
+			name:      "all endpoints except terminating ones that are not serving anymore",
+			onlyReady: false,
+			expectedPodInfo: []PodInfo{
+				expected("10.0.0.1", "zone1", true, "zone1"),
+				expected("10.0.0.2", "zone2", false),
+				expected("10.0.0.3", "zone2", false),
+			},
+			expectedPodNumber: 8080,
+		},
+		{
+			name:              "only ready endpoints",
+			onlyReady:         true,
+			expectedPodInfo:   []PodInfo{expected("10.0.0.1", "zone1", true, "zone1")},
+			expectedPodNumber: 8080,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+			r := &ReconcileVarnish{
+				config: &config.Config{Namespace: "default"},
+				Client: fake.NewClientBuilder().WithScheme(baseScheme).WithLists(slices).Build(),
+				logger: logger.NewNopLogger(),
+			}
+			backend := &v1alpha1.VarnishClusterBackend{
+				ServiceRef: &v1alpha1.VarnishClusterBackendServiceRef{Name: "web", Namespace: "shop"},
+				Port:       &portName,
+				OnlyReady:  c.onlyReady,
+			}
+
+			podInfo, portNumber, err := r.getServiceEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, backend, v1.LabelTopologyZone)
+			g.Expect(err).ToNot(gomega.HaveOccurred())
+			g.Expect(podInfo).To(gomega.Equal(c.expectedPodInfo))
+			g.Expect(portNumber).To(gomega.Equal(c.expectedPodNumber))
+		})
+	}
+}
+
+func TestIsLocalBackend(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	zoneLabels := map[string]string{v1.LabelTopologyZone: "zone2"}
+
+	g.Expect(isLocalBackend(PodInfo{NodeLabels: zoneLabels}, v1.LabelTopologyZone, "zone2")).To(gomega.BeTrue())
+	g.Expect(isLocalBackend(PodInfo{NodeLabels: zoneLabels}, v1.LabelTopologyZone, "zone1")).To(gomega.BeFalse())
+	// zone hints take precedence over the zone of the endpoint
+	g.Expect(isLocalBackend(PodInfo{NodeLabels: zoneLabels, ZoneHints: []string{"zone1"}}, v1.LabelTopologyZone, "zone1")).To(gomega.BeTrue())
+	g.Expect(isLocalBackend(PodInfo{NodeLabels: zoneLabels, ZoneHints: []string{"zone1"}}, v1.LabelTopologyZone, "zone2")).To(gomega.BeFalse())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/podutil"
 
@@ -13,51 +14,82 @@ import (
 	vclabels "github.com/ibm/varnish-operator/pkg/labels"
 
 	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
-func (r *ReconcileVarnish) getBackendEndpoints(ctx context.Context, vc *v1alpha1.VarnishCluster) ([]PodInfo, int32, float64, float64, error) {
+// getBackendGroups returns the backends of the named groups defined in spec.backends
+func (r *ReconcileVarnish) getBackendGroups(ctx context.Context, vc *v1alpha1.VarnishCluster) (map[string]BackendGroupInfo, error) {
+	groups := make(map[string]BackendGroupInfo, len(vc.Spec.Backends))
+	for i := range vc.Spec.Backends {
+		group := &vc.Spec.Backends[i]
+		backends, portNumber, _, _, err := r.getBackendEndpoints(ctx, vc, &group.VarnishClusterBackend)
+		if err != nil {
+			return nil, errors.Wrapf(err, "can't get backends of group %s", group.Name)
+		}
+		groups[group.Name] = BackendGroupInfo{
+			Name:              group.Name,
+			Backends:          backends,
+			TargetPort:        portNumber,
+			BackendParameters: backendParameters(&group.VarnishClusterBackend),
+			Director:          director(&group.VarnishClusterBackend),
+		}
+	}
+	return groups, nil
+}
+
+func (r *ReconcileVarnish) getBackendEndpoints(ctx context.Context, vc *v1alpha1.VarnishCluster, backend *v1alpha1.VarnishClusterBackend) ([]PodInfo, int32, float64, float64, error) {
+	// only backend groups are set
+	if backend == nil {
+		return nil, 0, 1.0, 1.0, nil
+	}
+
 	varnishNodeLabels, err := r.getNodeLabels(ctx, r.config.NodeName)
 	if err != nil {
 		return nil, 0, 0, 0, errors.WithStack(err)
 	}
 
-	// Check for deprecated topology labels
-	zoneLabel := v1.LabelTopologyZone
-	if _, ok := varnishNodeLabels[v1.LabelFailureDomainBetaZone]; ok {
-		zoneLabel = v1.LabelFailureDomainBetaZone
-	}
-
+	zoneLabel := nodeZoneLabel(varnishNodeLabels)
 	currentZone := varnishNodeLabels[zoneLabel]
 
 	actualLocalWeight := 1.0
 	actualRemoteWeight := 1.0
 
-	ns := []string{r.config.Namespace}
-	if len(vc.Spec.Backend.Namespaces) > 0 {
-		ns = vc.Spec.Backend.Namespaces
+	var backendList []PodInfo
+	var portNumber int32
+	if backend.ServiceRef != nil {
+		backendList, portNumber, err = r.getServiceEndpoints(ctx, vc, backend, zoneLabel)
+	} else if len(backend.Selector) > 0 {
+		selector := labels.SelectorFromSet(backend.Selector)
+		backendList, portNumber, err = r.getPodsInfo(ctx, vc, r.backendNamespaces(backend), selector, *backend.Port, backend.OnlyReady, true)
 	}
-
-	selector := labels.SelectorFromSet(vc.Spec.Backend.Selector)
-	backendList, portNumber, err := r.getPodsInfo(ctx, vc, ns, selector, *vc.Spec.Backend.Port, vc.Spec.Backend.OnlyReady)
 	if err != nil {
 		return nil, 0, 0, 0, errors.WithStack(err)
 	}
 
+	if backend.External != nil {
+		externalBackends, err := r.getExternalEndpoints(ctx, vc, backend.External)
+		if err != nil {
+			return nil, 0, 0, 0, errors.WithStack(err)
+		}
+		backendList = append(backendList, externalBackends...)
+	}
+
 	if !checkMultizone(backendList, zoneLabel, currentZone) {
 		return backendList, portNumber, actualLocalWeight, actualRemoteWeight, nil
 	}
 
 	backendRatio := calculateBackendRatio(backendList, currentZone, zoneLabel)
 
-	switch vc.Spec.Backend.ZoneBalancing.Type {
+	switch backend.ZoneBalancing.Type {
 	case v1alpha1.VarnishClusterBackendZoneBalancingTypeAuto:
 		baseLocalWeight := 10
 		for i, backend := range backendList {
-			if backend.NodeLabels[zoneLabel] == currentZone {
+			if isLocalBackend(backend, zoneLabel, currentZone) {
 				actualLocalWeight = float64(baseLocalWeight) * backendRatio
 				backendList[i].Weight = actualLocalWeight
 			} else {
@@ -66,7 +98,7 @@ func (r *ReconcileVarnish) getBackendEndpoints(ctx context.Context, vc *v1alpha1
 		}
 
 	case v1alpha1.VarnishClusterBackendZoneBalancingTypeThresholds:
-		thresholds := vc.Spec.Backend.ZoneBalancing.Thresholds
+		thresholds := backend.ZoneBalancing.Thresholds
 
 		if len(thresholds) < 1 {
 			break
@@ -87,7 +119,7 @@ func (r *ReconcileVarnish) getBackendEndpoints(ctx context.Context, vc *v1alpha1
 		}
 
 		for i, backend := range backendList {
-			if backend.NodeLabels[zoneLabel] == currentZone {
+			if isLocalBackend(backend, zoneLabel, currentZone) {
 				actualLocalWeight = float64(currentLocalWeight)
 				backendList[i].Weight = actualLocalWeight
 			} else {
@@ -104,11 +136,20 @@ func (r *ReconcileVarnish) getBackendEndpoints(ctx context.Context, vc *v1alpha1
 	return backendList, portNumber, actualLocalWeight, actualRemoteWeight, nil
 }
 
+// backendNamespaces returns the namespaces the backends are looked up in. Defaults to the namespace of the VarnishCluster.
+func (r *ReconcileVarnish) backendNamespaces(backend *v1alpha1.VarnishClusterBackend) []string {
+	if len(backend.Namespaces) > 0 {
+		return backend.Namespaces
+	}
+	return []string{r.config.Namespace}
+}
+
 func (r *ReconcileVarnish) getVarnishEndpoints(ctx context.Context, vc *v1alpha1.VarnishCluster) ([]PodInfo, error) {
 	varnishLables := labels.SelectorFromSet(vclabels.CombinedComponentLabels(vc, v1alpha1.VarnishComponentVarnish))
 	varnishPort := intstr.FromString(v1alpha1.VarnishPortName)
 
-	varnishEndpoints, _, err := r.getPodsInfo(ctx, vc, []string{r.config.Namespace}, varnishLables, varnishPort, false)
+	// the controller updates the annotations of varnish pods, so they are not exposed to not trigger VCL reloads
+	varnishEndpoints, _, err := r.getPodsInfo(ctx, vc, []string{r.config.Namespace}, varnishLables, varnishPort, false, false)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -116,7 +157,7 @@ func (r *ReconcileVarnish) getVarnishEndpoints(ctx context.Context, vc *v1alpha1
 	return varnishEndpoints, nil
 }
 
-func (r *ReconcileVarnish) getPodsInfo(ctx context.Context, vc *v1alpha1.VarnishCluster, namespaces []string, labels labels.Selector, validPort intstr.IntOrString, onlyReady bool) ([]PodInfo, int32, error) {
+func (r *ReconcileVarnish) getPodsInfo(ctx context.Context, vc *v1alpha1.VarnishCluster, namespaces []string, labels labels.Selector, validPort intstr.IntOrString, onlyReady, withMetadata bool) ([]PodInfo, int32, error) {
 	var pods []v1.Pod
 	for _, namespace := range namespaces {
 		listOptions := []client.ListOption{
@@ -145,7 +186,8 @@ func (r *ReconcileVarnish) getPodsInfo(ctx context.Context, vc *v1alpha1.Varnish
 			continue
 		}
 
-		if onlyReady && !podutil.PodReady(pod) {
+		ready := podutil.PodReady(pod)
+		if onlyReady && !ready {
 			continue
 		}
 
@@ -160,7 +202,22 @@ func (r *ReconcileVarnish) getPodsInfo(ctx context.Context, vc *v1alpha1.Varnish
 					if err != nil {
 						return nil, 0, errors.WithStack(err)
 					}
-					b := PodInfo{IP: pod.Status.PodIP, NodeLabels: nodeLabels, PodName: pod.Name, Weight: backendWeight}
+					b := PodInfo{
+						IP:         pod.Status.PodIP,
+						Port:       containerPort.ContainerPort,
+						NodeLabels: nodeLabels,
+						PodName:    pod.Name,
+						Namespace:  pod.Namespace,
+						Zone:       nodeLabels[nodeZoneLabel(nodeLabels)],
+						Ready:      ready,
+						Ports:      namedPorts(pod),
+						Owner:      podOwner(pod),
+						Weight:     backendWeight,
+					}
+					if withMetadata {
+						b.Labels = pod.Labels
+						b.Annotations = pod.Annotations
+					}
 					podInfoList = append(podInfoList, b)
 					break
 				}
@@ -184,6 +241,57 @@ func (r *ReconcileVarnish) getPodsInfo(ctx context.Context, vc *v1alpha1.Varnish
 	return podInfoList, portNumber, nil
 }
 
+// getLocalPodInfo returns the information about the varnish pod the controller runs in
+func (r *ReconcileVarnish) getLocalPodInfo(ctx context.Context) (LocalPodInfo, error) {
+	nodeLabels, err := r.getNodeLabels(ctx, r.config.NodeName)
+	if err != nil {
+		return LocalPodInfo{}, errors.WithStack(err)
+	}
+
+	return LocalPodInfo{
+		Name:     r.config.PodName,
+		NodeName: r.config.NodeName,
+		Zone:     nodeLabels[nodeZoneLabel(nodeLabels)],
+	}, nil
+}
+
+// nodeZoneLabel returns the label that defines the zone of the node. The deprecated topology label is used if the node still has it.
+func nodeZoneLabel(nodeLabels map[string]string) string {
+	if _, ok := nodeLabels[v1.LabelFailureDomainBetaZone]; ok {
+		return v1.LabelFailureDomainBetaZone
+	}
+	return v1.LabelTopologyZone
+}
+
+func namedPorts(pod v1.Pod) map[string]int32 {
+	ports := make(map[string]int32)
+	for _, container := range pod.Spec.Containers {
+		for _, containerPort := range container.Ports {
+			if containerPort.Name != "" {
+				ports[containerPort.Name] = containerPort.ContainerPort
+			}
+		}
+	}
+	return ports
+}
+
+// podOwner returns the workload that manages the pod. Pods of a Deployment are owned by a ReplicaSet
+// named <deployment name>-<pod template hash>, so the Deployment is returned in that case.
+func podOwner(pod v1.Pod) *OwnerInfo {
+	ref := metav1.GetControllerOf(&pod)
+	if ref == nil {
+		return nil
+	}
+
+	owner := &OwnerInfo{Kind: ref.Kind, Name: ref.Name}
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	if ref.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(ref.Name, "-"+hash) {
+		owner.Kind = "Deployment"
+		owner.Name = strings.TrimSuffix(ref.Name, "-"+hash)
+	}
+	return owner
+}
+
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
 		if item == s {
@@ -201,7 +309,7 @@ func calculateBackendRatio(backends []PodInfo, currentZone string, zoneLabel str
 			if !containsString(zones, b.NodeLabels[zoneLabel]) {
 				zones = append(zones, b.NodeLabels[zoneLabel])
 			}
-			if b.NodeLabels[zoneLabel] == currentZone {
+			if isLocalBackend(b, zoneLabel, currentZone) {
 				localCount++
 			} else {
 				remoteCount++
@@ -217,10 +325,19 @@ func calculateBackendRatio(backends []PodInfo, currentZone string, zoneLabel str
 	return backendRatio
 }
 
+// isLocalBackend returns true if the backend is in the same zone as the varnish pod.
+// Zone hints of EndpointSlices take precedence over the zone of the backend.
+func isLocalBackend(b PodInfo, zoneLabel string, currentZone string) bool {
+	if len(b.ZoneHints) > 0 {
+		return containsString(b.ZoneHints, currentZone)
+	}
+	return b.NodeLabels[zoneLabel] == currentZone
+}
+
 func checkMultizone(endpoints []PodInfo, zoneLabel string, currentZone string) bool {
 	for _, b := range endpoints {
 		if _, ok := b.NodeLabels[zoneLabel]; ok {
-			if b.NodeLabels[zoneLabel] != currentZone {
+			if !isLocalBackend(b, zoneLabel, currentZone) {
 				return true
 			}
 		}
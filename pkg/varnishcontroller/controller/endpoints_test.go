@@ -70,7 +70,8 @@ func TestGetBackendsEndpoint(t *testing.T) {
 			},
 			expectedPodNumber: backendPortNumber.IntVal,
 			expectedPodInfo: []PodInfo{
-				{IP: "10.24.12.2", NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Weight: 1},
+				{IP: "10.24.12.2", Port: backendPortNumber.IntVal, NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Namespace: "ns1",
+					Labels: map[string]string{"app": "backend"}, Zone: "zone1", Ports: map[string]int32{"backend": backendPortNumber.IntVal}, Weight: 1},
 			},
 			expectedErr: nil,
 		},
@@ -110,8 +111,10 @@ func TestGetBackendsEndpoint(t *testing.T) {
 			},
 			expectedPodNumber: backendPortNumber.IntVal,
 			expectedPodInfo: []PodInfo{
-				{IP: "10.24.12.2", NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Weight: 10},
-				{IP: "10.24.12.3", NodeLabels: map[string]string{v1.LabelTopologyZone: "zone2"}, PodName: "backend2", Weight: 1},
+				{IP: "10.24.12.2", Port: backendPortNumber.IntVal, NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Namespace: "ns1",
+					Labels: map[string]string{"app": "backend"}, Zone: "zone1", Ports: map[string]int32{"backend": backendPortNumber.IntVal}, Weight: 10},
+				{IP: "10.24.12.3", Port: backendPortNumber.IntVal, NodeLabels: map[string]string{v1.LabelTopologyZone: "zone2"}, PodName: "backend2", Namespace: "ns2",
+					Labels: map[string]string{"app": "backend"}, Zone: "zone2", Ports: map[string]int32{"backend": backendPortNumber.IntVal}, Weight: 1},
 			},
 			expectedErr: nil,
 		},
@@ -171,8 +174,10 @@ func TestGetBackendsEndpoint(t *testing.T) {
 			},
 			expectedPodNumber: backendPortNumber.IntVal,
 			expectedPodInfo: []PodInfo{
-				{IP: "10.24.12.2", NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Weight: 30},
-				{IP: "10.24.12.3", NodeLabels: map[string]string{v1.LabelTopologyZone: "zone2"}, PodName: "backend2", Weight: 70},
+				{IP: "10.24.12.2", Port: backendPortNumber.IntVal, NodeLabels: map[string]string{v1.LabelTopologyZone: "zone1"}, PodName: "backend1", Namespace: "ns1",
+					Labels: map[string]string{"app": "backend"}, Zone: "zone1", Ports: map[string]int32{"backend": backendPortNumber.IntVal}, Weight: 30},
+				{IP: "10.24.12.3", Port: backendPortNumber.IntVal, NodeLabels: map[string]string{v1.LabelTopologyZone: "zone2"}, PodName: "backend2", Namespace: "ns2",
+					Labels: map[string]string{"app": "backend"}, Zone: "zone2", Ports: map[string]int32{"backend": backendPortNumber.IntVal}, Weight: 70},
 			},
 			expectedErr: nil,
 		},
@@ -227,7 +232,7 @@ func TestGetBackendsEndpoint(t *testing.T) {
 			Client: tClient,
 			logger: logger.NewNopLogger(),
 		}
-		podInfo, portNumber, _, _, err := reconciler.getBackendEndpoints(context.Background(), tc.vc)
+		podInfo, portNumber, _, _, err := reconciler.getBackendEndpoints(context.Background(), tc.vc, tc.vc.Spec.Backend)
 
 		a := gomega.NewGomegaWithT(t)
 		if tc.expectedErr == nil {
@@ -241,6 +246,79 @@ func TestGetBackendsEndpoint(t *testing.T) {
 	}
 }
 
+func TestGetBackendGroups(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	baseScheme := scheme.Scheme
+	utilruntime.Must(clientgoscheme.AddToScheme(baseScheme))
+	utilruntime.Must(v1alpha1.AddToScheme(baseScheme))
+	apiPort := intstr.FromString("http")
+	staticPort := intstr.FromInt(8081)
+	vc := &v1alpha1.VarnishCluster{
+		Spec: v1alpha1.VarnishClusterSpec{
+			Backends: []v1alpha1.VarnishClusterBackendGroup{
+				{
+					Name: "api",
+					VarnishClusterBackend: v1alpha1.VarnishClusterBackend{
+						Selector:      map[string]string{"app": "api"},
+						Port:          &apiPort,
+						ZoneBalancing: &v1alpha1.VarnishClusterBackendZoneBalancing{Type: v1alpha1.VarnishClusterBackendZoneBalancingTypeDisabled},
+					},
+				},
+				{
+					Name: "static",
+					VarnishClusterBackend: v1alpha1.VarnishClusterBackend{
+						Selector:      map[string]string{"app": "static"},
+						Port:          &staticPort,
+						Namespaces:    []string{"assets"},
+						ZoneBalancing: &v1alpha1.VarnishClusterBackendZoneBalancing{Type: v1alpha1.VarnishClusterBackendZoneBalancingTypeDisabled},
+					},
+				},
+			},
+		},
+	}
+
+	nodeLabels := map[string]string{v1.LabelTopologyZone: "zone1"}
+	tClient := fake.NewClientBuilder().WithScheme(baseScheme).
+		WithObjects(createTestNode("node1", nodeLabels)).
+		WithLists(&v1.PodList{Items: []v1.Pod{
+			createTestPod("api-1", "ns1", "10.0.0.1", "node1", map[string]string{"app": "api"}, []v1.ContainerPort{{Name: "http", ContainerPort: 8080}}),
+			createTestPod("static-1", "assets", "10.0.0.2", "node1", map[string]string{"app": "static"}, []v1.ContainerPort{{ContainerPort: 8081}}),
+			createTestPod("static-2", "ns1", "10.0.0.3", "node1", map[string]string{"app": "static"}, []v1.ContainerPort{{ContainerPort: 8081}}),
+		}}).
+		Build()
+
+	reconciler := &ReconcileVarnish{
+		config: &config.Config{Namespace: "ns1", NodeName: "node1"},
+		Client: tClient,
+		logger: logger.NewNopLogger(),
+	}
+
+	// only backend groups are set, so there are no default backends
+	bks, port, _, _, err := reconciler.getBackendEndpoints(context.Background(), vc, vc.Spec.Backend)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(bks).To(gomega.BeEmpty())
+	g.Expect(port).To(gomega.BeZero())
+
+	groups, err := reconciler.getBackendGroups(context.Background(), vc)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(groups).To(gomega.Equal(map[string]BackendGroupInfo{
+		"api": {
+			Name:       "api",
+			TargetPort: 8080,
+			Director:   DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeRoundRobin},
+			Backends: []PodInfo{{IP: "10.0.0.1", Port: 8080, NodeLabels: nodeLabels, PodName: "api-1", Namespace: "ns1",
+				Labels: map[string]string{"app": "api"}, Zone: "zone1", Ports: map[string]int32{"http": 8080}, Weight: 1}},
+		},
+		"static": {
+			Name:       "static",
+			TargetPort: 8081,
+			Director:   DirectorInfo{Type: v1alpha1.VarnishClusterBackendDirectorTypeRoundRobin},
+			Backends: []PodInfo{{IP: "10.0.0.2", Port: 8081, NodeLabels: nodeLabels, PodName: "static-1", Namespace: "assets",
+				Labels: map[string]string{"app": "static"}, Zone: "zone1", Ports: map[string]int32{}, Weight: 1}},
+		},
+	}))
+}
+
 func createTestPod(name, namespace, ip, nodeName string, labels map[string]string, ports []v1.ContainerPort) v1.Pod {
 	return v1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
@@ -283,3 +361,21 @@ func createTestNode(name string, labels map[string]string) *v1.Node {
 
 	return &node
 }
+
+func TestPodOwner(t *testing.T) {
+	isController := true
+	pod := func(kind, name string, labels map[string]string) v1.Pod {
+		p := v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Labels: labels}}
+		if kind != "" {
+			p.OwnerReferences = []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &isController}}
+		}
+		return p
+	}
+
+	g := gomega.NewGomegaWithT(t)
+	g.Expect(podOwner(pod("", "", nil))).To(gomega.BeNil())
+	g.Expect(podOwner(pod("StatefulSet", "db", nil))).To(gomega.Equal(&OwnerInfo{Kind: "StatefulSet", Name: "db"}))
+	g.Expect(podOwner(pod("ReplicaSet", "web-6f4c6cbc6c", map[string]string{"pod-template-hash": "6f4c6cbc6c"}))).
+		To(gomega.Equal(&OwnerInfo{Kind: "Deployment", Name: "web"}))
+	g.Expect(podOwner(pod("ReplicaSet", "web", nil))).To(gomega.Equal(&OwnerInfo{Kind: "ReplicaSet", Name: "web"}))
+}
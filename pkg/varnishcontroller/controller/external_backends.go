@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// external backends are named by their address, as they don't have pod names
+const externalBackendNamePrefix = "external-"
+
+// Resolver resolves the DNS names of external backends. Implemented by net.Resolver.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// getExternalEndpoints returns the external backends with their DNS names resolved.
+// If a name can't be resolved, the previously resolved addresses are used, so a DNS outage doesn't remove the backends.
+// If there are no previously resolved addresses, an error is returned and the reconcile is retried.
+func (r *ReconcileVarnish) getExternalEndpoints(ctx context.Context, vc *v1alpha1.VarnishCluster, external *v1alpha1.VarnishClusterBackendExternal) ([]PodInfo, error) {
+	var backends []PodInfo
+	for _, address := range external.Addresses {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid external backend address %s", address)
+		}
+		portNumber, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid external backend port %s", address)
+		}
+		hostBackends, err := r.resolveExternalHost(ctx, vc, host, int32(portNumber))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		backends = append(backends, hostBackends...)
+	}
+
+	for _, record := range external.SRVRecords {
+		srvBackends, err := r.resolveExternalSRV(ctx, vc, record)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		backends = append(backends, srvBackends...)
+	}
+
+	for _, serviceRef := range external.ExternalNameServices {
+		namespace := serviceRef.Namespace
+		if namespace == "" {
+			namespace = r.config.Namespace
+		}
+
+		svc := &v1.Service{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: serviceRef.Name}, svc); err != nil {
+			if apierrors.IsNotFound(err) {
+				r.externalBackendIgnored(vc, fmt.Sprintf("ExternalName service %s/%s is not found", namespace, serviceRef.Name))
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not get service %s/%s", namespace, serviceRef.Name)
+		}
+
+		if svc.Spec.Type != v1.ServiceTypeExternalName || len(svc.Spec.Ports) == 0 {
+			r.externalBackendIgnored(vc, fmt.Sprintf("Service %s/%s ignored since it's not of type ExternalName or has no ports", namespace, serviceRef.Name))
+			continue
+		}
+		serviceBackends, err := r.resolveExternalHost(ctx, vc, svc.Spec.ExternalName, svc.Spec.Ports[0].Port)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		backends = append(backends, serviceBackends...)
+	}
+
+	// DNS servers can return the addresses in any order, so sort them to not reload VCL because of that
+	sort.SliceStable(backends, func(i, j int) bool {
+		if backends[i].IP == backends[j].IP {
+			return backends[i].Port < backends[j].Port
+		}
+		return backends[i].IP < backends[j].IP
+	})
+
+	// the same address can be resolved from different names
+	uniqueBackends := backends[:0]
+	for i, b := range backends {
+		if i == 0 || b.PodName != backends[i-1].PodName {
+			uniqueBackends = append(uniqueBackends, b)
+		}
+	}
+
+	return uniqueBackends, nil
+}
+
+func (r *ReconcileVarnish) resolveExternalHost(ctx context.Context, vc *v1alpha1.VarnishCluster, host string, port int32) ([]PodInfo, error) {
+	if net.ParseIP(host) != nil {
+		return []PodInfo{externalBackend(host, port, "")}, nil
+	}
+
+	r.lookedUpNames[host] = true
+	ips, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		ips = r.resolvedHosts[host]
+		if len(ips) == 0 {
+			return nil, errors.Wrapf(err, "could not resolve external backend %s", host)
+		}
+		r.externalNameUnresolved(vc, host, fmt.Sprintf("Can't resolve external backend %s, using %d previously resolved address(es): %s", host, len(ips), err))
+	} else {
+		r.externalNameResolved(host)
+		r.resolvedHosts[host] = ips
+	}
+
+	backends := make([]PodInfo, 0, len(ips))
+	for _, ip := range ips {
+		backends = append(backends, externalBackend(ip, port, host))
+	}
+	return backends, nil
+}
+
+func (r *ReconcileVarnish) resolveExternalSRV(ctx context.Context, vc *v1alpha1.VarnishCluster, record string) ([]PodInfo, error) {
+	r.lookedUpNames[record] = true
+	_, srvs, err := r.resolver.LookupSRV(ctx, "", "", record)
+	if err != nil {
+		backends, found := r.resolvedSRVs[record]
+		if !found {
+			return nil, errors.Wrapf(err, "could not resolve SRV record %s", record)
+		}
+		r.externalNameUnresolved(vc, record, fmt.Sprintf("Can't resolve SRV record %s, using %d previously resolved address(es): %s", record, len(backends), err))
+		return backends, nil
+	}
+	r.externalNameResolved(record)
+
+	var backends []PodInfo
+	for _, srv := range srvs {
+		hostBackends, err := r.resolveExternalHost(ctx, vc, strings.TrimSuffix(srv.Target, "."), int32(srv.Port))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		backends = append(backends, hostBackends...)
+	}
+	r.resolvedSRVs[record] = backends
+	return backends, nil
+}
+
+// pruneResolvedNames removes the previously resolved addresses of the names that weren't looked up since the last prune,
+// i.e. the names removed from the external backends
+func (r *ReconcileVarnish) pruneResolvedNames() {
+	for host := range r.resolvedHosts {
+		if !r.lookedUpNames[host] {
+			delete(r.resolvedHosts, host)
+		}
+	}
+	for record := range r.resolvedSRVs {
+		if !r.lookedUpNames[record] {
+			delete(r.resolvedSRVs, record)
+		}
+	}
+	for name := range r.unresolvedNames {
+		if !r.lookedUpNames[name] {
+			delete(r.unresolvedNames, name)
+		}
+	}
+	r.lookedUpNames = make(map[string]bool)
+}
+
+// externalNameUnresolved reports a name that can't be resolved. The names are resolved on every reconcile,
+// so the event is sent only when the name stops resolving.
+func (r *ReconcileVarnish) externalNameUnresolved(vc *v1alpha1.VarnishCluster, name, msg string) {
+	if r.unresolvedNames[name] {
+		r.logger.Debugw(msg)
+		return
+	}
+	r.unresolvedNames[name] = true
+	r.externalBackendIgnored(vc, msg)
+}
+
+func (r *ReconcileVarnish) externalNameResolved(name string) {
+	if r.unresolvedNames[name] {
+		delete(r.unresolvedNames, name)
+		r.logger.Infow("External backend is resolved again", "name", name)
+	}
+}
+
+func (r *ReconcileVarnish) externalBackendIgnored(vc *v1alpha1.VarnishCluster, msg string) {
+	r.eventHandler.Warning(vc, events.EventReasonBackendIgnored, msg)
+	r.logger.Warnw(msg)
+}
+
+// externalResolveInterval returns the shortest resolve interval of the external backends, or 0 if there are no external backends
+func externalResolveInterval(vc *v1alpha1.VarnishCluster) time.Duration {
+	var interval time.Duration
+	for _, backend := range clusterBackends(vc) {
+		if backend.External == nil || backend.External.ResolveIntervalSeconds == nil {
+			continue
+		}
+		backendInterval := time.Duration(*backend.External.ResolveIntervalSeconds) * time.Second
+		if interval == 0 || backendInterval < interval {
+			interval = backendInterval
+		}
+	}
+	return interval
+}
+
+func externalBackend(ip string, port int32, hostname string) PodInfo {
+	return PodInfo{
+		IP:       ip,
+		Port:     port,
+		PodName:  externalBackendNamePrefix + vclIdentInvalidChars.ReplaceAllString(ip, "-") + "-" + strconv.Itoa(int(port)),
+		Hostname: hostname,
+		Ready:    true,
+		Weight:   1.0,
+	}
+}
+
+var _ Resolver = &net.Resolver{}
@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibm/varnish-operator/api/v1alpha1"
+	"github.com/ibm/varnish-operator/pkg/logger"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/config"
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/events"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/scheme"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type resolverMock struct {
+	hosts map[string][]string
+	srvs  map[string][]*net.SRV
+}
+
+func (r *resolverMock) LookupHost(_ context.Context, host string) ([]string, error) {
+	if ips, found := r.hosts[host]; found {
+		return ips, nil
+	}
+	return nil, errors.Errorf("no such host %s", host)
+}
+
+func (r *resolverMock) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	if srvs, found := r.srvs[name]; found {
+		return name, srvs, nil
+	}
+	return "", nil, errors.Errorf("no such host %s", name)
+}
+
+func TestGetExternalEndpoints(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	baseScheme := scheme.Scheme
+	utilruntime.Must(clientgoscheme.AddToScheme(baseScheme))
+
+	resolver := &resolverMock{
+		hosts: map[string][]string{
+			"origin.example.com": {"192.0.2.20", "192.0.2.10"},
+			"legacy.example.com": {"192.0.2.30"},
+			"srv1.example.com":   {"192.0.2.40"},
+		},
+		srvs: map[string][]*net.SRV{
+			"_http._tcp.example.com": {{Target: "srv1.example.com.", Port: 8080}},
+		},
+	}
+
+	recorder := record.NewFakeRecorder(100)
+	r := &ReconcileVarnish{
+		config: &config.Config{Namespace: "default"},
+		Client: fake.NewClientBuilder().WithScheme(baseScheme).WithObjects(&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				Type:         v1.ServiceTypeExternalName,
+				ExternalName: "legacy.example.com",
+				Ports:        []v1.ServicePort{{Port: 9000}},
+			},
+		}).Build(),
+		logger:          logger.NewNopLogger(),
+		eventHandler:    events.NewEventHandler(recorder, "varnish-0"),
+		resolver:        resolver,
+		resolvedHosts:   make(map[string][]string),
+		resolvedSRVs:    make(map[string][]PodInfo),
+		lookedUpNames:   make(map[string]bool),
+		unresolvedNames: make(map[string]bool),
+	}
+
+	external := &v1alpha1.VarnishClusterBackendExternal{
+		Addresses:            []string{"198.51.100.1:80", "origin.example.com:8080", "[2001:db8::1]:80", "192.0.2.10:8080"},
+		SRVRecords:           []string{"_http._tcp.example.com"},
+		ExternalNameServices: []v1alpha1.VarnishClusterBackendServiceRef{{Name: "legacy"}},
+	}
+	expected := []PodInfo{
+		externalBackend("192.0.2.10", 8080, "origin.example.com"),
+		externalBackend("192.0.2.20", 8080, "origin.example.com"),
+		externalBackend("192.0.2.30", 9000, "legacy.example.com"),
+		externalBackend("192.0.2.40", 8080, "srv1.example.com"),
+		externalBackend("198.51.100.1", 80, ""),
+		externalBackend("2001:db8::1", 80, ""),
+	}
+
+	backends, err := r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, external)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(backends).To(gomega.Equal(expected))
+	g.Expect(backends[0].PodName).To(gomega.Equal("external-192-0-2-10-8080"))
+	g.Expect(backends[5].PodName).To(gomega.Equal("external-2001-db8--1-80"))
+
+	// the previously resolved addresses are used if the names can't be resolved anymore
+	r.pruneResolvedNames()
+	r.resolver = &resolverMock{}
+	backends, err = r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, external)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(backends).To(gomega.Equal(expected))
+	g.Expect(recorder.Events).To(gomega.HaveLen(3))
+
+	// the names that still can't be resolved are not reported again
+	r.pruneResolvedNames()
+	backends, err = r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, external)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(backends).To(gomega.Equal(expected))
+	g.Expect(recorder.Events).To(gomega.HaveLen(3))
+
+	// names that were never resolved don't silently remove the backends
+	_, err = r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, &v1alpha1.VarnishClusterBackendExternal{
+		Addresses: []string{"new.example.com:80"},
+	})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("could not resolve external backend new.example.com")))
+	_, err = r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, &v1alpha1.VarnishClusterBackendExternal{
+		SRVRecords: []string{"_http._tcp.new.example.com"},
+	})
+	g.Expect(err).To(gomega.MatchError(gomega.ContainSubstring("could not resolve SRV record _http._tcp.new.example.com")))
+
+	// the names removed from the spec are pruned
+	r.pruneResolvedNames()
+	r.resolver = resolver
+	external.SRVRecords = nil
+	external.ExternalNameServices = nil
+	_, err = r.getExternalEndpoints(context.Background(), &v1alpha1.VarnishCluster{}, external)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	r.pruneResolvedNames()
+	g.Expect(r.resolvedHosts).To(gomega.Equal(map[string][]string{"origin.example.com": {"192.0.2.20", "192.0.2.10"}}))
+	g.Expect(r.resolvedSRVs).To(gomega.BeEmpty())
+	g.Expect(r.unresolvedNames).To(gomega.BeEmpty())
+}
+
+func TestExternalResolveInterval(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	interval := func(seconds int32) *v1alpha1.VarnishClusterBackendExternal {
+		return &v1alpha1.VarnishClusterBackendExternal{Addresses: []string{"origin.example.com:80"}, ResolveIntervalSeconds: &seconds}
+	}
+
+	vc := &v1alpha1.VarnishCluster{
+		Spec: v1alpha1.VarnishClusterSpec{
+			Backends: []v1alpha1.VarnishClusterBackendGroup{
+				{Name: "api", VarnishClusterBackend: v1alpha1.VarnishClusterBackend{External: interval(60)}},
+				{Name: "static", VarnishClusterBackend: v1alpha1.VarnishClusterBackend{External: interval(30)}},
+			},
+		},
+	}
+	g.Expect(externalResolveInterval(vc)).To(gomega.Equal(30 * time.Second))
+
+	vc.Spec.Backend = &v1alpha1.VarnishClusterBackend{External: interval(10)}
+	g.Expect(externalResolveInterval(vc)).To(gomega.Equal(10 * time.Second))
+
+	vc.Spec.Backend = &v1alpha1.VarnishClusterBackend{Selector: map[string]string{"app": "backend"}}
+	vc.Spec.Backends = nil
+	g.Expect(externalResolveInterval(vc)).To(gomega.BeZero())
+}
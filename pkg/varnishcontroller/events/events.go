@@ -13,6 +13,12 @@ const (
 	EventReasonVCLCompilationError EventReason = "VCLCompilationError"
 	EventReasonInvalidVCLConfigMap EventReason = "InvalidVCLConfigMap"
 	EventReasonBackendIgnored      EventReason = "BackendIgnored"
+	EventReasonVCLValidationError  EventReason = "VCLValidationError"
+	EventReasonVCLReverted         EventReason = "VCLReverted"
+	EventReasonInvalidationFailed  EventReason = "InvalidationFailed"
+	EventReasonParameterError      EventReason = "ParameterError"
+	EventReasonLogSessionFailed    EventReason = "LogSessionFailed"
+	EventReasonVarnishSiteRejected EventReason = "VarnishSiteRejected"
 
 	annotationSourcePod string = "sourcePod"
 )
@@ -1,20 +1,261 @@
 package metrics
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+	"time"
 
-const vclCompilationErrorMetricName = "varnish_vcl_compilation_error"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
+const (
+	vclCompilationErrorMetricName = "varnish_vcl_compilation_error"
+	controllerMetricsNamespace    = "varnish_controller"
+	labelPod                      = "pod"
+	labelGroup                    = "group"
+	labelReason                   = "reason"
+
+	// ReloadFailureCompilationError is the reason of a reload that failed as the VCL doesn't compile
+	ReloadFailureCompilationError = "compilation_error"
+	// ReloadFailureReloadError is the reason of a reload that failed for any other reason
+	ReloadFailureReloadError = "reload_error"
+)
+
+// VarnishControllerMetrics are the metrics of the varnish-controller. They are labeled with the VarnishCluster and the pod.
+// The methods do nothing if the metrics are not set.
 type VarnishControllerMetrics struct {
 	VCLCompilationError prometheus.Gauge
+	ReloadDuration      prometheus.Histogram
+	TemplateRenderTime  prometheus.Histogram
+	Reloads             prometheus.Counter
+	ReloadFailures      *prometheus.CounterVec
+	FilesWritten        prometheus.Counter
+	FilesRemoved        prometheus.Counter
+	VCLsDiscarded       prometheus.Counter
+	Backends            *prometheus.GaugeVec
+	LocalWeight         prometheus.Gauge
+	RemoteWeight        prometheus.Gauge
+	LoadedVCLs          prometheus.Gauge
+	lastReload          *lastReloadCollector
+	// Exporter exports the varnish counters. Set only if the builtin metrics exporter is used
+	Exporter *VarnishExporter
 }
 
-func NewVarnishControllerMetrics() *VarnishControllerMetrics {
+// NewVarnishControllerMetrics creates the metrics of the varnish-controller. They have to be registered by the caller.
+func NewVarnishControllerMetrics(varnishCluster, pod string) *VarnishControllerMetrics {
+	constLabels := prometheus.Labels{labelVarnishCluster: varnishCluster, labelPod: pod}
 	return &VarnishControllerMetrics{
 		VCLCompilationError: prometheus.NewGauge(
 			prometheus.GaugeOpts{
-				Name: vclCompilationErrorMetricName,
-				Help: "Indicates if the VCL compilation failed. 0 - successfully compiled, 1 - failed.",
+				Name:        vclCompilationErrorMetricName,
+				Help:        "Indicates if the VCL compilation failed. 0 - successfully compiled, 1 - failed.",
+				ConstLabels: constLabels,
 			},
 		),
+		ReloadDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "reload_duration_seconds",
+				Help:        "Time it took to compile and activate the VCL.",
+				Buckets:     []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+				ConstLabels: constLabels,
+			},
+		),
+		TemplateRenderTime: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "template_render_duration_seconds",
+				Help:        "Time it took to render the VCL templates.",
+				Buckets:     []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+				ConstLabels: constLabels,
+			},
+		),
+		Reloads: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "reloads_total",
+				Help:        "Number of VCL reloads, including the failed ones.",
+				ConstLabels: constLabels,
+			},
+		),
+		ReloadFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "reload_failures_total",
+				Help:        "Number of failed VCL reloads by reason (compilation_error or reload_error).",
+				ConstLabels: constLabels,
+			},
+			[]string{labelReason},
+		),
+		FilesWritten: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "vcl_files_written_total",
+				Help:        "Number of VCL files created or rewritten.",
+				ConstLabels: constLabels,
+			},
+		),
+		FilesRemoved: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "vcl_files_removed_total",
+				Help:        "Number of VCL files removed.",
+				ConstLabels: constLabels,
+			},
+		),
+		VCLsDiscarded: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "vcls_discarded_total",
+				Help:        "Number of VCLs discarded from varnish as they are not needed anymore.",
+				ConstLabels: constLabels,
+			},
+		),
+		Backends: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "backends",
+				Help:        "Number of discovered backends by zone and backend group. The group is empty for the backends of .spec.backend.",
+				ConstLabels: constLabels,
+			},
+			[]string{labelZone, labelGroup},
+		),
+		LocalWeight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "local_backends_weight",
+				Help:        "Current weight of the backends in the zone of the pod.",
+				ConstLabels: constLabels,
+			},
+		),
+		RemoteWeight: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "remote_backends_weight",
+				Help:        "Current weight of the backends in the other zones.",
+				ConstLabels: constLabels,
+			},
+		),
+		LoadedVCLs: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace:   controllerMetricsNamespace,
+				Name:        "loaded_vcls",
+				Help:        "Number of VCLs loaded in varnish.",
+				ConstLabels: constLabels,
+			},
+		),
+		lastReload: &lastReloadCollector{desc: prometheus.NewDesc(
+			controllerMetricsNamespace+"_seconds_since_last_successful_reload",
+			"Seconds since the VCL has been successfully reloaded the last time. Not exported until the first reload.",
+			nil, constLabels,
+		)},
+	}
+}
+
+// Collectors returns all metrics to register
+func (m *VarnishControllerMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.VCLCompilationError, m.ReloadDuration, m.TemplateRenderTime, m.Reloads, m.ReloadFailures, m.FilesWritten,
+		m.FilesRemoved, m.VCLsDiscarded, m.Backends, m.LocalWeight, m.RemoteWeight, m.LoadedVCLs, m.lastReload,
+	}
+}
+
+// ObserveReload records a VCL reload. The failure reason is empty if the reload succeeded.
+func (m *VarnishControllerMetrics) ObserveReload(duration time.Duration, failureReason string) {
+	if m == nil {
+		return
+	}
+	m.Reloads.Inc()
+	m.ReloadDuration.Observe(duration.Seconds())
+	if failureReason != "" {
+		m.ReloadFailures.WithLabelValues(failureReason).Inc()
+		return
+	}
+	m.lastReload.set(time.Now())
+}
+
+// ObserveTemplateRender records the time it took to render the VCL templates
+func (m *VarnishControllerMetrics) ObserveTemplateRender(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.TemplateRenderTime.Observe(duration.Seconds())
+}
+
+// FileWritten counts a created or rewritten VCL file
+func (m *VarnishControllerMetrics) FileWritten() {
+	if m == nil {
+		return
+	}
+	m.FilesWritten.Inc()
+}
+
+// FileRemoved counts a removed VCL file
+func (m *VarnishControllerMetrics) FileRemoved() {
+	if m == nil {
+		return
+	}
+	m.FilesRemoved.Inc()
+}
+
+// SetVCLs records the number of the loaded and the just discarded VCLs
+func (m *VarnishControllerMetrics) SetVCLs(loaded, discarded int) {
+	if m == nil {
+		return
+	}
+	m.LoadedVCLs.Set(float64(loaded))
+	m.VCLsDiscarded.Add(float64(discarded))
+}
+
+// SetBackends sets the number of the discovered backends by zone and group. The zones and groups not passed are removed.
+func (m *VarnishControllerMetrics) SetBackends(backends map[BackendsKey]int) {
+	if m == nil {
+		return
+	}
+	m.Backends.Reset()
+	for key, count := range backends {
+		m.Backends.WithLabelValues(key.Zone, key.Group).Set(float64(count))
+	}
+}
+
+// SetWeights sets the current weights of the local and the remote backends
+func (m *VarnishControllerMetrics) SetWeights(local, remote float64) {
+	if m == nil {
+		return
+	}
+	m.LocalWeight.Set(local)
+	m.RemoteWeight.Set(remote)
+}
+
+// BackendsKey identifies the backends counted together
+type BackendsKey struct {
+	Zone  string
+	Group string
+}
+
+// lastReloadCollector exports the time since the last successful reload, computed on each scrape
+type lastReloadCollector struct {
+	desc *prometheus.Desc
+
+	mu   sync.RWMutex
+	last time.Time
+}
+
+func (c *lastReloadCollector) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last = t
+}
+
+func (c *lastReloadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lastReloadCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	last := c.last
+	c.mu.RUnlock()
+	if last.IsZero() {
+		return
 	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(last).Seconds())
 }
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestVarnishControllerMetrics(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	m := NewVarnishControllerMetrics("cache", "cache-varnish-0")
+	registry := prometheus.NewRegistry()
+	g.Expect(func() { registry.MustRegister(m.Collectors()...) }).ToNot(gomega.Panic())
+
+	// not reloaded yet
+	g.Expect(testutil.CollectAndCount(m.lastReload)).To(gomega.Equal(0))
+
+	m.ObserveReload(2*time.Second, ReloadFailureCompilationError)
+	m.ObserveReload(time.Second, "")
+	m.FileWritten()
+	m.FileWritten()
+	m.FileRemoved()
+	m.SetVCLs(3, 2)
+	m.SetWeights(1, 0.5)
+	m.SetBackends(map[BackendsKey]int{{Zone: "zone-a"}: 2, {Zone: "zone-b", Group: "api"}: 1})
+	m.SetBackends(map[BackendsKey]int{{Zone: "zone-a"}: 3})
+
+	expected := `
+# HELP varnish_controller_reloads_total Number of VCL reloads, including the failed ones.
+# TYPE varnish_controller_reloads_total counter
+varnish_controller_reloads_total{pod="cache-varnish-0",varnish_cluster="cache"} 2
+# HELP varnish_controller_reload_failures_total Number of failed VCL reloads by reason (compilation_error or reload_error).
+# TYPE varnish_controller_reload_failures_total counter
+varnish_controller_reload_failures_total{pod="cache-varnish-0",reason="compilation_error",varnish_cluster="cache"} 1
+# HELP varnish_controller_vcl_files_written_total Number of VCL files created or rewritten.
+# TYPE varnish_controller_vcl_files_written_total counter
+varnish_controller_vcl_files_written_total{pod="cache-varnish-0",varnish_cluster="cache"} 2
+# HELP varnish_controller_vcl_files_removed_total Number of VCL files removed.
+# TYPE varnish_controller_vcl_files_removed_total counter
+varnish_controller_vcl_files_removed_total{pod="cache-varnish-0",varnish_cluster="cache"} 1
+# HELP varnish_controller_vcls_discarded_total Number of VCLs discarded from varnish as they are not needed anymore.
+# TYPE varnish_controller_vcls_discarded_total counter
+varnish_controller_vcls_discarded_total{pod="cache-varnish-0",varnish_cluster="cache"} 2
+# HELP varnish_controller_loaded_vcls Number of VCLs loaded in varnish.
+# TYPE varnish_controller_loaded_vcls gauge
+varnish_controller_loaded_vcls{pod="cache-varnish-0",varnish_cluster="cache"} 3
+# HELP varnish_controller_local_backends_weight Current weight of the backends in the zone of the pod.
+# TYPE varnish_controller_local_backends_weight gauge
+varnish_controller_local_backends_weight{pod="cache-varnish-0",varnish_cluster="cache"} 1
+# HELP varnish_controller_remote_backends_weight Current weight of the backends in the other zones.
+# TYPE varnish_controller_remote_backends_weight gauge
+varnish_controller_remote_backends_weight{pod="cache-varnish-0",varnish_cluster="cache"} 0.5
+# HELP varnish_controller_backends Number of discovered backends by zone and backend group. The group is empty for the backends of .spec.backend.
+# TYPE varnish_controller_backends gauge
+varnish_controller_backends{group="",pod="cache-varnish-0",varnish_cluster="cache",zone="zone-a"} 3
+`
+	g.Expect(testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"varnish_controller_reloads_total", "varnish_controller_reload_failures_total",
+		"varnish_controller_vcl_files_written_total", "varnish_controller_vcl_files_removed_total",
+		"varnish_controller_vcls_discarded_total", "varnish_controller_loaded_vcls",
+		"varnish_controller_local_backends_weight", "varnish_controller_remote_backends_weight",
+		"varnish_controller_backends",
+	)).To(gomega.Succeed())
+	g.Expect(testutil.CollectAndCount(m.ReloadDuration)).To(gomega.Equal(1))
+	g.Expect(testutil.CollectAndCount(m.lastReload)).To(gomega.Equal(1))
+}
+
+func TestVarnishControllerMetricsNotSet(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	var m *VarnishControllerMetrics
+	g.Expect(func() {
+		m.ObserveReload(time.Second, "")
+		m.ObserveTemplateRender(time.Second)
+		m.FileWritten()
+		m.FileRemoved()
+		m.SetVCLs(1, 1)
+		m.SetBackends(nil)
+		m.SetWeights(1, 1)
+	}).ToNot(gomega.Panic())
+}
@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// NamingCompatible uses the metric names of prometheus_varnish_exporter
+	NamingCompatible = "compatible"
+	// NamingNative adds the _total suffix to the counters as the Prometheus naming conventions require
+	NamingNative = "native"
+
+	varnishMetricsNamespace = "varnish"
+	labelVarnishCluster     = "varnish_cluster"
+	labelZone               = "zone"
+
+	// how long a scrape waits for the varnish version, as the CLI connection can be busy with a VCL reload
+	versionTimeout = 5 * time.Second
+)
+
+// VersionReader returns the version of varnish, e.g. 6.5.1, and its source revision
+type VersionReader interface {
+	Version(ctx context.Context) (version, revision string, err error)
+}
+
+// BackendLabels describe the pod behind a VCL backend
+type BackendLabels struct {
+	Pod       string
+	Namespace string
+	Zone      string
+}
+
+// VarnishExporter exports the varnish counters read with varnishstat as Prometheus metrics.
+// The metrics are named like the ones of prometheus_varnish_exporter, so the existing dashboards keep working,
+// and have the VarnishCluster and zone labels. The metrics of the backends have the labels of the backend pods.
+type VarnishExporter struct {
+	stats          varnishstat.Reader
+	version        VersionReader
+	native         bool
+	varnishCluster string
+
+	mu   sync.RWMutex
+	zone string
+	// the backends by their VCL names
+	backends map[string]BackendLabels
+}
+
+// NewVarnishExporter returns the collector of the varnish counters. The naming is compatible or native.
+func NewVarnishExporter(stats varnishstat.Reader, version VersionReader, naming, varnishCluster string) *VarnishExporter {
+	return &VarnishExporter{
+		stats:          stats,
+		version:        version,
+		native:         naming == NamingNative,
+		varnishCluster: varnishCluster,
+		backends:       map[string]BackendLabels{},
+	}
+}
+
+// SetZone sets the zone of the varnish pod
+func (e *VarnishExporter) SetZone(zone string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.zone = zone
+}
+
+// SetBackends sets the pods of the backends defined in the VCL, by the VCL backend names
+func (e *VarnishExporter) SetBackends(backends map[string]BackendLabels) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.backends = backends
+}
+
+// Describe sends no descriptions, as the exported counters depend on the varnish version and the loaded VCL.
+// That makes the collector unchecked.
+func (e *VarnishExporter) Describe(chan<- *prometheus.Desc) {}
+
+// Collect reads the varnish counters and sends them as metrics
+func (e *VarnishExporter) Collect(ch chan<- prometheus.Metric) {
+	e.mu.RLock()
+	zone, backends := e.zone, e.backends
+	e.mu.RUnlock()
+
+	constLabels := prometheus.Labels{labelVarnishCluster: e.varnishCluster, labelZone: zone}
+
+	counters, err := e.stats.Stats()
+	up := 1.0
+	if err != nil {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(
+		prometheus.NewDesc(varnishMetricsNamespace+"_up", "Was the last scrape of varnish successful.", nil, constLabels),
+		prometheus.GaugeValue, up)
+
+	versionCtx, cancel := context.WithTimeout(context.Background(), versionTimeout)
+	defer cancel()
+	if version, revision, err := e.version.Version(versionCtx); err == nil {
+		labels := []string{"version", "major", "minor", "patch", "revision"}
+		parts := append(strings.SplitN(version, ".", 3), "", "")
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(varnishMetricsNamespace+"_version", "Varnish version information.", labels, constLabels),
+			prometheus.GaugeValue, 1, version, parts[0], parts[1], parts[2], revision)
+	}
+
+	for _, counter := range counters {
+		var valueType prometheus.ValueType
+		switch counter.Flag {
+		case varnishstat.FlagCounter:
+			valueType = prometheus.CounterValue
+		case varnishstat.FlagGauge:
+			valueType = prometheus.GaugeValue
+		default:
+			// bitmaps are not numbers
+			continue
+		}
+
+		m, ok := e.metric(counter, backends)
+		if !ok {
+			continue
+		}
+		if valueType == prometheus.CounterValue && e.native && !strings.HasSuffix(m.name, "_total") {
+			m.name += "_total"
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			prometheus.NewDesc(m.name, m.help, m.labelNames, constLabels),
+			valueType, float64(counter.Value), m.labelValues...)
+	}
+}
+
+// Serve exposes the varnish metrics on /metrics of the port until the context is done.
+// They are served separately from the varnish-controller metrics, like the metrics-exporter container does.
+func (e *VarnishExporter) Serve(ctx context.Context, port int) error {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(e); err != nil {
+		return errors.WithStack(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return errors.Wrap(err, "varnish metrics server failed")
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return errors.WithStack(srv.Shutdown(shutdownCtx))
+	}
+}
+
+type varnishMetric struct {
+	name        string
+	help        string
+	labelNames  []string
+	labelValues []string
+}
+
+// metric returns the name, help and labels of the metric the varnish counter is exported as.
+// The counters are named <section>.<field>, or <section>.<id>.<field> for the counters of e.g. storages and locks,
+// or VBE.<vcl>.<backend>.<field> for the backend counters.
+func (e *VarnishExporter) metric(counter varnishstat.Counter, backends map[string]BackendLabels) (varnishMetric, bool) {
+	parts := strings.Split(counter.Name, ".")
+	if len(parts) < 2 {
+		return varnishMetric{}, false
+	}
+	section, field := strings.ToLower(parts[0]), parts[len(parts)-1]
+	m := varnishMetric{help: counter.Description}
+
+	switch {
+	case section == "main" && len(parts) == 2:
+		m.name = metricName(section, field)
+		// the session and fetch counters are grouped by type, like prometheus_varnish_exporter does
+		switch {
+		case field == "s_sess" && !e.native:
+			m.name = metricName(section, "sessions_total")
+		case strings.HasPrefix(field, "sess_"):
+			m.name, m.help = metricName(section, "sessions"), "Number of sessions by type."
+			m.labelNames, m.labelValues = []string{"type"}, []string{strings.TrimPrefix(field, "sess_")}
+		case strings.HasPrefix(field, "fetch_"):
+			m.name, m.help = metricName(section, "fetch"), "Number of fetches by type."
+			m.labelNames, m.labelValues = []string{"type"}, []string{strings.TrimPrefix(field, "fetch_")}
+		}
+	case section == "vbe" && len(parts) >= 4:
+		vcl, backend := parts[1], strings.Join(parts[2:len(parts)-1], ".")
+		pod := backends[backend]
+		m.name = metricName("backend", field)
+		m.labelNames = []string{"backend", "server", "backend_pod", "backend_namespace", "backend_zone"}
+		m.labelValues = []string{backend, vcl, pod.Pod, pod.Namespace, pod.Zone}
+	case len(parts) == 2:
+		m.name = metricName(section, field)
+	default:
+		m.name = metricName(section, field)
+		labelName := "id"
+		if section == "sma" || section == "smf" || section == "smu" || section == "mse" {
+			labelName = "type"
+		}
+		m.labelNames, m.labelValues = []string{labelName}, []string{strings.Join(parts[1:len(parts)-1], ".")}
+	}
+
+	return m, true
+}
+
+func metricName(section, field string) string {
+	return prometheus.BuildFQName(varnishMetricsNamespace, sanitizeMetricName(section), sanitizeMetricName(strings.ToLower(field)))
+}
+
+func sanitizeMetricName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
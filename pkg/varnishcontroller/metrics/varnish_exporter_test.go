@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ibm/varnish-operator/pkg/varnishcontroller/varnishstat"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type statsMock struct {
+	stats []varnishstat.Counter
+	err   error
+}
+
+func (s *statsMock) Counters(...string) (map[string]uint64, error) {
+	return nil, s.err
+}
+
+func (s *statsMock) Stats() ([]varnishstat.Counter, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.stats, nil
+}
+
+type versionMock struct{}
+
+func (versionMock) Version(context.Context) (string, string, error) {
+	return "6.5.1", "1dae233", nil
+}
+
+func TestVarnishExporter(t *testing.T) {
+	stats := []varnishstat.Counter{
+		{Name: "MAIN.cache_hit", Description: "Cache hits", Flag: varnishstat.FlagCounter, Value: 10},
+		{Name: "MAIN.n_object", Description: "object structs made", Flag: varnishstat.FlagGauge, Value: 3},
+		{Name: "MAIN.s_sess", Description: "Total sessions seen", Flag: varnishstat.FlagCounter, Value: 7},
+		{Name: "MAIN.sess_conn", Description: "Sessions accepted", Flag: varnishstat.FlagCounter, Value: 5},
+		{Name: "SMA.s0.g_bytes", Description: "Bytes outstanding", Flag: varnishstat.FlagGauge, Value: 1024},
+		{Name: "VBE.v-1.web_pod-1.req", Description: "Backend requests sent", Flag: varnishstat.FlagCounter, Value: 4},
+		{Name: "VBE.v-1.web_pod-1.happy", Description: "Happy health probes", Flag: varnishstat.FlagBitmap, Value: 255},
+	}
+
+	cases := []struct {
+		name     string
+		naming   string
+		err      error
+		expected string
+	}{
+		{
+			name:   "compatible naming",
+			naming: NamingCompatible,
+			expected: `
+# HELP varnish_backend_req Backend requests sent
+# TYPE varnish_backend_req counter
+varnish_backend_req{backend="web_pod-1",backend_namespace="shop",backend_pod="web.pod-1",backend_zone="zone2",server="v-1",varnish_cluster="cache",zone="zone1"} 4
+# HELP varnish_main_cache_hit Cache hits
+# TYPE varnish_main_cache_hit counter
+varnish_main_cache_hit{varnish_cluster="cache",zone="zone1"} 10
+# HELP varnish_main_n_object object structs made
+# TYPE varnish_main_n_object gauge
+varnish_main_n_object{varnish_cluster="cache",zone="zone1"} 3
+# HELP varnish_main_sessions Number of sessions by type.
+# TYPE varnish_main_sessions counter
+varnish_main_sessions{type="conn",varnish_cluster="cache",zone="zone1"} 5
+# HELP varnish_main_sessions_total Total sessions seen
+# TYPE varnish_main_sessions_total counter
+varnish_main_sessions_total{varnish_cluster="cache",zone="zone1"} 7
+# HELP varnish_sma_g_bytes Bytes outstanding
+# TYPE varnish_sma_g_bytes gauge
+varnish_sma_g_bytes{type="s0",varnish_cluster="cache",zone="zone1"} 1024
+# HELP varnish_up Was the last scrape of varnish successful.
+# TYPE varnish_up gauge
+varnish_up{varnish_cluster="cache",zone="zone1"} 1
+# HELP varnish_version Varnish version information.
+# TYPE varnish_version gauge
+varnish_version{major="6",minor="5",patch="1",revision="1dae233",varnish_cluster="cache",version="6.5.1",zone="zone1"} 1
+`,
+		},
+		{
+			name:   "native naming",
+			naming: NamingNative,
+			expected: `
+# HELP varnish_backend_req_total Backend requests sent
+# TYPE varnish_backend_req_total counter
+varnish_backend_req_total{backend="web_pod-1",backend_namespace="shop",backend_pod="web.pod-1",backend_zone="zone2",server="v-1",varnish_cluster="cache",zone="zone1"} 4
+# HELP varnish_main_cache_hit_total Cache hits
+# TYPE varnish_main_cache_hit_total counter
+varnish_main_cache_hit_total{varnish_cluster="cache",zone="zone1"} 10
+# HELP varnish_main_n_object object structs made
+# TYPE varnish_main_n_object gauge
+varnish_main_n_object{varnish_cluster="cache",zone="zone1"} 3
+# HELP varnish_main_s_sess_total Total sessions seen
+# TYPE varnish_main_s_sess_total counter
+varnish_main_s_sess_total{varnish_cluster="cache",zone="zone1"} 7
+# HELP varnish_main_sessions_total Number of sessions by type.
+# TYPE varnish_main_sessions_total counter
+varnish_main_sessions_total{type="conn",varnish_cluster="cache",zone="zone1"} 5
+# HELP varnish_sma_g_bytes Bytes outstanding
+# TYPE varnish_sma_g_bytes gauge
+varnish_sma_g_bytes{type="s0",varnish_cluster="cache",zone="zone1"} 1024
+# HELP varnish_up Was the last scrape of varnish successful.
+# TYPE varnish_up gauge
+varnish_up{varnish_cluster="cache",zone="zone1"} 1
+# HELP varnish_version Varnish version information.
+# TYPE varnish_version gauge
+varnish_version{major="6",minor="5",patch="1",revision="1dae233",varnish_cluster="cache",version="6.5.1",zone="zone1"} 1
+`,
+		},
+		{
+			name:   "varnishstat failed",
+			naming: NamingCompatible,
+			err:    errors.New("Could not get hold of varnishd"),
+			expected: `
+# HELP varnish_up Was the last scrape of varnish successful.
+# TYPE varnish_up gauge
+varnish_up{varnish_cluster="cache",zone="zone1"} 0
+# HELP varnish_version Varnish version information.
+# TYPE varnish_version gauge
+varnish_version{major="6",minor="5",patch="1",revision="1dae233",varnish_cluster="cache",version="6.5.1",zone="zone1"} 1
+`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(tt *testing.T) {
+			e := NewVarnishExporter(&statsMock{stats: stats, err: c.err}, versionMock{}, c.naming, "cache")
+			e.SetZone("zone1")
+			e.SetBackends(map[string]BackendLabels{"web_pod-1": {Pod: "web.pod-1", Namespace: "shop", Zone: "zone2"}})
+
+			if err := testutil.CollectAndCompare(e, strings.NewReader(c.expected)); err != nil {
+				tt.Error(err)
+			}
+		})
+	}
+}
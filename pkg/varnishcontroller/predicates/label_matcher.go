@@ -1,6 +1,8 @@
 package predicates
 
 import (
+	"reflect"
+
 	"github.com/ibm/varnish-operator/pkg/logger"
 	"github.com/ibm/varnish-operator/pkg/varnishcontroller/podutil"
 	v1 "k8s.io/api/core/v1"
@@ -12,8 +14,11 @@ import (
 var _ predicate.Predicate = &LabelMatcherPredicate{}
 
 type LabelMatcherPredicate struct {
-	logger   *logger.Logger
-	Selector labels.Selector
+	logger *logger.Logger
+	// Selectors the objects are matched against. An object matching any of them triggers the event
+	Selectors []labels.Selector
+	// MetadataSignificant makes label and annotation changes of pods trigger the event
+	MetadataSignificant bool
 }
 
 func NewLabelMatcherPredicate(selector labels.Selector, logr *logger.Logger) *LabelMatcherPredicate {
@@ -21,21 +26,21 @@ func NewLabelMatcherPredicate(selector labels.Selector, logr *logger.Logger) *La
 		logr = logger.NewNopLogger()
 	}
 	return &LabelMatcherPredicate{
-		logger:   logr,
-		Selector: selector,
+		logger:    logr,
+		Selectors: []labels.Selector{selector},
 	}
 }
 
 func (p *LabelMatcherPredicate) Create(e event.CreateEvent) bool {
-	return p.Selector.Matches(labels.Set(e.Object.GetLabels()))
+	return p.matches(e.Object.GetLabels())
 }
 
 func (p *LabelMatcherPredicate) Delete(e event.DeleteEvent) bool {
-	return p.Selector.Matches(labels.Set(e.Object.GetLabels()))
+	return p.matches(e.Object.GetLabels())
 }
 
 func (p *LabelMatcherPredicate) Update(e event.UpdateEvent) bool {
-	if !p.Selector.Matches(labels.Set(e.ObjectNew.GetLabels())) {
+	if !p.matches(e.ObjectNew.GetLabels()) {
 		return false
 	}
 
@@ -60,9 +65,22 @@ func (p *LabelMatcherPredicate) Update(e event.UpdateEvent) bool {
 		return true
 	}
 
+	if p.MetadataSignificant && (!reflect.DeepEqual(newPod.Labels, oldPod.Labels) || !reflect.DeepEqual(newPod.Annotations, oldPod.Annotations)) {
+		return true
+	}
+
 	return false
 }
 
 func (p *LabelMatcherPredicate) Generic(e event.GenericEvent) bool {
-	return p.Selector.Matches(labels.Set(e.Object.GetLabels()))
+	return p.matches(e.Object.GetLabels())
+}
+
+func (p *LabelMatcherPredicate) matches(objLabels map[string]string) bool {
+	for _, selector := range p.Selectors {
+		if selector.Matches(labels.Set(objLabels)) {
+			return true
+		}
+	}
+	return false
 }
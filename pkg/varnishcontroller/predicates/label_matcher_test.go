@@ -14,10 +14,11 @@ import (
 
 func TestLabelMatcherPredicate_Update(t *testing.T) {
 	tcs := []struct {
-		name               string
-		selector           map[string]string
-		updateEvent        event.UpdateEvent
-		shouldTriggerEvent bool
+		name                string
+		selector            map[string]string
+		updateEvent         event.UpdateEvent
+		metadataSignificant bool
+		shouldTriggerEvent  bool
 	}{
 		{
 			name:     "nothing changed",
@@ -53,6 +54,42 @@ func TestLabelMatcherPredicate_Update(t *testing.T) {
 			},
 			shouldTriggerEvent: false,
 		},
+		{
+			name:                "labels changed",
+			selector:            map[string]string{"app": "backend"},
+			metadataSignificant: true,
+			updateEvent: event.UpdateEvent{
+				ObjectOld: &v1.Pod{
+					ObjectMeta: v12.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "backend", "one": "two"}},
+					Spec:       v1.PodSpec{NodeName: "node1"},
+					Status:     v1.PodStatus{PodIP: "19.43.11.32"},
+				},
+				ObjectNew: &v1.Pod{
+					ObjectMeta: v12.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "backend", "one": "three"}},
+					Spec:       v1.PodSpec{NodeName: "node1"},
+					Status:     v1.PodStatus{PodIP: "19.43.11.32"},
+				},
+			},
+			shouldTriggerEvent: true,
+		},
+		{
+			name:                "annotations changed",
+			selector:            map[string]string{"app": "backend"},
+			metadataSignificant: true,
+			updateEvent: event.UpdateEvent{
+				ObjectOld: &v1.Pod{
+					ObjectMeta: v12.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "backend"}},
+					Spec:       v1.PodSpec{NodeName: "node1"},
+					Status:     v1.PodStatus{PodIP: "19.43.11.32"},
+				},
+				ObjectNew: &v1.Pod{
+					ObjectMeta: v12.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": "backend"}, Annotations: map[string]string{"one": "two"}},
+					Spec:       v1.PodSpec{NodeName: "node1"},
+					Status:     v1.PodStatus{PodIP: "19.43.11.32"},
+				},
+			},
+			shouldTriggerEvent: true,
+		},
 		{
 			name:     "pod doesn't match selector",
 			selector: map[string]string{"app": "backend"},
@@ -108,9 +145,22 @@ func TestLabelMatcherPredicate_Update(t *testing.T) {
 
 	for _, tc := range tcs {
 		predicate := NewLabelMatcherPredicate(labels.SelectorFromSet(tc.selector), logger.NewNopLogger())
+		predicate.MetadataSignificant = tc.metadataSignificant
 		if predicate.Update(tc.updateEvent) != tc.shouldTriggerEvent {
 			t.Logf(tc.name+": expected %t got %t", tc.shouldTriggerEvent, !tc.shouldTriggerEvent)
 			t.Fail()
 		}
 	}
 }
+
+func TestLabelMatcherPredicate_MultipleSelectors(t *testing.T) {
+	predicate := NewLabelMatcherPredicate(labels.SelectorFromSet(map[string]string{"app": "api"}), logger.NewNopLogger())
+	predicate.Selectors = append(predicate.Selectors, labels.SelectorFromSet(map[string]string{"app": "static"}))
+
+	for app, shouldTriggerEvent := range map[string]bool{"api": true, "static": true, "other": false} {
+		pod := &v1.Pod{ObjectMeta: v12.ObjectMeta{Name: "pod1", Labels: map[string]string{"app": app}}}
+		if predicate.Create(event.CreateEvent{Object: pod}) != shouldTriggerEvent {
+			t.Errorf("pod with label app=%s: expected %t got %t", app, shouldTriggerEvent, !shouldTriggerEvent)
+		}
+	}
+}
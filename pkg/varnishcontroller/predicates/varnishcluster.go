@@ -61,7 +61,8 @@ func (p *varnishClusterPredicate) Update(e event.UpdateEvent) bool {
 		return true
 	}
 
-	if !cmp.Equal(newCluster.Spec.Backend, oldCluster.Spec.Backend) {
+	if !cmp.Equal(newCluster.Spec.Backend, oldCluster.Spec.Backend) || !cmp.Equal(newCluster.Spec.Backends, oldCluster.Spec.Backends) ||
+		!cmp.Equal(newCluster.Spec.Clustering, oldCluster.Spec.Clustering) {
 		return true
 	}
 
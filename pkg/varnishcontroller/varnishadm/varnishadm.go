@@ -1,7 +1,9 @@
 package varnishadm
 
 import (
+	"context"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,23 +22,50 @@ const (
 	VCLTemperatureCold = "cold"
 	//VCLTemperatureWarm for preloaded varnish's VCL
 	VCLTemperatureWarm = "warm"
+	//VCLStateAuto - varnish manages the VCL temperature. It cools down after vcl_cooldown seconds of not being used
+	VCLStateAuto = "auto"
+	//VCLStateCold - VCL is kept cold. Its resources (e.g. backend probes) are released
+	VCLStateCold = "cold"
+	//VCLStateWarm - VCL is kept warm and ready to serve traffic
+	VCLStateWarm = "warm"
 )
 
 // Commander defines the interface to use for call external utilities to manage varnish instance.
+// The context bounds how long a command can take, including the time spent waiting for the previous commands.
 // - Ping() check if a varnish instace ready and reachable
 // - Reload() try to load a new varnish VCL configuration
+// - Load() compiles and loads a VCL configuration without making it active
+// - Use() switches to an already loaded VCL configuration
 // - List() returns the VCL config currently used in varnish
+// - SetState() sets the state (and so the temperature) of a loaded VCL configuration
+// - Label() points a VCL label to a loaded VCL configuration
+// - PanicShow() returns the last panic of the varnish child process
+// - PanicClear() clears the last panic of the varnish child process
+// - Ban() invalidates the cached objects matching the ban expression
+// - ParamShow() returns the varnishd parameters
+// - ParamSet() changes a varnishd parameter at runtime
+// - BackendList() returns the backends of the loaded VCL configurations and their health
 type Commander interface {
-	Ping() error
-	Reload(version, entry string) ([]byte, error)
-	List() ([]VCLConfig, error)
-	Discard(vclConfigName string) error
+	Ping(ctx context.Context) error
+	Reload(ctx context.Context, version, entry string) ([]byte, error)
+	Load(ctx context.Context, version, entry string) ([]byte, error)
+	Use(ctx context.Context, version string) ([]byte, error)
+	List(ctx context.Context) ([]VCLConfig, error)
+	Discard(ctx context.Context, vclConfigName string) error
+	SetState(ctx context.Context, vclConfigName, state string) error
+	Label(ctx context.Context, label, vclConfigName string) error
+	PanicShow(ctx context.Context) (string, error)
+	PanicClear(ctx context.Context) error
+	Ban(ctx context.Context, expression string) error
+	ParamShow(ctx context.Context) ([]Parameter, error)
+	ParamSet(ctx context.Context, name, value string) error
+	BackendList(ctx context.Context) ([]Backend, error)
 }
 
 // VarnishAdministrator the Commander interface extension by the funtcion which returns active configuration name.
 // - GetActiveConfigurationName() returns an active VCL configuration name or error
 type VarnishAdministrator interface {
-	GetActiveConfigurationName() (name string, err error)
+	GetActiveConfigurationName(ctx context.Context) (name string, err error)
 	Commander
 }
 
@@ -75,12 +104,12 @@ type executor interface {
 	CombinedOutput() ([]byte, error)
 }
 
-type executorProvider func(name string, arg ...string) executor
+type executorProvider func(ctx context.Context, name string, arg ...string) executor
 
 // Ping try to reach varnish instance to ensure it is up and running.
 // It applies pingDelay value as a maximum time to try to reach the varnish instance
 // it is a wrapper over varnishadm ping command.
-func (v *VarnishAdm) Ping() error {
+func (v *VarnishAdm) Ping(ctx context.Context) error {
 	out := make(chan struct{})
 	done := make(chan struct{})
 	defer close(done)
@@ -88,7 +117,7 @@ func (v *VarnishAdm) Ping() error {
 	go func(out, done chan struct{}) {
 		defer close(out)
 		for {
-			_, err := v.run(args)
+			_, err := v.run(ctx, args)
 			select {
 			case <-done:
 				return
@@ -103,6 +132,8 @@ func (v *VarnishAdm) Ping() error {
 	select {
 	case <-time.After(v.pingTimeout):
 		return errors.New("varnish is unreachable")
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "varnish is unreachable")
 	case <-out:
 		return nil
 	}
@@ -112,18 +143,91 @@ func (v *VarnishAdm) Ping() error {
 // - version string, a version which describes the configuration
 // - entrypoint string, a start filename to use as a new VCL configuration
 // it is a wrapper over varnishadm vcl.load and vcl.use commands combination
-func (v *VarnishAdm) Reload(version, entry string) ([]byte, error) {
-	out, err := v.load(version, entry)
+func (v *VarnishAdm) Reload(ctx context.Context, version, entry string) ([]byte, error) {
+	out, err := v.load(ctx, version, entry)
 	if err != nil {
 		return out, err
 	}
-	return v.use(version)
+	return v.use(ctx, version)
+}
+
+// Load compiles and loads a new VCL configuration into the varnish instance without switching to it.
+// Useful to check if the VCL compiles before applying it.
+// it is a wrapper over varnishadm vcl.load command
+func (v *VarnishAdm) Load(ctx context.Context, version, entry string) ([]byte, error) {
+	return v.load(ctx, version, entry)
+}
+
+// Use switches the varnish instance to an already loaded VCL configuration.
+// it is a wrapper over varnishadm vcl.use command
+func (v *VarnishAdm) Use(ctx context.Context, version string) ([]byte, error) {
+	return v.use(ctx, version)
 }
 
 // Discard deletes an existing VCL from the Varnish instance
 // it is a wrapper over varnishadm vcl.discard command
-func (v *VarnishAdm) Discard(vclConfigName string) error {
-	out, err := v.run(append(v.varnishAdmArgs, "vcl.discard", vclConfigName))
+func (v *VarnishAdm) Discard(ctx context.Context, vclConfigName string) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "vcl.discard", vclConfigName))
+	if err != nil {
+		return errors.Wrap(err, string(out))
+	}
+
+	return nil
+}
+
+// SetState sets the state of a loaded VCL: auto, cold or warm
+// it is a wrapper over varnishadm vcl.state command
+func (v *VarnishAdm) SetState(ctx context.Context, vclConfigName, state string) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "vcl.state", vclConfigName, state))
+	if err != nil {
+		return errors.Wrap(err, string(out))
+	}
+
+	return nil
+}
+
+// Label creates a VCL label or moves an existing one to point to the given VCL configuration.
+// A label can be used in the active VCL to switch to the labeled VCL with return(vcl(<label>)).
+// it is a wrapper over varnishadm vcl.label command
+func (v *VarnishAdm) Label(ctx context.Context, label, vclConfigName string) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "vcl.label", label, vclConfigName))
+	if err != nil {
+		return errors.Wrap(err, string(out))
+	}
+
+	return nil
+}
+
+// PanicShow returns the last panic of the varnish child process.
+// Returns an empty string if the child hasn't panicked or the panic has been cleared.
+// it is a wrapper over varnishadm panic.show command
+func (v *VarnishAdm) PanicShow(ctx context.Context) (string, error) {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "panic.show"))
+	if err != nil {
+		if strings.Contains(string(out), "has not panicked") {
+			return "", nil
+		}
+		return "", errors.Wrap(err, string(out))
+	}
+
+	return string(out), nil
+}
+
+// PanicClear clears the last panic of the varnish child process
+// it is a wrapper over varnishadm panic.clear command
+func (v *VarnishAdm) PanicClear(ctx context.Context) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "panic.clear"))
+	if err != nil && !strings.Contains(string(out), "No panic to clear") {
+		return errors.Wrap(err, string(out))
+	}
+
+	return nil
+}
+
+// Ban invalidates all cached objects matching the expression, e.g. req.url ~ "^/images/".
+// it is a wrapper over varnishadm ban command
+func (v *VarnishAdm) Ban(ctx context.Context, expression string) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "ban", expression))
 	if err != nil {
 		return errors.Wrap(err, string(out))
 	}
@@ -131,18 +235,18 @@ func (v *VarnishAdm) Discard(vclConfigName string) error {
 	return nil
 }
 
-func (v *VarnishAdm) run(args []string) ([]byte, error) {
-	return v.execute(v.binary, args...).CombinedOutput()
+func (v *VarnishAdm) run(ctx context.Context, args []string) ([]byte, error) {
+	return v.execute(ctx, v.binary, args...).CombinedOutput()
 }
 
-func (v *VarnishAdm) load(version, entry string) ([]byte, error) {
+func (v *VarnishAdm) load(ctx context.Context, version, entry string) ([]byte, error) {
 	args := append(v.varnishAdmArgs, "vcl.load", version, v.vclBase+"/"+entry)
-	return v.run(args)
+	return v.run(ctx, args)
 }
 
-func (v *VarnishAdm) use(version string) ([]byte, error) {
+func (v *VarnishAdm) use(ctx context.Context, version string) ([]byte, error) {
 	args := append(v.varnishAdmArgs, "vcl.use", version)
-	return v.run(args)
+	return v.run(ctx, args)
 }
 
 func sanitizeVarnishArgs(input []string) []string {
@@ -157,14 +261,14 @@ func sanitizeVarnishArgs(input []string) []string {
 	return out
 }
 
-func execCommandProvider(name string, args ...string) executor {
-	cmd := exec.Command(name, args...)
+func execCommandProvider(ctx context.Context, name string, args ...string) executor {
+	cmd := exec.CommandContext(ctx, name, args...)
 	return cmd
 }
 
 // GetActiveConfigurationName parses varnishadm list output and compute a name of active configuration
-func (v *VarnishAdm) GetActiveConfigurationName() (string, error) {
-	active, err := v.getActiveVCLConfig()
+func (v *VarnishAdm) GetActiveConfigurationName(ctx context.Context) (string, error) {
+	active, err := v.getActiveVCLConfig(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -172,8 +276,8 @@ func (v *VarnishAdm) GetActiveConfigurationName() (string, error) {
 }
 
 // getActiveVCLConfig returns the VarnishClusterVCL config currently used in VarnishClusterVarnish
-func (v *VarnishAdm) getActiveVCLConfig() (*VCLConfig, error) {
-	configsList, err := v.List()
+func (v *VarnishAdm) getActiveVCLConfig(ctx context.Context) (*VCLConfig, error) {
+	configsList, err := v.List(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,101 @@
+package varnishadm
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Backend represents a backend as reported by backend.list -j
+type Backend struct {
+	// Name is the VCL name prefixed by the name of the VCL configuration it's defined in, e.g. v-1234-1600000000.app
+	Name    string
+	Type    string
+	Healthy bool
+}
+
+// BackendList returns the backends of all loaded VCL configurations.
+// it is a wrapper over varnishadm backend.list command
+func (v *VarnishAdm) BackendList(ctx context.Context) ([]Backend, error) {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "backend.list", "-j"))
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	return parseBackendList(out)
+}
+
+// BackendList returns the backends of all loaded VCL configurations
+func (c *CLIClient) BackendList(ctx context.Context) ([]Backend, error) {
+	out, err := c.Run(ctx, "backend.list", "-j")
+	if err != nil {
+		return nil, err
+	}
+	return parseBackendList([]byte(out))
+}
+
+type backendListEntry struct {
+	Type string `json:"type"`
+	// the name of the field differs between varnish versions
+	AdminOverride string          `json:"admin_override"`
+	AdminHealth   string          `json:"admin_health"`
+	ProbeMessage  json.RawMessage `json:"probe_message"`
+}
+
+// parseBackendList parses the output of backend.list -j. It's an array of the JSON format version, the command,
+// the response time and an object with the backends by name. The probe message is [good, window, "healthy|sick"]
+// for the backends with a probe, or a plain string otherwise. The administrative health overrides the probe.
+func parseBackendList(out []byte) ([]Backend, error) {
+	var response []json.RawMessage
+	if err := json.Unmarshal(out, &response); err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+	if len(response) < 4 {
+		return nil, errors.Errorf("unknown backend.list format: %s", out)
+	}
+
+	entries := map[string]backendListEntry{}
+	if err := json.Unmarshal(response[3], &entries); err != nil {
+		return nil, errors.Wrap(err, "unknown backend format")
+	}
+
+	backends := make([]Backend, 0, len(entries))
+	for name, entry := range entries {
+		admin := entry.AdminOverride
+		if admin == "" {
+			admin = entry.AdminHealth
+		}
+
+		var healthy bool
+		switch admin {
+		case "healthy":
+			healthy = true
+		case "sick":
+			healthy = false
+		default:
+			healthy = probeHealthy(entry.ProbeMessage)
+		}
+		backends = append(backends, Backend{Name: name, Type: entry.Type, Healthy: healthy})
+	}
+
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	return backends, nil
+}
+
+func probeHealthy(message json.RawMessage) bool {
+	var status string
+	if err := json.Unmarshal(message, &status); err == nil {
+		return status == "healthy"
+	}
+
+	var probe []json.RawMessage
+	if err := json.Unmarshal(message, &probe); err != nil || len(probe) == 0 {
+		return false
+	}
+	if err := json.Unmarshal(probe[len(probe)-1], &status); err != nil {
+		return false
+	}
+	return status == "healthy"
+}
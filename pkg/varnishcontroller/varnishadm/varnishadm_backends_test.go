@@ -0,0 +1,65 @@
+package varnishadm
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseBackendList(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	out := []byte(`[ 2, ["backend.list", "-j"], 1651406400.000,
+  {
+    "boot.default": {
+      "type": "backend",
+      "admin_override": "probe",
+      "probe_message": [0, 0, "healthy"],
+      "last_change": 1651406000.000
+    },
+    "v-1234-1651406100.app_0": {
+      "type": "backend",
+      "admin_override": "probe",
+      "probe_message": [3, 8, "sick"],
+      "last_change": 1651406100.000
+    },
+    "v-1234-1651406100.app_1": {
+      "type": "backend",
+      "admin_override": "probe",
+      "probe_message": [8, 8, "healthy"],
+      "last_change": 1651406100.000
+    },
+    "v-1234-1651406100.app_2": {
+      "type": "backend",
+      "admin_override": "sick",
+      "probe_message": [8, 8, "healthy"],
+      "last_change": 1651406100.000
+    },
+    "v-1234-1651406100.api_0": {
+      "type": "backend",
+      "admin_health": "healthy",
+      "probe_message": "-",
+      "last_change": 1651406100.000
+    },
+    "v-1234-1651406100.app": {
+      "type": "round-robin",
+      "admin_override": "probe",
+      "probe_message": "healthy",
+      "last_change": 1651406100.000
+    }
+  }
+]`)
+
+	backends, err := parseBackendList(out)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(backends).To(gomega.Equal([]Backend{
+		{Name: "boot.default", Type: "backend", Healthy: true},
+		{Name: "v-1234-1651406100.api_0", Type: "backend", Healthy: true},
+		{Name: "v-1234-1651406100.app", Type: "round-robin", Healthy: true},
+		{Name: "v-1234-1651406100.app_0", Type: "backend", Healthy: false},
+		{Name: "v-1234-1651406100.app_1", Type: "backend", Healthy: true},
+		{Name: "v-1234-1651406100.app_2", Type: "backend", Healthy: false},
+	}))
+
+	_, err = parseBackendList([]byte(`[ 2, ["backend.list", "-j"], 1651406400.000 ]`))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -0,0 +1,445 @@
+package varnishadm
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Status codes of the varnish CLI protocol responses
+const (
+	CLIStatusSyntax    = 100
+	CLIStatusUnknown   = 101
+	CLIStatusUnimpl    = 102
+	CLIStatusTooFew    = 104
+	CLIStatusTooMany   = 105
+	CLIStatusParam     = 106
+	CLIStatusAuth      = 107
+	CLIStatusOK        = 200
+	CLIStatusTruncated = 201
+	CLIStatusCant      = 300
+	CLIStatusComms     = 400
+	CLIStatusClose     = 500
+
+	// the response header is "SSS LLLLLLLL\n": the status code, a space, the body length padded to 8 chars and a new line
+	cliHeaderLength = 13
+	// the length of the challenge in the authentication response
+	cliChallengeLength = 32
+	// the timeout of a single command if the context doesn't have a deadline
+	defaultCLICommandTimeout = 30 * time.Second
+)
+
+// the version line of the banner, e.g. "varnish-6.5.1 revision 1dae23376bb5ea7a6b8e9e4b9ed95cdc9469fb64"
+var bannerVersionRegexp = regexp.MustCompile(`varnish-(\S+) revision (\S+)`)
+
+// CLIError is a varnish CLI response with a non-OK status code
+type CLIError struct {
+	Command string
+	Status  int
+	Message string
+}
+
+func (e *CLIError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s", e.Command, e.Status, strings.TrimSpace(e.Message))
+}
+
+// IsVCLCompilationError returns true if the error is returned by vcl.load because the VCL doesn't compile.
+// The command output is checked for errors that don't carry the CLI status code, i.e. the ones returned by VarnishAdm.
+func IsVCLCompilationError(err error, out []byte) bool {
+	if err == nil {
+		return false
+	}
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) {
+		return cliErr.Command == "vcl.load" && cliErr.Status == CLIStatusParam
+	}
+	return strings.Contains(string(out), "VCL compilation failed")
+}
+
+// CLIClient implements the VarnishAdministrator interface by talking the varnish CLI protocol to the management
+// port of varnishd (-T), instead of running the varnishadm binary for every command. The connection is kept open and
+// reestablished if it breaks.
+type CLIClient struct {
+	address     string
+	secretFile  string
+	vclBase     string
+	pingTimeout time.Duration
+	pingDelay   time.Duration
+	dialer      net.Dialer
+
+	// a semaphore instead of a mutex, so the commands waiting for the connection give up when their context is done
+	sem    chan struct{}
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewCLIClient returns a varnish CLI client. It accepts the same parameters as NewVarnishAdministartor.
+// The address and the secret file are taken from the -T and -S varnishadm args.
+func NewCLIClient(timeout, delay time.Duration, vclBase string, args []string) (*CLIClient, error) {
+	c := &CLIClient{
+		vclBase:     vclBase,
+		pingTimeout: timeout,
+		pingDelay:   delay,
+		sem:         make(chan struct{}, 1),
+	}
+
+	args = sanitizeVarnishArgs(args)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-T", "-S":
+			if i+1 == len(args) {
+				return nil, errors.Errorf("varnishadm arg %s has no value", args[i])
+			}
+			if args[i] == "-T" {
+				c.address = args[i+1]
+			} else {
+				c.secretFile = args[i+1]
+			}
+			i++
+		default:
+			return nil, errors.Errorf("varnishadm arg %s is not supported", args[i])
+		}
+	}
+
+	if c.address == "" {
+		return nil, errors.New("the management address (-T) of varnish is not set")
+	}
+	return c, nil
+}
+
+// Run executes a CLI command and returns the response body. Arguments are quoted if needed.
+// Returns a *CLIError if varnish responds with a non-OK status.
+func (c *CLIClient) Run(ctx context.Context, command string, args ...string) (string, error) {
+	line := command
+	for _, arg := range args {
+		line += " " + cliQuote(arg)
+	}
+	return c.runLine(ctx, command, line)
+}
+
+// runLine sends the command line and reads the response. A broken connection is reopened and the command is retried once.
+func (c *CLIClient) runLine(ctx context.Context, command, line string) (string, error) {
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultCLICommandTimeout)
+		defer cancel()
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return "", errors.Wrapf(ctx.Err(), "varnish CLI command %s is not sent", command)
+	}
+
+	for attempt := 0; ; attempt++ {
+		reused := c.conn != nil
+		if !reused {
+			if err := c.connect(ctx); err != nil {
+				return "", err
+			}
+		}
+
+		status, body, err := c.roundTrip(ctx, line)
+		if err != nil {
+			c.close()
+			// varnishd closes idle connections on restarts, so a reused connection may be stale
+			if reused && attempt == 0 && ctx.Err() == nil {
+				continue
+			}
+			return "", errors.Wrapf(err, "varnish CLI command %s failed", command)
+		}
+
+		if status == CLIStatusClose {
+			c.close()
+		}
+		if status != CLIStatusOK {
+			return body, &CLIError{Command: command, Status: status, Message: body}
+		}
+		return body, nil
+	}
+}
+
+// connect opens the connection and authenticates with the secret, if varnish asks for it
+func (c *CLIClient) connect(ctx context.Context) error {
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return errors.Wrapf(err, "can't connect to varnish at %s", c.address)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	stop := c.watchContext(ctx)
+	defer stop()
+
+	status, body, err := c.readResponse()
+	if err != nil {
+		c.close()
+		return errors.Wrap(err, "can't read the varnish CLI banner")
+	}
+
+	if status == CLIStatusAuth {
+		if len(body) < cliChallengeLength {
+			c.close()
+			return errors.Errorf("invalid varnish CLI authentication challenge %q", body)
+		}
+		if c.secretFile == "" {
+			c.close()
+			return errors.New("varnish CLI requires authentication, but the secret file (-S) is not set")
+		}
+		secret, err := os.ReadFile(c.secretFile)
+		if err != nil {
+			c.close()
+			return errors.Wrap(err, "can't read the varnish secret")
+		}
+
+		if _, err = io.WriteString(c.conn, "auth "+cliAuthResponse(body[:cliChallengeLength], secret)+"\n"); err != nil {
+			c.close()
+			return errors.Wrap(err, "can't authenticate to varnish")
+		}
+		if status, body, err = c.readResponse(); err != nil {
+			c.close()
+			return errors.Wrap(err, "can't authenticate to varnish")
+		}
+	}
+
+	if status != CLIStatusOK {
+		c.close()
+		return &CLIError{Command: "auth", Status: status, Message: body}
+	}
+	return nil
+}
+
+func (c *CLIClient) roundTrip(ctx context.Context, line string) (int, string, error) {
+	stop := c.watchContext(ctx)
+	defer stop()
+
+	if _, err := io.WriteString(c.conn, line+"\n"); err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	return c.readResponse()
+}
+
+// watchContext applies the deadline of the context to the connection and interrupts the I/O if the context is canceled
+func (c *CLIClient) watchContext(ctx context.Context) (stop func()) {
+	deadline, _ := ctx.Deadline()
+	_ = c.conn.SetDeadline(deadline)
+
+	conn := c.conn
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (c *CLIClient) readResponse() (int, string, error) {
+	header := make([]byte, cliHeaderLength)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+
+	status, err := strconv.Atoi(string(header[0:3]))
+	if err != nil || header[3] != ' ' || header[cliHeaderLength-1] != '\n' {
+		return 0, "", errors.Errorf("invalid varnish CLI response header %q", header)
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(string(header[4 : cliHeaderLength-1])))
+	if err != nil || length < 0 {
+		return 0, "", errors.Errorf("invalid varnish CLI response length %q", header)
+	}
+
+	// the body is followed by a new line
+	body := make([]byte, length+1)
+	if _, err = io.ReadFull(c.reader, body); err != nil {
+		return 0, "", errors.WithStack(err)
+	}
+	return status, string(body[:length]), nil
+}
+
+func (c *CLIClient) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	c.conn = nil
+	c.reader = nil
+}
+
+// Close closes the connection to varnish
+func (c *CLIClient) Close() error {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	c.close()
+	return nil
+}
+
+// Ping waits until varnish responds to the ping command, for up to the ping timeout
+func (c *CLIClient) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.pingTimeout)
+	defer cancel()
+
+	for {
+		if _, err := c.Run(ctx, "ping"); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New("varnish is unreachable")
+		case <-time.After(c.pingDelay):
+		}
+	}
+}
+
+// Reload loads the VCL configuration and switches to it
+func (c *CLIClient) Reload(ctx context.Context, version, entry string) ([]byte, error) {
+	out, err := c.Load(ctx, version, entry)
+	if err != nil {
+		return out, err
+	}
+	return c.Use(ctx, version)
+}
+
+// Load compiles and loads a VCL configuration without switching to it
+func (c *CLIClient) Load(ctx context.Context, version, entry string) ([]byte, error) {
+	out, err := c.Run(ctx, "vcl.load", version, c.vclBase+"/"+entry)
+	return []byte(out), err
+}
+
+// Use switches to an already loaded VCL configuration
+func (c *CLIClient) Use(ctx context.Context, version string) ([]byte, error) {
+	out, err := c.Run(ctx, "vcl.use", version)
+	return []byte(out), err
+}
+
+// List returns the loaded VCL configurations
+func (c *CLIClient) List(ctx context.Context) ([]VCLConfig, error) {
+	out, err := c.Run(ctx, "vcl.list", "-j")
+	if err != nil {
+		var cliErr *CLIError
+		if !errors.As(err, &cliErr) {
+			return nil, err
+		}
+		// varnish versions without JSON support
+		if out, err = c.Run(ctx, "vcl.list"); err != nil {
+			return nil, err
+		}
+		return parseVCLConfigsList([]byte(out))
+	}
+
+	vclList := vclListResponse{}
+	if err = json.Unmarshal([]byte(out), &vclList); err != nil {
+		return nil, errors.Wrap(err, out)
+	}
+	return vclList.VCLs, nil
+}
+
+// GetActiveConfigurationName returns the name of the active VCL configuration
+func (c *CLIClient) GetActiveConfigurationName(ctx context.Context) (string, error) {
+	configs, err := c.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, vclConfig := range configs {
+		if vclConfig.Status == VCLStatusActive {
+			return vclConfig.Name, nil
+		}
+	}
+	return "", errors.Errorf("No active VCL configuration found")
+}
+
+// Discard deletes a loaded VCL configuration
+func (c *CLIClient) Discard(ctx context.Context, vclConfigName string) error {
+	_, err := c.Run(ctx, "vcl.discard", vclConfigName)
+	return err
+}
+
+// SetState sets the state of a loaded VCL: auto, cold or warm
+func (c *CLIClient) SetState(ctx context.Context, vclConfigName, state string) error {
+	_, err := c.Run(ctx, "vcl.state", vclConfigName, state)
+	return err
+}
+
+// Label points a VCL label to a loaded VCL configuration
+func (c *CLIClient) Label(ctx context.Context, label, vclConfigName string) error {
+	_, err := c.Run(ctx, "vcl.label", label, vclConfigName)
+	return err
+}
+
+// PanicShow returns the last panic of the varnish child process, or an empty string if there is none
+func (c *CLIClient) PanicShow(ctx context.Context) (string, error) {
+	out, err := c.Run(ctx, "panic.show")
+	if isCLIStatus(err, CLIStatusCant) {
+		return "", nil
+	}
+	return out, err
+}
+
+// PanicClear clears the last panic of the varnish child process
+func (c *CLIClient) PanicClear(ctx context.Context) error {
+	_, err := c.Run(ctx, "panic.clear")
+	if isCLIStatus(err, CLIStatusCant) {
+		return nil
+	}
+	return err
+}
+
+// Ban invalidates all cached objects matching the expression. The expression is sent as is, as it consists of several arguments.
+func (c *CLIClient) Ban(ctx context.Context, expression string) error {
+	if strings.ContainsAny(expression, "\n\r") {
+		return errors.New("ban expression can't contain new lines")
+	}
+	_, err := c.runLine(ctx, "ban", "ban "+expression)
+	return err
+}
+
+// Version returns the varnish version (e.g. 6.5.1) and the source revision from the CLI banner
+func (c *CLIClient) Version(ctx context.Context) (version, revision string, err error) {
+	out, err := c.Run(ctx, "banner")
+	if err != nil {
+		return "", "", err
+	}
+	match := bannerVersionRegexp.FindStringSubmatch(out)
+	if match == nil {
+		return "", "", errors.Errorf("can't find the varnish version in the banner %q", out)
+	}
+	return match[1], match[2], nil
+}
+
+func isCLIStatus(err error, status int) bool {
+	var cliErr *CLIError
+	return errors.As(err, &cliErr) && cliErr.Status == status
+}
+
+// cliAuthResponse computes the response to the authentication challenge: sha256(challenge + "\n" + secret + challenge + "\n")
+func cliAuthResponse(challenge string, secret []byte) string {
+	h := sha256.New()
+	h.Write([]byte(challenge + "\n"))
+	h.Write(secret)
+	h.Write([]byte(challenge + "\n"))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cliQuote quotes an argument of a CLI command if it's empty or contains whitespaces, quotes or backslashes
+func cliQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\n\r\"\\") {
+		return arg
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(arg) + `"`
+}
+
+var _ VarnishAdministrator = &CLIClient{}
@@ -0,0 +1,263 @@
+package varnishadm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+)
+
+const testChallenge = "abcdefghijklmnopqrstuvwxyzabcdef"
+
+// fakeVarnishd implements the server side of the varnish CLI protocol
+type fakeVarnishd struct {
+	listener net.Listener
+	secret   []byte
+	// responses by command line. Commands not listed get 101 Unknown request
+	responses map[string]fakeResponse
+
+	mu       sync.Mutex
+	commands []string
+	conns    int
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+	// close the connection instead of responding
+	hangUp bool
+	delay  time.Duration
+}
+
+func newFakeVarnishd(t *testing.T, secret string, responses map[string]fakeResponse) *fakeVarnishd {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeVarnishd{listener: l, secret: []byte(secret), responses: responses}
+	go f.serve()
+	t.Cleanup(func() { _ = l.Close() })
+	return f
+}
+
+func (f *fakeVarnishd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns++
+		f.mu.Unlock()
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeVarnishd) handle(conn net.Conn) {
+	defer conn.Close()
+	lines := bufio.NewScanner(conn)
+
+	if len(f.secret) > 0 {
+		writeFakeResponse(conn, CLIStatusAuth, testChallenge+"\n\nAuthentication required.\n")
+		if !lines.Scan() || lines.Text() != "auth "+cliAuthResponse(testChallenge, f.secret) {
+			writeFakeResponse(conn, CLIStatusClose, "Authentication failed")
+			return
+		}
+	}
+	writeFakeResponse(conn, CLIStatusOK, "-----------------------------\nVarnish Cache CLI 1.0\n")
+
+	for lines.Scan() {
+		line := lines.Text()
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		resp, found := f.responses[line]
+		if !found {
+			resp = fakeResponse{status: CLIStatusUnknown, body: "Unknown request."}
+		}
+		if resp.hangUp {
+			// hang up only once
+			delete(f.responses, line)
+		}
+		f.mu.Unlock()
+
+		time.Sleep(resp.delay)
+		if resp.hangUp {
+			return
+		}
+		writeFakeResponse(conn, resp.status, resp.body)
+	}
+}
+
+func (f *fakeVarnishd) connections() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+func writeFakeResponse(conn net.Conn, status int, body string) {
+	_, _ = fmt.Fprintf(conn, "%03d %-8d\n%s\n", status, len(body), body)
+}
+
+func (f *fakeVarnishd) client(t *testing.T, secretFile string) *CLIClient {
+	args := []string{"-T", f.listener.Addr().String()}
+	if secretFile != "" {
+		args = append(args, "-S", secretFile)
+	}
+	c, err := NewCLIClient(time.Second, 10*time.Millisecond, "/etc/varnish", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestCLIClient(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	g.Expect(os.WriteFile(secretFile, []byte("s3cr3t\n"), 0600)).To(gomega.Succeed())
+
+	f := newFakeVarnishd(t, "s3cr3t\n", map[string]fakeResponse{
+		"ping": {status: CLIStatusOK, body: "PONG 1651406400 1.0"},
+		"vcl.load v-1 /etc/varnish/entrypoint.vcl": {status: CLIStatusOK, body: "VCL compiled."},
+		"vcl.load v-2 /etc/varnish/entrypoint.vcl": {status: CLIStatusParam, body: "Message from VCC-compiler:\nSyntax error\nRunning VCC-compiler failed, exited with 2\nVCL compilation failed"},
+		"vcl.use v-1": {status: CLIStatusOK, body: "VCL 'v-1' now active"},
+		"vcl.list -j": {status: CLIStatusOK, body: `[ 2, ["vcl.list", "-j"], 1651406400.000,
+{"status": "active", "state": "auto", "temperature": "warm", "busy": 0, "name": "v-1"}]`},
+		`ban req.url ~ "^/products/"`:            {status: CLIStatusOK},
+		"vcl.label label-1 \"name with spaces\"": {status: CLIStatusOK},
+		"panic.show":                             {status: CLIStatusCant, body: "Child has not panicked or panic has been cleared"},
+		"banner": {status: CLIStatusOK, body: "-----------------------------\nVarnish Cache CLI 1.0\n-----------------------------\n" +
+			"Linux,5.4.0,x86_64,-junix,-smalloc,-sdefault,-hcritbit\nvarnish-6.5.1 revision 1dae23376bb5ea7a6b8e9e4b9ed95cdc9469fb64\n\n" +
+			"Type 'help' for command list.\nType 'quit' to close CLI session."},
+	})
+	c := f.client(t, secretFile)
+
+	g.Expect(c.Ping(context.Background())).To(gomega.Succeed())
+
+	out, err := c.Reload(context.Background(), "v-1", "entrypoint.vcl")
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(string(out)).To(gomega.Equal("VCL 'v-1' now active"))
+
+	out, err = c.Load(context.Background(), "v-2", "entrypoint.vcl")
+	g.Expect(string(out)).To(gomega.ContainSubstring("Syntax error"))
+	g.Expect(IsVCLCompilationError(err, out)).To(gomega.BeTrue())
+	cliErr := &CLIError{}
+	g.Expect(errors.As(err, &cliErr)).To(gomega.BeTrue())
+	g.Expect(cliErr.Status).To(gomega.Equal(CLIStatusParam))
+
+	name, err := c.GetActiveConfigurationName(context.Background())
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(name).To(gomega.Equal("v-1"))
+
+	g.Expect(c.Ban(context.Background(), `req.url ~ "^/products/"`)).To(gomega.Succeed())
+	g.Expect(c.Label(context.Background(), "label-1", "name with spaces")).To(gomega.Succeed())
+
+	panicMsg, err := c.PanicShow(context.Background())
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(panicMsg).To(gomega.BeEmpty())
+
+	version, revision, err := c.Version(context.Background())
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(version).To(gomega.Equal("6.5.1"))
+	g.Expect(revision).To(gomega.Equal("1dae23376bb5ea7a6b8e9e4b9ed95cdc9469fb64"))
+
+	err = c.Discard(context.Background(), "v-0")
+	g.Expect(isCLIStatus(err, CLIStatusUnknown)).To(gomega.BeTrue())
+	g.Expect(IsVCLCompilationError(err, nil)).To(gomega.BeFalse())
+
+	// all commands are sent over the same connection
+	g.Expect(f.connections()).To(gomega.Equal(1))
+}
+
+func TestCLIClientReconnect(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	f := newFakeVarnishd(t, "", map[string]fakeResponse{
+		"ping":        {status: CLIStatusOK, body: "PONG"},
+		"vcl.use v-1": {hangUp: true},
+	})
+	c := f.client(t, "")
+
+	g.Expect(c.Ping(context.Background())).To(gomega.Succeed())
+	// the connection is closed by varnish, so the command is retried on a new one
+	_, err := c.Run(context.Background(), "vcl.use", "v-1")
+	g.Expect(isCLIStatus(err, CLIStatusUnknown)).To(gomega.BeTrue())
+	g.Expect(f.connections()).To(gomega.Equal(2))
+}
+
+func TestCLIClientAuthenticationFailure(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	g.Expect(os.WriteFile(secretFile, []byte("wrong\n"), 0600)).To(gomega.Succeed())
+
+	f := newFakeVarnishd(t, "s3cr3t\n", map[string]fakeResponse{"ping": {status: CLIStatusOK}})
+	_, err := f.client(t, secretFile).Run(context.Background(), "ping")
+	g.Expect(isCLIStatus(err, CLIStatusClose)).To(gomega.BeTrue())
+}
+
+func TestCLIClientContext(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	f := newFakeVarnishd(t, "", map[string]fakeResponse{"ping": {status: CLIStatusOK, delay: time.Second}})
+	c := f.client(t, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Run(ctx, "ping")
+	g.Expect(err).To(gomega.HaveOccurred())
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 500*time.Millisecond))
+}
+
+func TestCLIClientContextWaitingForConnection(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	f := newFakeVarnishd(t, "", map[string]fakeResponse{
+		"vcl.load v-1 /etc/varnish/entrypoint.vcl": {status: CLIStatusOK, delay: time.Second},
+		"ping": {status: CLIStatusOK},
+	})
+	c := f.client(t, "")
+
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := c.Load(context.Background(), "v-1", "entrypoint.vcl")
+		loaded <- err
+	}()
+	g.Eventually(func() []string {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return f.commands
+	}).Should(gomega.HaveLen(1))
+
+	// the connection is busy with the slow command, so the ping gives up waiting for it when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := c.Run(ctx, "ping")
+	g.Expect(err).To(gomega.MatchError(context.DeadlineExceeded))
+	g.Expect(time.Since(start)).To(gomega.BeNumerically("<", 500*time.Millisecond))
+	g.Expect(<-loaded).To(gomega.Succeed())
+}
+
+func TestNewCLIClient(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	c, err := NewCLIClient(time.Second, time.Second, "/etc/varnish", strings.Split("-S /etc/varnish-secret/secret  -T 127.0.0.1:6082", " "))
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(c.address).To(gomega.Equal("127.0.0.1:6082"))
+	g.Expect(c.secretFile).To(gomega.Equal("/etc/varnish-secret/secret"))
+
+	_, err = NewCLIClient(time.Second, time.Second, "/etc/varnish", []string{"-S", "/etc/varnish-secret/secret"})
+	g.Expect(err).To(gomega.HaveOccurred())
+	_, err = NewCLIClient(time.Second, time.Second, "/etc/varnish", []string{"-n", "/var/lib/varnish", "-T", "127.0.0.1:6082"})
+	g.Expect(err).To(gomega.HaveOccurred())
+
+	g.Expect(cliQuote("v-1")).To(gomega.Equal("v-1"))
+	g.Expect(cliQuote("")).To(gomega.Equal(`""`))
+	g.Expect(cliQuote(`a "b"` + "\n")).To(gomega.Equal(`"a \"b\"\n"`))
+}
@@ -0,0 +1,104 @@
+package varnishadm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Parameter represents a varnishd parameter as reported by param.show -j
+type Parameter struct {
+	Name    string
+	Value   string
+	Default string
+	Units   string
+	Flags   []string
+}
+
+// ParamShow returns the parameters of the varnish instance.
+// it is a wrapper over varnishadm param.show command
+func (v *VarnishAdm) ParamShow(ctx context.Context) ([]Parameter, error) {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "param.show", "-j"))
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	return parseParamShow(out)
+}
+
+// ParamSet changes the value of a parameter at runtime.
+// it is a wrapper over varnishadm param.set command
+func (v *VarnishAdm) ParamSet(ctx context.Context, name, value string) error {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "param.set", name, value))
+	if err != nil {
+		return errors.Wrap(err, string(out))
+	}
+
+	return nil
+}
+
+// ParamShow returns the parameters of the varnish instance
+func (c *CLIClient) ParamShow(ctx context.Context) ([]Parameter, error) {
+	out, err := c.Run(ctx, "param.show", "-j")
+	if err != nil {
+		return nil, err
+	}
+	return parseParamShow([]byte(out))
+}
+
+// ParamSet changes the value of a parameter at runtime
+func (c *CLIClient) ParamSet(ctx context.Context, name, value string) error {
+	_, err := c.Run(ctx, "param.set", name, value)
+	return err
+}
+
+type paramShowEntry struct {
+	Name        string          `json:"name"`
+	Implemented *bool           `json:"implemented"`
+	Value       json.RawMessage `json:"value"`
+	Default     string          `json:"default"`
+	Units       string          `json:"units"`
+	Flags       []string        `json:"flags"`
+}
+
+// parseParamShow parses the output of param.show -j. It's an array of the JSON format version, the command,
+// the response time and the parameters. Values are JSON numbers, booleans or strings depending on the parameter type.
+func parseParamShow(out []byte) ([]Parameter, error) {
+	var response []json.RawMessage
+	if err := json.Unmarshal(out, &response); err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+	if len(response) < 3 {
+		return nil, errors.Errorf("unknown param.show format: %s", out)
+	}
+
+	params := make([]Parameter, 0, len(response)-3)
+	for _, raw := range response[3:] {
+		entry := paramShowEntry{}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, errors.Wrap(err, "unknown parameter format")
+		}
+		if entry.Implemented != nil && !*entry.Implemented {
+			continue
+		}
+
+		var value string
+		if err := json.Unmarshal(entry.Value, &value); err != nil {
+			// numbers and booleans are kept as they are printed by varnish
+			value = string(bytes.TrimSpace(entry.Value))
+		}
+
+		params = append(params, Parameter{
+			Name:    entry.Name,
+			Value:   strings.TrimSpace(value),
+			Default: entry.Default,
+			Units:   entry.Units,
+			Flags:   entry.Flags,
+		})
+	}
+
+	return params, nil
+}
@@ -0,0 +1,63 @@
+package varnishadm
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+)
+
+func TestParseParamShow(t *testing.T) {
+	g := gomega.NewGomegaWithT(t)
+	out := []byte(`[ 2, ["param.show", "-j"], 1651406400.000,
+  {
+    "name": "accept_filter",
+    "implemented": false
+  },
+  {
+    "name": "default_ttl",
+    "implemented": true,
+    "value": 120.000,
+    "units": "seconds",
+    "default": "120.000",
+    "minimum": "0.000",
+    "description": "The TTL assigned to objects if neither the backend nor the VCL code assigns one."
+  },
+  {
+    "name": "http_gzip_support",
+    "implemented": true,
+    "value": true,
+    "default": "on",
+    "description": "Enable gzip support."
+  },
+  {
+    "name": "http_max_hdr",
+    "implemented": true,
+    "value": 64,
+    "units": "header lines",
+    "default": "64",
+    "description": "Maximum number of HTTP header lines we allow in {req|resp|bereq|beresp}.http",
+    "flags": [
+      "must_restart"
+    ]
+  },
+  {
+    "name": "pool_req",
+    "implemented": true,
+    "value": "10,100,10",
+    "default": "10,100,10",
+    "description": "Parameters for per worker pool request memory pool."
+  }
+]`)
+
+	params, err := parseParamShow(out)
+	g.Expect(err).ToNot(gomega.HaveOccurred())
+	g.Expect(params).To(gomega.Equal([]Parameter{
+		{Name: "default_ttl", Value: "120.000", Default: "120.000", Units: "seconds"},
+		{Name: "http_gzip_support", Value: "true", Default: "on"},
+		{Name: "http_max_hdr", Value: "64", Default: "64", Units: "header lines", Flags: []string{"must_restart"}},
+		{Name: "pool_req", Value: "10,100,10", Default: "10,100,10"},
+	}))
+
+	_, err = parseParamShow([]byte("Unknown request."))
+	g.Expect(err).To(gomega.HaveOccurred())
+}
@@ -1,6 +1,7 @@
 package varnishadm
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -69,7 +70,7 @@ func TestPingCommand(t *testing.T) {
 				pingDelay:   tc.delay,
 				execute:     tc.execute,
 			}
-			err := p.Ping()
+			err := p.Ping(context.Background())
 			if !cmp.Equal(err, tc.errExpected, equalError) {
 				tt.Errorf("Unexpected response for. %s", cmp.Diff(err, tc.errExpected))
 			}
@@ -109,7 +110,7 @@ func TestReloadCommand(t *testing.T) {
 			p := &VarnishAdm{
 				execute: tc.execute,
 			}
-			data, err := p.Reload("ver", "entry")
+			data, err := p.Reload(context.Background(), "ver", "entry")
 			if !cmp.Equal(data, tc.response) {
 				tt.Errorf("Unexpected response %q\n Expected: %q", data, tc.response)
 			}
@@ -121,7 +122,7 @@ func TestReloadCommand(t *testing.T) {
 }
 
 func TestEnsureNotNilDefaultExecCommandProvider(t *testing.T) {
-	c := execCommandProvider("echo", "hello", "world")
+	c := execCommandProvider(context.Background(), "echo", "hello", "world")
 	if c == nil || (reflect.ValueOf(c).Kind() == reflect.Ptr && reflect.ValueOf(c).IsNil()) {
 		t.Error("Unexpected nil for default execution command")
 	}
@@ -147,35 +148,35 @@ func (m *mockExecutor) CombinedOutput() ([]byte, error) {
 	return m.response, m.err
 }
 
-func mockSuccessPing(name string, args ...string) executor {
+func mockSuccessPing(_ context.Context, name string, args ...string) executor {
 	return &mockExecutor{}
 }
 
-func mockUnreachabePing(name string, args ...string) executor {
+func mockUnreachabePing(_ context.Context, name string, args ...string) executor {
 	return &mockExecutor{err: errors.New("something goes wrong"), intermediateErr: errors.New("intermediate err")}
 }
 
-func mockReachable5thTryPing(name string, args ...string) executor {
+func mockReachable5thTryPing(_ context.Context, name string, args ...string) executor {
 	return &staticPingMock
 }
 
-func mockLoadErrResponse(name string, args ...string) executor {
+func mockLoadErrResponse(_ context.Context, name string, args ...string) executor {
 	return &staticLoadErrMock
 }
 
-func mockUseErrResponse(name string, args ...string) executor {
+func mockUseErrResponse(_ context.Context, name string, args ...string) executor {
 	return &staticUseErrMock
 }
 
-func mockSuccesResponse(name string, args ...string) executor {
+func mockSuccesResponse(_ context.Context, name string, args ...string) executor {
 	return &mockExecutor{response: response}
 }
 
-func mockSuccesListResponse(name string, args ...string) executor {
+func mockSuccesListResponse(_ context.Context, name string, args ...string) executor {
 	return &mockExecutor{response: []byte(simpleVCLconfig)}
 }
 
-func mockErrResponse(name string, args ...string) executor {
+func mockErrResponse(_ context.Context, name string, args ...string) executor {
 	return &mockExecutor{response: response, err: errors.New("some error")}
 }
 
@@ -233,7 +234,7 @@ func TestGetActiveConfigurationName(t *testing.T) {
 	}{
 		{
 			nil,
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
@@ -244,7 +245,7 @@ func TestGetActiveConfigurationName(t *testing.T) {
 		},
 		{
 			nil,
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
@@ -255,7 +256,7 @@ func TestGetActiveConfigurationName(t *testing.T) {
 		},
 		{
 			errors.Errorf("No active VCL configuration found"),
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
@@ -266,7 +267,7 @@ func TestGetActiveConfigurationName(t *testing.T) {
 		},
 		{
 			errors.WithStack(errors.New("unknown VCL config format")),
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
@@ -277,11 +278,11 @@ func TestGetActiveConfigurationName(t *testing.T) {
 		},
 		{
 			errors.Wrap(errors.New("some error"), string(response)),
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
-				return mockErrResponse(name, args...)
+				return mockErrResponse(ctx, name, args...)
 			},
 			"",
 			"externalError",
@@ -292,7 +293,7 @@ func TestGetActiveConfigurationName(t *testing.T) {
 			p := &VarnishAdm{
 				execute: tc.execute,
 			}
-			name, err := p.GetActiveConfigurationName()
+			name, err := p.GetActiveConfigurationName(context.Background())
 			if !errorEqual(err, tc.errExpected) {
 				//cmp.Diff(err, tc.errExpected, equalError)
 				tt.Logf("Unexpected error values: %#v. Expected: %#v \n %s\n", err, tc.errExpected, cmp.Diff(err, tc.errExpected, equalError))
@@ -3,6 +3,7 @@ package varnishadm
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"strings"
 	"time"
@@ -23,11 +24,11 @@ type VCLConfig struct {
 
 // List returns a list of VCL names which had been loaded into the varnish instance.
 // it is a wrapper over varnishadm vcl.list command
-func (v *VarnishAdm) List() ([]VCLConfig, error) {
-	out, err := v.run(append(v.varnishAdmArgs, "vcl.list", "-j"))
+func (v *VarnishAdm) List(ctx context.Context) ([]VCLConfig, error) {
+	out, err := v.run(ctx, append(v.varnishAdmArgs, "vcl.list", "-j"))
 	if err != nil {
 		if strings.Contains(string(out), "JSON unimplemented") {
-			out, err = v.run(append(v.varnishAdmArgs, "vcl.list"))
+			out, err = v.run(ctx, append(v.varnishAdmArgs, "vcl.list"))
 			if err != nil {
 				return []VCLConfig{}, errors.Wrap(err, string(out))
 			}
@@ -60,7 +61,7 @@ func parseVCLConfigsList(commandOutput []byte) ([]VCLConfig, error) {
 			continue
 		case 4: //config without a label
 			temp := strings.Split(columns[1], "/")
-			configs = append(configs, VCLConfig{Status: columns[0], Name: columns[3], Label: false, Temperature: temp[1]})
+			configs = append(configs, VCLConfig{Status: columns[0], Name: columns[3], Label: false, State: temp[0], Temperature: temp[1]})
 		case 6: //labeled config or a label itself
 			var refVCL *string
 			temp := strings.Split(columns[1], "/")
@@ -68,7 +69,7 @@ func parseVCLConfigsList(commandOutput []byte) ([]VCLConfig, error) {
 			if isLabel {
 				refVCL = &columns[5]
 			}
-			config := VCLConfig{Status: columns[0], Name: columns[3], Label: isLabel, Temperature: temp[1], ReferencedVCL: refVCL}
+			config := VCLConfig{Status: columns[0], Name: columns[3], Label: isLabel, State: temp[0], Temperature: temp[1], ReferencedVCL: refVCL}
 			configs = append(configs, config)
 		default:
 			return nil, errors.New("unknown VCL config format")
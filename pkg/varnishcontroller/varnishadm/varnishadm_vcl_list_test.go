@@ -1,6 +1,7 @@
 package varnishadm
 
 import (
+	"context"
 	"testing"
 
 	"github.com/gogo/protobuf/proto"
@@ -17,21 +18,23 @@ func TestListCommand(t *testing.T) {
 	}{
 		{
 			nil,
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
-				return mockSuccesListResponse(name, args...)
+				return mockSuccesListResponse(ctx, name, args...)
 			},
 			[]VCLConfig{
 				{
 					Status:      VCLStatusAvailable,
 					Name:        "boot",
+					State:       VCLStateCold,
 					Temperature: VCLTemperatureCold,
 				},
 				{
 					Status:      VCLStatusActive,
 					Name:        "v55329",
+					State:       VCLStateAuto,
 					Temperature: VCLTemperatureWarm,
 				},
 			},
@@ -39,18 +42,18 @@ func TestListCommand(t *testing.T) {
 		},
 		{
 			errors.Wrap(errors.New("some error"), "A response from external program"),
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				if args[len(args)-1] == "-j" {
 					return &mockExecutor{err: errors.New("err"), response: []byte("Command failed with error code 102\nJSON unimplemented")}
 				}
-				return mockErrResponse(name, args...)
+				return mockErrResponse(ctx, name, args...)
 			},
 			[]VCLConfig{},
 			"error",
 		},
 		{
 			nil,
-			func(name string, args ...string) executor {
+			func(ctx context.Context, name string, args ...string) executor {
 				return &mockExecutor{response: []byte(`
 [ 2, ["vcl.list", "-j"], 1632389190.659,
   {
@@ -112,7 +115,7 @@ func TestListCommand(t *testing.T) {
 			p := &VarnishAdm{
 				execute: tc.execute,
 			}
-			data, err := p.List()
+			data, err := p.List(context.Background())
 			if !cmp.Equal(data, tc.response) {
 				tt.Errorf("Unexpected response %v\n Expected: %v", data, tc.response)
 			}
@@ -135,11 +138,13 @@ func TestParseConfigs(t *testing.T) {
 				{
 					Status:      VCLStatusAvailable,
 					Name:        "boot",
+					State:       VCLStateCold,
 					Temperature: VCLTemperatureCold,
 				},
 				{
 					Status:      VCLStatusActive,
 					Name:        "v55329",
+					State:       VCLStateAuto,
 					Temperature: VCLTemperatureWarm,
 				},
 			},
@@ -151,16 +156,19 @@ func TestParseConfigs(t *testing.T) {
 				{
 					Status:      VCLStatusAvailable,
 					Name:        "boot",
+					State:       VCLStateCold,
 					Temperature: VCLTemperatureCold,
 				},
 				{
 					Status:      VCLStatusActive,
 					Name:        "v55329",
+					State:       VCLStateAuto,
 					Temperature: VCLTemperatureWarm,
 				},
 				{
 					Status:        VCLStatusAvailable,
 					Name:          "label1",
+					State:         "label",
 					Temperature:   VCLTemperatureWarm,
 					Label:         true,
 					ReferencedVCL: proto.String("v55329"),
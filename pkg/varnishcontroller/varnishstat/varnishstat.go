@@ -0,0 +1,171 @@
+package varnishstat
+
+import (
+	"encoding/json"
+	"os/exec"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	//VarnishStatBinary binary to execute to read the varnish counters
+	VarnishStatBinary = "varnishstat"
+
+	//CounterClientRequests - number of good client requests received
+	CounterClientRequests = "MAIN.client_req"
+	//CounterFetchFailed - number of backend fetches that failed
+	CounterFetchFailed = "MAIN.fetch_failed"
+)
+
+const (
+	// FlagCounter marks the counters that only increase
+	FlagCounter = "c"
+	// FlagGauge marks the counters that can go up and down
+	FlagGauge = "g"
+	// FlagBitmap marks the counters that are bitmaps, e.g. the health of a backend
+	FlagBitmap = "b"
+)
+
+// Reader defines the interface to read varnish counters.
+// - Counters() returns the current values of the requested counters
+// - Stats() returns all varnish counters with their descriptions
+type Reader interface {
+	Counters(names ...string) (map[string]uint64, error)
+	Stats() ([]Counter, error)
+}
+
+// Counter represents a varnish counter as reported by varnishstat -j
+type Counter struct {
+	// Name is the full counter name, e.g. MAIN.client_req or VBE.boot.default.req
+	Name        string
+	Description string
+	// Flag is c for counters, g for gauges and b for bitmaps
+	Flag  string
+	Value uint64
+}
+
+// NewVarnishStat returns a wrapper over varnishstat utility. Accepts varnishstat CLI parameters
+// (e.g. -n to set the varnish working directory)
+func NewVarnishStat(args []string) *VarnishStat {
+	return &VarnishStat{
+		binary:  VarnishStatBinary,
+		args:    args,
+		execute: execCommandProvider,
+	}
+}
+
+// VarnishStat is a structure which implements Reader interface using the varnishstat binary
+type VarnishStat struct {
+	binary  string
+	args    []string
+	execute executorProvider
+}
+
+// executor an interface compatible with *exec.Cmd method Output()
+// added for testability
+type executor interface {
+	Output() ([]byte, error)
+}
+
+type executorProvider func(name string, arg ...string) executor
+
+type counter struct {
+	Description string `json:"description"`
+	Flag        string `json:"flag"`
+	Value       uint64 `json:"value"`
+}
+
+// Counters returns the current values of the counters with the given names.
+// Counters unknown to varnish are omitted from the result.
+func (v *VarnishStat) Counters(names ...string) (map[string]uint64, error) {
+	args := append([]string{}, v.args...)
+	args = append(args, "-j")
+	for _, name := range names {
+		args = append(args, "-f", name)
+	}
+
+	out, err := v.execute(v.binary, args...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	return parseCounters(out, names)
+}
+
+// Stats returns all varnish counters sorted by name
+func (v *VarnishStat) Stats() ([]Counter, error) {
+	args := append([]string{}, v.args...)
+	args = append(args, "-j")
+
+	out, err := v.execute(v.binary, args...).Output()
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	return parseStats(out)
+}
+
+// parseCounters supports both the varnish 6.5+ output format, where counters are nested under "counters" key,
+// and the older format, where counters are on the top level next to the "timestamp" key
+func parseCounters(out []byte, names []string) (map[string]uint64, error) {
+	raw, err := rawCounters(out)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]uint64, len(names))
+	for _, name := range names {
+		data, found := raw[name]
+		if !found {
+			continue
+		}
+		c := counter{}
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, errors.Wrapf(err, "can't parse counter %s", name)
+		}
+		counters[name] = c.Value
+	}
+
+	return counters, nil
+}
+
+func parseStats(out []byte) ([]Counter, error) {
+	raw, err := rawCounters(out)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]Counter, 0, len(raw))
+	for name, data := range raw {
+		c := counter{}
+		if err := json.Unmarshal(data, &c); err != nil {
+			// the legacy format has the timestamp next to the counters
+			continue
+		}
+		stats = append(stats, Counter{Name: name, Description: c.Description, Flag: c.Flag, Value: c.Value})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+
+	return stats, nil
+}
+
+func rawCounters(out []byte) (map[string]json.RawMessage, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return nil, errors.Wrap(err, "can't parse varnishstat output")
+	}
+
+	if nested, ok := raw["counters"]; ok {
+		raw = map[string]json.RawMessage{}
+		if err := json.Unmarshal(nested, &raw); err != nil {
+			return nil, errors.Wrap(err, "can't parse varnishstat output")
+		}
+	}
+
+	return raw, nil
+}
+
+func execCommandProvider(name string, args ...string) executor {
+	return exec.Command(name, args...)
+}
@@ -0,0 +1,156 @@
+package varnishstat
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pkg/errors"
+)
+
+const (
+	counters65 = `{
+  "version": 1,
+  "timestamp": "2023-01-20T11:01:24",
+  "counters": {
+    "MAIN.client_req": {
+      "description": "Good client requests received",
+      "flag": "c",
+      "format": "i",
+      "value": 1200
+    },
+    "MAIN.fetch_failed": {
+      "description": "Fetch failed (all causes)",
+      "flag": "c",
+      "format": "i",
+      "value": 12
+    }
+  }
+}`
+	countersLegacy = `{
+  "timestamp": "2019-06-24T12:59:56",
+  "MAIN.client_req": {
+    "description": "Good client requests received",
+    "flag": "c",
+    "format": "i",
+    "value": 1200
+  }
+}`
+)
+
+type mockExecutor struct {
+	response []byte
+	err      error
+}
+
+func (m *mockExecutor) Output() ([]byte, error) {
+	return m.response, m.err
+}
+
+func TestCounters(t *testing.T) {
+	cases := []struct {
+		desc        string
+		response    string
+		err         error
+		expected    map[string]uint64
+		expectedErr bool
+	}{
+		{
+			desc:     "varnish 6.5+ format",
+			response: counters65,
+			expected: map[string]uint64{CounterClientRequests: 1200, CounterFetchFailed: 12},
+		},
+		{
+			desc:     "legacy format with a missing counter",
+			response: countersLegacy,
+			expected: map[string]uint64{CounterClientRequests: 1200},
+		},
+		{
+			desc:        "varnishstat failed",
+			err:         errors.New("exit status 1"),
+			expectedErr: true,
+		},
+		{
+			desc:        "invalid output",
+			response:    "Could not get hold of varnishd",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(tt *testing.T) {
+			var calledWith []string
+			v := &VarnishStat{
+				binary: VarnishStatBinary,
+				execute: func(name string, args ...string) executor {
+					calledWith = append([]string{name}, args...)
+					return &mockExecutor{response: []byte(tc.response), err: tc.err}
+				},
+			}
+			counters, err := v.Counters(CounterClientRequests, CounterFetchFailed)
+			if tc.expectedErr != (err != nil) {
+				tt.Fatalf("Unexpected error: %v", err)
+			}
+			if !tc.expectedErr && !cmp.Equal(counters, tc.expected) {
+				tt.Errorf("Unexpected counters. %s", cmp.Diff(tc.expected, counters))
+			}
+			expectedArgs := []string{VarnishStatBinary, "-j", "-f", CounterClientRequests, "-f", CounterFetchFailed}
+			if !cmp.Equal(calledWith, expectedArgs) {
+				tt.Errorf("Unexpected arguments. %s", cmp.Diff(expectedArgs, calledWith))
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	cases := []struct {
+		desc        string
+		response    string
+		expected    []Counter
+		expectedErr bool
+	}{
+		{
+			desc:     "varnish 6.5+ format",
+			response: counters65,
+			expected: []Counter{
+				{Name: CounterClientRequests, Description: "Good client requests received", Flag: FlagCounter, Value: 1200},
+				{Name: CounterFetchFailed, Description: "Fetch failed (all causes)", Flag: FlagCounter, Value: 12},
+			},
+		},
+		{
+			desc:     "legacy format",
+			response: countersLegacy,
+			expected: []Counter{
+				{Name: CounterClientRequests, Description: "Good client requests received", Flag: FlagCounter, Value: 1200},
+			},
+		},
+		{
+			desc:        "invalid output",
+			response:    "Could not get hold of varnishd",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.desc, func(tt *testing.T) {
+			var calledWith []string
+			v := &VarnishStat{
+				binary: VarnishStatBinary,
+				execute: func(name string, args ...string) executor {
+					calledWith = append([]string{name}, args...)
+					return &mockExecutor{response: []byte(tc.response)}
+				},
+			}
+			stats, err := v.Stats()
+			if tc.expectedErr != (err != nil) {
+				tt.Fatalf("Unexpected error: %v", err)
+			}
+			if !tc.expectedErr && !cmp.Equal(stats, tc.expected) {
+				tt.Errorf("Unexpected counters. %s", cmp.Diff(tc.expected, stats))
+			}
+			expectedArgs := []string{VarnishStatBinary, "-j"}
+			if !cmp.Equal(calledWith, expectedArgs) {
+				tt.Errorf("Unexpected arguments. %s", cmp.Diff(expectedArgs, calledWith))
+			}
+		})
+	}
+}